@@ -0,0 +1,115 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+)
+
+// Actor identifies the user or service on whose behalf a transaction runs.
+type Actor struct {
+	// Kind tells what the ID refers to, e.g. "user" or "service".
+	Kind string
+	ID   string
+}
+
+// String formats the actor as "kind:id", or just the ID if Kind is empty.
+func (a Actor) String() string {
+	if a.Kind == "" {
+		return a.ID
+	}
+	return a.Kind + ":" + a.ID
+}
+
+type actorCtxKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the actor, which is
+// read by the default extractor of WithActor.
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext reads the actor attached to ctx with ContextWithActor.
+func ActorFromContext(ctx context.Context) (actor Actor, ok bool) {
+	actor, ok = ctx.Value(actorCtxKey{}).(Actor)
+	return actor, ok
+}
+
+// ActorOptions configures how WithActor identifies and attributes
+// the actor of a transaction.
+type ActorOptions struct {
+	// Extract reads the actor from the context the transaction is
+	// started with, e.g. from the claims of an authentication middleware.
+	//
+	// Defaults to ActorFromContext.
+	Extract func(ctx context.Context) (Actor, bool)
+
+	// ApplicationName sets the application_name of the transaction to the
+	// actor, so it shows up on pg_stat_activity and on the logs of Postgres.
+	ApplicationName bool
+
+	// Setting is the name of a custom setting, such as "app.actor", set to the
+	// actor for the transaction, so triggers can read it with current_setting.
+	Setting string
+}
+
+// WithActor extracts the actor of the transaction from its context when it
+// begins, making it available on tx.Actor() for hooks writing audit records
+// or logs, and on the timeline printed by WithDebug.
+//
+// The Postgres settings of ActorOptions are set with set_config, which
+// only lasts until the end of the transaction, and require the transaction
+// to be started WithDialect(ktx.Postgres). They are skipped when no actor
+// is found.
+func WithActor(opts ActorOptions) Option {
+	if opts.Extract == nil {
+		opts.Extract = ActorFromContext
+	}
+
+	return func(c *config) {
+		c.actor = &opts
+	}
+}
+
+// Actor returns the actor of the transaction when
+// it was started WithActor and one was found.
+func (tx *Tx) Actor() (actor Actor, ok bool) {
+	if tx.actor == nil {
+		return Actor{}, false
+	}
+	return *tx.actor, true
+}
+
+// setActor extracts the actor of the transaction
+// and attributes the transaction to it on the database.
+func (tx *Tx) setActor(ctx context.Context) error {
+	opts := tx.cfg.actor
+	if opts == nil {
+		return nil
+	}
+
+	actor, ok := opts.Extract(ctx)
+	if !ok {
+		return nil
+	}
+	tx.actor = &actor
+
+	if tx.cfg.dialect == nil || tx.cfg.dialect.Name() != Postgres.Name() {
+		return nil
+	}
+
+	var settings []string
+	if opts.ApplicationName {
+		settings = append(settings, "application_name")
+	}
+	if opts.Setting != "" {
+		settings = append(settings, opts.Setting)
+	}
+	for _, setting := range settings {
+		_, err := tx.sqlTx.ExecContext(ctx, "SELECT set_config($1, $2, true)", setting, actor.String())
+		if err != nil {
+			return fmt.Errorf("error setting %s to the actor of the transaction: %w", setting, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithActor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should make the actor of the context available to hooks", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var audited Actor
+		err := Run(ContextWithActor(ctx, Actor{Kind: "user", ID: "42"}), db, func(tx *Tx) error {
+			return nil
+		}, WithActor(ActorOptions{}), WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				audited, _ = tx.Actor()
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if audited.String() != "user:42" {
+			t.Errorf("expected actor user:42, got: %v", audited)
+		}
+	})
+
+	t.Run("should use the custom extractor", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var debug bytes.Buffer
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithDebug(&debug), WithActor(ActorOptions{
+			Extract: func(ctx context.Context) (Actor, bool) {
+				return Actor{Kind: "service", ID: "billing"}, true
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if !strings.Contains(debug.String(), "BEGIN as service:billing") {
+			t.Errorf("expected the actor on the debug output, got:\n%s", debug.String())
+		}
+	})
+
+	t.Run("should not require an actor", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			if _, ok := tx.Actor(); ok {
+				t.Error("expected no actor")
+			}
+			return nil
+		}, WithActor(ActorOptions{ApplicationName: true}), WithDialect(Postgres))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("should set the actor on the postgres settings", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ContextWithActor(ctx, Actor{Kind: "user", ID: "42"}), db, func(tx *Tx) error {
+			return nil
+		}, WithDialect(Postgres), WithActor(ActorOptions{
+			ApplicationName: true,
+			Setting:         "app.actor",
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{
+			"SELECT set_config($1, $2, true) [application_name user:42]",
+			"SELECT set_config($1, $2, true) [app.actor user:42]",
+		}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+}
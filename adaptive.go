@@ -0,0 +1,121 @@
+package ktx
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveBackoffOptions configures an AdaptiveBackoff.
+type AdaptiveBackoffOptions struct {
+	// Increase multiplies the factor of a transaction name each
+	// time one of its transactions is retried, defaults to 2.
+	Increase float64
+
+	// Decrease is subtracted from the factor of a transaction name
+	// each time one of its transactions succeeds, defaults to 0.25.
+	Decrease float64
+
+	// MaxFactor caps how many times wider the
+	// backoff can get, defaults to 32.
+	MaxFactor float64
+}
+
+// AdaptiveBackoff widens the backoff of WithRetry for the transaction names
+// that are being retried often, which spreads the retries of a contention
+// storm instead of making all of them conflict again at the same time:
+//
+//	adaptive := ktx.NewAdaptiveBackoff(ktx.AdaptiveBackoffOptions{})
+//
+//	err := ktx.Run(ctx, db, reserveSeat, ktx.WithName("reserve-seat"), ktx.WithIdempotent(),
+//		ktx.WithRetry(ktx.RetryPolicy{MaxAttempts: 5, Adaptive: adaptive}),
+//	)
+//
+// Each name has a factor, starting at 1, that multiplies the delays returned
+// by the Backoff of the RetryPolicy. The factor grows multiplicatively with
+// each retry and shrinks additively with each transaction that succeeds
+// (AIMD), so it widens quickly under contention and recovers gradually.
+//
+// A single AdaptiveBackoff should be shared by all the transactions it
+// adapts. Transactions without a name are never adapted.
+type AdaptiveBackoff struct {
+	opts AdaptiveBackoffOptions
+
+	mu      sync.Mutex
+	factors map[string]float64
+}
+
+// NewAdaptiveBackoff creates an AdaptiveBackoff.
+func NewAdaptiveBackoff(opts AdaptiveBackoffOptions) *AdaptiveBackoff {
+	if opts.Increase <= 1 {
+		opts.Increase = 2
+	}
+	if opts.Decrease <= 0 {
+		opts.Decrease = 0.25
+	}
+	if opts.MaxFactor < 1 {
+		opts.MaxFactor = 32
+	}
+
+	return &AdaptiveBackoff{
+		opts:    opts,
+		factors: map[string]float64{},
+	}
+}
+
+// Factor returns how many times wider the backoff of the
+// transactions with the input name currently is.
+func (a *AdaptiveBackoff) Factor(name string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.factor(name)
+}
+
+// factor must be called with a.mu locked.
+func (a *AdaptiveBackoff) factor(name string) float64 {
+	if f, ok := a.factors[name]; ok {
+		return f
+	}
+	return 1
+}
+
+func (a *AdaptiveBackoff) widen(name string, backoff time.Duration) time.Duration {
+	if name == "" {
+		return backoff
+	}
+	return time.Duration(float64(backoff) * a.Factor(name))
+}
+
+func (a *AdaptiveBackoff) retried(name string) {
+	if name == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.factors[name] = min(a.factor(name)*a.opts.Increase, a.opts.MaxFactor)
+}
+
+func (a *AdaptiveBackoff) succeeded(name string) {
+	if name == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, ok := a.factors[name]
+	if !ok {
+		return
+	}
+
+	f -= a.opts.Decrease
+	if f <= 1 {
+		// Names back to normal are dropped so the map
+		// only holds the ones under contention:
+		delete(a.factors, name)
+		return
+	}
+	a.factors[name] = f
+}
@@ -0,0 +1,134 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveBackoff(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// failing returns a callback that fails with a
+	// serialization failure on the first n attempts:
+	failing := func(n int) func(tx *Tx) error {
+		attempts := 0
+		return func(tx *Tx) error {
+			attempts++
+			if attempts <= n {
+				return sqlStateError("40001")
+			}
+			return nil
+		}
+	}
+
+	noBackoff := func(attempt int) time.Duration {
+		return 0
+	}
+	fixedBackoff := func(attempt int) time.Duration {
+		return 10 * time.Millisecond
+	}
+
+	t.Run("should widen the backoff of the names being retried", func(t *testing.T) {
+		adaptive := NewAdaptiveBackoff(AdaptiveBackoffOptions{})
+		clock := &fakeClock{now: time.Now()}
+		opts := []Option{WithName("reserve-seat"), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     fixedBackoff,
+			Clock:       clock,
+			Adaptive:    adaptive,
+		})}
+
+		err := Run(ctx, db, failing(2), opts...)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if f := adaptive.Factor("reserve-seat"); f != 3.75 {
+			t.Errorf("expected factor 3.75, got: %v", f)
+		}
+
+		err = Run(ctx, db, failing(1), opts...)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			37500 * time.Microsecond,
+		}
+		if fmt.Sprint(clock.sleeps) != fmt.Sprint(expected) {
+			t.Errorf("expected sleeps %v, got %v", expected, clock.sleeps)
+		}
+	})
+
+	t.Run("should recover gradually with the successes", func(t *testing.T) {
+		adaptive := NewAdaptiveBackoff(AdaptiveBackoffOptions{Decrease: 1})
+		opts := []Option{WithName("reserve-seat"), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     noBackoff,
+			Adaptive:    adaptive,
+		})}
+
+		err := Run(ctx, db, failing(2), opts...)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if f := adaptive.Factor("reserve-seat"); f != 3 {
+			t.Fatalf("expected factor 3, got: %v", f)
+		}
+
+		for _, expected := range []float64{2, 1, 1} {
+			err = Run(ctx, db, failing(0), opts...)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if f := adaptive.Factor("reserve-seat"); f != expected {
+				t.Errorf("expected factor %v, got: %v", expected, f)
+			}
+		}
+	})
+
+	t.Run("should cap the factor", func(t *testing.T) {
+		adaptive := NewAdaptiveBackoff(AdaptiveBackoffOptions{MaxFactor: 5})
+		err := Run(ctx, db, failing(10), WithName("reserve-seat"), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     noBackoff,
+			Adaptive:    adaptive,
+		}))
+		if !errors.Is(err, sqlStateError("40001")) {
+			t.Fatalf("expected the last error, got: %v", err)
+		}
+		if f := adaptive.Factor("reserve-seat"); f != 5 {
+			t.Errorf("expected factor 5, got: %v", f)
+		}
+	})
+
+	t.Run("should not adapt the transactions without a name", func(t *testing.T) {
+		adaptive := NewAdaptiveBackoff(AdaptiveBackoffOptions{})
+		clock := &fakeClock{now: time.Now()}
+		err := Run(ctx, db, failing(3), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     fixedBackoff,
+			Clock:       clock,
+			Adaptive:    adaptive,
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}
+		if fmt.Sprint(clock.sleeps) != fmt.Sprint(expected) {
+			t.Errorf("expected sleeps %v, got %v", expected, clock.sleeps)
+		}
+		if f := adaptive.Factor(""); f != 1 {
+			t.Errorf("expected factor 1, got: %v", f)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxApplicationName is the length above which
+// Postgres truncates the application_name.
+const maxApplicationName = 63
+
+// ApplicationNameDialect is implemented by the dialects that support
+// setting the application name of the session for a single transaction.
+type ApplicationNameDialect interface {
+	Dialect
+
+	// ApplicationNameStmt returns the statement that sets the application
+	// name until the end of the current transaction, with a placeholder
+	// for the name.
+	ApplicationNameStmt() string
+}
+
+func (postgresDialect) ApplicationNameStmt() string {
+	return "SELECT set_config('application_name', $1, true)"
+}
+
+// WithApplicationName sets the application_name of the connection, for the
+// duration of the transaction, to the input prefix followed by the name set
+// WithName and the ID of the transaction, e.g. "orders-api/create-order/9f86d081884c7d65",
+// so DBAs can attribute the slow queries and locks seen in pg_stat_activity
+// back to the call sites:
+//
+//	err := ktx.Run(ctx, db, createOrder, ktx.WithDialect(ktx.Postgres),
+//		ktx.WithName("create-order"), ktx.WithApplicationName("orders-api"),
+//	)
+//
+// The name is truncated so the whole value fits the 63 characters kept by
+// Postgres, and the ID is the same written by the EventLog, if any.
+//
+// It requires the transaction to be started WithDialect with a dialect that
+// implements ApplicationNameDialect, such as Postgres or CockroachDB, and is
+// ignored otherwise. MySQL only sends the connection attributes when the
+// connection is opened, so they can't be set per transaction; use the
+// connectionAttributes parameter of the DSN for the name of the service instead.
+func WithApplicationName(prefix string) Option {
+	return func(c *config) {
+		c.applicationName = prefix
+	}
+}
+
+// ID returns the random identifier of the transaction used by the EventLog
+// and WithApplicationName, or an empty string if neither of them is used.
+// The attempts of a transaction retried WithRetry share the same ID.
+func (tx *Tx) ID() string {
+	return tx.cfg.id
+}
+
+// setApplicationName sets the application name of the transaction on the database.
+func (tx *Tx) setApplicationName(ctx context.Context) error {
+	if tx.cfg.applicationName == "" {
+		return nil
+	}
+
+	dialect, ok := tx.cfg.dialect.(ApplicationNameDialect)
+	if !ok {
+		return nil
+	}
+
+	if tx.cfg.id == "" {
+		tx.cfg.id = newEventID()
+	}
+
+	_, err := tx.sqlTx.ExecContext(ctx, dialect.ApplicationNameStmt(), applicationName(tx.cfg.applicationName, tx.cfg.name, tx.cfg.id))
+	if err != nil {
+		return fmt.Errorf("error setting the application name of the transaction: %w", err)
+	}
+	return nil
+}
+
+// applicationName joins the non-empty parts with slashes, truncating
+// the prefix and the name so the ID is never cut off.
+func applicationName(prefix string, name string, id string) string {
+	var parts []string
+	for _, part := range []string{prefix, name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	label := strings.Join(parts, "/")
+	if maxLabel := maxApplicationName - len(id) - 1; len(label) > maxLabel {
+		label = strings.ToValidUTF8(label[:maxLabel], "")
+	}
+	if label == "" {
+		return id
+	}
+	return label + "/" + id
+}
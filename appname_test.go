@@ -0,0 +1,123 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// appNameDialect records the application names
+// on a table, since SQLite doesn't support them.
+type appNameDialect struct {
+	Dialect
+}
+
+func (appNameDialect) ApplicationNameStmt() string {
+	return "INSERT INTO app_names (name) VALUES (?)"
+}
+
+func TestWithApplicationName(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (db *sql.DB, names func() []string) {
+		sqlDB := setupTestDB(t)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+
+		_, err := sqlDB.Exec("CREATE TABLE app_names (name TEXT NOT NULL)")
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		return sqlDB, func() (names []string) {
+			rows, err := sqlDB.Query("SELECT name FROM app_names")
+			if err != nil {
+				t.Fatalf("failed to query application names: %v", err)
+			}
+			defer func() { _ = rows.Close() }()
+
+			for rows.Next() {
+				var name string
+				_ = rows.Scan(&name)
+				names = append(names, name)
+			}
+			return names
+		}
+	}
+
+	t.Run("should set the prefix, the name and the ID of the transaction", func(t *testing.T) {
+		db, names := setup(t)
+
+		var id string
+		err := Run(ctx, db, func(tx *Tx) error {
+			id = tx.ID()
+			return nil
+		}, WithDialect(appNameDialect{SQLite}), WithName("create-order"), WithApplicationName("orders-api"))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(id) != 16 {
+			t.Fatalf("expected a 16 characters ID, got: %q", id)
+		}
+		expected := []string{"orders-api/create-order/" + id}
+		if strings.Join(names(), ",") != strings.Join(expected, ",") {
+			t.Errorf("expected application names %v, got %v", expected, names())
+		}
+	})
+
+	t.Run("should share the ID with the retries and the event log", func(t *testing.T) {
+		db, names := setup(t)
+
+		var buf bytes.Buffer
+		events := NewEventLog(&buf)
+
+		attempts := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			if attempts == 1 {
+				return sqlStateError("40001")
+			}
+			return nil
+		}, WithDialect(appNameDialect{SQLite}), WithApplicationName("orders-api"),
+			WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 2}), events.Option(),
+		)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// The name of the rolled back attempt is rolled back with it:
+		id := readEvents(t, &buf)[0].ID
+		expected := []string{"orders-api/" + id}
+		if strings.Join(names(), ",") != strings.Join(expected, ",") {
+			t.Errorf("expected application names %v, got %v", expected, names())
+		}
+	})
+
+	t.Run("should be ignored by the dialects that don't support it", func(t *testing.T) {
+		db, names := setup(t)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithDialect(SQLite), WithApplicationName("orders-api"))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(names()) != 0 {
+			t.Errorf("expected no application names, got %v", names())
+		}
+	})
+
+	t.Run("should truncate the name to keep the ID", func(t *testing.T) {
+		id := "9f86d081884c7d65"
+		name := applicationName("orders-api", strings.Repeat("x", 100), id)
+		if len(name) != maxApplicationName || !strings.HasSuffix(name, "/"+id) {
+			t.Errorf("expected a truncated name ending with the ID, got: %q", name)
+		}
+
+		if name := applicationName("", "", id); name != id {
+			t.Errorf("expected only the ID, got: %q", name)
+		}
+	})
+}
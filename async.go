@@ -0,0 +1,251 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrAsyncQueueFull is sent to the OnDeadLetter callback for the callbacks
+// dropped because the queue of the AsyncExecutor was full.
+var ErrAsyncQueueFull = errors.New("async executor queue is full")
+
+// ErrAsyncExecutorClosed is sent to the OnDeadLetter callback for the
+// callbacks of transactions committed after the AsyncExecutor was closed.
+var ErrAsyncExecutorClosed = errors.New("async executor is closed")
+
+// AsyncExecutorOptions configures an AsyncExecutor.
+type AsyncExecutorOptions struct {
+	// Workers is how many callbacks run concurrently, defaults to 4.
+	Workers int
+
+	// QueueSize is how many callbacks can wait for a worker, defaults to 100.
+	QueueSize int
+
+	// MaxAttempts is how many times a callback runs before being sent
+	// to OnDeadLetter, defaults to 3. It can be overridden for each
+	// callback with WithAsyncMaxAttempts.
+	MaxAttempts int
+
+	// Backoff returns how long to wait before the given attempt, starting
+	// at 2, and defaults to the exponential backoff of WithRetry. It can be
+	// overridden for each callback with WithAsyncBackoff.
+	Backoff func(attempt int) time.Duration
+
+	// OnDeadLetter is called with the callbacks that failed MaxAttempts
+	// times, panicked or couldn't be queued.
+	OnDeadLetter func(ctx context.Context, letter DeadLetter)
+}
+
+// DeadLetter is a callback of an AsyncExecutor that didn't succeed.
+type DeadLetter struct {
+	// Name is the name of the callback set with WithAsyncName, if any.
+	Name string
+
+	// Fn is the callback, so it can be replayed.
+	Fn func(ctx context.Context) error
+
+	// Err is the last error of the callback.
+	Err error
+
+	// Attempts is how many times the callback ran,
+	// which is zero for the callbacks that couldn't be queued.
+	Attempts int
+}
+
+// AsyncOption configures a callback registered with AsyncExecutor.AfterCommit.
+type AsyncOption func(*asyncJob)
+
+// WithAsyncName sets the name that identifies the callback on its DeadLetter.
+func WithAsyncName(name string) AsyncOption {
+	return func(j *asyncJob) {
+		j.name = name
+	}
+}
+
+// WithAsyncMaxAttempts overrides the MaxAttempts of the executor for the callback.
+func WithAsyncMaxAttempts(maxAttempts int) AsyncOption {
+	return func(j *asyncJob) {
+		if maxAttempts > 0 {
+			j.maxAttempts = maxAttempts
+		}
+	}
+}
+
+// WithAsyncBackoff overrides the Backoff of the executor for the callback.
+func WithAsyncBackoff(backoff func(attempt int) time.Duration) AsyncOption {
+	return func(j *asyncJob) {
+		if backoff != nil {
+			j.backoff = backoff
+		}
+	}
+}
+
+// AsyncExecutor runs AfterCommit callbacks on a bounded pool of workers, so
+// slow side effects such as emails and webhooks don't extend the latency of
+// the transactions, retrying them when they fail.
+//
+// The same executor should be shared by all the transactions
+// and closed with Close when the application shuts down.
+type AsyncExecutor struct {
+	opts AsyncExecutorOptions
+	jobs chan asyncJob
+	wg   sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type asyncJob struct {
+	ctx         context.Context
+	fn          func(ctx context.Context) error
+	name        string
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+}
+
+// NewAsyncExecutor creates an AsyncExecutor and starts its workers.
+func NewAsyncExecutor(opts AsyncExecutorOptions) *AsyncExecutor {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = defaultBackoff
+	}
+
+	e := &AsyncExecutor{
+		opts: opts,
+		jobs: make(chan asyncJob, opts.QueueSize),
+	}
+
+	e.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go e.work()
+	}
+
+	return e
+}
+
+// AfterCommit works like the AfterCommit function, but fn is queued to run
+// on a worker of the executor once the transaction behind db commits.
+//
+// The context received by fn keeps the values of the context of the
+// transaction but is not canceled when the transaction returns. If fn
+// panics the panic is recovered and fn is sent to OnDeadLetter without
+// being retried.
+func (e *AsyncExecutor) AfterCommit(db DBRunner, fn func(ctx context.Context) error, opts ...AsyncOption) error {
+	job := asyncJob{
+		fn:          fn,
+		maxAttempts: e.opts.MaxAttempts,
+		backoff:     e.opts.Backoff,
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+
+	return AfterCommit(db, func(ctx context.Context) {
+		job := job
+		job.ctx = context.WithoutCancel(ctx)
+		e.enqueue(job)
+	})
+}
+
+func (e *AsyncExecutor) enqueue(job asyncJob) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		e.deadLetter(job, ErrAsyncExecutorClosed, 0)
+		return
+	}
+
+	select {
+	case e.jobs <- job:
+	default:
+		e.deadLetter(job, ErrAsyncQueueFull, 0)
+	}
+}
+
+func (e *AsyncExecutor) work() {
+	defer e.wg.Done()
+
+	for job := range e.jobs {
+		e.run(job)
+	}
+}
+
+func (e *AsyncExecutor) run(job asyncJob) {
+	var err error
+	for attempt := 1; attempt <= job.maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(job.backoff(attempt))
+		}
+
+		var panicked bool
+		panicked, err = job.call()
+		if err == nil {
+			return
+		}
+		if panicked {
+			e.deadLetter(job, err, attempt)
+			return
+		}
+	}
+
+	e.deadLetter(job, err, job.maxAttempts)
+}
+
+// call runs the callback of the job, recovering its panics
+// so they don't crash the worker.
+func (job asyncJob) call() (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, err = true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return false, job.fn(job.ctx)
+}
+
+func (e *AsyncExecutor) deadLetter(job asyncJob, err error, attempts int) {
+	if e.opts.OnDeadLetter != nil {
+		e.opts.OnDeadLetter(job.ctx, DeadLetter{
+			Name:     job.name,
+			Fn:       job.fn,
+			Err:      err,
+			Attempts: attempts,
+		})
+	}
+}
+
+// Close stops accepting new callbacks and waits for the queued ones to
+// finish, returning the error of ctx if it is done before they finish.
+func (e *AsyncExecutor) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.jobs)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
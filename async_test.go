@@ -0,0 +1,254 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncExecutor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should run the callbacks after commit and retry failures", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		executor := NewAsyncExecutor(AsyncExecutorOptions{
+			Backoff: func(attempt int) time.Duration { return 0 },
+		})
+
+		var calls int32
+		err := Run(ctx, db, func(tx *Tx) error {
+			return executor.AfterCommit(tx, func(ctx context.Context) error {
+				if atomic.AddInt32(&calls, 1) < 3 {
+					return errors.New("transient error")
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		err = executor.Close(ctx)
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if calls != 3 {
+			t.Fatalf("expected the callback to succeed on the third attempt, got %d calls", calls)
+		}
+	})
+
+	t.Run("should not run callbacks on rollback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		executor := NewAsyncExecutor(AsyncExecutorOptions{})
+
+		var called int32
+		_ = Run(ctx, db, func(tx *Tx) error {
+			err := executor.AfterCommit(tx, func(ctx context.Context) error {
+				atomic.AddInt32(&called, 1)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return errors.New("test error")
+		})
+
+		_ = executor.Close(ctx)
+		if called != 0 {
+			t.Fatal("callback should not be called on rollback")
+		}
+	})
+
+	t.Run("should send exhausted callbacks to the dead letter", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errPermanent := errors.New("permanent error")
+		var mu sync.Mutex
+		var deadErrs []error
+		var deadAttempts []int
+		executor := NewAsyncExecutor(AsyncExecutorOptions{
+			MaxAttempts: 2,
+			Backoff:     func(attempt int) time.Duration { return 0 },
+			OnDeadLetter: func(ctx context.Context, letter DeadLetter) {
+				mu.Lock()
+				defer mu.Unlock()
+				deadErrs = append(deadErrs, letter.Err)
+				deadAttempts = append(deadAttempts, letter.Attempts)
+			},
+		})
+
+		ctx, cancel := context.WithCancel(ctx)
+		err := Run(ctx, db, func(tx *Tx) error {
+			return executor.AfterCommit(tx, func(ctx context.Context) error {
+				if ctx.Err() != nil {
+					t.Error("expected the context not to be canceled with the transaction")
+				}
+				return errPermanent
+			})
+		})
+		cancel()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		_ = executor.Close(context.Background())
+
+		// Callbacks of transactions committed after Close:
+		err = Run(context.Background(), db, func(tx *Tx) error {
+			return executor.AfterCommit(tx, func(ctx context.Context) error {
+				t.Error("callback should not run after Close")
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(deadErrs) != 2 || deadErrs[0] != errPermanent || deadErrs[1] != ErrAsyncExecutorClosed {
+			t.Fatalf("unexpected dead letter errors: %v", deadErrs)
+		}
+		if deadAttempts[0] != 2 || deadAttempts[1] != 0 {
+			t.Fatalf("unexpected dead letter attempts: %v", deadAttempts)
+		}
+	})
+
+	t.Run("should use the options of each callback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var letters []DeadLetter
+		executor := NewAsyncExecutor(AsyncExecutorOptions{
+			Workers:     1,
+			MaxAttempts: 5,
+			Backoff:     func(attempt int) time.Duration { return time.Hour },
+			OnDeadLetter: func(ctx context.Context, letter DeadLetter) {
+				letters = append(letters, letter)
+			},
+		})
+
+		var calls int32
+		webhook := func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("webhook failed")
+		}
+		err := Run(ctx, db, func(tx *Tx) error {
+			return executor.AfterCommit(tx, webhook,
+				WithAsyncName("webhook"),
+				WithAsyncMaxAttempts(2),
+				WithAsyncBackoff(func(attempt int) time.Duration { return 0 }),
+			)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		err = executor.Close(ctx)
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 attempts, got %d", calls)
+		}
+		if len(letters) != 1 || letters[0].Name != "webhook" || letters[0].Attempts != 2 || letters[0].Fn == nil {
+			t.Fatalf("unexpected dead letters: %+v", letters)
+		}
+
+		// The callback can be replayed from its dead letter:
+		_ = letters[0].Fn(ctx)
+		if calls != 3 {
+			t.Fatalf("expected the callback to be replayed, got %d calls", calls)
+		}
+	})
+
+	t.Run("should send the callbacks that panic to the dead letter", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var letters []DeadLetter
+		executor := NewAsyncExecutor(AsyncExecutorOptions{
+			Workers: 1,
+			OnDeadLetter: func(ctx context.Context, letter DeadLetter) {
+				letters = append(letters, letter)
+			},
+		})
+
+		var calls int32
+		for i := 0; i < 2; i++ {
+			err := Run(ctx, db, func(tx *Tx) error {
+				return executor.AfterCommit(tx, func(ctx context.Context) error {
+					atomic.AddInt32(&calls, 1)
+					panic("webhook panicked")
+				}, WithAsyncName("webhook"))
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		err := executor.Close(ctx)
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		// The worker survives the first panic and the panics are not retried:
+		if calls != 2 || len(letters) != 2 {
+			t.Fatalf("expected 2 calls and dead letters, got %d and %+v", calls, letters)
+		}
+		if letters[0].Attempts != 1 || !strings.Contains(letters[0].Err.Error(), "webhook panicked") {
+			t.Fatalf("unexpected dead letter: %+v", letters[0])
+		}
+	})
+
+	t.Run("should drop callbacks when the queue is full", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		release := make(chan struct{})
+		var dropped int32
+		executor := NewAsyncExecutor(AsyncExecutorOptions{
+			Workers:   1,
+			QueueSize: 1,
+			OnDeadLetter: func(ctx context.Context, letter DeadLetter) {
+				if letter.Err == ErrAsyncQueueFull {
+					atomic.AddInt32(&dropped, 1)
+				}
+			},
+		})
+
+		started := make(chan struct{}, 3)
+		for i := 0; i < 3; i++ {
+			err := Run(ctx, db, func(tx *Tx) error {
+				return executor.AfterCommit(tx, func(ctx context.Context) error {
+					started <- struct{}{}
+					<-release
+					return nil
+				})
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if i == 0 {
+				// Wait for the worker to be busy with the first callback:
+				<-started
+			}
+		}
+		close(release)
+
+		err := executor.Close(ctx)
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if dropped != 1 {
+			t.Fatalf("expected a single dropped callback, got %d", dropped)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+)
+
+// Attempt runs fn inside a savepoint of the transaction and, if fn returns
+// an error, rolls back only what fn did before returning its error, so the
+// transaction can go on and still be committed:
+//
+//	for _, user := range users {
+//		err := ktx.Attempt(ctx, tx, func(tx *ktx.Tx) error {
+//			return insertUser(ctx, tx, user)
+//		})
+//		if err != nil {
+//			failed = append(failed, user)
+//		}
+//	}
+//
+// The callbacks, deferred statements and captured changes registered
+// by fn are discarded together with its statements, except for the
+// AfterRollback callbacks, which are called with the error of fn.
+//
+// The standard savepoint syntax is used unless the
+// transaction is configured with WithDialect.
+func Attempt(ctx context.Context, db DBRunner, fn func(tx *Tx) error) (err error) {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	tx.attempts++
+	name := fmt.Sprintf("ktx_attempt_%d", tx.attempts)
+	snapshot := tx.snapshot()
+	tx.mu.Unlock()
+
+	dialect := tx.cfg.dialect
+	_, err = tx.ExecContext(ctx, savepointStmt(dialect, name))
+	if err != nil {
+		return fmt.Errorf("error creating attempt savepoint: %w", err)
+	}
+
+	err = fn(tx)
+	if err == nil {
+		err = execSavepoint(ctx, tx, releaseSavepointStmt(dialect, name))
+		if err != nil {
+			return fmt.Errorf("error releasing attempt savepoint: %w", err)
+		}
+		return nil
+	}
+
+	_, rollbackErr := tx.ExecContext(ctx, rollbackToSavepointStmt(dialect, name))
+	if rollbackErr == nil {
+		rollbackErr = execSavepoint(ctx, tx, releaseSavepointStmt(dialect, name))
+	}
+	if rollbackErr != nil {
+		return fmt.Errorf(
+			"unable to rollback attempt after error: %s, rollback error: %w",
+			err, rollbackErr,
+		)
+	}
+
+	tx.mu.Lock()
+	rolledBack := append([]func(context.Context, error){}, tx.afterRollback[snapshot.afterRollback:]...)
+	tx.restore(snapshot)
+	tx.mu.Unlock()
+
+	for _, fn := range rolledBack {
+		fn(ctx, err)
+	}
+
+	return err
+}
+
+// txSnapshot records how much state was registered on a
+// transaction so it can be discarded with restore.
+type txSnapshot struct {
+	beforeCommit  int
+	afterCommit   int
+	afterRollback int
+	invalidations int
+	deferred      int
+	changes       int
+}
+
+// snapshot must be called with tx.mu locked.
+func (tx *Tx) snapshot() txSnapshot {
+	return txSnapshot{
+		beforeCommit:  len(tx.beforeCommit),
+		afterCommit:   len(tx.afterCommit),
+		afterRollback: len(tx.afterRollback),
+		invalidations: len(tx.invalidations),
+		deferred:      len(tx.deferred),
+		changes:       len(tx.changes),
+	}
+}
+
+// restore must be called with tx.mu locked.
+func (tx *Tx) restore(s txSnapshot) {
+	tx.beforeCommit = tx.beforeCommit[:s.beforeCommit]
+	tx.afterCommit = tx.afterCommit[:s.afterCommit]
+	tx.afterRollback = tx.afterRollback[:s.afterRollback]
+	tx.invalidations = tx.invalidations[:s.invalidations]
+	tx.deferred = tx.deferred[:s.deferred]
+	tx.changes = tx.changes[:s.changes]
+}
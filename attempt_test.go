@@ -0,0 +1,186 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAttempt(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should call the AfterRollback callbacks of the failed attempts", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errAttempt := errors.New("attempt error")
+		var rolledBack []error
+		outerCalled := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := AfterRollback(tx, func(ctx context.Context, err error) {
+				outerCalled = true
+			})
+			if err != nil {
+				return err
+			}
+
+			attemptErr := Attempt(ctx, tx, func(tx *Tx) error {
+				err := AfterRollback(tx, func(ctx context.Context, err error) {
+					rolledBack = append(rolledBack, err)
+				})
+				if err != nil {
+					return err
+				}
+				return errAttempt
+			})
+			if attemptErr != errAttempt {
+				t.Errorf("expected the attempt error, got: %v", attemptErr)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(rolledBack) != 1 || rolledBack[0] != errAttempt {
+			t.Errorf("expected a single call with the attempt error, got: %v", rolledBack)
+		}
+		if outerCalled {
+			t.Error("expected the callbacks of the transaction not to run on commit")
+		}
+	})
+
+	t.Run("should rollback only the failed attempts", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var failed []string
+		var callbacks []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			for _, email := range []string{"john@example.com", "john@example.com", "jane@example.com"} {
+				email := email
+				err := Attempt(ctx, tx, func(tx *Tx) error {
+					err := AfterCommit(tx, func(ctx context.Context) {
+						callbacks = append(callbacks, email)
+					})
+					if err != nil {
+						return err
+					}
+
+					_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('name', ?)", email)
+					return err
+				})
+				if err != nil {
+					failed = append(failed, email)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(failed) != 1 || failed[0] != "john@example.com" {
+			t.Errorf("expected only the duplicated email to fail, got: %v", failed)
+		}
+		if len(callbacks) != 2 || callbacks[0] != "john@example.com" || callbacks[1] != "jane@example.com" {
+			t.Errorf("expected the callbacks of the failed attempt to be discarded, got: %v", callbacks)
+		}
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 users, got %d", count)
+		}
+	})
+
+	t.Run("should support nested attempts", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			return Attempt(ctx, tx, func(tx *Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+				if err != nil {
+					return err
+				}
+
+				err = Attempt(ctx, tx, func(tx *Tx) error {
+					_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Jane', 'jane@example.com')")
+					if err != nil {
+						return err
+					}
+					return fakeErr
+				})
+				if !errors.Is(err, fakeErr) {
+					t.Errorf("expected the error of the inner attempt, got: %v", err)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		var names []string
+		rows, err := db.Query("SELECT name FROM users")
+		if err != nil {
+			t.Fatalf("failed to read users: %v", err)
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var name string
+			err := rows.Scan(&name)
+			if err != nil {
+				t.Fatalf("failed to read users: %v", err)
+			}
+			names = append(names, name)
+		}
+		if len(names) != 1 || names[0] != "John" {
+			t.Errorf("expected only the outer insert to be kept, got: %v", names)
+		}
+	})
+
+	t.Run("should use the savepoint syntax of the dialect", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Attempt(ctx, tx, func(tx *Tx) error { return nil })
+			if err != nil {
+				return err
+			}
+			_ = Attempt(ctx, tx, func(tx *Tx) error { return errors.New("fake error") })
+			return nil
+		}, WithDialect(SQLServer))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{
+			"SAVE TRANSACTION ktx_attempt_1 []",
+			"SAVE TRANSACTION ktx_attempt_2 []",
+			"ROLLBACK TRANSACTION ktx_attempt_2 []",
+		}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should require a transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Attempt(ctx, db, func(tx *Tx) error { return nil })
+		if !errors.Is(err, ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrReadOnlyReverted is returned by the statements that write inside a
+// transaction started as read-only by a ReadOnlyDetector, which reverts
+// its name to read-write. IsRetryable reports it as transient, since the
+// next attempt starts as read-write.
+var ErrReadOnlyReverted = errors.New("write in a transaction started as read-only by the ReadOnlyDetector")
+
+// ReadOnlyDetectorOptions configures a ReadOnlyDetector.
+type ReadOnlyDetectorOptions struct {
+	// MinObservations is how many transactions with the same name must
+	// finish without writing before the next ones start as read-only,
+	// defaults to 100.
+	MinObservations int
+}
+
+// ReadOnlyDetector observes the transactions by their name and, once a name
+// was seen enough times without writing, starts the next transactions with
+// that name WithReadOnly, which lets ReadOnlyFallback route them to a replica
+// and skips their commits, without any code changes:
+//
+//	detector := ktx.NewReadOnlyDetector(ktx.ReadOnlyDetectorOptions{})
+//
+//	err := ktx.Run(ctx, db, listOrders, ktx.WithName("list-orders"), detector.Option())
+//
+// A transaction writes when it executes any statement with ExecContext or a
+// query that starts with a write, such as an INSERT with RETURNING. If one
+// of the transactions started as read-only writes, the statement fails with
+// ErrReadOnlyReverted before reaching the database and the name goes back
+// to read-write for good, so only that transaction fails, or none of them
+// when it is retried WithRetry.
+//
+// A single ReadOnlyDetector should be shared by all the transactions it
+// observes. Transactions without a name are never started as read-only.
+type ReadOnlyDetector struct {
+	opts ReadOnlyDetectorOptions
+
+	mu    sync.Mutex
+	names map[string]*readOnlyObservations
+}
+
+type readOnlyObservations struct {
+	reads int
+	wrote bool
+}
+
+// NewReadOnlyDetector creates a ReadOnlyDetector.
+func NewReadOnlyDetector(opts ReadOnlyDetectorOptions) *ReadOnlyDetector {
+	if opts.MinObservations <= 0 {
+		opts.MinObservations = 100
+	}
+
+	return &ReadOnlyDetector{
+		opts:  opts,
+		names: map[string]*readOnlyObservations{},
+	}
+}
+
+// Option returns the Option that makes the detector observe a transaction.
+func (d *ReadOnlyDetector) Option() Option {
+	return WithOptions(
+		func(c *config) {
+			c.readOnlyDetector = d
+		},
+		WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				d.observe(tx)
+			},
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				d.observe(tx)
+			},
+			OnCommitAmbiguous: func(ctx context.Context, tx *Tx, err error) {
+				d.observe(tx)
+			},
+		}),
+	)
+}
+
+// ReadOnly reports whether the transactions with the input
+// name are currently started as read-only.
+func (d *ReadOnlyDetector) ReadOnly(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	obs := d.names[name]
+	return name != "" && obs != nil && !obs.wrote && obs.reads >= d.opts.MinObservations
+}
+
+func (d *ReadOnlyDetector) observe(tx *Tx) {
+	name := tx.Name()
+	if name == "" || tx.autoReadOnly {
+		return
+	}
+
+	tx.mu.Lock()
+	wrote := tx.wrote
+	tx.mu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	obs := d.names[name]
+	if obs == nil {
+		obs = &readOnlyObservations{}
+		d.names[name] = obs
+	}
+	if wrote {
+		obs.wrote = true
+		return
+	}
+	obs.reads++
+}
+
+// revert makes the transactions with the input name read-write for good.
+func (d *ReadOnlyDetector) revert(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.names[name].wrote = true
+}
+
+// startReadOnly starts the transaction WithReadOnly if the
+// ReadOnlyDetector of its config has seen its name never writing.
+func (tx *Tx) startReadOnly() {
+	cfg := &tx.config
+	if cfg.readOnlyDetector == nil || cfg.readOnly {
+		return
+	}
+
+	if cfg.readOnlyDetector.ReadOnly(cfg.name) {
+		cfg.readOnly = true
+		tx.autoReadOnly = true
+	}
+}
+
+// writeDetectionRunner records the writes of the transactions
+// observed by a ReadOnlyDetector, and rejects them on the
+// transactions it started as read-only.
+type writeDetectionRunner struct {
+	next DBRunner
+	tx   *Tx
+}
+
+func (r writeDetectionRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	err := r.write(query)
+	if err != nil {
+		return nil, err
+	}
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r writeDetectionRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if _, ok := writeKeyword(query); ok {
+		err := r.write(query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r writeDetectionRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (r writeDetectionRunner) write(query string) error {
+	if r.tx.autoReadOnly {
+		r.tx.cfg.readOnlyDetector.revert(r.tx.cfg.name)
+		return fmt.Errorf("%w: %s", ErrReadOnlyReverted, Fingerprint(query))
+	}
+
+	r.tx.mu.Lock()
+	r.tx.wrote = true
+	r.tx.mu.Unlock()
+	return nil
+}
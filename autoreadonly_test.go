@@ -0,0 +1,117 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyDetector(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	countUsers := func(tx *Tx) error {
+		var count int
+		return queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+	}
+
+	t.Run("should start the transactions that never write as read-only", func(t *testing.T) {
+		detector := NewReadOnlyDetector(ReadOnlyDetectorOptions{MinObservations: 3})
+
+		var readOnly []bool
+		for i := 0; i < 5; i++ {
+			err := Run(ctx, db, countUsers, WithName("count-users"), detector.Option(), WithHooks(Hooks{
+				OnCommit: func(ctx context.Context, tx *Tx) {
+					readOnly = append(readOnly, tx.Stats().CommitSkipped)
+				},
+			}))
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		expected := []bool{false, false, false, true, true}
+		for i := range expected {
+			if readOnly[i] != expected[i] {
+				t.Fatalf("expected read-only transactions %v, got %v", expected, readOnly)
+			}
+		}
+		if !detector.ReadOnly("count-users") {
+			t.Errorf("expected the name to be read-only")
+		}
+	})
+
+	t.Run("should never start the transactions that write as read-only", func(t *testing.T) {
+		detector := NewReadOnlyDetector(ReadOnlyDetectorOptions{MinObservations: 2})
+
+		for i := 0; i < 4; i++ {
+			err := Run(ctx, db, func(tx *Tx) error {
+				if i == 0 {
+					_, err := tx.ExecContext(ctx, "UPDATE users SET name = name")
+					return err
+				}
+				return countUsers(tx)
+			}, WithName("sometimes-writes"), detector.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		if detector.ReadOnly("sometimes-writes") {
+			t.Errorf("expected the name to stay read-write")
+		}
+	})
+
+	t.Run("should revert and retry when a read-only transaction writes", func(t *testing.T) {
+		detector := NewReadOnlyDetector(ReadOnlyDetectorOptions{MinObservations: 1})
+
+		err := Run(ctx, db, countUsers, WithName("create-user"), detector.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if !detector.ReadOnly("create-user") {
+			t.Fatalf("expected the name to be read-only")
+		}
+
+		var errs []error
+		err = Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "autoreadonly@example.com")
+			return err
+		}, WithName("create-user"), detector.Option(), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     func(int) time.Duration { return 0 },
+		}), WithHooks(Hooks{
+			OnRetry: func(ctx context.Context, attempt int, err error) {
+				errs = append(errs, err)
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(errs) != 1 || !errors.Is(errs[0], ErrReadOnlyReverted) {
+			t.Errorf("expected a single retry after ErrReadOnlyReverted, got: %v", errs)
+		}
+		if detector.ReadOnly("create-user") {
+			t.Errorf("expected the name to be reverted to read-write")
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should ignore the transactions without a name", func(t *testing.T) {
+		detector := NewReadOnlyDetector(ReadOnlyDetectorOptions{MinObservations: 1})
+
+		for i := 0; i < 2; i++ {
+			err := Run(ctx, db, countUsers, detector.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+		if detector.ReadOnly("") {
+			t.Errorf("expected the transactions without a name to stay read-write")
+		}
+	})
+}
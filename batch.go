@@ -0,0 +1,83 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrBatchNotSupported is returned by a BatchExecutor when it can't execute
+// the batch on the connection of the transaction, in which case ExecBatch
+// falls back to executing the statements one by one.
+var ErrBatchNotSupported = errors.New("batches are not supported by this connection")
+
+// Batch is a list of statements to be executed together with ExecBatch.
+type Batch struct {
+	statements []BatchStatement
+}
+
+// BatchStatement is a statement queued on a Batch.
+type BatchStatement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Queue adds a statement to the end of the batch.
+func (b *Batch) Queue(query string, args ...interface{}) {
+	b.statements = append(b.statements, BatchStatement{
+		Query: query,
+		Args:  args,
+	})
+}
+
+// Len returns the number of statements queued on the batch.
+func (b *Batch) Len() int {
+	return len(b.statements)
+}
+
+// Statements returns the statements queued on the batch in order.
+func (b *Batch) Statements() []BatchStatement {
+	return b.statements
+}
+
+// BatchExecutor executes all the statements of the batch inside the
+// transaction, returning one result for each of them, e.g. by sending
+// them in a single round trip with the batch protocol of the driver.
+type BatchExecutor func(ctx context.Context, tx *Tx, b *Batch) ([]sql.Result, error)
+
+// WithBatchExecutor sets how ExecBatch executes the batches of the transaction,
+// e.g. with ktxpgx.ExecBatch for the pipelining of pgx.
+func WithBatchExecutor(executor BatchExecutor) Option {
+	return func(c *config) {
+		c.batchExecutor = executor
+	}
+}
+
+// ExecBatch executes the statements queued on the batch in order,
+// stopping at the first error, and returns their results.
+//
+// When db is a transaction started WithBatchExecutor the statements are
+// passed to the executor, which may send all of them in a single round trip,
+// saving the latency of each statement on high-RTT links. Otherwise, or if
+// the executor returns ErrBatchNotSupported, they are executed one by one.
+func ExecBatch(ctx context.Context, db DBRunner, b *Batch) ([]sql.Result, error) {
+	tx, err := TxFromRunner(db)
+	if err == nil && tx.cfg.batchExecutor != nil {
+		results, err := tx.cfg.batchExecutor(ctx, tx, b)
+		if !errors.Is(err, ErrBatchNotSupported) {
+			return results, err
+		}
+	}
+
+	results := make([]sql.Result, 0, len(b.statements))
+	for i, stmt := range b.statements {
+		result, err := db.ExecContext(ctx, stmt.Query, stmt.Args...)
+		if err != nil {
+			return results, fmt.Errorf("error executing statement %d of the batch: %w", i, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,121 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestExecBatch(t *testing.T) {
+	ctx := context.Background()
+
+	newBatch := func() *Batch {
+		b := &Batch{}
+		b.Queue("INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+		b.Queue("INSERT INTO users (name, email) VALUES (?, ?)", "Jane", "jane@example.com")
+		b.Queue("UPDATE users SET name = name || '!'")
+		return b
+	}
+
+	t.Run("should execute the statements in order", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var results []sql.Result
+		err := Run(ctx, db, func(tx *Tx) (err error) {
+			results, err = ExecBatch(ctx, tx, newBatch())
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(results))
+		}
+		n, err := results[2].RowsAffected()
+		if err != nil || n != 2 {
+			t.Errorf("expected the update to affect 2 rows, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("should stop at the first error", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		b := newBatch()
+		b.Queue("NOT VALID SQL")
+		b.Queue("DELETE FROM users")
+
+		results, err := ExecBatch(ctx, db, b)
+		if err == nil {
+			t.Fatal("expected an error for the invalid statement")
+		}
+		if len(results) != 3 {
+			t.Errorf("expected the results of the 3 statements before the error, got %d", len(results))
+		}
+	})
+
+	t.Run("should use the executor of the transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var executed int
+		executor := func(ctx context.Context, tx *Tx, b *Batch) ([]sql.Result, error) {
+			executed = b.Len()
+			return make([]sql.Result, b.Len()), nil
+		}
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := ExecBatch(ctx, tx, newBatch())
+			return err
+		}, WithBatchExecutor(executor))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if executed != 3 {
+			t.Errorf("expected the executor to receive 3 statements, got %d", executed)
+		}
+	})
+
+	t.Run("should fall back to sequential execution", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		executor := func(ctx context.Context, tx *Tx, b *Batch) ([]sql.Result, error) {
+			return nil, ErrBatchNotSupported
+		}
+
+		var results []sql.Result
+		err := Run(ctx, db, func(tx *Tx) (err error) {
+			results, err = ExecBatch(ctx, tx, newBatch())
+			return err
+		}, WithBatchExecutor(executor))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(results) != 3 {
+			t.Errorf("expected 3 results, got %d", len(results))
+		}
+	})
+
+	t.Run("should return the errors of the executor", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		executor := func(ctx context.Context, tx *Tx, b *Batch) ([]sql.Result, error) {
+			return nil, fakeErr
+		}
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := ExecBatch(ctx, tx, newBatch())
+			return err
+		}, WithBatchExecutor(executor))
+		if !errors.Is(err, fakeErr) {
+			t.Errorf("expected the executor error, got: %v", err)
+		}
+	})
+}
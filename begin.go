@@ -0,0 +1,172 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrTxLeaked is passed to the OnRollback hooks of the transactions
+// started with Begin that were rolled back by ktx because they were
+// never committed nor rolled back by the caller.
+var ErrTxLeaked = errors.New("transaction was never finished and was rolled back by ktx")
+
+// errRollbackRequested is passed to the OnRollback hooks
+// when ManualTx.Rollback is called.
+var errRollbackRequested = errors.New("transaction rolled back by the caller")
+
+// ManualTx is a transaction started with Begin, which must be
+// finished by calling either Commit or Rollback.
+//
+// It embeds the *Tx, so it can be used as a DBRunner
+// and with all the helpers of this package.
+type ManualTx struct {
+	*Tx
+	state *manualState
+}
+
+// manualState is kept apart from ManualTx so the leak timer
+// doesn't prevent the finalizer of ManualTx from running.
+type manualState struct {
+	ctx   context.Context
+	tx    *Tx
+	timer *time.Timer
+
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+// Begin starts a transaction whose lifecycle is controlled by the caller,
+// for the rare flows where the callback of Run doesn't fit, e.g. when the
+// transaction spans several calls of a streaming API:
+//
+//	tx, err := ktx.Begin(ctx, db)
+//	if err != nil {
+//		return err
+//	}
+//	defer tx.Rollback()
+//
+//	// ...
+//
+//	return tx.Commit()
+//
+// Commit and Rollback can be called more than once, the calls after the
+// transaction is finished have no effect, so Rollback can always be deferred.
+//
+// A transaction that is garbage collected without being finished is rolled
+// back and reported to the OnRollback hooks with ErrTxLeaked, and so are the
+// transactions still running after the timeout set with WithLeakTimeout.
+//
+// All Options of Run are supported except WithRetry, since there is no
+// callback that could be retried.
+func Begin(ctx context.Context, db DBRunner, opts ...Option) (*ManualTx, error) {
+	txBeginner, ok := db.(TxBeginner)
+	if !ok {
+		return nil, fmt.Errorf("provided db does not implement TxBeginner interface")
+	}
+
+	tx := &Tx{}
+	tx.config.apply(opts)
+	if tx.config.retry != nil {
+		return nil, fmt.Errorf("WithRetry is not supported by Begin since there is no callback to retry")
+	}
+
+	err := begin(ctx, txBeginner, tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.onBegin(ctx)
+
+	state := &manualState{
+		ctx: ctx,
+		tx:  tx,
+	}
+	if tx.config.leakTimeout > 0 {
+		// Locked so the timer can't read state.timer before it is set:
+		state.mu.Lock()
+		state.timer = time.AfterFunc(tx.config.leakTimeout, state.leak)
+		state.mu.Unlock()
+	}
+
+	m := &ManualTx{
+		Tx:    tx,
+		state: state,
+	}
+	runtime.SetFinalizer(m, func(m *ManualTx) {
+		m.state.leak()
+	})
+
+	return m, nil
+}
+
+// WithLeakTimeout makes the transactions started with Begin be rolled back
+// if they are not finished within d, reporting ErrTxLeaked to the
+// OnRollback hooks. Calling Commit afterwards returns ErrTxLeaked.
+//
+// It has no effect on Run.
+func WithLeakTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.leakTimeout = d
+	}
+}
+
+// Commit commits the transaction, running the BeforeCommit and
+// AfterCommit callbacks and the hooks just like Run.
+//
+// If the transaction was already finished it returns the error of the
+// commit that finished it, or sql.ErrTxDone if it was rolled back.
+func (m *ManualTx) Commit() error {
+	return m.state.finish(nil)
+}
+
+// Rollback rolls the transaction back,
+// doing nothing if it was already finished.
+func (m *ManualTx) Rollback() error {
+	err := m.state.finish(errRollbackRequested)
+	if err == errRollbackRequested {
+		return nil
+	}
+	return err
+}
+
+// Unwrap returns the underlying *Tx so TxFromRunner works on ManualTx.
+func (m *ManualTx) Unwrap() DBRunner {
+	return m.Tx
+}
+
+func (s *manualState) finish(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		if err != nil {
+			return nil
+		}
+		return s.err
+	}
+	s.done = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	defer s.tx.release()
+
+	finishErr := s.tx.finish(s.ctx, err)
+	switch {
+	case err == ErrTxLeaked:
+		s.err = ErrTxLeaked
+	case err != nil:
+		s.err = sql.ErrTxDone
+	default:
+		s.err = finishErr
+	}
+	return finishErr
+}
+
+func (s *manualState) leak() {
+	_ = s.finish(ErrTxLeaked)
+}
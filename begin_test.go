@@ -0,0 +1,180 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBegin(t *testing.T) {
+	ctx := context.Background()
+
+	countUsers := func(t *testing.T, db *sql.DB) int {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		return count
+	}
+
+	t.Run("should commit and ignore the deferred rollback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var afterCommit bool
+		tx, err := Begin(ctx, db)
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+		if err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+
+		err = AfterCommit(tx, func(ctx context.Context) {
+			afterCommit = true
+		})
+		if err != nil {
+			t.Fatalf("AfterCommit failed: %v", err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if !afterCommit {
+			t.Errorf("expected the AfterCommit callback to run")
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			t.Errorf("expected a second Commit to succeed, got: %v", err)
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Errorf("expected Rollback after Commit to be ignored, got: %v", err)
+		}
+
+		if count := countUsers(t, db); count != 1 {
+			t.Errorf("expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("should rollback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var rollbackErr error
+		tx, err := Begin(ctx, db, WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				rollbackErr = err
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+		if err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatalf("Rollback failed: %v", err)
+		}
+		if rollbackErr == nil {
+			t.Errorf("expected the OnRollback hook to be called")
+		}
+
+		err = tx.Rollback()
+		if err != nil {
+			t.Errorf("expected a second Rollback to succeed, got: %v", err)
+		}
+		err = tx.Commit()
+		if !errors.Is(err, sql.ErrTxDone) {
+			t.Errorf("expected sql.ErrTxDone on Commit after Rollback, got: %v", err)
+		}
+
+		if count := countUsers(t, db); count != 0 {
+			t.Errorf("expected no users, got %d", count)
+		}
+	})
+
+	t.Run("should rollback transactions that exceed the leak timeout", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		leaked := make(chan error, 1)
+		tx, err := Begin(ctx, db, WithLeakTimeout(10*time.Millisecond), WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				leaked <- err
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+
+		select {
+		case err := <-leaked:
+			if !errors.Is(err, ErrTxLeaked) {
+				t.Errorf("expected ErrTxLeaked, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the leaked transaction to be rolled back")
+		}
+
+		err = tx.Commit()
+		if !errors.Is(err, ErrTxLeaked) {
+			t.Errorf("expected ErrTxLeaked on Commit, got: %v", err)
+		}
+	})
+
+	t.Run("should rollback transactions that are garbage collected", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		leaked := make(chan error, 1)
+		func() {
+			_, err := Begin(ctx, db, WithHooks(Hooks{
+				OnRollback: func(ctx context.Context, tx *Tx, err error) {
+					leaked <- err
+				},
+			}))
+			if err != nil {
+				t.Fatalf("Begin failed: %v", err)
+			}
+		}()
+
+		deadline := time.After(time.Second)
+		for {
+			runtime.GC()
+			select {
+			case err := <-leaked:
+				if !errors.Is(err, ErrTxLeaked) {
+					t.Errorf("expected ErrTxLeaked, got: %v", err)
+				}
+				return
+			case <-deadline:
+				t.Fatalf("timed out waiting for the leaked transaction to be rolled back")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	t.Run("should not support retries", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		_, err := Begin(ctx, db, WithRetry(RetryPolicy{}), WithIdempotent())
+		if err == nil {
+			t.Errorf("expected an error for WithRetry")
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCommitSkipped is passed to the OnRollback hooks and the AfterRollback
+// callbacks of the transactions rolled back because the callback of
+// BeginFunc returned commit == false without an error.
+var ErrCommitSkipped = errors.New("commit skipped by the callback")
+
+// BeginFunc works like Run but lets the callback decide whether the
+// transaction is committed based on conditions other than the error,
+// e.g. skipping the commit of a transaction that changed nothing:
+//
+//	err := ktx.BeginFunc(ctx, db, func(tx *ktx.Tx) (commit bool, err error) {
+//		changed, err := syncInventory(ctx, tx)
+//		return changed, err
+//	})
+//
+// When the callback returns an error the transaction is rolled back and
+// the error is returned, just like Run, and the panics are handled in the
+// same way. When it returns commit == false without an error the transaction
+// is rolled back with ErrCommitSkipped and BeginFunc returns nil.
+//
+// If db is already a transaction it is reused and only its error is
+// returned, since it is up to the outer transaction to commit or not.
+func BeginFunc(ctx context.Context, db DBRunner, fn func(tx *Tx) (commit bool, err error), opts ...Option) error {
+	err := Run(ctx, db, func(tx *Tx) error {
+		commit, err := fn(tx)
+		if err == nil && !commit {
+			return ErrCommitSkipped
+		}
+		return err
+	}, opts...)
+	if err == ErrCommitSkipped {
+		// Compared directly so the errors wrapping it, such
+		// as the failures of the rollback, are still returned:
+		return nil
+	}
+	return err
+}
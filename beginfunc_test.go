@@ -0,0 +1,103 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBeginFunc(t *testing.T) {
+	ctx := context.Background()
+
+	insertUser := func(tx *Tx, email string) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", email)
+		return err
+	}
+
+	t.Run("should commit when the callback asks to", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := BeginFunc(ctx, db, func(tx *Tx) (bool, error) {
+			return true, insertUser(tx, "john@example.com")
+		})
+		if err != nil {
+			t.Fatalf("BeginFunc failed: %v", err)
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should roll back without an error when the commit is skipped", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var rollbackErr error
+		err := BeginFunc(ctx, db, func(tx *Tx) (bool, error) {
+			return false, insertUser(tx, "john@example.com")
+		}, WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				rollbackErr = err
+			},
+		}))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if rollbackErr != ErrCommitSkipped {
+			t.Errorf("expected the OnRollback hook to receive ErrCommitSkipped, got: %v", rollbackErr)
+		}
+		assertUserCount(t, db, 0)
+	})
+
+	t.Run("should roll back and return the error of the callback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		err := BeginFunc(ctx, db, func(tx *Tx) (bool, error) {
+			err := insertUser(tx, "john@example.com")
+			if err != nil {
+				return false, err
+			}
+			return true, fakeErr
+		})
+		if err != fakeErr {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+
+	t.Run("should roll back on panics", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		defer func() {
+			if r := recover(); r != "fake panic" {
+				t.Fatalf("expected the panic to be propagated, got: %v", r)
+			}
+			assertUserCount(t, db, 0)
+		}()
+
+		_ = BeginFunc(ctx, db, func(tx *Tx) (bool, error) {
+			err := insertUser(tx, "john@example.com")
+			if err != nil {
+				return false, err
+			}
+			panic("fake panic")
+		})
+	})
+
+	t.Run("should leave the decision to the outer transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return BeginFunc(ctx, tx, func(tx *Tx) (bool, error) {
+				return false, insertUser(tx, "john@example.com")
+			})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		assertUserCount(t, db, 1)
+	})
+}
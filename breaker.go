@@ -0,0 +1,190 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of starting a transaction
+// while the CircuitBreaker considers the database unhealthy.
+var ErrCircuitOpen = errors.New("circuit breaker is open, the database is considered unhealthy")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState string
+
+// The states of a CircuitBreaker.
+const (
+	// CircuitClosed lets all transactions start.
+	CircuitClosed CircuitState = "closed"
+
+	// CircuitOpen fails new transactions with ErrCircuitOpen.
+	CircuitOpen CircuitState = "open"
+
+	// CircuitHalfOpen lets a single transaction start
+	// for probing whether the database has recovered.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerOptions configures a CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// FailureRatio is the ratio of transactions that fail to start, from
+	// 0 to 1, above which the circuit opens, defaults to 0.5.
+	FailureRatio float64
+
+	// MinRequests is the minimum number of transactions started within
+	// the Window before the FailureRatio is checked, defaults to 10.
+	MinRequests int
+
+	// Window is the period over which the failures are counted, defaults to 10 seconds.
+	Window time.Duration
+
+	// SlowThreshold makes the transactions that take longer than it
+	// to start count as failures, which is disabled when zero.
+	SlowThreshold time.Duration
+
+	// OpenDuration is how long the circuit stays open before
+	// letting a transaction through as a probe, defaults to 5 seconds.
+	OpenDuration time.Duration
+}
+
+// CircuitBreaker short-circuits new transactions with ErrCircuitOpen while
+// too many of them fail, or take too long, to start, protecting the
+// application from piling up goroutines against a database that is down.
+//
+// After the OpenDuration a single transaction is let through, closing
+// the circuit if it starts successfully or opening it again otherwise.
+//
+// The same breaker should be shared by all the transactions on a database.
+type CircuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	probing     bool
+}
+
+// NewCircuitBreaker creates a CircuitBreaker, whose Option
+// must be passed to the transactions it protects.
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.FailureRatio <= 0 {
+		opts.FailureRatio = 0.5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = 5 * time.Second
+	}
+
+	return &CircuitBreaker{
+		opts:        opts,
+		state:       CircuitClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// Option returns the Option that protects a transaction with the breaker.
+func (b *CircuitBreaker) Option() Option {
+	return func(c *config) {
+		c.breaker = b
+	}
+}
+
+// State returns the current state of the breaker, e.g. for metrics.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a transaction can start
+// and whether it is the probe of a half-open circuit.
+func (b *CircuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.opts.OpenDuration {
+			return false, ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return false, ErrCircuitOpen
+		}
+	default:
+		return false, nil
+	}
+
+	b.probing = true
+	return true, nil
+}
+
+// record registers the outcome of starting a transaction.
+func (b *CircuitBreaker) record(probe bool, took time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The transactions cancelled by the caller say nothing about the database:
+	if errors.Is(err, context.Canceled) {
+		if probe {
+			b.probing = false
+		}
+		return
+	}
+	failed := err != nil || (b.opts.SlowThreshold > 0 && took > b.opts.SlowThreshold)
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open()
+			return
+		}
+		b.state = CircuitClosed
+		b.resetWindow()
+		return
+	}
+
+	// Transactions that started before the circuit opened are ignored:
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if time.Since(b.windowStart) > b.opts.Window {
+		b.resetWindow()
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= b.opts.MinRequests && float64(b.failures)/float64(b.requests) >= b.opts.FailureRatio {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+	b.resetWindow()
+}
+
+func (b *CircuitBreaker) resetWindow() {
+	b.windowStart = time.Now()
+	b.requests = 0
+	b.failures = 0
+}
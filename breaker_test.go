@@ -0,0 +1,94 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyDB fails to begin transactions while down is true.
+type flakyDB struct {
+	*sql.DB
+	down bool
+}
+
+func (db *flakyDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if db.down {
+		return nil, errors.New("connection refused")
+	}
+	return db.DB.BeginTx(ctx, opts)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+
+	sqlDB := setupTestDB(t)
+	defer func() { _ = sqlDB.Close() }()
+	db := &flakyDB{DB: sqlDB}
+
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{
+		MinRequests:  4,
+		FailureRatio: 0.5,
+		OpenDuration: 50 * time.Millisecond,
+	})
+	noop := func(tx *Tx) error { return nil }
+
+	t.Run("should open after too many failures", func(t *testing.T) {
+		db.down = false
+		for i := 0; i < 2; i++ {
+			err := Run(ctx, db, noop, breaker.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		db.down = true
+		for i := 0; i < 2; i++ {
+			err := Run(ctx, db, noop, breaker.Option())
+			if err == nil || errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("expected the begin error, got: %v", err)
+			}
+		}
+
+		if breaker.State() != CircuitOpen {
+			t.Fatalf("expected the circuit to be open, got %s", breaker.State())
+		}
+
+		db.down = false
+		err := Run(ctx, db, noop, breaker.Option())
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("expected ErrCircuitOpen, got: %v", err)
+		}
+	})
+
+	t.Run("should reopen when the probe fails", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+		if breaker.State() != CircuitHalfOpen {
+			t.Fatalf("expected the circuit to be half-open, got %s", breaker.State())
+		}
+
+		db.down = true
+		err := Run(ctx, db, noop, breaker.Option())
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the begin error, got: %v", err)
+		}
+		if breaker.State() != CircuitOpen {
+			t.Errorf("expected the circuit to be open again, got %s", breaker.State())
+		}
+	})
+
+	t.Run("should close when the probe succeeds", func(t *testing.T) {
+		time.Sleep(60 * time.Millisecond)
+
+		db.down = false
+		err := Run(ctx, db, noop, breaker.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if breaker.State() != CircuitClosed {
+			t.Errorf("expected the circuit to be closed, got %s", breaker.State())
+		}
+	})
+}
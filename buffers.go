@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"context"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity above which the backing arrays are
+// left for the garbage collector instead of being pooled, so a single
+// transaction with many callbacks doesn't pin its memory forever.
+const maxPooledBuffer = 64
+
+// txBuffers holds the backing arrays of the slices that a transaction
+// only uses while it runs, which are reused among transactions through
+// txBuffersPool so services running many transactions per second
+// don't allocate them again for each one.
+type txBuffers struct {
+	beforeCommit  []beforeCommitCallback
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context, err error)
+	invalidations []string
+	deferred      []deferredStmt
+}
+
+var txBuffersPool = sync.Pool{
+	New: func() interface{} {
+		return &txBuffers{}
+	},
+}
+
+// acquireBuffers makes the transaction append to pooled backing arrays,
+// which are returned to the pool by releaseBuffers.
+func (tx *Tx) acquireBuffers() {
+	b := txBuffersPool.Get().(*txBuffers)
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.buffers = b
+	tx.beforeCommit = b.beforeCommit[:0]
+	tx.afterCommit = b.afterCommit[:0]
+	tx.afterRollback = b.afterRollback[:0]
+	tx.invalidations = b.invalidations[:0]
+	tx.deferred = b.deferred[:0]
+}
+
+// releaseBuffers returns the backing arrays of the transaction to the
+// pool, cleared so they don't retain the callbacks. The transaction
+// allocates new arrays if anything is registered on it afterwards.
+func (tx *Tx) releaseBuffers() {
+	tx.mu.Lock()
+	b := tx.buffers
+	if b == nil {
+		tx.mu.Unlock()
+		return
+	}
+
+	b.beforeCommit = reusable(tx.beforeCommit)
+	b.afterCommit = reusable(tx.afterCommit)
+	b.afterRollback = reusable(tx.afterRollback)
+	b.invalidations = reusable(tx.invalidations)
+	b.deferred = reusable(tx.deferred)
+
+	tx.buffers = nil
+	tx.beforeCommit = nil
+	tx.afterCommit = nil
+	tx.afterRollback = nil
+	tx.invalidations = nil
+	tx.deferred = nil
+	tx.mu.Unlock()
+
+	txBuffersPool.Put(b)
+}
+
+func reusable[T any](s []T) []T {
+	if cap(s) > maxPooledBuffer {
+		return nil
+	}
+	clear(s[:cap(s)])
+	return s[:0]
+}
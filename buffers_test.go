@@ -0,0 +1,83 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestTxBuffers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should not allocate the callbacks slices", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("sync.Pool drops items randomly with the race detector")
+		}
+
+		db := sql.OpenDB(noopConnector{})
+		defer func() { _ = db.Close() }()
+
+		beforeCommit := func(ctx context.Context) error { return nil }
+		afterCommit := func(ctx context.Context) {}
+		afterRollback := func(ctx context.Context, err error) {}
+		noop := func(tx *Tx) error { return nil }
+		withCallbacks := func(tx *Tx) error {
+			for i := 0; i < 4; i++ {
+				_ = BeforeCommit(tx, beforeCommit, WithCallbackPolicy(IgnoreOnError))
+				_ = AfterCommit(tx, afterCommit)
+				_ = AfterRollback(tx, afterRollback)
+			}
+			return nil
+		}
+
+		noopAllocs := minAllocsPerRun(func() {
+			_ = Run(ctx, db, noop)
+		})
+		callbackAllocs := minAllocsPerRun(func() {
+			_ = Run(ctx, db, withCallbacks)
+		})
+		if callbackAllocs > noopAllocs {
+			t.Errorf("expected the callbacks to allocate nothing, got %v allocations on top of %v", callbackAllocs-noopAllocs, noopAllocs)
+		}
+	})
+
+	t.Run("should not share callbacks among transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		calls := 0
+		var finished *Tx
+		err := Run(ctx, db, func(tx *Tx) error {
+			finished = tx
+			return AfterCommit(tx, func(ctx context.Context) { calls++ })
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// Registering on a finished transaction must not
+		// write to the arrays reused by the next ones:
+		_ = AfterCommit(finished, func(ctx context.Context) { calls += 100 })
+
+		err = Run(ctx, db, func(tx *Tx) error { return nil })
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected only the first callback to be called once, got: %d", calls)
+		}
+	})
+
+	t.Run("should not pool large arrays", func(t *testing.T) {
+		s := make([]string, 3, maxPooledBuffer+1)
+		if reusable(s) != nil {
+			t.Errorf("expected the large array to be dropped")
+		}
+
+		s = []string{"a", "b"}
+		s = reusable(s)
+		if len(s) != 0 || s[:2][0] != "" {
+			t.Errorf("expected the array to be emptied and cleared, got: %q", s[:2])
+		}
+	})
+}
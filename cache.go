@@ -0,0 +1,234 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	// The types returned by the drivers that gob doesn't know by default:
+	gob.Register(time.Time{})
+}
+
+// Cache stores the encoded results of the queries read with
+// CachedRunner.Select, e.g. a Redis client or an in-process LRU.
+//
+// Set and Delete are only called after the transaction commits so they
+// can't affect its outcome, which means implementations are responsible
+// for handling (e.g. logging or retrying) their own errors.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, found bool)
+	Set(ctx context.Context, key string, value []byte)
+	Delete(ctx context.Context, keys []string)
+}
+
+// CachedRunner is a DBRunner wrapper that reads the queries executed with
+// its Select method through a Cache shared among transactions:
+//
+//	err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+//		cached := ktx.NewCachedRunner(tx, cache)
+//		rows, err := cached.Select(ctx, "user:42", "SELECT name FROM users WHERE id = ?", 42)
+//		// ...
+//	})
+//
+// Cache misses are only stored once the transaction commits, so the cache
+// is never filled with rows from a transaction that ends up rolled back.
+//
+// Once the transaction writes through ExecContext or QueryContext the cache
+// is bypassed for the rest of the transaction, since the cached rows might
+// not reflect its writes, and the misses read so far are not stored.
+// The keys affected by the writes must be deleted with Invalidate.
+type CachedRunner struct {
+	db    DBRunner
+	cache Cache
+
+	mu          sync.Mutex
+	registered  bool
+	wrote       bool
+	fills       map[string][]byte
+	invalidated map[string]bool
+}
+
+// NewCachedRunner returns a CachedRunner wrapping db, which
+// must be a transaction managed by ktx.
+func NewCachedRunner(db DBRunner, cache Cache) *CachedRunner {
+	return &CachedRunner{
+		db:          db,
+		cache:       cache,
+		fills:       map[string][]byte{},
+		invalidated: map[string]bool{},
+	}
+}
+
+// ExecContext executes a statement and bypasses
+// the cache for the rest of the transaction.
+func (c *CachedRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.markWrite()
+	return c.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query without caching it and
+// bypasses the cache for the rest of the transaction.
+func (c *CachedRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.markWrite()
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+// Unwrap returns the wrapped DBRunner.
+func (c *CachedRunner) Unwrap() DBRunner {
+	return c.db
+}
+
+func (c *CachedRunner) markWrite() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wrote = true
+	c.fills = map[string][]byte{}
+}
+
+// Select returns the rows cached under key or, on a miss, executes the
+// query and stores its rows under key once the transaction commits.
+//
+// The cache is bypassed after the transaction writes
+// and for the keys passed to Invalidate.
+func (c *CachedRunner) Select(ctx context.Context, key string, query string, args ...interface{}) (*MemoRows, error) {
+	tx, err := TxFromRunner(c.db)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	bypass := c.wrote || c.invalidated[key]
+	c.mu.Unlock()
+
+	if !bypass {
+		value, found := c.cache.Get(ctx, key)
+		if found {
+			result, err := decodeCacheEntry(value)
+			if err == nil {
+				return &MemoRows{result: result, cursor: -1}, nil
+			}
+			// Corrupted entries are handled as misses and overwritten.
+		}
+	}
+
+	result, err := (&Memo{db: c.db}).load(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bypass {
+		value, err := encodeCacheEntry(result)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding cache entry '%s': %w", key, err)
+		}
+
+		err = c.schedule(tx, func() {
+			c.fills[key] = value
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MemoRows{result: result, cursor: -1}, nil
+}
+
+// Invalidate deletes keys from the cache once the transaction commits, and
+// bypasses the cache for them for the rest of the transaction.
+func (c *CachedRunner) Invalidate(keys ...string) error {
+	tx, err := TxFromRunner(c.db)
+	if err != nil {
+		return err
+	}
+
+	return c.schedule(tx, func() {
+		for _, key := range keys {
+			c.invalidated[key] = true
+			delete(c.fills, key)
+		}
+	})
+}
+
+// schedule applies update to the pending changes of the cache and makes
+// sure they are delivered to the cache after the transaction commits.
+func (c *CachedRunner) schedule(tx *Tx, update func()) error {
+	c.mu.Lock()
+	update()
+	first := !c.registered
+	c.registered = true
+	c.mu.Unlock()
+
+	if !first {
+		return nil
+	}
+
+	err := AfterCommit(tx, c.deliver)
+	if err != nil {
+		return err
+	}
+
+	// The AfterCommit callback is discarded if it was registered inside
+	// an Attempt that fails, so it must be registered again afterwards:
+	return AfterRollback(tx, func(ctx context.Context, err error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.registered = false
+		// The misses were read after the callback was registered,
+		// so they might include rows that were rolled back:
+		c.fills = map[string][]byte{}
+	})
+}
+
+// deliver applies the pending changes to the cache.
+func (c *CachedRunner) deliver(ctx context.Context) {
+	c.mu.Lock()
+	fills := c.fills
+	invalidated := make([]string, 0, len(c.invalidated))
+	for key := range c.invalidated {
+		invalidated = append(invalidated, key)
+	}
+	c.mu.Unlock()
+
+	if len(invalidated) > 0 {
+		c.cache.Delete(ctx, invalidated)
+	}
+	for key, value := range fills {
+		c.cache.Set(ctx, key, value)
+	}
+}
+
+// cacheEntry is the encoded form of a memoResult.
+type cacheEntry struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+func encodeCacheEntry(result *memoResult) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(cacheEntry{
+		Columns: result.columns,
+		Rows:    result.rows,
+	})
+	return buf.Bytes(), err
+}
+
+func decodeCacheEntry(value []byte) (*memoResult, error) {
+	var entry cacheEntry
+	err := gob.NewDecoder(bytes.NewReader(value)).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memoResult{
+		columns: entry.Columns,
+		rows:    entry.Rows,
+	}, nil
+}
@@ -0,0 +1,275 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type memCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	deleted []string
+}
+
+func newMemCache() *memCache {
+	return &memCache{entries: map[string][]byte{}}
+}
+
+func (c *memCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, found := c.entries[key]
+	return value, found
+}
+
+func (c *memCache) Set(ctx context.Context, key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = value
+}
+
+func (c *memCache) Delete(ctx context.Context, keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.deleted = append(c.deleted, keys...)
+}
+
+func (c *memCache) has(key string) bool {
+	_, found := c.Get(context.Background(), key)
+	return found
+}
+
+func selectUserName(ctx context.Context, t *testing.T, cached *CachedRunner, id int) string {
+	t.Helper()
+
+	rows, err := cached.Select(ctx, "user:1", "SELECT name, NULL FROM users WHERE id = ?", id)
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatal("expected a row")
+	}
+
+	var name string
+	var missing *string
+	err = rows.Scan(&name, &missing)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if missing != nil {
+		t.Fatalf("expected a NULL column, got %q", *missing)
+	}
+	return name
+}
+
+func TestCachedRunner_FillsAfterCommit(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cache := newMemCache()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert initial record: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		cached := NewCachedRunner(tx, cache)
+		if name := selectUserName(ctx, t, cached, 1); name != "John" {
+			t.Fatalf("expected John, got %s", name)
+		}
+		if cache.has("user:1") {
+			t.Fatal("expected the cache to be filled only after the commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !cache.has("user:1") {
+		t.Fatal("expected the cache to be filled after the commit")
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		counter := &countingRunner{DBRunner: tx}
+		cached := NewCachedRunner(counter, cache)
+		if name := selectUserName(ctx, t, cached, 1); name != "John" {
+			t.Fatalf("expected John, got %s", name)
+		}
+		if counter.queries != 0 {
+			t.Fatalf("expected the rows to be read from the cache, got %d queries", counter.queries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestCachedRunner_RollbackDoesNotFill(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cache := newMemCache()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert initial record: %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	err = Run(ctx, db, func(tx *Tx) error {
+		selectUserName(ctx, t, NewCachedRunner(tx, cache), 1)
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected the rollback error, got %v", err)
+	}
+	if cache.has("user:1") {
+		t.Fatal("expected the cache not to be filled by a rolled back transaction")
+	}
+}
+
+func TestCachedRunner_WritesBypassTheCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cache := newMemCache()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert initial record: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		cached := NewCachedRunner(tx, cache)
+		selectUserName(ctx, t, cached, 1)
+
+		_, err := cached.ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "Jane", 1)
+		if err != nil {
+			return err
+		}
+
+		// A stale entry written concurrently must not be read after the write:
+		cache.Set(ctx, "user:1", mustEncode(t, "John"))
+		if name := selectUserName(ctx, t, cached, 1); name != "Jane" {
+			t.Fatalf("expected the cache to be bypassed after the write, got %s", name)
+		}
+
+		return cached.Invalidate("user:1")
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if cache.has("user:1") {
+		t.Fatal("expected the key to be deleted after the commit")
+	}
+}
+
+func TestCachedRunner_Invalidate(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cache := newMemCache()
+	cache.Set(ctx, "user:1", mustEncode(t, "John"))
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES (?, ?)", "Jane", "jane@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert initial record: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		cached := NewCachedRunner(tx, cache)
+		err := cached.Invalidate("user:1")
+		if err != nil {
+			return err
+		}
+
+		if !cache.has("user:1") {
+			t.Fatal("expected the key to be deleted only after the commit")
+		}
+		if name := selectUserName(ctx, t, cached, 1); name != "Jane" {
+			t.Fatalf("expected the invalidated key to bypass the cache, got %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if cache.has("user:1") {
+		t.Fatal("expected the key to be deleted and not refilled after the commit")
+	}
+	if len(cache.deleted) != 1 || cache.deleted[0] != "user:1" {
+		t.Fatalf("unexpected deleted keys: %v", cache.deleted)
+	}
+}
+
+func TestCachedRunner_AfterFailedAttempt(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	cache := newMemCache()
+	cache.Set(ctx, "user:1", mustEncode(t, "John"))
+	cache.Set(ctx, "user:2", mustEncode(t, "Jane"))
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		cached := NewCachedRunner(tx, cache)
+
+		err := Attempt(ctx, tx, func(tx *Tx) error {
+			err := cached.Invalidate("user:1")
+			if err != nil {
+				return err
+			}
+			return errors.New("fake error")
+		})
+		if err == nil {
+			t.Fatal("expected the attempt to fail")
+		}
+
+		return cached.Invalidate("user:2")
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if cache.has("user:2") {
+		t.Fatalf("expected the key invalidated after the failed attempt to be deleted")
+	}
+}
+
+func TestCachedRunner_RequiresManagedTx(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := NewCachedRunner(db, newMemCache()).Select(context.Background(), "user:1", "SELECT 1")
+	if !errors.Is(err, ErrTxNotManaged) {
+		t.Fatalf("expected ErrTxNotManaged, got %v", err)
+	}
+}
+
+func mustEncode(t *testing.T, name string) []byte {
+	t.Helper()
+
+	value, err := encodeCacheEntry(&memoResult{
+		columns: []string{"name", "NULL"},
+		rows:    [][]interface{}{{name, nil}},
+	})
+	if err != nil {
+		t.Fatalf("encodeCacheEntry failed: %v", err)
+	}
+	return value
+}
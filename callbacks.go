@@ -0,0 +1,185 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCallbacksFailed is returned, joined with their errors, when the
+// transaction was committed but BeforeCommit callbacks registered
+// with JoinOnError failed.
+var ErrCallbacksFailed = errors.New("transaction committed but some callbacks failed")
+
+// CallbackPolicy defines how the error of a BeforeCommit callback is handled.
+type CallbackPolicy int
+
+const (
+	// FailOnError rolls the transaction back and makes Run return the
+	// error, which is the default.
+	FailOnError CallbackPolicy = iota
+
+	// IgnoreOnError reports the error to the OnCallbackError
+	// hooks and commits the transaction anyway.
+	IgnoreOnError
+
+	// JoinOnError commits the transaction anyway and makes Run return
+	// the error joined with ErrCallbacksFailed and the errors of the
+	// other callbacks registered with JoinOnError.
+	JoinOnError
+)
+
+// CallbackOption configures a callback registered with BeforeCommit.
+type CallbackOption func(*beforeCommitCallback)
+
+// WithCallbackPolicy sets how the error of the callback is handled.
+func WithCallbackPolicy(policy CallbackPolicy) CallbackOption {
+	return func(c *beforeCommitCallback) {
+		c.policy = policy
+	}
+}
+
+type beforeCommitCallback struct {
+	fn     func(ctx context.Context) error
+	policy CallbackPolicy
+}
+
+// BeforeCommit registers a callback to be called right before the
+// transaction behind db is committed, after the callback of Run returns
+// successfully. If it returns an error the transaction is rolled back
+// and the error is returned by Run, unless the callback is registered
+// with a different CallbackPolicy:
+//
+//	err := ktx.BeforeCommit(tx, publishAuditLog, ktx.WithCallbackPolicy(ktx.IgnoreOnError))
+//
+// The db argument must be the runner received by the callback of Run
+// or Transaction (or a wrapper of it that implements `Unwrap() DBRunner`),
+// otherwise ErrTxNotManaged is returned.
+//
+// Callbacks are called in the order they were registered, including
+// callbacks registered by other BeforeCommit callbacks.
+func BeforeCommit(db DBRunner, fn func(ctx context.Context) error, opts ...CallbackOption) error {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	// The options are applied in place so the
+	// callback doesn't escape to the heap:
+	tx.beforeCommit = append(tx.beforeCommit, beforeCommitCallback{fn: fn})
+	callback := &tx.beforeCommit[len(tx.beforeCommit)-1]
+	for _, opt := range opts {
+		opt(callback)
+	}
+	return nil
+}
+
+// AfterCommit registers a callback to be called after the transaction
+// behind db is committed. The callback is never called if the transaction
+// is rolled back.
+//
+// The db argument must be the runner received by the callback of Run
+// or Transaction (or a wrapper of it that implements `Unwrap() DBRunner`),
+// otherwise ErrTxNotManaged is returned.
+//
+// Callbacks are called in the order they were registered.
+func AfterCommit(db DBRunner, fn func(ctx context.Context)) error {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.afterCommit = append(tx.afterCommit, fn)
+	return nil
+}
+
+// AfterRollback registers a callback to be called after the transaction
+// behind db is rolled back, with the error that caused it, which includes
+// commit errors and panics. The callback is never called if the transaction
+// is committed, nor when the commit fails with ErrCommitAmbiguous, since
+// the transaction might have been committed.
+//
+// It is useful for releasing external reservations or emitting
+// compensating metrics for the work done by the transaction.
+//
+// The db argument must be the runner received by the callback of Run
+// or Transaction (or a wrapper of it that implements `Unwrap() DBRunner`),
+// otherwise ErrTxNotManaged is returned.
+//
+// Callbacks are called in the order they were registered.
+func AfterRollback(db DBRunner, fn func(ctx context.Context, err error)) error {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.afterRollback = append(tx.afterRollback, fn)
+	return nil
+}
+
+func (tx *Tx) runAfterCommit(ctx context.Context) {
+	tx.mu.Lock()
+	callbacks := tx.afterCommit
+	tx.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(ctx)
+	}
+}
+
+func (tx *Tx) runAfterRollback(ctx context.Context, err error) {
+	tx.mu.Lock()
+	callbacks := tx.afterRollback
+	tx.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(ctx, err)
+	}
+}
+
+// runBeforeCommit returns the error of the first callback that fails the
+// transaction, and the errors of the callbacks registered with JoinOnError,
+// which should be returned once the transaction is committed.
+func (tx *Tx) runBeforeCommit(ctx context.Context) (joined []error, err error) {
+	for i := 0; ; i++ {
+		tx.mu.Lock()
+		if i >= len(tx.beforeCommit) {
+			tx.mu.Unlock()
+			return joined, nil
+		}
+		callback := tx.beforeCommit[i]
+		tx.mu.Unlock()
+
+		err := callback.fn(ctx)
+		if err == nil {
+			continue
+		}
+
+		switch callback.policy {
+		case IgnoreOnError:
+			tx.onCallbackError(ctx, err)
+		case JoinOnError:
+			joined = append(joined, err)
+		default:
+			return nil, err
+		}
+	}
+}
+
+// joinCallbackErrors builds the error returned after the commit
+// when callbacks registered with JoinOnError failed.
+func joinCallbackErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrCallbacksFailed, errors.Join(errs...))
+}
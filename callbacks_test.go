@@ -0,0 +1,356 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestAfterCommit(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should run callbacks in order after commit", func(t *testing.T) {
+		var calls []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := AfterCommit(tx, func(ctx context.Context) {
+				calls = append(calls, "first")
+			})
+			if err != nil {
+				return err
+			}
+
+			// Also works on the *sql.Tx and on nested transactions:
+			return Transaction(ctx, tx, func(sqlTx *sql.Tx) error {
+				return AfterCommit(sqlTx, func(ctx context.Context) {
+					calls = append(calls, "second")
+				})
+			})
+		}, WithHooks(Hooks{
+			OnBegin: func(ctx context.Context, tx *Tx) {
+				calls = append(calls, "begin")
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(calls) != 3 || calls[0] != "begin" || calls[1] != "first" || calls[2] != "second" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("should not run callbacks on rollback", func(t *testing.T) {
+		called := false
+		err := Transaction(ctx, db, func(tx *sql.Tx) error {
+			err := AfterCommit(tx, func(ctx context.Context) {
+				called = true
+			})
+			if err != nil {
+				return err
+			}
+			return errors.New("test error")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if called {
+			t.Fatal("callback should not be called on rollback")
+		}
+	})
+
+	t.Run("should reject transactions not started by ktx", func(t *testing.T) {
+		sqlTx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = sqlTx.Rollback() }()
+
+		err = AfterCommit(sqlTx, func(ctx context.Context) {})
+		if err != ErrTxNotManaged {
+			t.Fatalf("expected ErrTxNotManaged, got: %v", err)
+		}
+
+		err = Run(ctx, sqlTx, func(tx *Tx) error {
+			return AfterCommit(tx, func(ctx context.Context) {})
+		})
+		if err != ErrTxNotManaged {
+			t.Fatalf("expected ErrTxNotManaged, got: %v", err)
+		}
+
+		err = AfterCommit(db, func(ctx context.Context) {})
+		if err != ErrTxNotManaged {
+			t.Fatalf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+}
+
+func TestBeforeCommit(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should run callbacks before commit", func(t *testing.T) {
+		var calls []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := BeforeCommit(tx, func(ctx context.Context) error {
+				calls = append(calls, "first")
+
+				// Callbacks registered by other callbacks should also run:
+				return BeforeCommit(tx, func(ctx context.Context) error {
+					calls = append(calls, "third")
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			err = BeforeCommit(tx, func(ctx context.Context) error {
+				calls = append(calls, "second")
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			calls = append(calls, "callback")
+			return nil
+		}, WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				calls = append(calls, "commit")
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{"callback", "first", "second", "third", "commit"}
+		if len(calls) != len(expected) {
+			t.Fatalf("expected calls %v, got %v", expected, calls)
+		}
+		for i := range expected {
+			if calls[i] != expected[i] {
+				t.Fatalf("expected calls %v, got %v", expected, calls)
+			}
+		}
+	})
+
+	t.Run("should rollback if a callback fails", func(t *testing.T) {
+		testError := errors.New("test error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+
+			return BeforeCommit(tx, func(ctx context.Context) error {
+				return testError
+			})
+		})
+		if err != testError {
+			t.Fatalf("expected test error, got: %v", err)
+		}
+
+		count := countDbUsers(t, db)
+		if count != 0 {
+			t.Errorf("Expected 0 users (rollback should have occurred), got %d", count)
+		}
+	})
+}
+
+func TestAfterRollback(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should run callbacks in order with the rollback error", func(t *testing.T) {
+		errTest := errors.New("test error")
+
+		var calls []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			for _, name := range []string{"first", "second"} {
+				name := name
+				err := AfterRollback(tx, func(ctx context.Context, err error) {
+					if !errors.Is(err, errTest) {
+						t.Errorf("expected the rollback error, got: %v", err)
+					}
+					calls = append(calls, name)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return errTest
+		}, WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				calls = append(calls, "hook")
+			},
+		}))
+		if !errors.Is(err, errTest) {
+			t.Fatalf("expected the test error, got: %v", err)
+		}
+
+		if len(calls) != 3 || calls[0] != "first" || calls[1] != "second" || calls[2] != "hook" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+
+	t.Run("should not run callbacks on commit", func(t *testing.T) {
+		called := false
+		err := Transaction(ctx, db, func(tx *sql.Tx) error {
+			return AfterRollback(tx, func(ctx context.Context, err error) {
+				called = true
+			})
+		})
+		if err != nil {
+			t.Fatalf("Transaction failed: %v", err)
+		}
+		if called {
+			t.Fatal("callback should not be called on commit")
+		}
+	})
+
+	t.Run("should run callbacks on panics", func(t *testing.T) {
+		var rollbackErr error
+		func() {
+			defer func() { _ = recover() }()
+
+			_ = Run(ctx, db, func(tx *Tx) error {
+				err := AfterRollback(tx, func(ctx context.Context, err error) {
+					rollbackErr = err
+				})
+				if err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		}()
+
+		if rollbackErr == nil || rollbackErr.Error() != "panic: boom" {
+			t.Fatalf("expected the panic error, got: %v", rollbackErr)
+		}
+	})
+
+	t.Run("should reject transactions not started by ktx", func(t *testing.T) {
+		err := AfterRollback(db, func(ctx context.Context, err error) {})
+		if err != ErrTxNotManaged {
+			t.Fatalf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+}
+
+func TestCallbackPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should report and ignore errors with IgnoreOnError", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errCallback := errors.New("callback error")
+		var reported []error
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := BeforeCommit(tx, func(ctx context.Context) error {
+				return errCallback
+			}, WithCallbackPolicy(IgnoreOnError))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			return err
+		}, WithHooks(Hooks{
+			OnCallbackError: func(ctx context.Context, tx *Tx, err error) {
+				reported = append(reported, err)
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(reported) != 1 || reported[0] != errCallback {
+			t.Fatalf("expected the callback error to be reported, got: %v", reported)
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should commit and join errors with JoinOnError", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errFirst := errors.New("first error")
+		errSecond := errors.New("second error")
+		secondCalled := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := BeforeCommit(tx, func(ctx context.Context) error {
+				return errFirst
+			}, WithCallbackPolicy(JoinOnError))
+			if err != nil {
+				return err
+			}
+
+			err = BeforeCommit(tx, func(ctx context.Context) error {
+				secondCalled = true
+				return errSecond
+			}, WithCallbackPolicy(JoinOnError))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			return err
+		}, WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 3,
+			ShouldRetry: func(err error) bool { return true },
+		}))
+		if !errors.Is(err, ErrCallbacksFailed) || !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+			t.Fatalf("expected the joined callback errors, got: %v", err)
+		}
+		if !secondCalled {
+			t.Fatal("expected the callbacks after the failed one to run")
+		}
+
+		// Committed only once, despite the retry policy:
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should still fail the transaction by default", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errCallback := errors.New("callback error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := BeforeCommit(tx, func(ctx context.Context) error {
+				return errCallback
+			}, WithCallbackPolicy(FailOnError))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			return err
+		})
+		if err != errCallback {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+}
+
+func assertUserCount(t *testing.T, db *sql.DB, expected int) {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to count users: %v", err)
+	}
+	if count != expected {
+		t.Fatalf("expected %d users, got %d", expected, count)
+	}
+}
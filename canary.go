@@ -0,0 +1,63 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCanaryFailed is wrapped by the error returned by Run when the check
+// of WithCanary fails on the rehearsal of the transaction.
+var ErrCanaryFailed = errors.New("canary check failed, the transaction was not applied")
+
+// errCanaryRehearsed rolls back the rehearsal of a canary transaction.
+var errCanaryRehearsed = errors.New("canary rehearsal rolled back")
+
+// WithCanary makes Run rehearse the transaction before applying it: the
+// callback runs once on a transaction that is always rolled back, after
+// check verifies the invariants of the operation on it, and only if check
+// succeeds the callback runs again on a transaction that is committed:
+//
+//	err := ktx.Run(ctx, db, backfillBalances, ktx.WithIdempotent(), ktx.WithCanary(func(ctx context.Context, tx *ktx.Tx) error {
+//		// e.g. fails if any account would end up with a negative balance:
+//		return checkBalances(ctx, tx)
+//	}))
+//
+// It is meant for high-risk maintenance operations, where the rehearsal is
+// worth the double cost. When check fails Run returns its error wrapped
+// with ErrCanaryFailed and nothing is applied.
+//
+// Since the callback runs twice WithIdempotent must also be used, and the
+// data may change between the rehearsal and the second run. The rehearsal
+// goes through the hooks and AfterRollback callbacks of a rolled back
+// transaction and isn't retried WithRetry.
+func WithCanary(check func(ctx context.Context, tx *Tx) error) Option {
+	return func(c *config) {
+		c.canary = check
+	}
+}
+
+// rehearse runs fn and the canary check of cfg on
+// a transaction that is always rolled back.
+func rehearse(ctx context.Context, db TxBeginner, cfg *config, fn func(tx *Tx) error) error {
+	rehearsal := &Tx{config: *cfg}
+	// Only the transaction that is applied is recorded by RunWithResult:
+	rehearsal.config.result = nil
+
+	err := runAttempt(ctx, db, rehearsal, func(tx *Tx) error {
+		err := fn(tx)
+		if err != nil {
+			return err
+		}
+
+		err = cfg.canary(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrCanaryFailed, err)
+		}
+		return errCanaryRehearsed
+	})
+	if errors.Is(err, errCanaryRehearsed) {
+		return nil
+	}
+	return err
+}
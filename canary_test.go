@@ -0,0 +1,80 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithCanary(t *testing.T) {
+	ctx := context.Background()
+
+	insertJohn := func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+		return err
+	}
+
+	t.Run("should apply the transaction after a successful rehearsal", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		calls := 0
+		checked := 0
+		result, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			calls++
+			return insertJohn(tx)
+		}, WithIdempotent(), WithCanary(func(ctx context.Context, tx *Tx) error {
+			checked++
+			var count int
+			err := queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+			if err == nil && count != 1 {
+				t.Errorf("expected the check to see the changes of the rehearsal, got %d users", count)
+			}
+			return err
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if calls != 2 || checked != 1 {
+			t.Fatalf("expected the callback to run twice and the check once, got %d and %d", calls, checked)
+		}
+		if result.Attempts != 1 || result.RolledBack {
+			t.Errorf("expected the result to describe the applied transaction, got %+v", result)
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should not apply the transaction when the check fails", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errInvariant := errors.New("invariant violated")
+		calls := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			calls++
+			return insertJohn(tx)
+		}, WithIdempotent(), WithCanary(func(ctx context.Context, tx *Tx) error {
+			return errInvariant
+		}))
+		if !errors.Is(err, ErrCanaryFailed) || !errors.Is(err, errInvariant) {
+			t.Fatalf("expected ErrCanaryFailed wrapping the check error, got: %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected the callback to run only on the rehearsal, got %d calls", calls)
+		}
+		assertUserCount(t, db, 0)
+	})
+
+	t.Run("should require WithIdempotent", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, insertJohn, WithCanary(func(ctx context.Context, tx *Tx) error {
+			return nil
+		}))
+		if !errors.Is(err, ErrNotIdempotent) {
+			t.Fatalf("expected ErrNotIdempotent, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+}
@@ -0,0 +1,215 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Change describes the rows modified by a statement
+// captured with WithChangeCapture.
+type Change struct {
+	Kind  ChangeKind
+	Table string
+
+	// Rows contains the modified rows as returned by RETURNING *,
+	// indexed by column name, on the dialects that support it.
+	Rows []map[string]interface{}
+
+	// InsertIDs contains the IDs generated by INSERT statements on MySQL,
+	// which has no RETURNING.
+	InsertIDs []int64
+
+	RowsAffected int64
+}
+
+// WithChangeCapture records the rows modified by the INSERT, UPDATE and
+// DELETE statements executed with ExecContext, which are available on
+// tx.Changes() for hooks and callbacks, e.g. for lightweight change data
+// capture or for priming caches after the commit.
+//
+// On Postgres and SQLite `RETURNING *` is appended to the statements, and
+// `OUTPUT INSERTED.*` is added on SQL Server, which are then executed
+// as queries, so the LastInsertId of their
+// results is not available. On MySQL only the IDs generated by inserts
+// are captured, based on LastInsertId and RowsAffected, and on Oracle
+// only the number of affected rows is captured.
+//
+// Statements that already have a RETURNING or OUTPUT clause are not captured.
+func WithChangeCapture(dialect Dialect) Option {
+	return func(c *config) {
+		c.changeCapture = dialect
+	}
+}
+
+// Changes returns the changes captured so far when the
+// transaction was started with WithChangeCapture.
+func (tx *Tx) Changes() []Change {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return append([]Change(nil), tx.changes...)
+}
+
+// captureRunner is the runner that implements WithChangeCapture.
+type captureRunner struct {
+	next    DBRunner
+	tx      *Tx
+	dialect Dialect
+}
+
+func (r captureRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	kind, table, ok := parseChange(query)
+	if !ok {
+		return r.next.ExecContext(ctx, query, args...)
+	}
+
+	if r.dialect.Name() == MySQL.Name() || r.dialect.Name() == Oracle.Name() {
+		return r.execTrackingIDs(ctx, kind, table, query, args)
+	}
+
+	rows, err := r.next.QueryContext(ctx, returningQuery(r.dialect, query, []string{"*"}), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	change := Change{
+		Kind:  kind,
+		Table: table,
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		change.Rows = append(change.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	change.RowsAffected = int64(len(change.Rows))
+	r.tx.recordChange(change)
+	r.tx.recordCapturedExec(change.RowsAffected)
+
+	return capturedResult{rowsAffected: change.RowsAffected}, nil
+}
+
+func (r captureRunner) execTrackingIDs(ctx context.Context, kind ChangeKind, table string, query string, args []interface{}) (sql.Result, error) {
+	result, err := r.next.ExecContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+
+	change := Change{
+		Kind:  kind,
+		Table: table,
+	}
+	change.RowsAffected, _ = result.RowsAffected()
+
+	if kind == ChangeInsert && r.dialect.Name() == MySQL.Name() {
+		// MySQL returns the ID of the first row of multi-row inserts,
+		// and the following rows get consecutive IDs:
+		firstID, err := result.LastInsertId()
+		if err == nil && firstID > 0 {
+			for i := int64(0); i < change.RowsAffected; i++ {
+				change.InsertIDs = append(change.InsertIDs, firstID+i)
+			}
+		}
+	}
+
+	r.tx.recordChange(change)
+	return result, nil
+}
+
+func (r captureRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r captureRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (tx *Tx) recordChange(c Change) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.changes = append(tx.changes, c)
+}
+
+// parseChange extracts the kind and the table of INSERT, UPDATE
+// and DELETE statements without a RETURNING or OUTPUT clause.
+func parseChange(query string) (kind ChangeKind, table string, ok bool) {
+	tokens := tokenize(query)
+	if len(tokens) < 2 {
+		return "", "", false
+	}
+	for i, tok := range tokens {
+		if tok.text == "returning" {
+			return "", "", false
+		}
+		// The OUTPUT clause of SQL Server:
+		if tok.text == "output" && i+1 < len(tokens) && (tokens[i+1].text == "inserted" || tokens[i+1].text == "deleted") {
+			return "", "", false
+		}
+	}
+
+	var tableIdx int
+	switch tokens[0].text {
+	case "insert":
+		kind, tableIdx = ChangeInsert, 1
+		if tokens[1].text == "into" {
+			tableIdx = 2
+		}
+	case "update":
+		kind, tableIdx = ChangeUpdate, 1
+	case "delete":
+		kind, tableIdx = ChangeDelete, 1
+		if tokens[1].text == "from" {
+			tableIdx = 2
+		}
+	default:
+		return "", "", false
+	}
+
+	if tableIdx >= len(tokens) || tokens[tableIdx].kind != wordToken {
+		return "", "", false
+	}
+	table = tokens[tableIdx].text
+
+	// Qualified names such as schema.table:
+	for i := tableIdx + 1; i+1 < len(tokens) && tokens[i].text == "." && tokens[i+1].kind == wordToken; i += 2 {
+		table += "." + tokens[i+1].text
+	}
+
+	return kind, table, true
+}
+
+type capturedResult struct {
+	rowsAffected int64
+}
+
+func (r capturedResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not available for statements captured with WithChangeCapture")
+}
+
+func (r capturedResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
@@ -0,0 +1,93 @@
+package ktx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseChange(t *testing.T) {
+	tests := []struct {
+		query string
+		kind  ChangeKind
+		table string
+		ok    bool
+	}{
+		{query: "INSERT INTO users (name) VALUES (?)", kind: ChangeInsert, table: "users", ok: true},
+		{query: "update public.users SET name = ?", kind: ChangeUpdate, table: "public.users", ok: true},
+		{query: `DELETE FROM "Users" WHERE id = ?`, kind: ChangeDelete, table: `"Users"`, ok: true},
+		{query: "INSERT INTO users (name) VALUES (?) RETURNING id", ok: false},
+		{query: "SELECT * FROM users", ok: false},
+		{query: "CREATE TABLE foo (id INTEGER)", ok: false},
+	}
+
+	for _, test := range tests {
+		kind, table, ok := parseChange(test.query)
+		if ok != test.ok || kind != test.kind || table != test.table {
+			t.Errorf("parseChange(%q): expected (%q, %q, %v), got (%q, %q, %v)",
+				test.query, test.kind, test.table, test.ok, kind, table, ok)
+		}
+	}
+}
+
+func TestWithChangeCapture(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var changes []Change
+	err := Run(ctx, db, func(tx *Tx) error {
+		result, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?), (?, ?)", "John", "capture1@example.com", "Jane", "capture2@example.com")
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n != 2 {
+			t.Errorf("expected 2 rows affected, got %d", n)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE users SET name = ? WHERE email = ?;", "Johnny", "capture1@example.com")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM users WHERE email = ?", "capture2@example.com")
+		if err != nil {
+			return err
+		}
+
+		// Not captured:
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	}, WithChangeCapture(SQLite), WithHooks(Hooks{
+		OnCommit: func(ctx context.Context, tx *Tx) {
+			changes = tx.Changes()
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got: %+v", changes)
+	}
+
+	insert := changes[0]
+	if insert.Kind != ChangeInsert || insert.Table != "users" || insert.RowsAffected != 2 || len(insert.Rows) != 2 {
+		t.Errorf("unexpected insert change: %+v", insert)
+	} else if insert.Rows[0]["name"] != "John" || insert.Rows[1]["email"] != "capture2@example.com" || insert.Rows[0]["id"] == nil {
+		t.Errorf("unexpected inserted rows: %+v", insert.Rows)
+	}
+
+	update := changes[1]
+	if update.Kind != ChangeUpdate || len(update.Rows) != 1 || update.Rows[0]["name"] != "Johnny" {
+		t.Errorf("unexpected update change: %+v", update)
+	}
+
+	del := changes[2]
+	if del.Kind != ChangeDelete || len(del.Rows) != 1 || del.Rows[0]["email"] != "capture2@example.com" {
+		t.Errorf("unexpected delete change: %+v", del)
+	}
+}
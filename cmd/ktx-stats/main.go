@@ -0,0 +1,51 @@
+// Command ktx-stats reads the JSON lines written by ktx.EventLog and
+// prints aggregates that point to the transactions worth looking at:
+// the slowest ones by p99 duration, the ones with the highest rollback
+// rates and the ones retried the most.
+//
+// It reads the files given as arguments, or the standard input if none:
+//
+//	go run github.com/vingarcia/ktx/cmd/ktx-stats -top 5 transactions.jsonl
+//
+// Transactions are grouped by the name given with ktx.WithName.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	top := flag.Int("top", 10, "how many transaction names to list on each section, 0 for all")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		var readers []io.Reader
+		for _, path := range flag.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ktx-stats: %s\n", err)
+				os.Exit(1)
+			}
+			defer func() { _ = f.Close() }()
+
+			readers = append(readers, f)
+		}
+		input = io.MultiReader(readers...)
+	}
+
+	rep, err := analyze(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ktx-stats: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = rep.print(os.Stdout, *top)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ktx-stats: error writing output: %s\n", err)
+		os.Exit(1)
+	}
+}
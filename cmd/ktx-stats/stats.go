@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// unnamed is how the transactions without ktx.WithName are reported.
+const unnamed = "(unnamed)"
+
+// nameStats aggregates the events of the transactions with the same name.
+type nameStats struct {
+	Name      string
+	Count     int
+	Rollbacks int
+	Failures  int
+	Retries   int
+	Durations []time.Duration
+}
+
+func (s *nameStats) add(event ktx.TxEvent) {
+	s.Count++
+	switch event.Outcome {
+	case "commit":
+	case "rollback":
+		s.Rollbacks++
+	default:
+		s.Failures++
+	}
+	if event.Attempts > 1 {
+		s.Retries += event.Attempts - 1
+	}
+	s.Durations = append(s.Durations, time.Duration(event.DurationMS*float64(time.Millisecond)))
+}
+
+// percentile must be called after the Durations are sorted.
+func (s *nameStats) percentile(p float64) time.Duration {
+	i := int(p * float64(len(s.Durations)-1))
+	return s.Durations[i]
+}
+
+type report struct {
+	Total     nameStats
+	Names     []*nameStats
+	Malformed int
+}
+
+// analyze reads the JSON lines written by ktx.EventLog, skipping
+// the malformed ones so a truncated line doesn't stop the analysis.
+func analyze(r io.Reader) (report, error) {
+	rep := report{Total: nameStats{Name: "total"}}
+	byName := map[string]*nameStats{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event ktx.TxEvent
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			rep.Malformed++
+			continue
+		}
+
+		name := event.Name
+		if name == "" {
+			name = unnamed
+		}
+		stats := byName[name]
+		if stats == nil {
+			stats = &nameStats{Name: name}
+			byName[name] = stats
+			rep.Names = append(rep.Names, stats)
+		}
+		stats.add(event)
+		rep.Total.add(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return report{}, fmt.Errorf("error reading events: %w", err)
+	}
+
+	for _, stats := range append(rep.Names, &rep.Total) {
+		sort.Slice(stats.Durations, func(i, j int) bool {
+			return stats.Durations[i] < stats.Durations[j]
+		})
+	}
+
+	return rep, nil
+}
+
+// print writes the overall numbers followed by the top transaction
+// names by p99 duration, by rollback rate and by retries.
+func (rep report) print(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%d transactions, %.1f%% rolled back, %d failed, %d retries",
+		rep.Total.Count, 100*rollbackRate(&rep.Total), rep.Total.Failures, rep.Total.Retries)
+	if rep.Malformed > 0 {
+		fmt.Fprintf(tw, ", %d malformed lines skipped", rep.Malformed)
+	}
+	fmt.Fprintln(tw)
+	if rep.Total.Count == 0 {
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw, "\nSlowest transactions:")
+	fmt.Fprintln(tw, "NAME\tCOUNT\tP50\tP99\tMAX")
+	for _, s := range topBy(rep.Names, top, func(s *nameStats) float64 { return float64(s.percentile(0.99)) }) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", s.Name, s.Count,
+			roundDuration(s.percentile(0.5)), roundDuration(s.percentile(0.99)), roundDuration(s.percentile(1)))
+	}
+
+	fmt.Fprintln(tw, "\nHighest rollback rates:")
+	fmt.Fprintln(tw, "NAME\tCOUNT\tROLLBACKS\tRATE")
+	for _, s := range topBy(rep.Names, top, rollbackRate) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\n", s.Name, s.Count, s.Rollbacks, 100*rollbackRate(s))
+	}
+
+	fmt.Fprintln(tw, "\nRetry hotspots:")
+	fmt.Fprintln(tw, "NAME\tCOUNT\tRETRIES\tPER TX")
+	for _, s := range topBy(rep.Names, top, func(s *nameStats) float64 { return float64(s.Retries) }) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\n", s.Name, s.Count, s.Retries, float64(s.Retries)/float64(s.Count))
+	}
+
+	return tw.Flush()
+}
+
+// rollbackRate is the fraction of the transactions that were rolled back.
+func rollbackRate(s *nameStats) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Rollbacks) / float64(s.Count)
+}
+
+// topBy returns the top stats by key, ignoring the ones with a zero key,
+// with ties broken by name so the output is stable.
+func topBy(stats []*nameStats, top int, key func(s *nameStats) float64) []*nameStats {
+	var sorted []*nameStats
+	for _, s := range stats {
+		if key(s) > 0 {
+			sorted = append(sorted, s)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		ki, kj := key(sorted[i]), key(sorted[j])
+		if ki != kj {
+			return ki > kj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+	return sorted
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const events = `{"id":"1","name":"create-user","duration_ms":10,"attempts":1,"statements":2,"outcome":"commit"}
+{"id":"2","name":"create-user","duration_ms":30,"attempts":3,"statements":2,"outcome":"commit"}
+{"id":"3","name":"transfer","duration_ms":250,"attempts":2,"statements":4,"outcome":"rollback","error":"fake error"}
+{"id":"4","duration_ms":1,"attempts":1,"statements":1,"outcome":"error","error":"error starting transaction"}
+{"id":"5","name":"trunc
+
+`
+
+func TestAnalyze(t *testing.T) {
+	rep, err := analyze(strings.NewReader(events))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rep.Total.Count != 4 || rep.Total.Rollbacks != 1 || rep.Total.Failures != 1 || rep.Total.Retries != 3 {
+		t.Errorf("unexpected totals: %+v", rep.Total)
+	}
+	if rep.Malformed != 1 {
+		t.Errorf("expected 1 malformed line, got %d", rep.Malformed)
+	}
+
+	if len(rep.Names) != 3 {
+		t.Fatalf("expected 3 names, got %d", len(rep.Names))
+	}
+	createUser := rep.Names[0]
+	if createUser.Name != "create-user" || createUser.Count != 2 || createUser.Retries != 2 {
+		t.Errorf("unexpected create-user stats: %+v", createUser)
+	}
+	if createUser.percentile(0.5) != 10*time.Millisecond || createUser.percentile(1) != 30*time.Millisecond {
+		t.Errorf("unexpected create-user durations: %v", createUser.Durations)
+	}
+	if rep.Names[2].Name != unnamed {
+		t.Errorf("expected the transactions without a name to be grouped as %s, got: %s", unnamed, rep.Names[2].Name)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	rep, err := analyze(strings.NewReader(events))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = rep.print(&buf, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `4 transactions, 25.0% rolled back, 1 failed, 3 retries, 1 malformed lines skipped
+
+Slowest transactions:
+NAME      COUNT  P50    P99    MAX
+transfer  1      250ms  250ms  250ms
+
+Highest rollback rates:
+NAME      COUNT  ROLLBACKS  RATE
+transfer  1      1          100.0%
+
+Retry hotspots:
+NAME         COUNT  RETRIES  PER TX
+create-user  2      2        1.00
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
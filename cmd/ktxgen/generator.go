@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const ktxImportPath = "github.com/vingarcia/ktx"
+
+type method struct {
+	name    string
+	params  []param
+	results []string
+	options []string
+
+	ctxParam    int
+	runnerParam int
+	transact    bool
+}
+
+type param struct {
+	typ      string
+	variadic bool
+}
+
+// generate parses the package on dir and returns the code of the
+// decorator for the interface typeName.
+func generate(dir string, typeName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		iface := findInterface(file, typeName)
+		if iface == nil {
+			continue
+		}
+
+		return generateForInterface(file, typeName, iface)
+	}
+
+	return nil, fmt.Errorf("interface %s not found on %s", typeName, dir)
+}
+
+func findInterface(file *ast.File, typeName string) *ast.InterfaceType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != typeName {
+				continue
+			}
+			if iface, ok := ts.Type.(*ast.InterfaceType); ok {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+func generateForInterface(file *ast.File, typeName string, iface *ast.InterfaceType) ([]byte, error) {
+	imports := map[string]string{}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = path
+	}
+
+	ktxName := "ktx"
+	contextName := "context"
+	for name, path := range imports {
+		switch path {
+		case ktxImportPath:
+			ktxName = name
+		case "context":
+			contextName = name
+		}
+	}
+
+	usedImports := map[string]bool{}
+	var methods []method
+	for _, field := range iface.Methods.List {
+		fnType, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("embedded interfaces are not supported on %s", typeName)
+		}
+
+		m := method{
+			name:        field.Names[0].Name,
+			ctxParam:    -1,
+			runnerParam: -1,
+		}
+
+		skip := false
+		for _, line := range commentLines(field.Doc) {
+			switch {
+			case line == "ktx:skip":
+				skip = true
+			case strings.HasPrefix(line, "ktx:option "):
+				m.options = append(m.options, strings.TrimSpace(strings.TrimPrefix(line, "ktx:option ")))
+			}
+		}
+
+		for _, f := range fnType.Params.List {
+			collectPackages(f.Type, usedImports)
+
+			typ := f.Type
+			variadic := false
+			if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+				typ = ellipsis.Elt
+				variadic = true
+			}
+
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				typeStr := types.ExprString(typ)
+				switch typeStr {
+				case contextName + ".Context":
+					if m.ctxParam == -1 {
+						m.ctxParam = len(m.params)
+					}
+				case ktxName + ".DBRunner":
+					if m.runnerParam == -1 {
+						m.runnerParam = len(m.params)
+					}
+				}
+				m.params = append(m.params, param{typ: typeStr, variadic: variadic})
+			}
+		}
+
+		if fnType.Results != nil {
+			for _, f := range fnType.Results.List {
+				collectPackages(f.Type, usedImports)
+
+				n := len(f.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					m.results = append(m.results, types.ExprString(f.Type))
+				}
+			}
+		}
+
+		returnsError := len(m.results) > 0 && m.results[len(m.results)-1] == "error"
+		m.transact = !skip && m.ctxParam != -1 && m.runnerParam != -1 && returnsError
+		if m.transact {
+			usedImports[ktxName] = true
+		}
+
+		methods = append(methods, m)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ktxgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", file.Name.Name)
+
+	usedImports[ktxName] = true
+	var importNames []string
+	for name := range usedImports {
+		importNames = append(importNames, name)
+	}
+	sort.Strings(importNames)
+
+	buf.WriteString("import (\n")
+	for _, name := range importNames {
+		path, ok := imports[name]
+		if !ok {
+			if name != ktxName {
+				return nil, fmt.Errorf("import for package %s not found", name)
+			}
+			path = ktxImportPath
+		}
+		if filepath.Base(path) == name {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		} else {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, path)
+		}
+	}
+	buf.WriteString(")\n\n")
+
+	decorator := typeName + "Tx"
+	fmt.Fprintf(&buf, "// %s decorates a %s running each of its methods\n", decorator, typeName)
+	fmt.Fprintf(&buf, "// that receive a %s.DBRunner inside a ktx transaction.\n", ktxName)
+	fmt.Fprintf(&buf, "type %s struct {\n", decorator)
+	fmt.Fprintf(&buf, "\tnext %s\n", typeName)
+	fmt.Fprintf(&buf, "\topts []%s.Option\n", ktxName)
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "// New%s creates a %s, the input Options apply\n", decorator, decorator)
+	buf.WriteString("// to the transactions of all methods.\n")
+	fmt.Fprintf(&buf, "func New%s(next %s, opts ...%s.Option) *%s {\n", decorator, typeName, ktxName, decorator)
+	fmt.Fprintf(&buf, "\treturn &%s{next: next, opts: opts}\n", decorator)
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(&buf, "var _ %s = (*%s)(nil)\n", typeName, decorator)
+
+	for _, m := range methods {
+		writeMethod(&buf, decorator, ktxName, m)
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated code: %w\n%s", err, buf.String())
+	}
+	return code, nil
+}
+
+func writeMethod(buf *bytes.Buffer, decorator string, ktxName string, m method) {
+	var params, callArgs []string
+	for i, p := range m.params {
+		name := "p" + strconv.Itoa(i)
+		if p.variadic {
+			params = append(params, name+" ..."+p.typ)
+			callArgs = append(callArgs, name+"...")
+			continue
+		}
+		params = append(params, name+" "+p.typ)
+		callArgs = append(callArgs, name)
+	}
+
+	var results, resultNames []string
+	for i, r := range m.results {
+		name := "r" + strconv.Itoa(i)
+		results = append(results, name+" "+r)
+		resultNames = append(resultNames, name)
+	}
+
+	resultsStr := ""
+	if len(results) > 0 {
+		resultsStr = "(" + strings.Join(results, ", ") + ")"
+	}
+
+	fmt.Fprintf(buf, "\n// %s implements the wrapped interface.\n", m.name)
+	fmt.Fprintf(buf, "func (d *%s) %s(%s) %s {\n", decorator, m.name, strings.Join(params, ", "), resultsStr)
+
+	if !m.transact {
+		if len(resultNames) > 0 {
+			buf.WriteString("\treturn ")
+		} else {
+			buf.WriteString("\t")
+		}
+		fmt.Fprintf(buf, "d.next.%s(%s)\n", m.name, strings.Join(callArgs, ", "))
+		buf.WriteString("}\n")
+		return
+	}
+
+	ctxName := "p" + strconv.Itoa(m.ctxParam)
+	runnerName := "p" + strconv.Itoa(m.runnerParam)
+
+	txArgs := append([]string(nil), callArgs...)
+	txArgs[m.runnerParam] = "tx"
+
+	errName := resultNames[len(resultNames)-1]
+
+	optsExpr := "d.opts"
+	if len(m.options) > 0 {
+		optsExpr = fmt.Sprintf("append(append([]%s.Option{}, d.opts...), %s)", ktxName, strings.Join(m.options, ", "))
+	}
+
+	fmt.Fprintf(buf, "\t%s = %s.Run(%s, %s, func(tx *%s.Tx) error {\n", errName, ktxName, ctxName, runnerName, ktxName)
+	fmt.Fprintf(buf, "\t\t%s = d.next.%s(%s)\n", strings.Join(resultNames, ", "), m.name, strings.Join(txArgs, ", "))
+	fmt.Fprintf(buf, "\t\treturn %s\n", errName)
+	fmt.Fprintf(buf, "\t}, %s...)\n", optsExpr)
+	fmt.Fprintf(buf, "\treturn %s\n", strings.Join(resultNames, ", "))
+	buf.WriteString("}\n")
+}
+
+func commentLines(group *ast.CommentGroup) []string {
+	if group == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, c := range group.List {
+		text := strings.TrimPrefix(c.Text, "//")
+		text = strings.TrimPrefix(text, "/*")
+		text = strings.TrimSuffix(text, "*/")
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+func collectPackages(expr ast.Expr, used map[string]bool) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return false
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "service")
+	golden := filepath.Join(dir, "userservice_ktx.go.golden")
+
+	code, err := generate(dir, "UserService")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *update {
+		err := os.WriteFile(golden, code, 0o644)
+		if err != nil {
+			t.Fatalf("error updating golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("error reading golden file: %v", err)
+	}
+
+	if string(code) != string(expected) {
+		t.Fatalf("generated code differs from %s, run the tests with -update to see the diff:\n%s", golden, code)
+	}
+}
+
+func TestGenerate_InterfaceNotFound(t *testing.T) {
+	_, err := generate(filepath.Join("testdata", "service"), "Missing")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,59 @@
+// Command ktxgen generates decorators for interfaces whose methods receive
+// a ktx.DBRunner, wrapping each method call in a ktx transaction.
+//
+// It is meant to be used with go:generate:
+//
+//	//go:generate go run github.com/vingarcia/ktx/cmd/ktxgen -type=UserService
+//	type UserService interface {
+//		// ktx:option ktx.WithMetadata("operation", "create-user")
+//		CreateUser(ctx context.Context, db ktx.DBRunner, name string) error
+//
+//		// ktx:skip
+//		Ping(ctx context.Context) error
+//	}
+//
+// This generates the UserServiceTx type and the NewUserServiceTx constructor.
+// Each method that receives a context.Context and a ktx.DBRunner runs the
+// wrapped method inside ktx.Run, passing the transaction in place of the
+// DBRunner. Other methods, and methods marked with `ktx:skip`, are
+// forwarded without starting a transaction.
+//
+// Comments in the form `ktx:option <expression>` append the expression
+// to the Options used for the transaction of that method.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the interface to decorate (required)")
+	dir := flag.String("dir", ".", "directory of the package containing the interface")
+	output := flag.String("output", "", "output file name, defaults to <type>_ktx.go")
+	flag.Parse()
+
+	if *typeName == "" {
+		fmt.Fprintln(os.Stderr, "ktxgen: the -type flag is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_ktx.go"
+	}
+
+	code, err := generate(*dir, *typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ktxgen: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(*output, code, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ktxgen: error writing output file: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+
+	"github.com/vingarcia/ktx"
+)
+
+type User struct {
+	ID   int
+	Name string
+}
+
+type UserService interface {
+	// ktx:option ktx.WithMetadata("operation", "create-user")
+	CreateUser(ctx context.Context, db ktx.DBRunner, name string) (User, error)
+
+	DeleteUsers(ctx context.Context, db ktx.DBRunner, ids ...int) error
+
+	// ktx:skip
+	CountUsers(ctx context.Context, db ktx.DBRunner) (int, error)
+
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,107 @@
+package ktx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// ErrCommitAmbiguous is returned, wrapping the error of the commit, when
+// the connection to the database fails during the commit, in which case
+// the transaction may or may not have been committed.
+//
+// Operations that can't be safely repeated, such as money transfers,
+// should verify their outcome before retrying, e.g. with WithCommitVerifier.
+//
+// Neither the AfterCommit nor the AfterRollback callbacks are called for
+// these transactions, and only the OnCommitAmbiguous hooks are.
+var ErrCommitAmbiguous = errors.New("the outcome of the commit is unknown")
+
+// CommitVerifier checks whether a transaction whose commit failed with
+// ErrCommitAmbiguous was committed, usually by looking for a row it wrote.
+// It must use a connection other than the one of the transaction.
+type CommitVerifier func(ctx context.Context) (committed bool, err error)
+
+// WithCommitVerifier sets a CommitVerifier that is called when the commit
+// of the transaction fails with ErrCommitAmbiguous, e.g.:
+//
+//	ktx.WithCommitVerifier(func(ctx context.Context) (bool, error) {
+//		var exists bool
+//		err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM transfers WHERE id = $1)", transferID).Scan(&exists)
+//		return exists, err
+//	})
+//
+// If it reports that the transaction was committed the commit is handled as
+// successful, including the AfterCommit callbacks and the OnCommit hooks,
+// and if it reports that it wasn't the error of the commit is returned
+// without ErrCommitAmbiguous.
+func WithCommitVerifier(verifier CommitVerifier) Option {
+	return func(c *config) {
+		c.commitVerifier = verifier
+	}
+}
+
+// checkCommitErr classifies the error of the commit, returning nil if
+// the CommitVerifier reports that the transaction was committed anyway.
+func (tx *Tx) checkCommitErr(ctx context.Context, err error) error {
+	if !isConnectionError(err) {
+		return err
+	}
+
+	if tx.cfg.commitVerifier == nil {
+		return fmt.Errorf("%w: %w", ErrCommitAmbiguous, err)
+	}
+
+	committed, verifyErr := tx.cfg.commitVerifier(context.WithoutCancel(ctx))
+	if verifyErr != nil {
+		return fmt.Errorf("%w: %w, error verifying the commit: %w", ErrCommitAmbiguous, err, verifyErr)
+	}
+	if committed {
+		return nil
+	}
+	return fmt.Errorf("transaction verified as not committed: %w", err)
+}
+
+// isConnectionError reports whether err means that the connection
+// was lost, or the operation timed out, while waiting for the database.
+func isConnectionError(err error) bool {
+	if errors.Is(err, ErrCommitTimeout) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// The connection_exception class of SQLSTATEs:
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) && strings.HasPrefix(stateErr.SQLState(), "08") {
+		return true
+	}
+
+	msg := err.Error()
+	for _, s := range []string{
+		"connection reset",
+		"broken pipe",
+		"bad connection",
+		"unexpected EOF",
+		"SQLSTATE 08",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
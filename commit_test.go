@@ -0,0 +1,147 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommitAmbiguous(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should report connection errors on commit as ambiguous", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		})
+		if !errors.Is(err, ErrCommitAmbiguous) {
+			t.Fatalf("expected ErrCommitAmbiguous, got: %v", err)
+		}
+		if !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("expected the error of the commit to be wrapped, got: %v", err)
+		}
+	})
+
+	t.Run("should not run the rollback callbacks of ambiguous commits", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		var afterRollback, onRollback, onCommit bool
+		var ambiguousErr error
+		err := Run(ctx, db, func(tx *Tx) error {
+			return AfterRollback(tx, func(ctx context.Context, err error) {
+				afterRollback = true
+			})
+		}, WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				onCommit = true
+			},
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				onRollback = true
+			},
+			OnCommitAmbiguous: func(ctx context.Context, tx *Tx, err error) {
+				ambiguousErr = err
+			},
+		}))
+		if !errors.Is(err, ErrCommitAmbiguous) {
+			t.Fatalf("expected ErrCommitAmbiguous, got: %v", err)
+		}
+		if afterRollback || onRollback || onCommit {
+			t.Errorf("expected no commit or rollback callbacks, got afterRollback: %v, onRollback: %v, onCommit: %v", afterRollback, onRollback, onCommit)
+		}
+		if !errors.Is(ambiguousErr, ErrCommitAmbiguous) {
+			t.Errorf("expected OnCommitAmbiguous to receive ErrCommitAmbiguous, got: %v", ambiguousErr)
+		}
+	})
+
+	t.Run("should not report other commit errors as ambiguous", func(t *testing.T) {
+		errTest := errors.New("serialization failure")
+		db := sql.OpenDB(&procedureConnector{commitErr: errTest})
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		})
+		if !errors.Is(err, errTest) || errors.Is(err, ErrCommitAmbiguous) {
+			t.Fatalf("expected the plain commit error, got: %v", err)
+		}
+	})
+
+	t.Run("should treat commits verified as committed as successful", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		var afterCommit, onCommit bool
+		err := Run(ctx, db, func(tx *Tx) error {
+			return AfterCommit(tx, func(ctx context.Context) {
+				afterCommit = true
+			})
+		}, WithCommitVerifier(func(ctx context.Context) (bool, error) {
+			return true, nil
+		}), WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				onCommit = true
+			},
+		}))
+		if err != nil {
+			t.Fatalf("expected the verified commit to succeed, got: %v", err)
+		}
+		if !afterCommit || !onCommit {
+			t.Errorf("expected the commit callbacks and hooks to run, got AfterCommit: %v, OnCommit: %v", afterCommit, onCommit)
+		}
+	})
+
+	t.Run("should return the commit error when verified as not committed", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		var rolledBack bool
+		err := Run(ctx, db, func(tx *Tx) error {
+			return AfterRollback(tx, func(ctx context.Context, err error) {
+				rolledBack = true
+			})
+		}, WithCommitVerifier(func(ctx context.Context) (bool, error) {
+			return false, nil
+		}))
+		if !errors.Is(err, driver.ErrBadConn) || errors.Is(err, ErrCommitAmbiguous) {
+			t.Fatalf("expected the unambiguous commit error, got: %v", err)
+		}
+		if !rolledBack {
+			t.Error("expected the AfterRollback callbacks to run")
+		}
+	})
+
+	t.Run("should keep the commit ambiguous when the verification fails", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		errVerify := errors.New("verification error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithCommitVerifier(func(ctx context.Context) (bool, error) {
+			return false, errVerify
+		}))
+		if !errors.Is(err, ErrCommitAmbiguous) || !errors.Is(err, errVerify) {
+			t.Fatalf("expected ErrCommitAmbiguous and the verification error, got: %v", err)
+		}
+	})
+
+	t.Run("should not retry ambiguous commits", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{commitErr: driver.ErrBadConn})
+
+		calls := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			calls++
+			return nil
+		}, WithRetry(RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     func(attempt int) time.Duration { return 0 },
+			ShouldRetry: func(err error) bool { return true },
+		}), WithIdempotent())
+		if !errors.Is(err, ErrCommitAmbiguous) {
+			t.Fatalf("expected ErrCommitAmbiguous, got: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected a single attempt, got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package ktx
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Config is a declarative alternative to the Options for the settings that
+// ops teams usually tune, which can be decoded from YAML, JSON or environment
+// variables instead of being hardcoded:
+//
+//	# transactions.yaml
+//	isolation: serializable
+//	retry:
+//	  max_attempts: 3
+//	timeouts:
+//	  statement: 2s
+//	  commit: 5s
+//
+//	var cfg ktx.Config
+//	err := yaml.Unmarshal(data, &cfg)
+//	// ...
+//	err = ktx.Run(ctx, db, fn, cfg.Option(), ktx.WithIdempotent())
+//
+// The zero value of each field keeps the default behavior, and since
+// Config.Option is a regular Option it can be combined with others,
+// the ones passed after it taking precedence.
+type Config struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Isolation is the isolation level of the transactions,
+	// decoded from names such as "serializable" or "read committed".
+	Isolation Isolation `json:"isolation" yaml:"isolation"`
+
+	ReadOnly bool `json:"read_only" yaml:"read_only"`
+
+	Retry RetryConfig `json:"retry" yaml:"retry"`
+
+	Timeouts TimeoutsConfig `json:"timeouts" yaml:"timeouts"`
+
+	// Hooks and Logger can't be decoded and must be set from code.
+	Hooks Hooks `json:"-" yaml:"-"`
+
+	// Logger receives the timeline of each transaction described on
+	// WithDebug, it can be e.g. the Writer of a *log.Logger.
+	Logger io.Writer `json:"-" yaml:"-"`
+}
+
+// RetryConfig is the part of a Config that enables WithRetry, which still
+// requires the transaction to be marked as safe to retry WithIdempotent.
+type RetryConfig struct {
+	// MaxAttempts enables the retries when greater than 1.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+
+	RollbackReserve Duration `json:"rollback_reserve" yaml:"rollback_reserve"`
+}
+
+// TimeoutsConfig is the part of a Config that sets the
+// WithStatementTimeout, WithCommitTimeout, WithRollbackTimeout
+// and WithLeakTimeout of the transaction.
+type TimeoutsConfig struct {
+	Statement Duration `json:"statement" yaml:"statement"`
+	Commit    Duration `json:"commit" yaml:"commit"`
+	Rollback  Duration `json:"rollback" yaml:"rollback"`
+	Leak      Duration `json:"leak" yaml:"leak"`
+}
+
+// Option converts the Config into an Option.
+func (c Config) Option() Option {
+	var opts []Option
+	if c.Name != "" {
+		opts = append(opts, WithName(c.Name))
+	}
+	if c.Isolation != Isolation(sql.LevelDefault) {
+		opts = append(opts, WithIsolation(sql.IsolationLevel(c.Isolation)))
+	}
+	if c.ReadOnly {
+		opts = append(opts, WithReadOnly())
+	}
+	if c.Retry.MaxAttempts > 1 {
+		opts = append(opts, WithRetry(RetryPolicy{
+			MaxAttempts:     c.Retry.MaxAttempts,
+			RollbackReserve: time.Duration(c.Retry.RollbackReserve),
+		}))
+	}
+	if c.Timeouts.Statement > 0 {
+		opts = append(opts, WithStatementTimeout(time.Duration(c.Timeouts.Statement)))
+	}
+	if c.Timeouts.Commit > 0 {
+		opts = append(opts, WithCommitTimeout(time.Duration(c.Timeouts.Commit)))
+	}
+	if c.Timeouts.Rollback > 0 {
+		opts = append(opts, WithRollbackTimeout(time.Duration(c.Timeouts.Rollback)))
+	}
+	if c.Timeouts.Leak > 0 {
+		opts = append(opts, WithLeakTimeout(time.Duration(c.Timeouts.Leak)))
+	}
+
+	// The nil fields of the Hooks are skipped when they are called:
+	opts = append(opts, WithHooks(c.Hooks))
+	if c.Logger != nil {
+		opts = append(opts, WithDebug(c.Logger))
+	}
+
+	return WithOptions(opts...)
+}
+
+// Duration is a time.Duration that is decoded from and
+// encoded to strings such as "1.5s" or "300ms".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Isolation is a sql.IsolationLevel that is decoded from and encoded to
+// its name, case insensitively and with spaces, dashes or underscores
+// between the words, e.g. "serializable" or "repeatable_read".
+type Isolation sql.IsolationLevel
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (i *Isolation) UnmarshalText(text []byte) error {
+	name := strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(string(text)))
+	if name == "" {
+		*i = Isolation(sql.LevelDefault)
+		return nil
+	}
+
+	for level := sql.LevelDefault; level <= sql.LevelLinearizable; level++ {
+		if name == strings.ToLower(level.String()) {
+			*i = Isolation(level)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown isolation level: %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (i Isolation) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(sql.IsolationLevel(i).String())), nil
+}
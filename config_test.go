@@ -0,0 +1,109 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("should be decoded from JSON", func(t *testing.T) {
+		var cfg Config
+		err := json.Unmarshal([]byte(`{
+			"name": "create-user",
+			"isolation": "Repeatable_Read",
+			"read_only": true,
+			"retry": {"max_attempts": 3, "rollback_reserve": "100ms"},
+			"timeouts": {"statement": "2s", "commit": "1.5s", "rollback": "500ms", "leak": "1m"}
+		}`), &cfg)
+		if err != nil {
+			t.Fatalf("failed to decode config: %v", err)
+		}
+
+		var c config
+		c.apply([]Option{cfg.Option()})
+
+		if c.name != "create-user" || c.isolation != sql.LevelRepeatableRead || !c.readOnly {
+			t.Errorf("unexpected name, isolation or read-only: %q, %v, %v", c.name, c.isolation, c.readOnly)
+		}
+		if c.retry == nil || c.retry.MaxAttempts != 3 || c.retry.RollbackReserve != 100*time.Millisecond {
+			t.Errorf("unexpected retry policy: %+v", c.retry)
+		}
+		if c.statementTimeout != 2*time.Second || c.commitTimeout != 1500*time.Millisecond ||
+			c.rollbackTimeout != 500*time.Millisecond || c.leakTimeout != time.Minute {
+			t.Errorf("unexpected timeouts: %v, %v, %v, %v", c.statementTimeout, c.commitTimeout, c.rollbackTimeout, c.leakTimeout)
+		}
+	})
+
+	t.Run("should keep the defaults for the zero value", func(t *testing.T) {
+		var c config
+		c.apply([]Option{Config{}.Option()})
+
+		if c.retry != nil || c.readOnly || c.isolation != sql.LevelDefault || c.statementTimeout != 0 || len(c.middlewares) != 0 {
+			t.Errorf("expected the zero Config to change nothing, got: %+v", c)
+		}
+	})
+
+	t.Run("should reject invalid values", func(t *testing.T) {
+		for _, input := range []string{
+			`{"isolation": "eventual"}`,
+			`{"timeouts": {"commit": "5 seconds"}}`,
+		} {
+			var cfg Config
+			err := json.Unmarshal([]byte(input), &cfg)
+			if err == nil {
+				t.Errorf("expected an error decoding %s", input)
+			}
+		}
+	})
+
+	t.Run("should be encoded back to the same names", func(t *testing.T) {
+		cfg := Config{
+			Isolation: Isolation(sql.LevelSerializable),
+			Timeouts:  TimeoutsConfig{Statement: Duration(2 * time.Second)},
+		}
+
+		encoded, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatalf("failed to encode config: %v", err)
+		}
+		if !bytes.Contains(encoded, []byte(`"isolation":"serializable"`)) || !bytes.Contains(encoded, []byte(`"statement":"2s"`)) {
+			t.Errorf("unexpected encoding: %s", encoded)
+		}
+	})
+
+	t.Run("should configure Run", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var buf bytes.Buffer
+		committed := false
+		cfg := Config{
+			Name:   "create-user",
+			Logger: &buf,
+			Hooks: Hooks{
+				OnCommit: func(ctx context.Context, tx *Tx) {
+					committed = tx.Name() == "create-user"
+				},
+			},
+		}
+
+		err := Run(context.Background(), db, func(tx *Tx) error {
+			_, err := tx.ExecContext(context.Background(), "SELECT 1")
+			return err
+		}, cfg.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if !committed {
+			t.Errorf("expected the OnCommit hook to be called")
+		}
+		if !bytes.Contains(buf.Bytes(), []byte("COMMIT")) {
+			t.Errorf("expected the timeline to be written to the Logger, got: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WithDebug prints a human-readable timeline of the transaction to w:
+// the begin, each statement with its arguments, duration and affected rows,
+// and the final commit or rollback.
+//
+// The number of rows is only available for statements executed with
+// ExecContext, since the rows returned by QueryContext are consumed
+// by the caller.
+//
+// Each line is prefixed with the time elapsed since the transaction began,
+// and the begin includes the actor of transactions started WithActor:
+//
+//	ktx: +0s BEGIN
+//	ktx: +412µs EXEC INSERT INTO users (name) VALUES (?) [John]: 1 rows in 380µs
+//	ktx: +530µs COMMIT after 530µs
+func WithDebug(w io.Writer) Option {
+	// id tells apart the timelines of different
+	// WithDebug options used on the same transaction.
+	id := new(int)
+
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, func(next DBRunner) DBRunner {
+			return &debugRunner{
+				next:  next,
+				id:    id,
+				w:     w,
+				start: time.Now(),
+			}
+		})
+
+		c.hooks = append(c.hooks, Hooks{
+			OnBegin: func(ctx context.Context, tx *Tx) {
+				if d := findDebugRunner(tx, id); d != nil {
+					if actor, ok := tx.Actor(); ok {
+						d.printf("BEGIN as %s", actor)
+						return
+					}
+					d.printf("BEGIN")
+				}
+			},
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				if d := findDebugRunner(tx, id); d != nil {
+					d.printf("COMMIT after %s", time.Since(d.start))
+				}
+			},
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				if d := findDebugRunner(tx, id); d != nil {
+					d.printf("ROLLBACK after %s: %s", time.Since(d.start), err)
+				}
+			},
+			OnCommitAmbiguous: func(ctx context.Context, tx *Tx, err error) {
+				if d := findDebugRunner(tx, id); d != nil {
+					d.printf("COMMIT with unknown outcome after %s: %s", time.Since(d.start), err)
+				}
+			},
+		})
+	}
+}
+
+type debugRunner struct {
+	next DBRunner
+	id   *int
+	w    io.Writer
+
+	// start is set when the middleware is built,
+	// right after the transaction begins.
+	start time.Time
+
+	mu sync.Mutex
+}
+
+func (d *debugRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.next.ExecContext(ctx, query, args...)
+	took := time.Since(start)
+
+	if err != nil {
+		d.printfAt(start, "EXEC %s %v: error after %s: %s", query, args, took, err)
+		return result, err
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		d.printfAt(start, "EXEC %s %v: done in %s", query, args, took)
+	} else {
+		d.printfAt(start, "EXEC %s %v: %d rows in %s", query, args, rows, took)
+	}
+	return result, nil
+}
+
+func (d *debugRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.next.QueryContext(ctx, query, args...)
+	took := time.Since(start)
+
+	if err != nil {
+		d.printfAt(start, "QUERY %s %v: error after %s: %s", query, args, took, err)
+		return rows, err
+	}
+
+	d.printfAt(start, "QUERY %s %v: done in %s", query, args, took)
+	return rows, nil
+}
+
+func (d *debugRunner) Unwrap() DBRunner {
+	return d.next
+}
+
+func (d *debugRunner) printf(format string, args ...interface{}) {
+	d.printfAt(time.Now(), format, args...)
+}
+
+func (d *debugRunner) printfAt(t time.Time, format string, args ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, _ = fmt.Fprintf(d.w, "ktx: +%s %s\n", t.Sub(d.start), fmt.Sprintf(format, args...))
+}
+
+// findDebugRunner finds the debugRunner created by
+// the WithDebug option with the input id.
+func findDebugRunner(tx *Tx, id *int) *debugRunner {
+	runner := tx.runner
+	for runner != nil {
+		if d, ok := runner.(*debugRunner); ok && d.id == id {
+			return d
+		}
+
+		u, ok := runner.(interface{ Unwrap() DBRunner })
+		if !ok {
+			return nil
+		}
+		runner = u.Unwrap()
+	}
+	return nil
+}
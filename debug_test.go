@@ -0,0 +1,87 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithDebug(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should print the timeline of a committed transaction", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "debug@example.com")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithDebug(&buf))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("expected 4 lines, got: %q", buf.String())
+		}
+
+		expected := []string{
+			"BEGIN",
+			"EXEC INSERT INTO users (name, email) VALUES (?, ?) [John debug@example.com]: 1 rows in ",
+			"QUERY SELECT id FROM users []: done in ",
+			"COMMIT after ",
+		}
+		for i, line := range lines {
+			if !strings.HasPrefix(line, "ktx: +") || !strings.Contains(line, expected[i]) {
+				t.Errorf("expected line %d to contain %q, got: %q", i, expected[i], line)
+			}
+		}
+	})
+
+	t.Run("should print statement errors and rollbacks", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO missing_table VALUES (1)")
+			return err
+		}, WithDebug(&buf))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		output := buf.String()
+		if !strings.Contains(output, "EXEC INSERT INTO missing_table VALUES (1) []: error after ") {
+			t.Errorf("expected the statement error on the output, got: %q", output)
+		}
+		if !strings.Contains(output, "ROLLBACK after ") || !strings.Contains(output, "no such table") {
+			t.Errorf("expected the rollback on the output, got: %q", output)
+		}
+	})
+
+	t.Run("should keep separate timelines for each option", func(t *testing.T) {
+		var first, second bytes.Buffer
+		err := Run(ctx, db, func(tx *Tx) error {
+			return errors.New("fake error")
+		}, WithDebug(&first), WithDebug(&second))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		for _, buf := range []*bytes.Buffer{&first, &second} {
+			if strings.Count(buf.String(), "\n") != 2 {
+				t.Errorf("expected 2 lines on each output, got: %q", buf.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+)
+
+type deferredStmt struct {
+	query string
+	args  []interface{}
+}
+
+// Defer buffers a statement to be executed right before the transaction
+// behind db is committed.
+//
+// Deferred statements are executed in the order they were registered,
+// which allows "collect then flush" patterns such as coalescing several
+// writes to the same row into a single statement. If any of them fail
+// the transaction is rolled back and the error is returned by Run.
+//
+// Nothing is executed if the transaction is rolled back.
+func Defer(db DBRunner, query string, args ...interface{}) error {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	first := len(tx.deferred) == 0
+	tx.deferred = append(tx.deferred, deferredStmt{
+		query: query,
+		args:  args,
+	})
+	tx.mu.Unlock()
+
+	if !first {
+		return nil
+	}
+
+	return BeforeCommit(tx, tx.flushDeferred)
+}
+
+func (tx *Tx) flushDeferred(ctx context.Context) error {
+	for i := 0; ; i++ {
+		tx.mu.Lock()
+		if i >= len(tx.deferred) {
+			tx.mu.Unlock()
+			return nil
+		}
+		stmt := tx.deferred[i]
+		tx.mu.Unlock()
+
+		_, err := tx.ExecContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return fmt.Errorf("error executing deferred statement '%s': %w", stmt.query, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefer(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should execute deferred statements in order before commit", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Defer(tx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+			err = Defer(tx, "UPDATE users SET name = ? WHERE email = ?", "Johnny", "john@example.com")
+			if err != nil {
+				return err
+			}
+
+			if n := countTxUsers(t, tx); n != 0 {
+				t.Fatalf("deferred statements should not run before the callback returns, got %d users", n)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		var name string
+		err = db.QueryRow("SELECT name FROM users WHERE email = ?", "john@example.com").Scan(&name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if name != "Johnny" {
+			t.Fatalf("expected statements to run in order, got name: %s", name)
+		}
+	})
+
+	t.Run("should not execute deferred statements on rollback", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Defer(tx, "DELETE FROM users")
+			if err != nil {
+				return err
+			}
+			return errors.New("test error")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if n := countDbUsers(t, db); n != 1 {
+			t.Fatalf("expected 1 user, got %d", n)
+		}
+	})
+
+	t.Run("should rollback when a deferred statement fails", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "Jane", "jane@example.com")
+			if err != nil {
+				return err
+			}
+			return Defer(tx, "INSERT INTO users (name, email) VALUES (?, ?)", "Duplicated", "john@example.com")
+		})
+		if err == nil || !strings.Contains(err.Error(), "deferred statement") {
+			t.Fatalf("expected a deferred statement error, got: %v", err)
+		}
+
+		if n := countDbUsers(t, db); n != 1 {
+			t.Fatalf("expected 1 user (rollback should have occurred), got %d", n)
+		}
+	})
+}
+
+func countTxUsers(t *testing.T, db DBRunner) (count int) {
+	rows, err := db.QueryContext(context.Background(), "SELECT COUNT(*) FROM users")
+	if err != nil {
+		t.Fatalf("Failed to query users: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to scan count: %v", err)
+		}
+	}
+
+	return count
+}
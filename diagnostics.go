@@ -0,0 +1,329 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LockDiagnosticsOptions configures WithLockDiagnostics.
+type LockDiagnosticsOptions struct {
+	// DB runs the diagnostics query, which must use a connection other
+	// than the one of the transaction, e.g. the *sql.DB passed to Run.
+	DB DBRunner
+
+	// Query overrides the diagnostics query, which defaults to one over
+	// pg_locks and pg_stat_activity on Postgres and to
+	// `SHOW ENGINE INNODB STATUS` on MySQL, chosen by the dialect
+	// of the transaction set WithDialect.
+	Query string
+
+	// Timeout limits how long the diagnostics query can take,
+	// defaults to 2 seconds.
+	Timeout time.Duration
+
+	// MaxLines limits the number of rows of the summary, defaults to 50.
+	MaxLines int
+}
+
+// LockConflictError wraps the deadlocks and lock timeouts of transactions
+// started WithLockDiagnostics, adding a summary of the locks held when
+// the error happened.
+type LockConflictError struct {
+	Err error
+
+	// Diagnostics has one line per row returned by the diagnostics
+	// query, with multiple columns separated by " | ".
+	Diagnostics []string
+
+	// DiagnosticsErr is set when the diagnostics could not be captured.
+	DiagnosticsErr error
+
+	// BlockingSessions are the sessions that may be holding the
+	// conflicting lock, oldest transactions first, which are only
+	// reported on Postgres and MySQL.
+	BlockingSessions []BlockingSession
+}
+
+func (e *LockConflictError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Err.Error())
+	for _, s := range e.BlockingSessions {
+		fmt.Fprintf(&sb, ", possibly blocked by %s", s)
+	}
+	if e.DiagnosticsErr != nil {
+		fmt.Fprintf(&sb, " (lock diagnostics unavailable: %s)", e.DiagnosticsErr)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, ", lock diagnostics:\n  %s", strings.Join(e.Diagnostics, "\n  "))
+	return sb.String()
+}
+
+func (e *LockConflictError) Unwrap() error {
+	return e.Err
+}
+
+// WithLockDiagnostics runs a diagnostics query on a separate connection
+// when the transaction fails with a deadlock or a lock timeout, as reported
+// by IsLockConflict, and wraps the error in a *LockConflictError with
+// a summary of the locks held by the other sessions, which is also
+// received by the OnRollback hooks:
+//
+//	err := ktx.Run(ctx, db, fn, ktx.WithDialect(ktx.Postgres), ktx.WithLockDiagnostics(ktx.LockDiagnosticsOptions{
+//		DB: db,
+//	}))
+//
+//	var conflict *ktx.LockConflictError
+//	if errors.As(err, &conflict) {
+//		log.Printf("lock conflict: %s", conflict)
+//	}
+//
+// The diagnostics run before the transaction is rolled back,
+// so they include the locks held by the transaction itself.
+//
+// On Postgres and MySQL the error also lists the sessions that may be
+// holding the conflicting lock, so on-call engineers know what to kill,
+// at the cost of loading the ID of the session when each transaction
+// begins.
+func WithLockDiagnostics(opts LockDiagnosticsOptions) Option {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = 50
+	}
+
+	return func(c *config) {
+		c.lockDiagnostics = &opts
+	}
+}
+
+// IsLockConflict reports whether err is a deadlock or a lock timeout.
+func IsLockConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40P01", "55P03":
+			return true
+		}
+	}
+
+	// Drivers that don't expose the error codes with a method:
+	msg := err.Error()
+	for _, s := range []string{
+		"Error 1213",                            // MySQL deadlock
+		"Error 1205",                            // MySQL lock wait timeout
+		"SQLSTATE 40P01",                        // Postgres deadlock
+		"SQLSTATE 55P03",                        // Postgres lock timeout
+		"database is locked",                    // SQLite busy
+		"was deadlocked on",                     // SQL Server deadlock
+		"Lock request time out period exceeded", // SQL Server lock timeout
+		"ORA-00060",                             // Oracle deadlock
+		"ORA-30006",                             // Oracle lock wait timeout
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const postgresLockDiagnostics = `SELECT a.pid, l.locktype, l.mode, l.granted,
+	COALESCE(l.relation::regclass::text, ''), a.state,
+	COALESCE((now() - a.xact_start)::text, ''), a.query
+FROM pg_locks l
+JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE a.datname = current_database()
+	AND a.pid <> pg_backend_pid()
+	AND l.locktype IN ('relation', 'transactionid', 'tuple')
+ORDER BY l.granted, a.xact_start`
+
+// diagnoseLocks wraps err in a *LockConflictError if it is a lock
+// conflict and the transaction was started WithLockDiagnostics.
+func (tx *Tx) diagnoseLocks(ctx context.Context, err error) error {
+	opts := tx.cfg.lockDiagnostics
+	if opts == nil || !IsLockConflict(err) {
+		return err
+	}
+
+	conflict := &LockConflictError{Err: err}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opts.Timeout)
+	defer cancel()
+	conflict.Diagnostics, conflict.DiagnosticsErr = queryLockDiagnostics(ctx, opts, tx.cfg.dialect)
+	if tx.sessionID != "" {
+		sessions, err := tx.queryBlockingSessions(ctx)
+		if err != nil && conflict.DiagnosticsErr == nil {
+			conflict.DiagnosticsErr = fmt.Errorf("error listing the blocking sessions: %w", err)
+		}
+		conflict.BlockingSessions = sessions
+	}
+
+	return conflict
+}
+
+func queryLockDiagnostics(ctx context.Context, opts *LockDiagnosticsOptions, dialect Dialect) ([]string, error) {
+	query := opts.Query
+	if query == "" && dialect != nil {
+		switch dialect.Name() {
+		case Postgres.Name():
+			query = postgresLockDiagnostics
+		case MySQL.Name():
+			query = "SHOW ENGINE INNODB STATUS"
+		}
+	}
+	if query == "" {
+		return nil, errors.New("no diagnostics query for the dialect of the transaction")
+	}
+
+	rows, err := opts.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() && len(lines) < opts.MaxLines {
+		err := rows.Scan(dest...)
+		if err != nil {
+			return lines, err
+		}
+
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = "NULL"
+			if v.Valid {
+				cells[i] = v.String
+			}
+		}
+		lines = append(lines, strings.Join(cells, " | "))
+	}
+
+	if len(lines) == 1 && strings.Contains(lines[0], "LATEST DETECTED DEADLOCK") {
+		lines = innodbDeadlockSection(lines[0])
+	}
+
+	return lines, rows.Err()
+}
+
+// innodbDeadlockSection extracts the section about the last deadlock
+// from the output of `SHOW ENGINE INNODB STATUS`.
+func innodbDeadlockSection(status string) []string {
+	start := strings.Index(status, "LATEST DETECTED DEADLOCK")
+	section := status[start:]
+	if end := strings.Index(section, "\nTRANSACTIONS\n"); end >= 0 {
+		section = section[:end]
+	}
+	return strings.Split(strings.TrimRight(section, "-\n"), "\n")
+}
+
+// BlockingSession is a session that may be holding the lock that caused a
+// LockConflictError, i.e. another session with an open transaction holding
+// locks on the same tables as the transaction that failed.
+type BlockingSession struct {
+	// ID is the backend PID on Postgres and the connection ID on MySQL,
+	// which can be passed to pg_terminate_backend or KILL.
+	ID string
+
+	// State and Query are the state of the session and its current or last
+	// statement, and TransactionAge is how long its transaction is open.
+	State          string
+	Query          string
+	TransactionAge time.Duration
+}
+
+func (s BlockingSession) String() string {
+	return fmt.Sprintf("session %s (%s, transaction open for %s): %s", s.ID, s.State, s.TransactionAge, s.Query)
+}
+
+const postgresBlockingSessions = `SELECT DISTINCT a.pid, COALESCE(a.state, ''), COALESCE(a.query, ''),
+	COALESCE(EXTRACT(EPOCH FROM now() - a.xact_start), 0)::float8
+FROM pg_locks mine
+JOIN pg_locks other ON other.relation = mine.relation AND other.pid <> mine.pid AND other.granted
+JOIN pg_stat_activity a ON a.pid = other.pid
+WHERE mine.pid::text = $1 AND a.xact_start IS NOT NULL`
+
+const mysqlBlockingSessions = `SELECT trx_mysql_thread_id, trx_state, COALESCE(trx_query, ''),
+	TIMESTAMPDIFF(MICROSECOND, trx_started, NOW()) / 1000000
+FROM information_schema.innodb_trx
+WHERE CAST(trx_mysql_thread_id AS CHAR) <> ? AND trx_rows_locked > 0`
+
+// loadSessionID loads the ID of the session of the transaction, on the
+// dialects that can tell which sessions are blocking it, for reporting
+// them on the LockConflictError of transactions started
+// WithLockDiagnostics.
+func (tx *Tx) loadSessionID(ctx context.Context) error {
+	if tx.cfg.lockDiagnostics == nil || tx.cfg.dialect == nil {
+		return nil
+	}
+
+	var query string
+	switch tx.cfg.dialect.Name() {
+	case Postgres.Name():
+		query = "SELECT pg_backend_pid()"
+	case MySQL.Name():
+		query = "SELECT CONNECTION_ID()"
+	default:
+		return nil
+	}
+
+	err := tx.sqlTx.QueryRowContext(ctx, query).Scan(&tx.sessionID)
+	if err != nil {
+		return fmt.Errorf("error loading the session ID for the lock diagnostics: %w", err)
+	}
+	return nil
+}
+
+// queryBlockingSessions lists the sessions that hold locks on the tables
+// locked by the session of the transaction, oldest transactions first.
+func (tx *Tx) queryBlockingSessions(ctx context.Context) ([]BlockingSession, error) {
+	query := postgresBlockingSessions
+	if tx.cfg.dialect.Name() == MySQL.Name() {
+		query = mysqlBlockingSessions
+	}
+
+	rows, err := tx.cfg.lockDiagnostics.DB.QueryContext(ctx, query, tx.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []BlockingSession
+	for rows.Next() {
+		var s BlockingSession
+		var age float64
+		err := rows.Scan(&s.ID, &s.State, &s.Query, &age)
+		if err != nil {
+			return nil, err
+		}
+		s.TransactionAge = time.Duration(age * float64(time.Second))
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].TransactionAge > sessions[j].TransactionAge
+	})
+	return sessions, nil
+}
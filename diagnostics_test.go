@@ -0,0 +1,131 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockDiagnostics(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// Each connection to :memory: has its own database, so the
+	// diagnostics, which use a separate connection, get their own:
+	diagnosticsDB := setupTestDB(t)
+	defer func() { _ = diagnosticsDB.Close() }()
+
+	_, err := diagnosticsDB.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	opts := LockDiagnosticsOptions{
+		DB:    diagnosticsDB,
+		Query: "SELECT name, email, NULL FROM users",
+	}
+
+	t.Run("should attach the diagnostics to lock conflicts", func(t *testing.T) {
+		var rolledBack error
+		err := Run(ctx, db, func(tx *Tx) error {
+			return sqlStateError("40P01")
+		}, WithLockDiagnostics(opts), WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				rolledBack = err
+			},
+		}))
+
+		var conflict *LockConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("expected a LockConflictError, got: %v", err)
+		}
+		if !errors.Is(err, sqlStateError("40P01")) {
+			t.Fatalf("expected the original error to be wrapped, got: %v", err)
+		}
+		expected := []string{"John | john@example.com | NULL"}
+		if conflict.DiagnosticsErr != nil || !reflect.DeepEqual(conflict.Diagnostics, expected) {
+			t.Fatalf("expected diagnostics %q, got %q, err: %v", expected, conflict.Diagnostics, conflict.DiagnosticsErr)
+		}
+		if !errors.As(rolledBack, &conflict) {
+			t.Errorf("expected the OnRollback hooks to receive the diagnostics, got: %v", rolledBack)
+		}
+	})
+
+	t.Run("should not diagnose other errors", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			return sqlStateError("23505")
+		}, WithLockDiagnostics(opts))
+
+		var conflict *LockConflictError
+		if errors.As(err, &conflict) {
+			t.Fatalf("expected the error not to be diagnosed, got: %v", err)
+		}
+	})
+
+	t.Run("should report when there is no diagnostics query", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			return errors.New("Error 1205: Lock wait timeout exceeded")
+		}, WithLockDiagnostics(LockDiagnosticsOptions{DB: db}), WithDialect(SQLite))
+
+		var conflict *LockConflictError
+		if !errors.As(err, &conflict) || conflict.DiagnosticsErr == nil {
+			t.Fatalf("expected a LockConflictError without diagnostics, got: %v", err)
+		}
+	})
+}
+
+func TestInnodbDeadlockSection(t *testing.T) {
+	status := "\n=====\nINNODB MONITOR OUTPUT\n------------------------\nLATEST DETECTED DEADLOCK\n------------------------\n*** (1) TRANSACTION:\nUPDATE accounts\n------------\nTRANSACTIONS\n------------\nTrx id counter 1234\n"
+
+	expected := []string{
+		"LATEST DETECTED DEADLOCK",
+		"------------------------",
+		"*** (1) TRANSACTION:",
+		"UPDATE accounts",
+	}
+	if got := innodbDeadlockSection(status); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestBlockingSessions(t *testing.T) {
+	ctx := context.Background()
+
+	db := sql.OpenDB(&procedureConnector{row: []driver.Value{int64(42)}})
+	diagnostics := &procedureConnector{row: []driver.Value{int64(7), "idle in transaction", "UPDATE accounts SET balance = 0", 12.5}}
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		return sqlStateError("55P03")
+	}, WithDialect(Postgres), WithLockDiagnostics(LockDiagnosticsOptions{
+		DB: sql.OpenDB(diagnostics),
+	}))
+
+	var conflict *LockConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a LockConflictError, got: %v", err)
+	}
+	expected := []BlockingSession{{
+		ID:             "7",
+		State:          "idle in transaction",
+		Query:          "UPDATE accounts SET balance = 0",
+		TransactionAge: 12500 * time.Millisecond,
+	}}
+	if !reflect.DeepEqual(conflict.BlockingSessions, expected) {
+		t.Fatalf("expected blocking sessions %+v, got %+v", expected, conflict.BlockingSessions)
+	}
+
+	stmts := diagnostics.statements()
+	if len(stmts) != 2 || !strings.HasSuffix(stmts[1], "[42]") {
+		t.Fatalf("expected the blocking sessions to be listed for the session 42, got %q", stmts)
+	}
+	if !strings.Contains(err.Error(), "possibly blocked by session 7 (idle in transaction, transaction open for 12.5s)") {
+		t.Errorf("expected the error to mention the blocking session, got: %v", err)
+	}
+}
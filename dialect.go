@@ -0,0 +1,128 @@
+package ktx
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the differences in the SQL syntax of each database
+// that are relevant for the helpers of this package that generate SQL.
+type Dialect interface {
+	// Name returns the name of the database, e.g. "postgres".
+	Name() string
+
+	// Placeholder returns the placeholder for the argument
+	// at the input 0-based position, e.g. "$1" or "?".
+	Placeholder(idx int) string
+
+	// Quote escapes an identifier such as a table or a column name.
+	Quote(identifier string) string
+}
+
+// The dialects supported out of the box.
+var (
+	Postgres  Dialect = postgresDialect{}
+	MySQL     Dialect = mysqlDialect{}
+	SQLite    Dialect = sqliteDialect{}
+	SQLServer Dialect = sqlserverDialect{}
+	Oracle    Dialect = oracleDialect{}
+)
+
+// WithDialect sets the dialect of the database for the helpers that
+// generate SQL without receiving a Dialect, such as Attempt, which
+// otherwise use the standard syntax supported by Postgres, MySQL and SQLite.
+func WithDialect(dialect Dialect) Option {
+	return func(c *config) {
+		c.dialect = dialect
+	}
+}
+
+// LockForUpdate returns the table reference and the suffix for selecting
+// rows of table with an exclusive lock until the end of the transaction:
+//
+//	table, suffix := ktx.LockForUpdate(dialect, "accounts")
+//	query := "SELECT balance FROM " + table + " WHERE id = " + dialect.Placeholder(0) + suffix
+//
+// On SQL Server the lock is requested by the `WITH (UPDLOCK, ROWLOCK)`
+// table hint, on SQLite, which locks the whole database on writes,
+// the suffix is empty and on the other dialects it is ` FOR UPDATE`.
+func LockForUpdate(dialect Dialect, table string) (tableRef string, suffix string) {
+	switch dialect.Name() {
+	case SQLServer.Name():
+		return dialect.Quote(table) + " WITH (UPDLOCK, ROWLOCK)", ""
+	case SQLite.Name():
+		return dialect.Quote(table), ""
+	default:
+		return dialect.Quote(table), " FOR UPDATE"
+	}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Placeholder(idx int) string {
+	return "$" + strconv.Itoa(idx+1)
+}
+
+func (postgresDialect) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Placeholder(idx int) string {
+	return "?"
+}
+
+func (mysqlDialect) Quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) Placeholder(idx int) string {
+	return "?"
+}
+
+func (sqliteDialect) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) Name() string {
+	return "sqlserver"
+}
+
+func (sqlserverDialect) Placeholder(idx int) string {
+	return "@p" + strconv.Itoa(idx+1)
+}
+
+func (sqlserverDialect) Quote(identifier string) string {
+	return "[" + strings.ReplaceAll(identifier, "]", "]]") + "]"
+}
+
+type oracleDialect struct{}
+
+func (oracleDialect) Name() string {
+	return "oracle"
+}
+
+func (oracleDialect) Placeholder(idx int) string {
+	return ":" + strconv.Itoa(idx+1)
+}
+
+func (oracleDialect) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
@@ -0,0 +1,52 @@
+package ktx
+
+import "testing"
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		dialect             Dialect
+		expectedPlaceholder string
+		expectedQuote       string
+	}{
+		{dialect: Postgres, expectedPlaceholder: "$3", expectedQuote: `"my""table"`},
+		{dialect: MySQL, expectedPlaceholder: "?", expectedQuote: "`my\"table`"},
+		{dialect: SQLite, expectedPlaceholder: "?", expectedQuote: `"my""table"`},
+		{dialect: SQLServer, expectedPlaceholder: "@p3", expectedQuote: `[my"table]`},
+		{dialect: Oracle, expectedPlaceholder: ":3", expectedQuote: `"my""table"`},
+		{dialect: CockroachDB, expectedPlaceholder: "$3", expectedQuote: `"my""table"`},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.Name(), func(t *testing.T) {
+			placeholder := test.dialect.Placeholder(2)
+			if placeholder != test.expectedPlaceholder {
+				t.Errorf("expected placeholder %s, got %s", test.expectedPlaceholder, placeholder)
+			}
+
+			quoted := test.dialect.Quote(`my"table`)
+			if quoted != test.expectedQuote {
+				t.Errorf("expected quoted identifier %s, got %s", test.expectedQuote, quoted)
+			}
+		})
+	}
+}
+
+func TestLockForUpdate(t *testing.T) {
+	tests := []struct {
+		dialect        Dialect
+		expectedTable  string
+		expectedSuffix string
+	}{
+		{dialect: Postgres, expectedTable: `"accounts"`, expectedSuffix: " FOR UPDATE"},
+		{dialect: MySQL, expectedTable: "`accounts`", expectedSuffix: " FOR UPDATE"},
+		{dialect: SQLite, expectedTable: `"accounts"`, expectedSuffix: ""},
+		{dialect: SQLServer, expectedTable: "[accounts] WITH (UPDLOCK, ROWLOCK)", expectedSuffix: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.Name(), func(t *testing.T) {
+			table, suffix := LockForUpdate(test.dialect, "accounts")
+			if table != test.expectedTable || suffix != test.expectedSuffix {
+				t.Errorf("expected (%s, %s), got (%s, %s)", test.expectedTable, test.expectedSuffix, table, suffix)
+			}
+		})
+	}
+}
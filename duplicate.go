@@ -0,0 +1,99 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// DuplicateStatement describes a statement executed repeatedly
+// with the same arguments inside a transaction.
+type DuplicateStatement struct {
+	Query string
+
+	// Args is a copy of the arguments, so it can be kept
+	// after OnDuplicate returns.
+	Args []interface{}
+
+	// Count is how many times the statement was executed so far.
+	Count int
+}
+
+// DuplicateOptions configures WithDuplicateDetection.
+type DuplicateOptions struct {
+	// Threshold is how many times the same statement can be executed with
+	// the same arguments before it is reported, defaults to 1.
+	Threshold int
+
+	// OnDuplicate is called once for each statement
+	// when it is executed more than Threshold times.
+	OnDuplicate func(ctx context.Context, dup DuplicateStatement)
+}
+
+// WithDuplicateDetection reports the statements executed more than
+// opts.Threshold times with the exact same arguments in the transaction,
+// which usually means an accidental loop or a redundant read that could
+// be done once, e.g.:
+//
+//	ktx.WithDuplicateDetection(ktx.DuplicateOptions{
+//		OnDuplicate: func(ctx context.Context, dup ktx.DuplicateStatement) {
+//			log.Printf("statement executed %d times: %s %v", dup.Count, dup.Query, dup.Args)
+//		},
+//	})
+//
+// It is meant for development since it keeps a counter for every
+// distinct statement executed by the transaction.
+func WithDuplicateDetection(opts DuplicateOptions) Option {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 1
+	}
+
+	return WithMiddleware(func(next DBRunner) DBRunner {
+		return &duplicateRunner{
+			next:   next,
+			opts:   opts,
+			counts: map[string]int{},
+		}
+	})
+}
+
+type duplicateRunner struct {
+	next DBRunner
+	opts DuplicateOptions
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *duplicateRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.count(ctx, query, args)
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r *duplicateRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.count(ctx, query, args)
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r *duplicateRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (r *duplicateRunner) count(ctx context.Context, query string, args []interface{}) {
+	key := fmt.Sprintf("%s %#v", query, unwrapSensitive(args))
+
+	r.mu.Lock()
+	r.counts[key]++
+	count := r.counts[key]
+	r.mu.Unlock()
+
+	if count == r.opts.Threshold+1 && r.opts.OnDuplicate != nil {
+		r.opts.OnDuplicate(ctx, DuplicateStatement{
+			Query: query,
+			// The caller may reuse the slice, e.g. the pooled args of the Repo:
+			Args:  append([]interface{}(nil), args...),
+			Count: count,
+		})
+	}
+}
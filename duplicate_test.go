@@ -0,0 +1,52 @@
+package ktx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithDuplicateDetection(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var reported []DuplicateStatement
+	err := Run(ctx, db, func(tx *Tx) error {
+		for i := 0; i < 4; i++ {
+			for _, id := range []int{1, 2} {
+				rows, err := tx.QueryContext(ctx, "SELECT name FROM users WHERE id = ?", id)
+				if err != nil {
+					return err
+				}
+				_ = rows.Close()
+			}
+		}
+
+		// Different arguments are different statements:
+		for i := 3; i < 6; i++ {
+			_, err := tx.ExecContext(ctx, "UPDATE users SET name = 'x' WHERE id = ?", i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, WithDuplicateDetection(DuplicateOptions{
+		Threshold: 2,
+		OnDuplicate: func(ctx context.Context, dup DuplicateStatement) {
+			reported = append(reported, dup)
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("expected each repeated query to be reported once, got: %+v", reported)
+	}
+	for i, dup := range reported {
+		if dup.Count != 3 || dup.Args[0] != i+1 {
+			t.Errorf("unexpected duplicate: %+v", dup)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ktx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// TxEvent is the line written by an EventLog for each finished transaction.
+type TxEvent struct {
+	// ID is a random identifier generated for each call to Run,
+	// also returned by tx.ID().
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	Start      time.Time `json:"start"`
+	DurationMS float64   `json:"duration_ms"`
+
+	// Attempts counts the attempts to run the transaction,
+	// which is more than 1 when it is retried WithRetry.
+	Attempts int `json:"attempts"`
+
+	// Statements counts the statements executed by the last attempt.
+	Statements int `json:"statements"`
+
+	// Outcome is one of "commit", "rollback", "ambiguous" when the
+	// outcome of the commit is unknown, or "error" when the transaction
+	// failed before starting.
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EventLog writes one JSON line describing each finished transaction to
+// an io.Writer, for analyzing the behavior of the transactions with tools
+// such as jq or BigQuery instead of a metrics system:
+//
+//	events, err := ktx.OpenEventLog("/var/log/app/transactions.jsonl")
+//	if err != nil {
+//		return err
+//	}
+//	defer events.Close()
+//
+//	err = ktx.Run(ctx, db, fn, ktx.WithName("create-user"), events.Option())
+//
+// A transaction retried WithRetry is written once, after its last attempt.
+type EventLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	err    error
+}
+
+// NewEventLog returns an EventLog that writes to w,
+// which may be shared by concurrent transactions.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w}
+}
+
+// OpenEventLog returns an EventLog that appends to the file at
+// path, creating it if needed, which is closed by Close.
+func OpenEventLog(path string) (*EventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening event log: %w", err)
+	}
+	return &EventLog{w: f, closer: f}, nil
+}
+
+// Option returns the Option that writes the transaction to the log,
+// it can be reused on any number of transactions.
+func (l *EventLog) Option() Option {
+	return func(c *config) {
+		c.eventLog = l
+	}
+}
+
+// Close closes the file opened by OpenEventLog, if any, and returns the
+// first error that happened while writing the events.
+func (l *EventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var closeErr error
+	if l.closer != nil {
+		closeErr = l.closer.Close()
+		l.closer = nil
+	}
+	return errors.Join(l.err, closeErr)
+}
+
+// observe runs the transaction configured with cfg
+// with run and writes its event once it finishes.
+func (l *EventLog) observe(cfg *config, run func() error) error {
+	// RunWithResult might already be tracking the result:
+	result := cfg.result
+	if result == nil {
+		result = &TxResult{}
+		cfg.result = result
+	}
+
+	// WithApplicationName might already have generated the ID:
+	if cfg.id == "" {
+		cfg.id = newEventID()
+	}
+
+	start := time.Now()
+	err := run()
+
+	event := TxEvent{
+		ID:         cfg.id,
+		Name:       cfg.name,
+		Start:      start,
+		DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+		Attempts:   result.Attempts,
+		Statements: result.Statements,
+		Outcome:    eventOutcome(*result, err),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	l.write(event)
+
+	return err
+}
+
+func (l *EventLog) write(event TxEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.w.Write(append(line, '\n'))
+	if err != nil && l.err == nil {
+		l.err = fmt.Errorf("error writing event log: %w", err)
+	}
+}
+
+func eventOutcome(result TxResult, err error) string {
+	switch {
+	case err == nil || errors.Is(err, ErrCallbacksFailed):
+		return "commit"
+	case errors.Is(err, ErrCommitAmbiguous):
+		return "ambiguous"
+	case result.RolledBack:
+		return "rollback"
+	default:
+		return "error"
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+package ktx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventLog(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should write one line per transaction", func(t *testing.T) {
+		var buf bytes.Buffer
+		events := NewEventLog(&buf)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "eventlog@example.com")
+			return err
+		}, WithName("create-user"), events.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		fakeErr := errors.New("fake error")
+		attempts := 0
+		err = Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			if attempts < 2 {
+				return sqlStateError("40001")
+			}
+			return fakeErr
+		}, events.Option(), WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     func(int) time.Duration { return 0 },
+		}))
+		if err != fakeErr {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+
+		err = events.Close()
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		lines := readEvents(t, &buf)
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(lines))
+		}
+
+		committed := lines[0]
+		if committed.Name != "create-user" || committed.Outcome != "commit" || committed.Attempts != 1 ||
+			committed.Statements != 1 || committed.Error != "" || committed.ID == "" {
+			t.Errorf("unexpected commit event: %+v", committed)
+		}
+
+		rolledBack := lines[1]
+		if rolledBack.Outcome != "rollback" || rolledBack.Attempts != 2 || rolledBack.Error != "fake error" {
+			t.Errorf("unexpected rollback event: %+v", rolledBack)
+		}
+		if rolledBack.ID == committed.ID {
+			t.Errorf("expected each transaction to have its own ID")
+		}
+	})
+
+	t.Run("should not interfere with RunWithResult", func(t *testing.T) {
+		var buf bytes.Buffer
+		events := NewEventLog(&buf)
+
+		result, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "SELECT 1")
+			return err
+		}, events.Option())
+		if err != nil {
+			t.Fatalf("RunWithResult failed: %v", err)
+		}
+		if result.Attempts != 1 || result.Statements != 1 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+
+		lines := readEvents(t, &buf)
+		if len(lines) != 1 || lines[0].Attempts != 1 || lines[0].Statements != 1 {
+			t.Errorf("unexpected events: %+v", lines)
+		}
+	})
+
+	t.Run("should append to the file opened with OpenEventLog", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+		for i := 0; i < 2; i++ {
+			events, err := OpenEventLog(path)
+			if err != nil {
+				t.Fatalf("OpenEventLog failed: %v", err)
+			}
+
+			err = Run(ctx, db, func(tx *Tx) error { return nil }, events.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			err = events.Close()
+			if err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open event log: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		if lines := readEvents(t, f); len(lines) != 2 {
+			t.Errorf("expected 2 events, got %d", len(lines))
+		}
+	})
+
+	t.Run("should report write errors on Close", func(t *testing.T) {
+		events := NewEventLog(failingWriter{})
+
+		err := Run(ctx, db, func(tx *Tx) error { return nil }, events.Option())
+		if err != nil {
+			t.Fatalf("expected the write error not to fail the transaction, got: %v", err)
+		}
+
+		err = events.Close()
+		if err == nil {
+			t.Errorf("expected the write error to be returned by Close")
+		}
+	})
+}
+
+func readEvents(t *testing.T, r io.Reader) []TxEvent {
+	t.Helper()
+
+	var events []TxEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event TxEvent
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("failed to decode event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,193 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Plan is the execution plan of a statement captured by WithExplain.
+type Plan struct {
+	Query string
+
+	// Args is a copy of the arguments, so it can be kept
+	// after OnPlan returns.
+	Args []interface{}
+
+	// Lines contains one entry for each row returned by EXPLAIN,
+	// with multiple columns separated by " | ".
+	Lines []string
+
+	// Err is set when the plan could not be captured,
+	// in which case the statement still runs normally.
+	Err error
+}
+
+// String returns the plan in a human-readable format.
+func (p Plan) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("plan unavailable for '%s': %s", p.Query, p.Err)
+	}
+	return p.Query + "\n  " + strings.Join(p.Lines, "\n  ")
+}
+
+// ExplainOptions configures WithExplain.
+type ExplainOptions struct {
+	// Dialect decides the syntax of the EXPLAIN statement.
+	Dialect Dialect
+
+	// Analyze makes the database execute the statement for collecting
+	// the actual costs of the plan, if the database supports it.
+	Analyze bool
+
+	// OnPlan receives the plan of every statement.
+	OnPlan func(ctx context.Context, plan Plan)
+}
+
+// WithExplain runs EXPLAIN for each statement executed inside the
+// transaction and sends the resulting plans to opts.OnPlan, which
+// helps finding missing indexes during development.
+//
+// The EXPLAIN runs right before the statement inside a savepoint that is
+// always rolled back and released, so the extra execution caused by Analyze has no
+// effect on the transaction. Statements that can't be explained, such as
+// DDL, are not explained.
+//
+// It is meant for debugging only since it at least doubles
+// the number of round trips of each statement.
+func WithExplain(opts ExplainOptions) Option {
+	return WithMiddleware(func(next DBRunner) DBRunner {
+		return explainRunner{next: next, opts: opts}
+	})
+}
+
+type explainRunner struct {
+	next DBRunner
+	opts ExplainOptions
+}
+
+func (r explainRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.explain(ctx, query, args)
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r explainRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.explain(ctx, query, args)
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r explainRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (r explainRunner) explain(ctx context.Context, query string, args []interface{}) {
+	if r.opts.OnPlan == nil || !isExplainable(query) {
+		return
+	}
+
+	plan := Plan{
+		Query: query,
+		// The caller may reuse the slice, e.g. the pooled args of the Repo:
+		Args: append([]interface{}(nil), args...),
+	}
+	plan.Lines, plan.Err = r.capture(ctx, query, args)
+
+	r.opts.OnPlan(ctx, plan)
+}
+
+func (r explainRunner) capture(ctx context.Context, query string, args []interface{}) (lines []string, err error) {
+	dialect := r.opts.Dialect
+	if tx, txErr := TxFromRunner(r.next); txErr == nil && tx.cfg.dialect != nil {
+		dialect = tx.cfg.dialect
+	}
+
+	_, err = r.next.ExecContext(ctx, savepointStmt(dialect, "ktx_explain"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating explain savepoint: %w", err)
+	}
+	defer func() {
+		_, rollbackErr := r.next.ExecContext(ctx, rollbackToSavepointStmt(dialect, "ktx_explain"))
+		if rollbackErr == nil {
+			// Otherwise the savepoints would pile up until the commit:
+			if release := releaseSavepointStmt(dialect, "ktx_explain"); release != "" {
+				_, rollbackErr = r.next.ExecContext(ctx, release)
+			}
+		}
+		if rollbackErr != nil && err == nil {
+			err = fmt.Errorf("error rolling back explain savepoint: %w", rollbackErr)
+		}
+	}()
+
+	rows, err := r.next.QueryContext(ctx, r.explainPrefix()+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make([]string, 0, len(values))
+		for _, v := range values {
+			if v.Valid {
+				fields = append(fields, v.String)
+			}
+		}
+		lines = append(lines, strings.Join(fields, " | "))
+	}
+
+	return lines, rows.Err()
+}
+
+func (r explainRunner) explainPrefix() string {
+	name := ""
+	if r.opts.Dialect != nil {
+		name = r.opts.Dialect.Name()
+	}
+
+	switch name {
+	case "postgres":
+		if r.opts.Analyze {
+			return "EXPLAIN (ANALYZE) "
+		}
+		return "EXPLAIN "
+	case "mysql":
+		if r.opts.Analyze {
+			return "EXPLAIN ANALYZE "
+		}
+		return "EXPLAIN "
+	default:
+		// SQLite has no equivalent to ANALYZE:
+		return "EXPLAIN QUERY PLAN "
+	}
+}
+
+// isExplainable reports whether the statement is a query or a DML
+// statement, which are the only ones that EXPLAIN accepts.
+func isExplainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH", "REPLACE":
+		return true
+	}
+	return false
+}
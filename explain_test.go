@@ -0,0 +1,126 @@
+package ktx
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithExplain(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should capture the plan of each statement", func(t *testing.T) {
+		var plans []Plan
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY, title TEXT)")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT id FROM posts WHERE title = ?", "Hello")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithExplain(ExplainOptions{
+			Dialect: SQLite,
+			OnPlan: func(ctx context.Context, plan Plan) {
+				plans = append(plans, plan)
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(plans) != 1 {
+			t.Fatalf("expected only the SELECT to be explained, got: %v", plans)
+		}
+		if plans[0].Err != nil {
+			t.Fatalf("unexpected plan error: %v", plans[0].Err)
+		}
+		if !strings.Contains(plans[0].String(), "SCAN posts") {
+			t.Errorf("expected a full scan on the plan, got: %s", plans[0])
+		}
+	})
+
+	t.Run("should release the savepoint after each plan", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			for i := 0; i < 2; i++ {
+				rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+				if err != nil {
+					return err
+				}
+				_ = rows.Close()
+			}
+
+			_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT ktx_explain")
+			if err == nil {
+				t.Error("expected no explain savepoint to be left in the transaction")
+			}
+			return nil
+		}, WithExplain(ExplainOptions{
+			OnPlan: func(ctx context.Context, plan Plan) {},
+		}), WithDialect(SQLite))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("should not execute the statement twice", func(t *testing.T) {
+		var plans []Plan
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "explain@example.com")
+			return err
+		}, WithExplain(ExplainOptions{
+			Dialect: SQLite,
+			Analyze: true,
+			OnPlan: func(ctx context.Context, plan Plan) {
+				plans = append(plans, plan)
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(plans) != 1 || plans[0].Err != nil {
+			t.Fatalf("unexpected plans: %v", plans)
+		}
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", "explain@example.com").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("should report plan errors without failing the statement", func(t *testing.T) {
+		var plans []Plan
+		err := Run(ctx, db, func(tx *Tx) error {
+			rows, err := tx.QueryContext(ctx, "SELECT 1")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithExplain(ExplainOptions{
+			// EXPLAIN (ANALYZE) is not valid on SQLite:
+			Dialect: Postgres,
+			OnPlan: func(ctx context.Context, plan Plan) {
+				plans = append(plans, plan)
+			},
+			Analyze: true,
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(plans) != 1 || plans[0].Err == nil {
+			t.Fatalf("expected a plan error, got: %v", plans)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package ktx
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fingerprint normalizes a statement into a stable form that identifies its
+// shape regardless of the values it uses, which makes it suitable as a
+// metric or trace label without exploding their cardinality.
+//
+// Literals and placeholders are replaced by "?", lists of values such as
+// the ones used by IN and VALUES are collapsed into "(...)", comments are
+// removed, unquoted words are lower-cased and whitespace is normalized:
+//
+//	Fingerprint("SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'John'")
+//	// select * from users where id in (...) and name = ?
+func Fingerprint(query string) string {
+	tokens := tokenize(query)
+	tokens = collapseLists(tokens)
+
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], tok) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	valueToken
+	punctToken
+	opToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// spaced reports whether the token was preceded by whitespace
+	// or a comment on the original statement.
+	spaced bool
+	// end is the index of the rune right after the
+	// token on the original statement.
+	end int
+}
+
+func tokenize(query string) []token {
+	var tokens []token
+	spaced := false
+	i := 0
+	add := func(kind tokenKind, text string) {
+		tokens = append(tokens, token{kind: kind, text: text, spaced: spaced, end: i})
+		spaced = false
+	}
+
+	runes := []rune(query)
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			spaced = true
+			i++
+
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			spaced = true
+
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+			spaced = true
+
+		case c == '\'':
+			i = skipQuoted(runes, i, '\'')
+			add(valueToken, "?")
+
+		case c == '"' || c == '`':
+			start := i
+			i = skipQuoted(runes, i, c)
+			add(wordToken, string(runes[start:i]))
+
+		case c == '[':
+			// SQL Server quoted identifiers, where ]] escapes the ]:
+			start := i
+			i++
+			for i < len(runes) && !(runes[i] == ']' && (i+1 == len(runes) || runes[i+1] != ']')) {
+				if runes[i] == ']' {
+					i++
+				}
+				i++
+			}
+			i = min(i+1, len(runes))
+			add(wordToken, string(runes[start:i]))
+
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || unicode.IsLetter(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			add(valueToken, "?")
+
+		case c == '?':
+			i++
+			add(valueToken, "?")
+
+		case (c == '$' || c == '@' || c == ':') && i+1 < len(runes) && isWordRune(runes[i+1]):
+			// Postgres, SQL Server and named placeholders:
+			i++
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			add(valueToken, "?")
+
+		case isWordRune(c):
+			start := i
+			for i < len(runes) && (isWordRune(runes[i]) || runes[i] == '$') {
+				i++
+			}
+			add(wordToken, strings.ToLower(string(runes[start:i])))
+
+		case c == '(' || c == ')' || c == ',' || c == ';' || c == '.':
+			i++
+			add(punctToken, string(c))
+
+		default:
+			start := i
+			for i < len(runes) && isOpRune(runes[i]) {
+				i++
+			}
+			if i == start {
+				i++
+			}
+			add(opToken, string(runes[start:i]))
+		}
+	}
+
+	// Trailing semicolons don't change the statement:
+	for len(tokens) > 0 && tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	return tokens
+}
+
+// skipQuoted returns the position right after the quoted
+// section that starts at runes[start], handling doubled quotes.
+func skipQuoted(runes []rune, start int, quote rune) int {
+	i := start + 1
+	for i < len(runes) {
+		if runes[i] == quote {
+			if i+1 < len(runes) && runes[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		if runes[i] == '\\' && quote == '\'' {
+			i++
+		}
+		i++
+	}
+	return i
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isOpRune(c rune) bool {
+	return strings.ContainsRune("=<>!|&+-*/%^~:", c)
+}
+
+// collapseLists replaces parenthesized lists of values, e.g. "(?, ?, ?)",
+// by a single "(...)" token, and sequences of those lists, such as the
+// ones of a multi-row VALUES clause, by a single one.
+func collapseLists(tokens []token) []token {
+	result := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		end, ok := valueListEnd(tokens, i)
+		if !ok {
+			result = append(result, tokens[i])
+			continue
+		}
+
+		list := token{kind: valueToken, text: "(...)", spaced: tokens[i].spaced}
+		i = end
+		for i+2 < len(tokens) && tokens[i+1].text == "," {
+			next, ok := valueListEnd(tokens, i+2)
+			if !ok {
+				break
+			}
+			i = next
+		}
+		result = append(result, list)
+	}
+	return result
+}
+
+// valueListEnd checks if tokens[start] opens a list that only
+// contains values and returns the position of its closing paren.
+func valueListEnd(tokens []token, start int) (end int, ok bool) {
+	if tokens[start].text != "(" {
+		return 0, false
+	}
+
+	expectValue := true
+	for i := start + 1; i < len(tokens); i++ {
+		switch {
+		case expectValue && tokens[i].kind == valueToken:
+			expectValue = false
+		case expectValue && tokens[i].text == "-" && i+1 < len(tokens) && tokens[i+1].kind == valueToken:
+			// Negative numbers
+			i++
+			expectValue = false
+		case !expectValue && tokens[i].text == ",":
+			expectValue = true
+		case !expectValue && tokens[i].text == ")":
+			return i, true
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+func needsSpace(prev token, tok token) bool {
+	switch {
+	case tok.text == "," || tok.text == ")" || tok.text == ";" || tok.text == ".":
+		return false
+	case prev.text == "(" || prev.text == ".":
+		return false
+	case prev.text == "::" || tok.text == "::":
+		return false
+	case tok.text == "(" || tok.text == "(...)":
+		// Keep function calls such as "count(*)" together:
+		return tok.spaced || prev.kind != wordToken
+	}
+	return true
+}
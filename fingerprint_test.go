@@ -0,0 +1,64 @@
+package ktx
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		desc     string
+		query    string
+		expected string
+	}{
+		{
+			desc:     "should replace literals",
+			query:    "SELECT * FROM users WHERE id = 42 AND name = 'O''Brien' AND score > 1.5",
+			expected: "select * from users where id = ? and name = ? and score > ?",
+		},
+		{
+			desc:     "should replace placeholders of every dialect",
+			query:    "SELECT a FROM t WHERE b = $1 AND c = ? AND d = :name AND e = @p1",
+			expected: "select a from t where b = ? and c = ? and d = ? and e = ?",
+		},
+		{
+			desc:     "should collapse IN lists of any size",
+			query:    "SELECT id FROM users WHERE id IN (1, 2, 3) AND age NOT IN ($1)",
+			expected: "select id from users where id in (...) and age not in (...)",
+		},
+		{
+			desc:     "should collapse multi-row VALUES",
+			query:    "INSERT INTO users (name, age) VALUES ('a', 1), ('b', -2), ('c', 3)",
+			expected: "insert into users (name, age) values (...)",
+		},
+		{
+			desc:     "should remove comments and normalize whitespace",
+			query:    "/* app:api */ SELECT  id\n\tFROM users -- the users\n WHERE id = 1;",
+			expected: "select id from users where id = ?",
+		},
+		{
+			desc:     "should keep quoted identifiers, casts and function calls",
+			query:    `SELECT count(*), "User"."Name", created_at::date FROM "User" WHERE coalesce(a, b) > 0`,
+			expected: `select count(*), "User"."Name", created_at::date from "User" where coalesce(a, b) > ?`,
+		},
+		{
+			desc:     "should keep bracketed identifiers of SQL Server",
+			query:    "SELECT [Order Id] FROM [dbo].[Orders] WHERE [Total] > 10",
+			expected: "select [Order Id] from [dbo].[Orders] where [Total] > ?",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := Fingerprint(test.query)
+			if got != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+
+	t.Run("should produce the same fingerprint for equivalent statements", func(t *testing.T) {
+		a := Fingerprint("SELECT id FROM users WHERE id IN (1,2) AND name='John'")
+		b := Fingerprint("select id\nfrom users\nwhere id in (10, 20, 30, 40) and name = 'Jane'")
+		if a != b {
+			t.Errorf("expected equal fingerprints, got %q and %q", a, b)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HealthCheckOption configures HealthCheck.
+type HealthCheckOption func(*healthCheckConfig)
+
+type healthCheckConfig struct {
+	writeQuery string
+	writeArgs  []interface{}
+}
+
+// HeartbeatWrite makes HealthCheck also execute a write statement, such as
+// an UPDATE of a heartbeat table, for checking that the database accepts
+// writes, e.g. that it is not a replica or out of disk. It is rolled back
+// with the rest of the check.
+func HeartbeatWrite(query string, args ...interface{}) HealthCheckOption {
+	return func(c *healthCheckConfig) {
+		c.writeQuery = query
+		c.writeArgs = args
+	}
+}
+
+// HealthCheck begins a transaction, runs a trivial read on it, and the write
+// of HeartbeatWrite if set, then rolls it back and returns how long it took.
+//
+// It is a more realistic readiness probe than db.Ping, since it exercises
+// the connection pool and the transaction machinery of the database.
+// The transaction is started directly on db, so the Options and hooks
+// of Run are not involved.
+func HealthCheck(ctx context.Context, db TxBeginner, opts ...HealthCheckOption) (latency time.Duration, err error) {
+	var cfg healthCheckConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	start := time.Now()
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Since(start), fmt.Errorf("health check failed to begin transaction: %w", err)
+	}
+	defer func() { _ = sqlTx.Rollback() }()
+
+	var result int
+	err = queryValue(ctx, sqlTx, &result, "SELECT 1")
+	if err != nil {
+		return time.Since(start), fmt.Errorf("health check failed to read: %w", err)
+	}
+
+	if cfg.writeQuery != "" {
+		_, err = sqlTx.ExecContext(ctx, cfg.writeQuery, cfg.writeArgs...)
+		if err != nil {
+			return time.Since(start), fmt.Errorf("health check failed to write: %w", err)
+		}
+	}
+
+	err = sqlTx.Rollback()
+	if err != nil {
+		return time.Since(start), fmt.Errorf("health check failed to rollback: %w", err)
+	}
+
+	return time.Since(start), nil
+}
@@ -0,0 +1,56 @@
+package ktx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should report the latency of a healthy database", func(t *testing.T) {
+		latency, err := HealthCheck(ctx, db)
+		if err != nil {
+			t.Fatalf("HealthCheck failed: %v", err)
+		}
+		if latency <= 0 {
+			t.Errorf("expected a positive latency, got %s", latency)
+		}
+	})
+
+	t.Run("should roll back the heartbeat write", func(t *testing.T) {
+		_, err := HealthCheck(ctx, db, HeartbeatWrite("INSERT INTO users (name, email) VALUES (?, ?)", "heartbeat", "heartbeat@example.com"))
+		if err != nil {
+			t.Fatalf("HealthCheck failed: %v", err)
+		}
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected the write to be rolled back, got %d users", count)
+		}
+	})
+
+	t.Run("should fail when the write fails", func(t *testing.T) {
+		_, err := HealthCheck(ctx, db, HeartbeatWrite("UPDATE missing_table SET beat = 1"))
+		if err == nil {
+			t.Error("expected an error for the failed write")
+		}
+	})
+
+	t.Run("should fail when the database is closed", func(t *testing.T) {
+		db := setupTestDB(t)
+		_ = db.Close()
+
+		_, err := HealthCheck(ctx, db)
+		if err == nil {
+			t.Error("expected an error for the closed database")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package ktx
+
+import (
+	"context"
+	"time"
+)
+
+// Heartbeat describes the progress of a long transaction,
+// sent periodically to the callback of WithHeartbeat.
+type Heartbeat struct {
+	// Elapsed is how long the transaction has been open.
+	Elapsed time.Duration
+
+	// Statements counts the statements executed so far, so a count that
+	// stops growing between heartbeats points to a stalled transaction.
+	Statements int
+}
+
+// WithHeartbeat calls fn every interval while the transaction is open, so
+// intentionally long transactions such as backfills can emit liveness
+// metrics and be detected when they stall:
+//
+//	ktx.WithHeartbeat(30*time.Second, func(ctx context.Context, tx *ktx.Tx, hb ktx.Heartbeat) {
+//		log.Printf("backfill open for %s, %d statements so far", hb.Elapsed, hb.Statements)
+//	})
+//
+// The callback runs on its own goroutine, concurrently with the
+// transaction, and is never called after Run returns.
+func WithHeartbeat(interval time.Duration, fn func(ctx context.Context, tx *Tx, hb Heartbeat)) Option {
+	return func(c *config) {
+		c.heartbeatInterval = interval
+		c.heartbeat = fn
+	}
+}
+
+// startHeartbeat starts the heartbeat of the transaction, if any,
+// and returns a function that stops it and waits for its callback.
+func (tx *Tx) startHeartbeat(ctx context.Context) (stop func()) {
+	if tx.cfg.heartbeat == nil || tx.cfg.heartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(tx.cfg.heartbeatInterval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				tx.cfg.heartbeat(ctx, tx, Heartbeat{
+					Elapsed:    time.Since(start),
+					Statements: tx.statementCount(),
+				})
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,73 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should report the progress of long transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var mu sync.Mutex
+		var beats []Heartbeat
+		returned := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			for i := 0; i < 3; i++ {
+				_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', ?)", fmt.Sprintf("john%d@example.com", i))
+				if err != nil {
+					return err
+				}
+				time.Sleep(30 * time.Millisecond)
+			}
+			return nil
+		}, WithHeartbeat(10*time.Millisecond, func(ctx context.Context, tx *Tx, hb Heartbeat) {
+			mu.Lock()
+			defer mu.Unlock()
+			if returned {
+				t.Error("heartbeat called after Run returned")
+			}
+			beats = append(beats, hb)
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		mu.Lock()
+		returned = true
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+
+		if len(beats) < 2 {
+			t.Fatalf("expected at least 2 heartbeats, got %d", len(beats))
+		}
+		for i := 1; i < len(beats); i++ {
+			if beats[i].Elapsed <= beats[i-1].Elapsed || beats[i].Statements < beats[i-1].Statements {
+				t.Fatalf("expected the heartbeats to progress, got %+v", beats)
+			}
+		}
+		if last := beats[len(beats)-1]; last.Statements < 2 || last.Statements > 3 {
+			t.Errorf("expected the last heartbeat to count the statements, got %d", last.Statements)
+		}
+	})
+
+	t.Run("should not beat on short transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithHeartbeat(time.Hour, func(ctx context.Context, tx *Tx, hb Heartbeat) {
+			t.Error("unexpected heartbeat")
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+}
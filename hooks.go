@@ -0,0 +1,105 @@
+package ktx
+
+import "context"
+
+// Hooks are callbacks invoked on the lifecycle events of the
+// transactions started by Run. Any of the fields can be left nil.
+type Hooks struct {
+	// OnBegin is called right after the transaction starts
+	// and before the callback is executed.
+	OnBegin func(ctx context.Context, tx *Tx)
+
+	// OnCommit is called after the transaction is committed.
+	OnCommit func(ctx context.Context, tx *Tx)
+
+	// OnRollback is called after the transaction is rolled back
+	// with the error that caused it, which includes commit errors
+	// and panics.
+	OnRollback func(ctx context.Context, tx *Tx, err error)
+
+	// OnCommitAmbiguous is called instead of OnCommit and OnRollback
+	// when the commit fails with ErrCommitAmbiguous, since the
+	// transaction may or may not have been committed.
+	OnCommitAmbiguous func(ctx context.Context, tx *Tx, err error)
+
+	// OnCallbackError is called with the errors of the BeforeCommit
+	// callbacks registered with IgnoreOnError, which don't prevent
+	// the transaction from being committed.
+	OnCallbackError func(ctx context.Context, tx *Tx, err error)
+
+	// OnRetry is called when a transaction configured with WithRetry
+	// is about to be retried, with the number of the attempt
+	// that failed, starting at 1, and its error.
+	OnRetry func(ctx context.Context, attempt int, err error)
+
+	// OnLeak is called when a LeakDetector finds that the transaction
+	// has been open for longer than expected. The transaction keeps
+	// running and may still finish normally.
+	OnLeak func(ctx context.Context, tx *Tx, leak Leak)
+}
+
+// WithHooks registers lifecycle hooks for the transaction.
+//
+// It can be used more than once, in which case the hooks
+// are called in the order they were registered.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, h)
+	}
+}
+
+func (c *config) onRetry(ctx context.Context, attempt int, err error) {
+	for _, h := range c.hooks {
+		if h.OnRetry != nil {
+			h.OnRetry(ctx, attempt, err)
+		}
+	}
+}
+
+func (tx *Tx) onBegin(ctx context.Context) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnBegin != nil {
+			h.OnBegin(ctx, tx)
+		}
+	}
+}
+
+func (tx *Tx) onCommit(ctx context.Context) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnCommit != nil {
+			h.OnCommit(ctx, tx)
+		}
+	}
+}
+
+func (tx *Tx) onRollback(ctx context.Context, err error) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnRollback != nil {
+			h.OnRollback(ctx, tx, err)
+		}
+	}
+}
+
+func (tx *Tx) onCommitAmbiguous(ctx context.Context, err error) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnCommitAmbiguous != nil {
+			h.OnCommitAmbiguous(ctx, tx, err)
+		}
+	}
+}
+
+func (tx *Tx) onCallbackError(ctx context.Context, err error) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnCallbackError != nil {
+			h.OnCallbackError(ctx, tx, err)
+		}
+	}
+}
+
+func (tx *Tx) onLeak(ctx context.Context, leak Leak) {
+	for _, h := range tx.cfg.hooks {
+		if h.OnLeak != nil {
+			h.OnLeak(ctx, tx, leak)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRun_Hooks(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var events []string
+	hooks := WithHooks(Hooks{
+		OnBegin: func(ctx context.Context, tx *Tx) {
+			events = append(events, "begin")
+		},
+		OnCommit: func(ctx context.Context, tx *Tx) {
+			events = append(events, "commit")
+		},
+		OnRollback: func(ctx context.Context, tx *Tx, err error) {
+			events = append(events, "rollback: "+err.Error())
+		},
+	})
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		events = append(events, "callback")
+		return nil
+	}, hooks)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		return errors.New("test error")
+	}, hooks)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = Run(ctx, db, func(tx *Tx) error {
+			panic("test panic")
+		}, hooks)
+	}()
+
+	expected := []string{
+		"begin", "callback", "commit",
+		"begin", "rollback: test error",
+		"begin", "rollback: panic: test panic",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected events %v, got %v", expected, events)
+		}
+	}
+}
+
+func TestRun_HooksAreCalledInOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	var order []int
+	err := Run(context.Background(), db, func(tx *Tx) error {
+		return nil
+	}, WithHooks(Hooks{
+		OnCommit: func(ctx context.Context, tx *Tx) { order = append(order, 1) },
+	}), WithHooks(Hooks{
+		OnCommit: func(ctx context.Context, tx *Tx) { order = append(order, 2) },
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("expected hooks to run in registration order, got: %v", order)
+	}
+}
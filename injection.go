@@ -0,0 +1,178 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// InjectionWarning describes a statement that seems to have one of its
+// arguments interpolated into the query string, reported by the callback
+// of WithInjectionCheck.
+type InjectionWarning struct {
+	// Query is the statement as it was sent to the transaction.
+	Query string
+
+	// ArgIndex is the 0-based position of the argument
+	// whose value was found in the query, and Literal is
+	// the literal of the query that matches it, or the
+	// redaction mask if the argument was wrapped by Sensitive.
+	ArgIndex int
+	Literal  string
+}
+
+// String returns the warning in a human-readable format.
+func (w InjectionWarning) String() string {
+	return fmt.Sprintf(
+		"argument %d (%s) also appears as a literal in '%s', which suggests string concatenation instead of placeholders",
+		w.ArgIndex, w.Literal, w.Query,
+	)
+}
+
+// WithInjectionCheck calls onWarning for the statements that contain a
+// literal equal to one of their arguments, e.g. a query built with
+// fmt.Sprintf that also passes the value as an argument, which usually
+// means some other code path concatenates values into the query string:
+//
+//	ktx.WithInjectionCheck(func(ctx context.Context, w ktx.InjectionWarning) {
+//		t.Errorf("possible SQL injection: %s", w)
+//	})
+//
+// Only string arguments and numbers with at least 3 digits are compared,
+// to avoid flagging common literals such as `LIMIT 1`.
+//
+// It is a heuristic meant for development and tests, since it adds
+// the cost of scanning each statement and may have false positives.
+func WithInjectionCheck(onWarning func(ctx context.Context, w InjectionWarning)) Option {
+	return WithMiddleware(func(next DBRunner) DBRunner {
+		return injectionRunner{next: next, onWarning: onWarning}
+	})
+}
+
+type injectionRunner struct {
+	next      DBRunner
+	onWarning func(ctx context.Context, w InjectionWarning)
+}
+
+func (r injectionRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.check(ctx, query, args)
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r injectionRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.check(ctx, query, args)
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r injectionRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (r injectionRunner) check(ctx context.Context, query string, args []interface{}) {
+	if len(args) == 0 {
+		return
+	}
+
+	var literals []string
+	for i, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok {
+			arg = named.Value
+		}
+		sensitive := false
+		if s, ok := arg.(SensitiveValue); ok {
+			arg = s.value
+			sensitive = true
+		}
+
+		value, ok := comparableArg(arg)
+		if !ok {
+			continue
+		}
+
+		if literals == nil {
+			literals = queryLiterals(query)
+		}
+		for _, literal := range literals {
+			if literal == value {
+				if sensitive {
+					literal = redacted
+				}
+				r.onWarning(ctx, InjectionWarning{
+					Query:    query,
+					ArgIndex: i,
+					Literal:  literal,
+				})
+				break
+			}
+		}
+	}
+}
+
+// comparableArg returns the text of the arguments
+// that are worth looking for on the query.
+func comparableArg(arg interface{}) (string, bool) {
+	switch v := arg.(type) {
+	case string:
+		return v, v != ""
+	case []byte:
+		return string(v), len(v) > 0
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := strings.TrimPrefix(fmt.Sprint(v), "-")
+		return s, len(s) >= 3
+	}
+	return "", false
+}
+
+// queryLiterals returns the contents of the string literals of the
+// query and its numbers, ignoring comments and quoted identifiers.
+func queryLiterals(query string) []string {
+	literals := []string{}
+
+	runes := []rune(query)
+	i := 0
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+
+		case c == '"' || c == '`':
+			i = skipQuoted(runes, i, c)
+
+		case c == '\'':
+			start := i
+			i = skipQuoted(runes, i, c)
+			end := max(i-1, start+1)
+			literals = append(literals, strings.ReplaceAll(string(runes[start+1:end]), "''", "'"))
+
+		case isWordRune(c) && !unicode.IsDigit(c):
+			// Words may contain digits, e.g. table2:
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			literals = append(literals, string(runes[start:i]))
+
+		default:
+			i++
+		}
+	}
+
+	return literals
+}
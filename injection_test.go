@@ -0,0 +1,97 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestQueryLiterals(t *testing.T) {
+	got := queryLiterals(`SELECT "col1", 'it''s' FROM t2 -- 'comment'
+		WHERE id = 1234 AND name = 'John' /* 99 */ LIMIT 1`)
+	expected := []string{"it's", "1234", "John", "1"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected literals %q, got %q", expected, got)
+	}
+}
+
+func TestInjectionCheck(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	tests := []struct {
+		desc     string
+		query    string
+		args     []interface{}
+		expected []InjectionWarning
+	}{
+		{
+			desc:  "placeholders",
+			query: "SELECT id FROM users WHERE name = ? AND id > ? LIMIT 1",
+			args:  []interface{}{"John", 1},
+		},
+		{
+			desc:  "interpolated string",
+			query: fmt.Sprintf("SELECT id FROM users WHERE name = '%s' AND email = ?", "John"),
+			args:  []interface{}{"John", "john@example.com"},
+			expected: []InjectionWarning{{
+				Query:    "SELECT id FROM users WHERE name = 'John' AND email = ?",
+				ArgIndex: 0,
+				Literal:  "John",
+			}},
+		},
+		{
+			desc:  "interpolated number",
+			query: fmt.Sprintf("SELECT id FROM users WHERE id = %d OR id = ?", 1234),
+			args:  []interface{}{int64(1234)},
+			expected: []InjectionWarning{{
+				Query:    "SELECT id FROM users WHERE id = 1234 OR id = ?",
+				ArgIndex: 0,
+				Literal:  "1234",
+			}},
+		},
+		{
+			desc:  "interpolated sensitive value",
+			query: fmt.Sprintf("SELECT id FROM users WHERE email = '%s' OR email = ?", "john@example.com"),
+			args:  []interface{}{Sensitive("john@example.com")},
+			expected: []InjectionWarning{{
+				Query:    "SELECT id FROM users WHERE email = 'john@example.com' OR email = ?",
+				ArgIndex: 0,
+				Literal:  redacted,
+			}},
+		},
+		{
+			desc:  "short numbers",
+			query: "SELECT id FROM users WHERE id = 1 OR id = ?",
+			args:  []interface{}{1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var warnings []InjectionWarning
+			err := Run(ctx, db, func(tx *Tx) error {
+				rows, err := tx.QueryContext(ctx, test.query, test.args...)
+				if err != nil {
+					return err
+				}
+				return rows.Close()
+			}, WithInjectionCheck(func(ctx context.Context, w InjectionWarning) {
+				warnings = append(warnings, w)
+			}))
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if !reflect.DeepEqual(warnings, test.expected) {
+				t.Fatalf("expected warnings %+v, got %+v", test.expected, warnings)
+			}
+		})
+	}
+}
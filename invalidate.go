@@ -0,0 +1,80 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+)
+
+// Invalidator removes keys from a cache, e.g. a Redis client
+// or an in-process LRU.
+//
+// It is called after the transaction is committed so it can't affect
+// the outcome of the transaction, which means implementations are
+// responsible for handling (e.g. logging or retrying) their own errors.
+type Invalidator interface {
+	Invalidate(ctx context.Context, keys []string)
+}
+
+// InvalidatorFunc adapts a function into an Invalidator.
+type InvalidatorFunc func(ctx context.Context, keys []string)
+
+// Invalidate implements the Invalidator interface.
+func (f InvalidatorFunc) Invalidate(ctx context.Context, keys []string) {
+	f(ctx, keys)
+}
+
+// WithInvalidator configures the Invalidator that receives the keys
+// registered with the Invalidate function.
+func WithInvalidator(inv Invalidator) Option {
+	return func(c *config) {
+		c.invalidator = inv
+	}
+}
+
+// Invalidate registers cache keys that should be invalidated once the
+// transaction behind db commits.
+//
+// The keys are delivered in a single call to the Invalidator configured
+// with WithInvalidator strictly after the commit, which prevents the
+// cache from being refilled with stale data when the transaction is
+// rolled back after the keys were invalidated.
+func Invalidate(db DBRunner, keys ...string) error {
+	tx, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	if tx.cfg.invalidator == nil {
+		return fmt.Errorf("no Invalidator configured, please use the WithInvalidator option")
+	}
+
+	tx.mu.Lock()
+	first := len(tx.invalidations) == 0
+	tx.invalidations = append(tx.invalidations, keys...)
+	tx.mu.Unlock()
+
+	if !first {
+		return nil
+	}
+
+	return AfterCommit(tx, func(ctx context.Context) {
+		tx.mu.Lock()
+		keys := dedupKeys(tx.invalidations)
+		tx.mu.Unlock()
+
+		tx.cfg.invalidator.Invalidate(ctx, keys)
+	})
+}
+
+func dedupKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, key)
+	}
+	return unique
+}
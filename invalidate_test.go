@@ -0,0 +1,75 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInvalidate(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var invalidated [][]string
+	inv := WithInvalidator(InvalidatorFunc(func(ctx context.Context, keys []string) {
+		invalidated = append(invalidated, keys)
+	}))
+
+	t.Run("should deliver deduplicated keys after commit", func(t *testing.T) {
+		invalidated = nil
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Invalidate(tx, "user:1", "user:2")
+			if err != nil {
+				return err
+			}
+
+			if len(invalidated) != 0 {
+				t.Fatal("keys should not be invalidated before commit")
+			}
+
+			return Invalidate(Memoize(tx), "user:2", "user:3")
+		}, inv)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(invalidated) != 1 {
+			t.Fatalf("expected a single call to the invalidator, got: %v", invalidated)
+		}
+		keys := invalidated[0]
+		if len(keys) != 3 || keys[0] != "user:1" || keys[1] != "user:2" || keys[2] != "user:3" {
+			t.Fatalf("unexpected keys: %v", keys)
+		}
+	})
+
+	t.Run("should not deliver keys on rollback", func(t *testing.T) {
+		invalidated = nil
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Invalidate(tx, "user:1")
+			if err != nil {
+				return err
+			}
+			return errors.New("test error")
+		}, inv)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if len(invalidated) != 0 {
+			t.Fatalf("expected no invalidations, got: %v", invalidated)
+		}
+	})
+
+	t.Run("should report missing invalidator", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			return Invalidate(tx, "user:1")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -3,13 +3,28 @@ package ktx
 import (
 	"context"
 	"database/sql"
-	"fmt"
 )
 
 // DBRunner represents the minimal interface needed to execute database operations.
 // It is compatible with database/sql standard library interfaces.
 type DBRunner interface {
+	Execer
+	Queryer
+}
+
+// Execer is the part of a DBRunner that executes statements.
+type Execer interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Queryer is the part of a DBRunner that executes queries, which lets the
+// layers that only read from the database, such as read-only repositories,
+// prove at compile time that they never execute statements:
+//
+//	type UserReader struct {
+//		db ktx.Queryer
+//	}
+type Queryer interface {
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 }
 
@@ -29,53 +44,10 @@ type TxBeginner interface {
 // If a panic occurs during the callback execution, the transaction will be
 // rolled back and the panic will be re-raised.
 //
-// If the provided db is already a transaction (sql.Tx), it will be reused
-// without starting a new transaction.
+// If the provided db is already a transaction (sql.Tx or ktx.Tx), it will be
+// reused without starting a new transaction.
 func Transaction(ctx context.Context, db DBRunner, fn func(db *sql.Tx) error) error {
-	// Check if db is already a transaction
-	if tx, ok := db.(*sql.Tx); ok {
-		return fn(tx)
-	}
-
-	// Check if db can begin transactions
-	txBeginner, ok := db.(TxBeginner)
-	if !ok {
-		return fmt.Errorf("provided db does not implement TxBeginner interface")
-	}
-
-	// Start a new transaction
-	tx, err := txBeginner.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
-	}
-
-	// Handle panics by rolling back the transaction
-	defer func() {
-		if r := recover(); r != nil {
-			rollbackErr := tx.Rollback()
-			if rollbackErr != nil {
-				r = fmt.Errorf(
-					"unable to rollback after panic with value: %v, rollback error: %w",
-					r, rollbackErr,
-				)
-			}
-			panic(r)
-		}
-	}()
-
-	// Execute the callback with the transaction
-	err = fn(tx)
-	if err != nil {
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			err = fmt.Errorf(
-				"unable to rollback after error: %s, rollback error: %w",
-				err, rollbackErr,
-			)
-		}
-		return err
-	}
-
-	// Commit the transaction
-	return tx.Commit()
+	return Run(ctx, db, func(tx *Tx) error {
+		return fn(tx.SQLTx())
+	})
 }
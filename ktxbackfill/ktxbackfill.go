@@ -0,0 +1,258 @@
+// Package ktxbackfill runs long backfills as a sequence of small ktx
+// transactions over a keyset range.
+//
+// The cursor of the backfill is saved on a checkpoint table in the same
+// transaction as each chunk, so a backfill that crashes or is interrupted
+// resumes from the last committed chunk without processing any row twice.
+package ktxbackfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Config configures a backfill.
+type Config[K any] struct {
+	// Name identifies the checkpoint of the backfill, it must be unique
+	// among the backfills that share the same checkpoint table.
+	Name string
+
+	// Start is the cursor used when there is no checkpoint yet,
+	// the first chunk processes the keys after it.
+	Start K
+
+	// ChunkSize is the maximum number of rows processed in each
+	// transaction, defaults to 1000.
+	ChunkSize int
+
+	// MaxRowsPerSecond limits the write rate of the backfill by
+	// pausing between chunks, zero means no limit.
+	MaxRowsPerSecond float64
+
+	// Dialect is used for generating the checkpoint queries.
+	Dialect ktx.Dialect
+
+	// CheckpointTable defaults to "ktx_backfill_checkpoints".
+	CheckpointTable string
+}
+
+// ProcessFunc processes up to limit rows whose keys come after the input
+// cursor, in key order, and returns the key of the last processed row
+// and how many rows were processed.
+//
+// The backfill is finished when it processes fewer rows than the limit.
+type ProcessFunc[K any] func(ctx context.Context, tx *ktx.Tx, after K, limit int) (last K, rows int, err error)
+
+// Result summarizes the execution of Run.
+type Result struct {
+	Chunks  int
+	Rows    int
+	Resumed bool
+}
+
+// Run processes the backfill chunk by chunk until it is finished,
+// the context is canceled or fn returns an error.
+//
+// If the backfill was already finished by a previous execution
+// Run returns immediately.
+func Run[K any](ctx context.Context, db ktx.DBRunner, cfg Config[K], fn ProcessFunc[K]) (result Result, err error) {
+	if cfg.Name == "" {
+		return result, errors.New("the name of the backfill is required")
+	}
+	if cfg.Dialect == nil {
+		return result, errors.New("the dialect of the backfill is required")
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1000
+	}
+	if cfg.CheckpointTable == "" {
+		cfg.CheckpointTable = "ktx_backfill_checkpoints"
+	}
+
+	cp := checkpoints{
+		dialect: cfg.Dialect,
+		table:   cfg.Dialect.Quote(cfg.CheckpointTable),
+		name:    cfg.Name,
+	}
+
+	err = cp.createTable(ctx, db)
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		var rows int
+		var finished bool
+		start := time.Now()
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			cursor := cfg.Start
+			state, found, err := cp.load(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if found {
+				if state.done {
+					finished = true
+					return nil
+				}
+
+				err = json.Unmarshal([]byte(state.cursor), &cursor)
+				if err != nil {
+					return fmt.Errorf("error decoding the checkpoint cursor: %w", err)
+				}
+				if result.Chunks == 0 {
+					result.Resumed = true
+				}
+			}
+
+			last, n, err := fn(ctx, tx, cursor, cfg.ChunkSize)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				last = cursor
+			}
+
+			encoded, err := json.Marshal(last)
+			if err != nil {
+				return fmt.Errorf("error encoding the checkpoint cursor: %w", err)
+			}
+
+			rows = n
+			finished = n < cfg.ChunkSize
+			return cp.save(ctx, tx, found, string(encoded), finished)
+		})
+		if err != nil {
+			return result, fmt.Errorf("error processing chunk #%d of backfill '%s': %w", result.Chunks+1, cfg.Name, err)
+		}
+
+		if rows > 0 {
+			result.Chunks++
+			result.Rows += rows
+		}
+		if finished {
+			return result, nil
+		}
+
+		err = pace(ctx, start, rows, cfg.MaxRowsPerSecond)
+		if err != nil {
+			return result, err
+		}
+	}
+}
+
+// pace sleeps long enough for the chunk with the input
+// number of rows to respect the maximum rate.
+func pace(ctx context.Context, chunkStart time.Time, rows int, maxRowsPerSecond float64) error {
+	if maxRowsPerSecond <= 0 {
+		return ctx.Err()
+	}
+
+	minDuration := time.Duration(float64(rows) / maxRowsPerSecond * float64(time.Second))
+	wait := minDuration - time.Since(chunkStart)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type checkpoints struct {
+	dialect ktx.Dialect
+	table   string
+	name    string
+}
+
+type checkpointState struct {
+	cursor string
+	done   bool
+}
+
+func (c checkpoints) createTable(ctx context.Context, db ktx.DBRunner) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) PRIMARY KEY, cursor_value TEXT NOT NULL, done INTEGER NOT NULL, updated_at TIMESTAMP NOT NULL)",
+		c.table,
+	))
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint table: %w", err)
+	}
+	return nil
+}
+
+func (c checkpoints) load(ctx context.Context, db ktx.DBRunner) (state checkpointState, found bool, err error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT cursor_value, done FROM %s WHERE name = %s", c.table, c.dialect.Placeholder(0),
+	), c.name)
+	if err != nil {
+		return state, false, fmt.Errorf("error loading checkpoint: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		return state, false, rows.Err()
+	}
+
+	var done int
+	err = rows.Scan(&state.cursor, &done)
+	if err != nil {
+		return state, false, fmt.Errorf("error loading checkpoint: %w", err)
+	}
+	state.done = done != 0
+
+	return state, true, rows.Close()
+}
+
+func (c checkpoints) save(ctx context.Context, db ktx.DBRunner, exists bool, cursor string, done bool) error {
+	p := c.dialect.Placeholder
+
+	doneValue := 0
+	if done {
+		doneValue = 1
+	}
+
+	var err error
+	if exists {
+		_, err = db.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET cursor_value = %s, done = %s, updated_at = CURRENT_TIMESTAMP WHERE name = %s",
+			c.table, p(0), p(1), p(2),
+		), cursor, doneValue, c.name)
+	} else {
+		_, err = db.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (name, cursor_value, done, updated_at) VALUES (%s, %s, %s, CURRENT_TIMESTAMP)",
+			c.table, p(0), p(1), p(2),
+		), c.name, cursor, doneValue)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Reset deletes the checkpoint of the named backfill so
+// the next call to Run starts from the beginning.
+func Reset(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect, checkpointTable string, name string) error {
+	if checkpointTable == "" {
+		checkpointTable = "ktx_backfill_checkpoints"
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE name = %s", dialect.Quote(checkpointTable), dialect.Placeholder(0),
+	), name)
+	if err != nil {
+		return fmt.Errorf("error resetting checkpoint: %w", err)
+	}
+	return nil
+}
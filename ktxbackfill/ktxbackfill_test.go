@@ -0,0 +1,237 @@
+package ktxbackfill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestDB(t *testing.T, numItems int) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, processed INTEGER NOT NULL DEFAULT 0)`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+	for i := 1; i <= numItems; i++ {
+		_, err = db.Exec(`INSERT INTO items (id) VALUES (?)`, i)
+		if err != nil {
+			t.Fatalf("Failed to insert test item: %v", err)
+		}
+	}
+
+	return db
+}
+
+// markProcessed increments the processed column of the next chunk of items.
+func markProcessed(ctx context.Context, tx *ktx.Tx, after int64, limit int) (last int64, n int, err error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM items WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, 0, err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+
+	for _, id := range ids {
+		_, err = tx.ExecContext(ctx, `UPDATE items SET processed = processed + 1 WHERE id = ?`, id)
+		if err != nil {
+			return 0, 0, err
+		}
+		last = id
+	}
+
+	return last, len(ids), nil
+}
+
+func assertAllProcessedOnce(t *testing.T, db *sql.DB, numItems int) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM items WHERE processed = 1`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count processed items: %v", err)
+	}
+	if count != numItems {
+		t.Errorf("Expected %d items processed exactly once, got %d", numItems, count)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should process all rows in chunks", func(t *testing.T) {
+		db := setupTestDB(t, 25)
+		defer db.Close()
+
+		result, err := Run(ctx, db, Config[int64]{
+			Name:      "mark-items",
+			ChunkSize: 10,
+			Dialect:   ktx.SQLite,
+		}, markProcessed)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if result.Chunks != 3 || result.Rows != 25 || result.Resumed {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		assertAllProcessedOnce(t, db, 25)
+	})
+
+	t.Run("should resume from the last checkpoint after a failure", func(t *testing.T) {
+		db := setupTestDB(t, 25)
+		defer db.Close()
+
+		cfg := Config[int64]{
+			Name:      "mark-items",
+			ChunkSize: 10,
+			Dialect:   ktx.SQLite,
+		}
+
+		chunks := 0
+		crashErr := errors.New("fake crash")
+		_, err := Run(ctx, db, cfg, func(ctx context.Context, tx *ktx.Tx, after int64, limit int) (int64, int, error) {
+			chunks++
+			last, n, err := markProcessed(ctx, tx, after, limit)
+			if chunks == 2 {
+				return 0, 0, crashErr
+			}
+			return last, n, err
+		})
+		if !errors.Is(err, crashErr) {
+			t.Fatalf("Expected the crash error, got: %v", err)
+		}
+
+		var resumedFrom int64 = -1
+		result, err := Run(ctx, db, cfg, func(ctx context.Context, tx *ktx.Tx, after int64, limit int) (int64, int, error) {
+			if resumedFrom == -1 {
+				resumedFrom = after
+			}
+			return markProcessed(ctx, tx, after, limit)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if resumedFrom != 10 {
+			t.Errorf("Expected to resume after key 10, got %d", resumedFrom)
+		}
+		if !result.Resumed || result.Rows != 15 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		assertAllProcessedOnce(t, db, 25)
+	})
+
+	t.Run("should not run again after finishing", func(t *testing.T) {
+		db := setupTestDB(t, 5)
+		defer db.Close()
+
+		cfg := Config[int64]{Name: "mark-items", Dialect: ktx.SQLite}
+
+		_, err := Run(ctx, db, cfg, markProcessed)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		called := false
+		result, err := Run(ctx, db, cfg, func(ctx context.Context, tx *ktx.Tx, after int64, limit int) (int64, int, error) {
+			called = true
+			return 0, 0, nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if called || result.Chunks != 0 {
+			t.Errorf("Expected finished backfill to be skipped, called: %v, result: %+v", called, result)
+		}
+		assertAllProcessedOnce(t, db, 5)
+
+		err = Reset(ctx, db, ktx.SQLite, "", "mark-items")
+		if err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+
+		_, err = Run(ctx, db, cfg, func(ctx context.Context, tx *ktx.Tx, after int64, limit int) (int64, int, error) {
+			called = true
+			return 0, 0, nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if !called {
+			t.Errorf("Expected backfill to run again after Reset")
+		}
+	})
+
+	t.Run("should respect the max write rate", func(t *testing.T) {
+		db := setupTestDB(t, 30)
+		defer db.Close()
+
+		start := time.Now()
+		_, err := Run(ctx, db, Config[int64]{
+			Name:             "mark-items",
+			ChunkSize:        10,
+			MaxRowsPerSecond: 200,
+			Dialect:          ktx.SQLite,
+		}, markProcessed)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// The last chunk ends the backfill without pausing,
+		// so only the first two chunks are paced: 2 * 10 / 200 = 100ms
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("Expected backfill to take at least 100ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("should stop when the context is canceled", func(t *testing.T) {
+		db := setupTestDB(t, 30)
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		_, err := Run(ctx, db, Config[int64]{
+			Name:      "mark-items",
+			ChunkSize: 10,
+			Dialect:   ktx.SQLite,
+		}, func(ctx context.Context, tx *ktx.Tx, after int64, limit int) (int64, int, error) {
+			cancel()
+			return markProcessed(ctx, tx, after, limit)
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got: %v", err)
+		}
+	})
+
+	t.Run("should validate the config", func(t *testing.T) {
+		db := setupTestDB(t, 0)
+		defer db.Close()
+
+		for _, cfg := range []Config[int64]{
+			{Dialect: ktx.SQLite},
+			{Name: "no-dialect"},
+		} {
+			_, err := Run(ctx, db, cfg, markProcessed)
+			if err == nil {
+				t.Errorf("Expected error for config %+v", cfg)
+			}
+		}
+	})
+}
@@ -0,0 +1,13 @@
+// Command ktxcheck runs the ktxcheck analyzer:
+//
+//	go run github.com/vingarcia/ktx/ktxcheck/cmd/ktxcheck ./...
+package main
+
+import (
+	"github.com/vingarcia/ktx/ktxcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(ktxcheck.Analyzer)
+}
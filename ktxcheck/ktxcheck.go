@@ -0,0 +1,430 @@
+// Package ktxcheck provides static analyzers that detect common misuses
+// of the github.com/vingarcia/ktx package.
+package ktxcheck
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const ktxPath = "github.com/vingarcia/ktx"
+
+// Analyzer reports misuses of the transactions inside the callbacks
+// of ktx.Transaction and ktx.Run, namely:
+//
+// Statements executed outside of the transaction:
+//
+//	ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+//		_, err := db.ExecContext(ctx, "...") // should be tx.ExecContext
+//		return err
+//	})
+//
+// Manual calls to Commit or Rollback on the transaction,
+// which is already committed or rolled back by ktx:
+//
+//	ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+//		// ...
+//		return tx.Commit() // should be return nil
+//	})
+//
+// And statements that receive a context that doesn't derive from the one
+// passed to ktx.Transaction, which breaks cancellation and tracing:
+//
+//	ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+//		_, err := tx.ExecContext(context.Background(), "...") // should be ctx
+//		return err
+//	})
+var Analyzer = &analysis.Analyzer{
+	Name:     "ktxcheck",
+	Doc:      "reports misuses of the transactions inside ktx.Transaction callbacks",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// statementMethods are the methods that execute statements on the
+// database/sql types and on the ktx runners.
+var statementMethods = map[string]bool{
+	"Exec":            true,
+	"ExecContext":     true,
+	"Query":           true,
+	"QueryContext":    true,
+	"QueryRow":        true,
+	"QueryRowContext": true,
+	"Prepare":         true,
+	"PrepareContext":  true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+
+		tc, ok := transactionCall(pass, call)
+		if !ok {
+			return
+		}
+
+		checkOuterDBUsage(pass, tc)
+		checkManualFinish(pass, tc)
+		checkStatementContexts(pass, tc)
+	})
+
+	return nil, nil
+}
+
+// txCall describes a call to ktx.Transaction or ktx.Run.
+type txCall struct {
+	name     string
+	ctx      ast.Expr
+	db       ast.Expr
+	callback *ast.FuncLit
+}
+
+func transactionCall(pass *analysis.Pass, call *ast.CallExpr) (txCall, bool) {
+	fn := calledFunc(pass, call)
+	if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != ktxPath {
+		return txCall{}, false
+	}
+
+	switch fn.Name() {
+	case "Transaction", "Run":
+	default:
+		return txCall{}, false
+	}
+
+	if len(call.Args) < 3 {
+		return txCall{}, false
+	}
+
+	callback, ok := call.Args[2].(*ast.FuncLit)
+	if !ok {
+		return txCall{}, false
+	}
+
+	return txCall{
+		name:     "ktx." + fn.Name(),
+		ctx:      call.Args[0],
+		db:       call.Args[1],
+		callback: callback,
+	}, true
+}
+
+// checkOuterDBUsage reports statements executed on the same variable or field
+// that was passed as db to the transaction. Other databases, e.g. one used for
+// auditing, are allowed since they are outside of the transaction on purpose.
+func checkOuterDBUsage(pass *analysis.Pass, tc txCall) {
+	outerDB := referencedObject(pass, tc.db)
+	if outerDB == nil {
+		return
+	}
+
+	ast.Inspect(tc.callback.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !statementMethods[sel.Sel.Name] {
+			return true
+		}
+
+		if referencedObject(pass, sel.X) != outerDB {
+			return true
+		}
+
+		pass.Reportf(
+			sel.Pos(),
+			"%s.%s is called inside the callback of %s but runs outside of the transaction, use the callback argument instead",
+			types.ExprString(sel.X), sel.Sel.Name, tc.name,
+		)
+		return true
+	})
+}
+
+// checkManualFinish reports calls to Commit or Rollback on the callback
+// argument or on anything derived from it, e.g. tx.SQLTx() or tx.(*sql.Tx).
+func checkManualFinish(pass *analysis.Pass, tc txCall) {
+	derived := map[types.Object]bool{}
+	for _, field := range tc.callback.Type.Params.List {
+		for _, name := range field.Names {
+			if obj := pass.TypesInfo.Defs[name]; obj != nil {
+				derived[obj] = true
+			}
+		}
+	}
+
+	derivesFromTx := func(expr ast.Expr) bool {
+		return derivesFrom(pass, expr, derived)
+	}
+
+	ast.Inspect(tc.callback.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			if len(node.Lhs) != len(node.Rhs) {
+				return true
+			}
+			for i, rhs := range node.Rhs {
+				if !derivesFromTx(rhs) {
+					continue
+				}
+				if obj := referencedOrDefinedObject(pass, node.Lhs[i]); obj != nil {
+					derived[obj] = true
+				}
+			}
+
+		case *ast.ValueSpec:
+			if len(node.Names) != len(node.Values) {
+				return true
+			}
+			for i, value := range node.Values {
+				if !derivesFromTx(value) {
+					continue
+				}
+				if obj := pass.TypesInfo.Defs[node.Names[i]]; obj != nil {
+					derived[obj] = true
+				}
+			}
+
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Commit" && sel.Sel.Name != "Rollback") {
+				return true
+			}
+
+			if !derivesFromTx(sel.X) {
+				return true
+			}
+
+			pass.Reportf(
+				sel.Pos(),
+				"%s.%s is called inside the callback of %s, but the transaction is already finished by ktx when the callback returns",
+				types.ExprString(sel.X), sel.Sel.Name, tc.name,
+			)
+		}
+		return true
+	})
+}
+
+// checkStatementContexts reports statements inside the callback that receive
+// a context that doesn't derive from the context passed to the transaction.
+func checkStatementContexts(pass *analysis.Pass, tc txCall) {
+	txCtx := referencedObject(pass, tc.ctx)
+	if txCtx == nil {
+		// The context is not a variable, e.g. context.Background(),
+		// so there is no way of telling what derives from it.
+		return
+	}
+
+	derived := map[types.Object]bool{txCtx: true}
+	derivesFromCtx := func(expr ast.Expr) bool {
+		return derivesContext(pass, expr, derived)
+	}
+
+	ast.Inspect(tc.callback.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncLit:
+			// Contexts received by nested functions, e.g. the callbacks of
+			// ktx.AfterCommit, are assumed to be provided by the caller:
+			for _, field := range node.Type.Params.List {
+				for _, name := range field.Names {
+					if obj := pass.TypesInfo.Defs[name]; obj != nil && isContextType(obj.Type()) {
+						derived[obj] = true
+					}
+				}
+			}
+
+		case *ast.AssignStmt:
+			if len(node.Rhs) == 1 && len(node.Lhs) > 1 {
+				// e.g. ctx, cancel := context.WithTimeout(ctx, time.Second)
+				if !derivesFromCtx(node.Rhs[0]) {
+					return true
+				}
+				for _, lhs := range node.Lhs {
+					obj := referencedOrDefinedObject(pass, lhs)
+					if obj != nil && isContextType(obj.Type()) {
+						derived[obj] = true
+					}
+				}
+				return true
+			}
+
+			for i := range node.Lhs {
+				if i >= len(node.Rhs) {
+					break
+				}
+				obj := referencedOrDefinedObject(pass, node.Lhs[i])
+				if obj != nil && isContextType(obj.Type()) && derivesFromCtx(node.Rhs[i]) {
+					derived[obj] = true
+				}
+			}
+
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || !statementMethods[sel.Sel.Name] {
+				return true
+			}
+
+			// Statements outside of the transaction are either reported by
+			// checkOuterDBUsage or run on another database on purpose:
+			receiverType := pass.TypesInfo.TypeOf(sel.X)
+			if !isStatementReceiver(receiverType) || isOutsideTxType(receiverType) {
+				return true
+			}
+
+			if !strings.HasSuffix(sel.Sel.Name, "Context") {
+				pass.Reportf(
+					sel.Pos(),
+					"%s.%s is called inside the callback of %s without a context, use %sContext with the context of the transaction instead",
+					types.ExprString(sel.X), sel.Sel.Name, tc.name, sel.Sel.Name,
+				)
+				return true
+			}
+
+			if len(node.Args) == 0 || derivesFromCtx(node.Args[0]) {
+				return true
+			}
+
+			pass.Reportf(
+				node.Args[0].Pos(),
+				"the context passed to %s.%s does not derive from the context passed to %s",
+				types.ExprString(sel.X), sel.Sel.Name, tc.name,
+			)
+		}
+		return true
+	})
+}
+
+// derivesContext reports whether expr is one of the contexts in the
+// input set or a context derived from them, e.g. context.WithTimeout(ctx, d).
+func derivesContext(pass *analysis.Pass, expr ast.Expr, ctxs map[types.Object]bool) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return ctxs[pass.TypesInfo.Uses[e]]
+	case *ast.SelectorExpr:
+		return ctxs[pass.TypesInfo.Uses[e.Sel]]
+	case *ast.ParenExpr:
+		return derivesContext(pass, e.X, ctxs)
+	case *ast.CallExpr:
+		for _, arg := range e.Args {
+			if derivesContext(pass, arg, ctxs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isContextType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+// isStatementReceiver reports whether t is one of the database/sql
+// types or a ktx runner, whose statement methods should receive
+// the context of the transaction.
+func isStatementReceiver(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	switch named.Obj().Pkg().Path() {
+	case "database/sql", ktxPath:
+		return true
+	}
+	return false
+}
+
+// derivesFrom reports whether expr is one of the objects in the input set
+// or is obtained from them via type assertions or calls to SQLTx.
+func derivesFrom(pass *analysis.Pass, expr ast.Expr, objs map[types.Object]bool) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return objs[pass.TypesInfo.Uses[e]]
+	case *ast.ParenExpr:
+		return derivesFrom(pass, e.X, objs)
+	case *ast.TypeAssertExpr:
+		return derivesFrom(pass, e.X, objs)
+	case *ast.CallExpr:
+		sel, ok := e.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "SQLTx" {
+			return false
+		}
+		return derivesFrom(pass, sel.X, objs)
+	}
+	return false
+}
+
+func referencedOrDefinedObject(pass *analysis.Pass, expr ast.Expr) types.Object {
+	if ident, ok := expr.(*ast.Ident); ok {
+		if obj := pass.TypesInfo.Defs[ident]; obj != nil {
+			return obj
+		}
+	}
+	return referencedObject(pass, expr)
+}
+
+// isOutsideTxType reports whether t is a type that always runs
+// statements outside of any transaction.
+func isOutsideTxType(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+
+	named, ok := ptr.Elem().(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "database/sql" {
+		return false
+	}
+
+	switch named.Obj().Name() {
+	case "DB", "Conn":
+		return true
+	}
+	return false
+}
+
+func calledFunc(pass *analysis.Pass, call *ast.CallExpr) *types.Func {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	case *ast.IndexExpr:
+		return calledFunc(pass, &ast.CallExpr{Fun: fun.X})
+	default:
+		return nil
+	}
+
+	fn, _ := pass.TypesInfo.Uses[ident].(*types.Func)
+	return fn
+}
+
+// referencedObject returns the variable or field referenced by expr, if any.
+func referencedObject(pass *analysis.Pass, expr ast.Expr) types.Object {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return pass.TypesInfo.Uses[e]
+	case *ast.SelectorExpr:
+		return pass.TypesInfo.Uses[e.Sel]
+	case *ast.ParenExpr:
+		return referencedObject(pass, e.X)
+	}
+	return nil
+}
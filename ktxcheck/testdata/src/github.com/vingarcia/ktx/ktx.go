@@ -0,0 +1,34 @@
+// Package ktx is a stub of the real package used by the analyzer tests.
+package ktx
+
+import (
+	"context"
+	"database/sql"
+)
+
+type DBRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type Tx struct{ sqlTx *sql.Tx }
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.sqlTx.ExecContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.sqlTx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) SQLTx() *sql.Tx { return tx.sqlTx }
+
+type Option func()
+
+func Transaction(ctx context.Context, db DBRunner, fn func(db *sql.Tx) error) error {
+	return nil
+}
+
+func Run(ctx context.Context, db DBRunner, fn func(tx *Tx) error, opts ...Option) error {
+	return nil
+}
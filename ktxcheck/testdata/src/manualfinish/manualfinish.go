@@ -0,0 +1,53 @@
+package manualfinish
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/vingarcia/ktx"
+)
+
+func commitsManually(ctx context.Context, db *sql.DB) error {
+	return ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (1)")
+		if err != nil {
+			_ = tx.Rollback() // want `tx.Rollback is called inside the callback of ktx.Transaction, but the transaction is already finished by ktx`
+			return err
+		}
+		return tx.Commit() // want `tx.Commit is called inside the callback of ktx.Transaction`
+	})
+}
+
+func commitsUnderlyingTx(ctx context.Context, db *sql.DB) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		if err := tx.SQLTx().Commit(); err != nil { // want `tx.SQLTx\(\).Commit is called inside the callback of ktx.Run`
+			return err
+		}
+
+		sqlTx := tx.SQLTx()
+		return sqlTx.Rollback() // want `sqlTx.Rollback is called inside the callback of ktx.Run`
+	})
+}
+
+func commitsAssertedRunner(ctx context.Context, db *sql.DB, fn func(ctx context.Context, db ktx.DBRunner) error) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		return fn(ctx, tx)
+	})
+}
+
+func helper(ctx context.Context, db *sql.DB) error {
+	return ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+		var runner ktx.DBRunner = tx
+		return runner.(*sql.Tx).Commit() // want `runner.\(\*sql.Tx\).Commit is called inside the callback of ktx.Transaction`
+	})
+}
+
+func independentTx(ctx context.Context, db *sql.DB, other *sql.DB) error {
+	return ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+		otherTx, err := other.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return otherTx.Commit()
+	})
+}
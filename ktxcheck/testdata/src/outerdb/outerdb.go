@@ -0,0 +1,73 @@
+package outerdb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/vingarcia/ktx"
+)
+
+type service struct {
+	db ktx.DBRunner
+}
+
+func usesOuterDB(ctx context.Context, db *sql.DB) error {
+	return ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO users VALUES (1)") // want `db.ExecContext is called inside the callback of ktx.Transaction but runs outside of the transaction`
+		if err != nil {
+			return err
+		}
+
+		_ = db.QueryRow("SELECT 1") // want `db.QueryRow is called inside the callback of ktx.Transaction`
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO users VALUES (2)")
+		return err
+	})
+}
+
+func usesOuterRunner(ctx context.Context, s service) error {
+	return ktx.Run(ctx, s.db, func(tx *ktx.Tx) error {
+		_, err := s.db.ExecContext(ctx, "INSERT INTO users VALUES (1)") // want `s.db.ExecContext is called inside the callback of ktx.Run`
+		return err
+	})
+}
+
+func usesOuterDBInGoroutine(ctx context.Context, db *sql.DB) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		go func() {
+			_, _ = db.Exec("INSERT INTO users VALUES (1)") // want `db.Exec is called inside the callback of ktx.Run`
+		}()
+		return nil
+	})
+}
+
+func usesAnotherSQLDB(ctx context.Context, db *sql.DB, audit *sql.DB, conn *sql.Conn) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		_, err := audit.ExecContext(ctx, "INSERT INTO audit_log VALUES (1)")
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.ExecContext(ctx, "INSERT INTO audit_log VALUES (2)")
+		return err
+	})
+}
+
+func correctUsage(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO users VALUES (1)")
+	if err != nil {
+		return err
+	}
+
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (2)")
+		if err != nil {
+			return err
+		}
+
+		return ktx.Transaction(ctx, tx, func(sqlTx *sql.Tx) error {
+			_, err := sqlTx.ExecContext(ctx, "INSERT INTO users VALUES (3)")
+			return err
+		})
+	})
+}
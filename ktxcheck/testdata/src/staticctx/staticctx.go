@@ -0,0 +1,59 @@
+package staticctx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+func usesOtherContexts(ctx context.Context, db *sql.DB) error {
+	return ktx.Transaction(ctx, db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(context.Background(), "INSERT INTO users VALUES (1)") // want `the context passed to tx.ExecContext does not derive from the context passed to ktx.Transaction`
+		if err != nil {
+			return err
+		}
+
+		ctx := context.TODO()
+		_, err = tx.QueryContext(ctx, "SELECT 1") // want `the context passed to tx.QueryContext does not derive from the context passed to ktx.Transaction`
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO users VALUES (2)") // want `tx.Exec is called inside the callback of ktx.Transaction without a context`
+		return err
+	})
+}
+
+func usesDerivedContexts(ctx context.Context, db *sql.DB) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (1)")
+		if err != nil {
+			return err
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		_, err = tx.ExecContext(timeoutCtx, "INSERT INTO users VALUES (2)")
+		if err != nil {
+			return err
+		}
+
+		valueCtx := context.WithValue(timeoutCtx, "key", "value")
+		_, err = tx.QueryContext(valueCtx, "SELECT 1")
+		if err != nil {
+			return err
+		}
+
+		return withCallback(func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users VALUES (3)")
+			return err
+		})
+	})
+}
+
+func withCallback(fn func(ctx context.Context) error) error {
+	return fn(context.Background())
+}
@@ -0,0 +1,219 @@
+// Package ktxdualwrite writes to both the old and the new schema or database
+// during a zero-downtime migration.
+//
+// The old target remains the source of truth: its write always runs and
+// decides whether the operation succeeded. The write on the new target
+// either runs in best-effort mode, where failures are only counted and
+// reported, or in strict mode, where a failure on the new target aborts
+// the write on the old one as well.
+package ktxdualwrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Target identifies on which side of the migration a write is running.
+type Target int
+
+const (
+	// TargetOld is the schema or database currently used as the source of truth.
+	TargetOld Target = iota
+	// TargetNew is the schema or database being migrated to.
+	TargetNew
+)
+
+func (t Target) String() string {
+	if t == TargetNew {
+		return "new"
+	}
+	return "old"
+}
+
+// Option configures a Writer.
+type Option func(*config)
+
+type config struct {
+	strict       bool
+	onDivergence func(ctx context.Context, err error)
+}
+
+// WithStrict makes a failure on the new target fail the whole write.
+//
+// When both targets are the same database both writes run on the same
+// transaction. Otherwise the transaction on the new target is committed
+// right before the one on the old target, so a divergence is only possible
+// if that last commit fails.
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithDivergenceHandler registers a function that is called every time
+// the old and the new targets diverge, e.g. for logging the error.
+func WithDivergenceHandler(fn func(ctx context.Context, err error)) Option {
+	return func(c *config) {
+		c.onDivergence = fn
+	}
+}
+
+// WriteFunc performs a write on one of the targets. It is called once for
+// each target so it can adapt the statements to the schema of each of them.
+type WriteFunc func(ctx context.Context, db ktx.DBRunner, target Target) error
+
+// Stats are the counters of a Writer.
+type Stats struct {
+	// Writes counts the writes that succeeded on the old target.
+	Writes uint64
+	// Failures counts the writes that failed on the old target
+	// or were aborted because of the new one.
+	Failures uint64
+	// Divergences counts the writes that were committed on only one of the
+	// targets, these rows need to be reconciled before the migration ends.
+	Divergences uint64
+}
+
+// DivergenceError describes a write that succeeded on one target and
+// failed on the other.
+type DivergenceError struct {
+	Target Target
+	Err    error
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("dual-write diverged, write on %s target failed: %s", e.Target, e.Err)
+}
+
+func (e *DivergenceError) Unwrap() error {
+	return e.Err
+}
+
+// Writer runs each write on both targets.
+type Writer struct {
+	oldDB ktx.DBRunner
+	newDB ktx.DBRunner
+	cfg   config
+
+	writes      atomic.Uint64
+	failures    atomic.Uint64
+	divergences atomic.Uint64
+}
+
+// New creates a Writer for the old and the new targets, which
+// may be the same database when migrating between tables.
+func New(oldDB ktx.DBRunner, newDB ktx.DBRunner, opts ...Option) *Writer {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Writer{
+		oldDB: oldDB,
+		newDB: newDB,
+		cfg:   cfg,
+	}
+}
+
+// Stats returns a snapshot of the counters of the Writer.
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Writes:      w.writes.Load(),
+		Failures:    w.failures.Load(),
+		Divergences: w.divergences.Load(),
+	}
+}
+
+// Write runs fn on the old target and then on the new one,
+// according to the configured mode.
+//
+// In best-effort mode errors from the new target are never returned,
+// they are counted as divergences and sent to the divergence handler.
+func (w *Writer) Write(ctx context.Context, fn WriteFunc) error {
+	var err error
+	if w.cfg.strict {
+		err = w.writeStrict(ctx, fn)
+	} else {
+		err = w.writeBestEffort(ctx, fn)
+	}
+
+	var divergence *DivergenceError
+	if errors.As(err, &divergence) {
+		// In strict mode a divergence happens after the new target
+		// committed, so the write counts as a failure on the old one.
+		w.failures.Add(1)
+		w.diverged(ctx, divergence)
+		return err
+	}
+	if err != nil {
+		w.failures.Add(1)
+		return err
+	}
+
+	w.writes.Add(1)
+	return nil
+}
+
+func (w *Writer) writeBestEffort(ctx context.Context, fn WriteFunc) error {
+	err := ktx.Run(ctx, w.oldDB, func(tx *ktx.Tx) error {
+		return fn(ctx, tx, TargetOld)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ktx.Run(ctx, w.newDB, func(tx *ktx.Tx) error {
+		return fn(ctx, tx, TargetNew)
+	})
+	if err != nil {
+		w.diverged(ctx, &DivergenceError{Target: TargetNew, Err: err})
+	}
+
+	return nil
+}
+
+func (w *Writer) writeStrict(ctx context.Context, fn WriteFunc) error {
+	if w.oldDB == w.newDB {
+		return ktx.Run(ctx, w.oldDB, func(tx *ktx.Tx) error {
+			err := fn(ctx, tx, TargetOld)
+			if err != nil {
+				return err
+			}
+			return fn(ctx, tx, TargetNew)
+		})
+	}
+
+	newCommitted := false
+	err := ktx.Run(ctx, w.oldDB, func(oldTx *ktx.Tx) error {
+		err := fn(ctx, oldTx, TargetOld)
+		if err != nil {
+			return err
+		}
+
+		err = ktx.Run(ctx, w.newDB, func(newTx *ktx.Tx) error {
+			return fn(ctx, newTx, TargetNew)
+		})
+		if err != nil {
+			return fmt.Errorf("error writing on the new target: %w", err)
+		}
+
+		newCommitted = true
+		return nil
+	})
+	if err != nil && newCommitted {
+		return &DivergenceError{Target: TargetOld, Err: err}
+	}
+
+	return err
+}
+
+func (w *Writer) diverged(ctx context.Context, err *DivergenceError) {
+	w.divergences.Add(1)
+	if w.cfg.onDivergence != nil {
+		w.cfg.onDivergence(ctx, err)
+	}
+}
@@ -0,0 +1,200 @@
+package ktxdualwrite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+		CREATE TABLE users_v2 (id INTEGER PRIMARY KEY, full_name TEXT NOT NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+var errNewTarget = errors.New("new target failure")
+
+// insertUser writes a user on both schemas, failing on
+// the new one if failNew is true.
+func insertUser(id int, failNew bool) WriteFunc {
+	return func(ctx context.Context, db ktx.DBRunner, target Target) error {
+		if target == TargetOld {
+			_, err := db.ExecContext(ctx, `INSERT INTO users (id, name) VALUES (?, ?)`, id, "John")
+			return err
+		}
+
+		if failNew {
+			return errNewTarget
+		}
+		_, err := db.ExecContext(ctx, `INSERT INTO users_v2 (id, full_name) VALUES (?, ?)`, id, "John")
+		return err
+	}
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("best-effort mode", func(t *testing.T) {
+		t.Run("should write on both targets", func(t *testing.T) {
+			oldDB, newDB := setupTestDB(t), setupTestDB(t)
+			defer oldDB.Close()
+			defer newDB.Close()
+
+			w := New(oldDB, newDB)
+			err := w.Write(ctx, insertUser(1, false))
+			if err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			if countRows(t, oldDB, "users") != 1 || countRows(t, newDB, "users_v2") != 1 {
+				t.Errorf("Expected the write on both targets")
+			}
+			if stats := w.Stats(); stats != (Stats{Writes: 1}) {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		})
+
+		t.Run("should report failures on the new target as divergences", func(t *testing.T) {
+			oldDB, newDB := setupTestDB(t), setupTestDB(t)
+			defer oldDB.Close()
+			defer newDB.Close()
+
+			var reported error
+			w := New(oldDB, newDB, WithDivergenceHandler(func(ctx context.Context, err error) {
+				reported = err
+			}))
+
+			err := w.Write(ctx, insertUser(1, true))
+			if err != nil {
+				t.Fatalf("Expected no error in best-effort mode, got: %v", err)
+			}
+
+			var divergence *DivergenceError
+			if !errors.As(reported, &divergence) || divergence.Target != TargetNew || !errors.Is(reported, errNewTarget) {
+				t.Errorf("Unexpected divergence error: %v", reported)
+			}
+			if countRows(t, oldDB, "users") != 1 {
+				t.Errorf("Expected the write on the old target to be committed")
+			}
+			if stats := w.Stats(); stats != (Stats{Writes: 1, Divergences: 1}) {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		})
+
+		t.Run("should not write on the new target if the old one fails", func(t *testing.T) {
+			oldDB, newDB := setupTestDB(t), setupTestDB(t)
+			defer oldDB.Close()
+			defer newDB.Close()
+
+			w := New(oldDB, newDB)
+			_, err := oldDB.Exec(`INSERT INTO users (id, name) VALUES (1, 'Jane')`)
+			if err != nil {
+				t.Fatalf("Failed to insert user: %v", err)
+			}
+
+			err = w.Write(ctx, insertUser(1, false))
+			if err == nil {
+				t.Fatalf("Expected error for duplicated id")
+			}
+
+			if countRows(t, newDB, "users_v2") != 0 {
+				t.Errorf("Expected no write on the new target")
+			}
+			if stats := w.Stats(); stats != (Stats{Failures: 1}) {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		})
+	})
+
+	t.Run("strict mode", func(t *testing.T) {
+		t.Run("should roll back the old target if the new one fails", func(t *testing.T) {
+			oldDB, newDB := setupTestDB(t), setupTestDB(t)
+			defer oldDB.Close()
+			defer newDB.Close()
+
+			divergences := 0
+			w := New(oldDB, newDB, WithStrict(), WithDivergenceHandler(func(ctx context.Context, err error) {
+				divergences++
+			}))
+
+			err := w.Write(ctx, insertUser(1, true))
+			if !errors.Is(err, errNewTarget) {
+				t.Fatalf("Expected the new target error, got: %v", err)
+			}
+
+			if countRows(t, oldDB, "users") != 0 {
+				t.Errorf("Expected the write on the old target to be rolled back")
+			}
+			if divergences != 0 {
+				t.Errorf("Expected no divergences, got %d", divergences)
+			}
+			if stats := w.Stats(); stats != (Stats{Failures: 1}) {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		})
+
+		t.Run("should write on both targets", func(t *testing.T) {
+			oldDB, newDB := setupTestDB(t), setupTestDB(t)
+			defer oldDB.Close()
+			defer newDB.Close()
+
+			w := New(oldDB, newDB, WithStrict())
+			err := w.Write(ctx, insertUser(1, false))
+			if err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			if countRows(t, oldDB, "users") != 1 || countRows(t, newDB, "users_v2") != 1 {
+				t.Errorf("Expected the write on both targets")
+			}
+		})
+
+		t.Run("should use a single transaction when both targets are the same database", func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			w := New(db, db, WithStrict())
+
+			var txs []*sql.Tx
+			err := w.Write(ctx, func(ctx context.Context, runner ktx.DBRunner, target Target) error {
+				txs = append(txs, runner.(*ktx.Tx).SQLTx())
+				return insertUser(1, target == TargetNew)(ctx, runner, target)
+			})
+			if !errors.Is(err, errNewTarget) {
+				t.Fatalf("Expected the new target error, got: %v", err)
+			}
+
+			if len(txs) != 2 || txs[0] != txs[1] {
+				t.Errorf("Expected both writes on the same transaction")
+			}
+			if countRows(t, db, "users") != 0 {
+				t.Errorf("Expected the write on the old table to be rolled back")
+			}
+		})
+	})
+}
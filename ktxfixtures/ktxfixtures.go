@@ -0,0 +1,209 @@
+// Package ktxfixtures loads fixtures into database tables inside a single
+// ktx transaction, for seeding development databases and test suites.
+//
+// Each fixture file contains the rows of the table with the same name as
+// the file, e.g. users.json:
+//
+//	[
+//		{"id": 1, "name": "John"},
+//		{"id": 2, "name": "Jane"}
+//	]
+//
+// JSON is supported out of the box and other formats can be added with
+// WithDecoder, e.g. for YAML:
+//
+//	fixtures, err := ktxfixtures.Read(fsys, ktxfixtures.WithDecoder(".yaml", yaml.Unmarshal))
+//
+// The tables are filled in an order that respects their foreign keys,
+// which are read from the database.
+package ktxfixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Option configures how the fixtures are read.
+type Option func(*config)
+
+type config struct {
+	decoders map[string]func(data []byte, v interface{}) error
+}
+
+// WithDecoder registers a decoder for the fixture files with the
+// input extension, e.g. ".yaml", the decoder must be able to decode
+// the file into a *[]map[string]interface{}.
+func WithDecoder(ext string, decode func(data []byte, v interface{}) error) Option {
+	return func(c *config) {
+		c.decoders[ext] = decode
+	}
+}
+
+// Fixtures are the rows to be inserted on each table.
+type Fixtures struct {
+	tables map[string][]map[string]interface{}
+}
+
+// Read reads all fixture files at the root of fsys whose extension
+// has a registered decoder.
+func Read(fsys fs.FS, opts ...Option) (*Fixtures, error) {
+	cfg := config{
+		decoders: map[string]func([]byte, interface{}) error{
+			".json": decodeJSON,
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error listing fixtures: %w", err)
+	}
+
+	fixtures := &Fixtures{
+		tables: map[string][]map[string]interface{}{},
+	}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		decode, ok := cfg.decoders[ext]
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading fixture %s: %w", entry.Name(), err)
+		}
+
+		var rows []map[string]interface{}
+		err = decode(data, &rows)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding fixture %s: %w", entry.Name(), err)
+		}
+
+		table := strings.TrimSuffix(entry.Name(), ext)
+		fixtures.tables[table] = append(fixtures.tables[table], rows...)
+	}
+
+	return fixtures, nil
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Tables returns the names of the tables with fixtures.
+func (f *Fixtures) Tables() []string {
+	tables := make([]string, 0, len(f.tables))
+	for table := range f.tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Load inserts the fixtures in a single transaction, if db is already
+// a transaction the fixtures are inserted on it.
+func (f *Fixtures) Load(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect) error {
+	return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		order, err := insertionOrder(ctx, tx, dialect, f.Tables())
+		if err != nil {
+			return err
+		}
+
+		for _, table := range order {
+			for i, row := range f.tables[table] {
+				err := insertRow(ctx, tx, dialect, table, row)
+				if err != nil {
+					return fmt.Errorf("error inserting fixture #%d on table '%s': %w", i+1, table, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+var errRollback = errors.New("rollback requested by ktxfixtures")
+
+// LoadAndRollback loads the fixtures and calls fn inside a transaction
+// that is always rolled back afterwards, so each test can start from
+// the same state without cleaning up after itself.
+//
+// If db is already a transaction started by ktx, the fixtures are loaded
+// inside of it and rolled back to a savepoint with ktx.Attempt instead.
+//
+// The error returned by fn is returned by LoadAndRollback.
+func (f *Fixtures) LoadAndRollback(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect, fn func(tx *ktx.Tx) error) error {
+	var fnErr error
+	loadAndRollback := func(tx *ktx.Tx) error {
+		err := f.Load(ctx, tx, dialect)
+		if err != nil {
+			return err
+		}
+
+		fnErr = fn(tx)
+		return errRollback
+	}
+
+	var err error
+	if _, ok := db.(ktx.TxBeginner); ok {
+		err = ktx.Run(ctx, db, loadAndRollback)
+	} else {
+		// ktx.Run would reuse the transaction, which the
+		// caller would then commit with the fixtures:
+		err = ktx.Attempt(ctx, db, loadAndRollback)
+	}
+	if err != nil && !errors.Is(err, errRollback) {
+		return err
+	}
+
+	return fnErr
+}
+
+func insertRow(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect, table string, row map[string]interface{}) error {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		quoted[i] = dialect.Quote(column)
+		placeholders[i] = dialect.Placeholder(i)
+
+		value := row[column]
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			// Nested values are stored as JSON, e.g. on JSON columns:
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			value = string(b)
+		}
+		args[i] = value
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		dialect.Quote(table),
+		strings.Join(quoted, ", "),
+		strings.Join(placeholders, ", "),
+	), args...)
+	return err
+}
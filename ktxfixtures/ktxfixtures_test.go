@@ -0,0 +1,220 @@
+package ktxfixtures
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, settings TEXT);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users(id), title TEXT);
+		CREATE TABLE comments (id INTEGER PRIMARY KEY, post_id INTEGER NOT NULL REFERENCES posts(id), body TEXT);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+
+	return db
+}
+
+var testFixtures = fstest.MapFS{
+	"comments.json": {Data: []byte(`[{"id": 1, "post_id": 1, "body": "first!"}]`)},
+	"posts.json":    {Data: []byte(`[{"id": 1, "user_id": 1, "title": "Hello"}]`)},
+	"users.json":    {Data: []byte(`[{"id": 1, "name": "John", "settings": {"theme": "dark"}}]`)},
+	"README.md":     {Data: []byte("not a fixture")},
+}
+
+func countRows(t *testing.T, db ktx.DBRunner, table string) (count int) {
+	rows, err := db.QueryContext(context.Background(), "SELECT COUNT(*) FROM "+table)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	rows.Next()
+	err = rows.Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to scan count: %v", err)
+	}
+	return count
+}
+
+func TestFixtures_Load(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	fixtures, err := Read(testFixtures)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(fixtures.Tables(), ",") != "comments,posts,users" {
+		t.Fatalf("unexpected tables: %v", fixtures.Tables())
+	}
+
+	err = fixtures.Load(context.Background(), db, ktx.SQLite)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, table := range []string{"users", "posts", "comments"} {
+		if n := countRows(t, db, table); n != 1 {
+			t.Fatalf("expected 1 row on %s, got %d", table, n)
+		}
+	}
+
+	var settings string
+	err = db.QueryRow("SELECT settings FROM users WHERE id = 1").Scan(&settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings != `{"theme":"dark"}` {
+		t.Fatalf("expected nested values to be stored as JSON, got: %s", settings)
+	}
+}
+
+func TestFixtures_LoadIsAtomic(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	fixtures, err := Read(fstest.MapFS{
+		"users.json": {Data: []byte(`[{"id": 1, "name": "John"}]`)},
+		"posts.json": {Data: []byte(`[{"id": 1, "user_id": 42, "title": "Orphan"}]`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = fixtures.Load(context.Background(), db, ktx.SQLite)
+	if err == nil || !strings.Contains(err.Error(), "posts") {
+		t.Fatalf("expected a foreign key error on posts, got: %v", err)
+	}
+
+	if n := countRows(t, db, "users"); n != 0 {
+		t.Fatalf("expected the users to be rolled back, got %d rows", n)
+	}
+}
+
+func TestFixtures_LoadAndRollback(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	fixtures, err := Read(testFixtures)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = fixtures.LoadAndRollback(context.Background(), db, ktx.SQLite, func(tx *ktx.Tx) error {
+		if n := countRows(t, tx, "comments"); n != 1 {
+			t.Fatalf("expected the fixtures to be visible inside the transaction, got %d rows", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := countRows(t, db, "users"); n != 0 {
+		t.Fatalf("expected the fixtures to be rolled back, got %d rows", n)
+	}
+}
+
+func TestFixtures_LoadAndRollback_InsideTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	fixtures, err := Read(testFixtures)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		err := fixtures.LoadAndRollback(ctx, tx, ktx.SQLite, func(tx *ktx.Tx) error {
+			if n := countRows(t, tx, "comments"); n != 1 {
+				t.Fatalf("expected the fixtures to be visible inside the transaction, got %d rows", n)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO users (id, name) VALUES (2, 'Jane')`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := countRows(t, db, "users"); n != 1 {
+		t.Fatalf("expected only the user of the outer transaction to be committed, got %d rows", n)
+	}
+}
+
+func TestRead_WithDecoder(t *testing.T) {
+	// A fake decoder for a line based format: one name per line
+	decodeNames := func(data []byte, v interface{}) error {
+		var rows []map[string]interface{}
+		for _, name := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			rows = append(rows, map[string]interface{}{"name": name})
+		}
+		b, err := json.Marshal(rows)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+
+	fixtures, err := Read(fstest.MapFS{
+		"users.names": {Data: []byte("John\nJane\n")},
+	}, WithDecoder(".names", decodeNames))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	err = fixtures.Load(context.Background(), db, ktx.SQLite)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := countRows(t, db, "users"); n != 2 {
+		t.Fatalf("expected 2 users, got %d", n)
+	}
+}
+
+func TestTopologicalSort(t *testing.T) {
+	order, err := topologicalSort([]string{"a", "b", "c"}, map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(order, ",") != "c,b,a" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+
+	_, err = topologicalSort([]string{"a", "b"}, map[string][]string{
+		"a": {"b"},
+		"b": {"a"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("expected a circular dependency error, got: %v", err)
+	}
+}
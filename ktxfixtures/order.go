@@ -0,0 +1,115 @@
+package ktxfixtures
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vingarcia/ktx"
+)
+
+// insertionOrder sorts the tables so that the tables referenced by the
+// foreign keys of other tables come first.
+func insertionOrder(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect, tables []string) ([]string, error) {
+	inSet := map[string]bool{}
+	for _, table := range tables {
+		inSet[table] = true
+	}
+
+	deps := map[string][]string{}
+	for _, table := range tables {
+		referenced, err := referencedTables(ctx, db, dialect, table)
+		if err != nil {
+			return nil, fmt.Errorf("error reading foreign keys of table '%s': %w", table, err)
+		}
+
+		for _, ref := range referenced {
+			if ref != table && inSet[ref] {
+				deps[table] = append(deps[table], ref)
+			}
+		}
+	}
+
+	return topologicalSort(tables, deps)
+}
+
+func topologicalSort(tables []string, deps map[string][]string) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	order := make([]string, 0, len(tables))
+
+	var visit func(table string, path []string) error
+	visit = func(table string, path []string) error {
+		switch state[table] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular foreign keys between tables: %s", strings.Join(append(path, table), " -> "))
+		}
+
+		state[table] = visiting
+		for _, dep := range deps[table] {
+			err := visit(dep, append(path, table))
+			if err != nil {
+				return err
+			}
+		}
+		state[table] = visited
+
+		order = append(order, table)
+		return nil
+	}
+
+	for _, table := range tables {
+		err := visit(table, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func referencedTables(ctx context.Context, db ktx.DBRunner, dialect ktx.Dialect, table string) ([]string, error) {
+	var query string
+	switch dialect.Name() {
+	case ktx.SQLite.Name():
+		query = `SELECT "table" FROM pragma_foreign_key_list(?)`
+	case ktx.Postgres.Name():
+		query = `SELECT ccu.table_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.constraint_column_usage ccu
+				ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema
+			WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1`
+	case ktx.MySQL.Name():
+		query = `SELECT REFERENCED_TABLE_NAME
+			FROM information_schema.KEY_COLUMN_USAGE
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL`
+	default:
+		// Without foreign key information the tables are inserted in alphabetical order:
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var referenced []string
+	for rows.Next() {
+		var ref string
+		err := rows.Scan(&ref)
+		if err != nil {
+			return nil, err
+		}
+		referenced = append(referenced, ref)
+	}
+
+	return referenced, rows.Err()
+}
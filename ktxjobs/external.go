@@ -0,0 +1,290 @@
+package ktxjobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Enqueuer pushes jobs into an external job queue, such as River or asynq.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload json.RawMessage) error
+}
+
+// ExternalJob is a job pushed by External into a BatchEnqueuer.
+type ExternalJob struct {
+	// ID is the ID returned by MessageIDFromContext for the job.
+	ID      string
+	Kind    string
+	Payload json.RawMessage
+}
+
+// BatchEnqueuer is implemented by the Enqueuers that can push several
+// jobs at once, in which case External pushes all the jobs enqueued by a
+// transaction with a single call to EnqueueBatch after it commits.
+//
+// The relay jobs of WithFallback are still pushed one by one with Enqueue.
+type BatchEnqueuer interface {
+	Enqueuer
+	EnqueueBatch(ctx context.Context, jobs []ExternalJob) error
+}
+
+// ExternalOption configures an External.
+type ExternalOption func(*External)
+
+// WithFallback makes External also record each job on the table of q in
+// the same transaction as the caller, as a relay job that runs after
+// delay. Once the job is pushed after the commit the relay job is
+// removed, otherwise the poller of q pushes it once it is due, so no
+// job is lost if the external queue is unavailable or the process
+// crashes right after the commit.
+//
+// Since the push and the removal of the relay job are not atomic, a job
+// may be pushed more than once, so the workers of the external queue
+// should be idempotent.
+//
+// The name identifies the relay jobs of this External on q, so each
+// External sharing the same Queue must use a different name.
+func WithFallback(q *Queue, name string, delay time.Duration) ExternalOption {
+	return func(x *External) {
+		x.fallback = q
+		x.relayKind = "ktxjobs.relay:" + name
+		x.relayDelay = delay
+	}
+}
+
+// WithErrorHandler sets a function that receives the errors that happen
+// after the commit, since they can no longer be returned to the caller.
+func WithErrorHandler(fn func(ctx context.Context, err error)) ExternalOption {
+	return func(x *External) {
+		x.onError = fn
+	}
+}
+
+// External pushes jobs into an external queue only
+// after the transaction of the caller commits.
+type External struct {
+	enqueuer   Enqueuer
+	fallback   *Queue
+	relayKind  string
+	relayDelay time.Duration
+	onError    func(ctx context.Context, err error)
+
+	// The jobs of each transaction pushed with a BatchEnqueuer:
+	mu      sync.Mutex
+	batches map[*ktx.Tx][]pendingJob
+}
+
+type pendingJob struct {
+	job   ExternalJob
+	relay *relayPayload
+}
+
+// NewExternal creates an External for the input Enqueuer.
+//
+// When WithFallback is used the relay handler is registered on the
+// fallback Queue, so every process polling that Queue must call
+// NewExternal with the same options.
+func NewExternal(enqueuer Enqueuer, opts ...ExternalOption) *External {
+	x := &External{
+		enqueuer: enqueuer,
+		batches:  map[*ktx.Tx][]pendingJob{},
+	}
+	for _, opt := range opts {
+		opt(x)
+	}
+
+	if x.fallback != nil {
+		x.fallback.Register(x.relayKind, x.relay)
+	}
+
+	return x
+}
+
+type messageIDKey struct{}
+
+// MessageIDFromContext returns the ID of the job being pushed by External
+// to its Enqueuer, which is the same for the push after the commit and for
+// the push of its relay job, so adapters can use it for deduplication.
+func MessageIDFromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(messageIDKey{}).(string)
+	return id, ok
+}
+
+type relayPayload struct {
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// Token makes the relay job unique so it can be removed
+	// without knowing the ID generated for it.
+	Token string `json:"token"`
+}
+
+// Enqueue schedules a job to be pushed into the external queue
+// after the transaction behind db commits. The payload is encoded
+// as JSON.
+//
+// Without WithFallback the job is lost if the push fails, and the
+// error is sent to the handler set with WithErrorHandler.
+func (x *External) Enqueue(ctx context.Context, db ktx.DBRunner, kind string, payload interface{}) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding payload of job '%s': %w", kind, err)
+	}
+
+	token := newToken()
+
+	var relay *relayPayload
+	if x.fallback != nil {
+		relay = &relayPayload{
+			Kind:    kind,
+			Payload: encoded,
+			Token:   token,
+		}
+
+		err = x.fallback.Enqueue(ctx, db, x.relayKind, relay, time.Now().Add(x.relayDelay))
+		if err != nil {
+			return err
+		}
+	}
+
+	if batcher, ok := x.enqueuer.(BatchEnqueuer); ok {
+		return x.enqueueBatched(db, batcher, pendingJob{
+			job: ExternalJob{
+				ID:      token,
+				Kind:    kind,
+				Payload: encoded,
+			},
+			relay: relay,
+		})
+	}
+
+	return ktx.AfterCommit(db, func(ctx context.Context) {
+		err := x.enqueuer.Enqueue(context.WithValue(ctx, messageIDKey{}, token), kind, encoded)
+		if err != nil {
+			x.reportError(ctx, fmt.Errorf("error pushing job '%s' after commit: %w", kind, err))
+			return
+		}
+
+		if relay != nil {
+			err = x.removeRelay(ctx, relay)
+			if err != nil {
+				x.reportError(ctx, err)
+			}
+		}
+	})
+}
+
+// enqueueBatched adds the job to the batch of the transaction behind db,
+// which is pushed once the transaction commits.
+func (x *External) enqueueBatched(db ktx.DBRunner, batcher BatchEnqueuer, pending pendingJob) error {
+	tx, err := ktx.TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	first := len(x.batches[tx]) == 0
+	x.batches[tx] = append(x.batches[tx], pending)
+	x.mu.Unlock()
+
+	// Each job removes itself from the batch when it is rolled back, which
+	// also covers the jobs enqueued inside a ktx.Attempt that failed:
+	err = ktx.AfterRollback(db, func(ctx context.Context, err error) {
+		x.mu.Lock()
+		defer x.mu.Unlock()
+
+		jobs := x.batches[tx]
+		for i := range jobs {
+			if jobs[i].job.ID == pending.job.ID {
+				jobs = append(jobs[:i:i], jobs[i+1:]...)
+				break
+			}
+		}
+		if len(jobs) == 0 {
+			delete(x.batches, tx)
+			return
+		}
+		x.batches[tx] = jobs
+	})
+	if err != nil || !first {
+		return err
+	}
+
+	return ktx.AfterCommit(db, func(ctx context.Context) {
+		x.mu.Lock()
+		jobs := x.batches[tx]
+		delete(x.batches, tx)
+		x.mu.Unlock()
+
+		if len(jobs) == 0 {
+			return
+		}
+
+		batch := make([]ExternalJob, len(jobs))
+		for i, pending := range jobs {
+			batch[i] = pending.job
+		}
+
+		err := batcher.EnqueueBatch(ctx, batch)
+		if err != nil {
+			x.reportError(ctx, fmt.Errorf("error pushing batch of %d jobs after commit: %w", len(batch), err))
+			return
+		}
+
+		for _, pending := range jobs {
+			if pending.relay == nil {
+				continue
+			}
+
+			err = x.removeRelay(ctx, pending.relay)
+			if err != nil {
+				x.reportError(ctx, err)
+			}
+		}
+	})
+}
+
+func (x *External) relay(ctx context.Context, tx *ktx.Tx, job Job) error {
+	var relay relayPayload
+	err := json.Unmarshal(job.Payload, &relay)
+	if err != nil {
+		return fmt.Errorf("error decoding relay job: %w", err)
+	}
+
+	return x.enqueuer.Enqueue(context.WithValue(ctx, messageIDKey{}, relay.Token), relay.Kind, relay.Payload)
+}
+
+func (x *External) removeRelay(ctx context.Context, relay *relayPayload) error {
+	encoded, err := json.Marshal(relay)
+	if err != nil {
+		return err
+	}
+
+	q := x.fallback
+	_, err = q.db.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE kind = %s AND payload = %s",
+		q.dialect.Quote(q.table), q.dialect.Placeholder(0), q.dialect.Placeholder(1),
+	), x.relayKind, string(encoded))
+	if err != nil {
+		return fmt.Errorf("error removing relay job of '%s': %w", relay.Kind, err)
+	}
+	return nil
+}
+
+func (x *External) reportError(ctx context.Context, err error) {
+	if x.onError != nil {
+		x.onError(ctx, err)
+	}
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
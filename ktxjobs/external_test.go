@@ -0,0 +1,237 @@
+package ktxjobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+type fakeEnqueuer struct {
+	err  error
+	jobs []string
+	ids  []string
+}
+
+func (f *fakeEnqueuer) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	id, _ := MessageIDFromContext(ctx)
+	f.ids = append(f.ids, id)
+	if f.err != nil {
+		return f.err
+	}
+	f.jobs = append(f.jobs, kind+":"+string(payload))
+	return nil
+}
+
+func TestExternal(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should only push jobs after commit", func(t *testing.T) {
+		db, _ := setupTestQueue(t)
+		defer db.Close()
+
+		enqueuer := &fakeEnqueuer{}
+		x := NewExternal(enqueuer)
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 1})
+			if err != nil {
+				return err
+			}
+
+			if len(enqueuer.jobs) != 0 {
+				t.Errorf("expected no jobs before commit")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 2})
+			if err != nil {
+				return err
+			}
+			return errors.New("fake error")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if len(enqueuer.jobs) != 1 || enqueuer.jobs[0] != `send-email:{"order_id":1}` {
+			t.Errorf("unexpected jobs: %v", enqueuer.jobs)
+		}
+	})
+
+	t.Run("should report push errors without a fallback", func(t *testing.T) {
+		db, _ := setupTestQueue(t)
+		defer db.Close()
+
+		var reported error
+		x := NewExternal(&fakeEnqueuer{err: errors.New("queue unavailable")}, WithErrorHandler(func(ctx context.Context, err error) {
+			reported = err
+		}))
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			return x.Enqueue(ctx, tx, "send-email", nil)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if reported == nil {
+			t.Errorf("expected the push error to be reported")
+		}
+	})
+
+	t.Run("should remove the relay job once the job is pushed", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		enqueuer := &fakeEnqueuer{}
+		x := NewExternal(enqueuer, WithFallback(q, "fake", time.Minute))
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			return x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 1})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(enqueuer.jobs) != 1 {
+			t.Errorf("expected the job to be pushed, got: %v", enqueuer.jobs)
+		}
+		if count := countJobs(t, db); count != 0 {
+			t.Errorf("expected the relay job to be removed, got %d jobs", count)
+		}
+	})
+
+	t.Run("should relay the jobs that failed to be pushed", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		enqueuer := &fakeEnqueuer{err: errors.New("queue unavailable")}
+		x := NewExternal(enqueuer, WithFallback(q, "fake", 0))
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			return x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 1})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if count := countJobs(t, db); count != 1 {
+			t.Fatalf("expected the relay job to remain, got %d jobs", count)
+		}
+
+		// Once the queue is back the poller pushes the job:
+		enqueuer.err = nil
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+
+		if attempted != 1 || len(enqueuer.jobs) != 1 || enqueuer.jobs[0] != `send-email:{"order_id":1}` {
+			t.Errorf("unexpected jobs: %v", enqueuer.jobs)
+		}
+		if len(enqueuer.ids) != 2 || enqueuer.ids[0] == "" || enqueuer.ids[0] != enqueuer.ids[1] {
+			t.Errorf("expected the same message ID on the push and on the relay, got: %v", enqueuer.ids)
+		}
+		if count := countJobs(t, db); count != 0 {
+			t.Errorf("expected the relay job to be removed, got %d jobs", count)
+		}
+	})
+}
+
+type fakeBatchEnqueuer struct {
+	fakeEnqueuer
+	batches [][]ExternalJob
+}
+
+func (f *fakeBatchEnqueuer) EnqueueBatch(ctx context.Context, jobs []ExternalJob) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, jobs)
+	return nil
+}
+
+func TestExternalBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should push the jobs of a transaction in a single batch", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		enqueuer := &fakeBatchEnqueuer{}
+		x := NewExternal(enqueuer, WithFallback(q, "fake", time.Minute))
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 1})
+			if err != nil {
+				return err
+			}
+
+			// The jobs of failed attempts are not pushed:
+			_ = ktx.Attempt(ctx, tx, func(tx *ktx.Tx) error {
+				err := x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 2})
+				if err != nil {
+					return err
+				}
+				return errors.New("fake error")
+			})
+
+			return x.Enqueue(ctx, tx, "send-sms", orderPayload{OrderID: 3})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(enqueuer.batches) != 1 || len(enqueuer.batches[0]) != 2 {
+			t.Fatalf("expected a single batch with 2 jobs, got: %v", enqueuer.batches)
+		}
+		first, second := enqueuer.batches[0][0], enqueuer.batches[0][1]
+		if first.Kind != "send-email" || string(first.Payload) != `{"order_id":1}` || first.ID == "" {
+			t.Errorf("unexpected first job: %+v", first)
+		}
+		if second.Kind != "send-sms" || string(second.Payload) != `{"order_id":3}` || second.ID == first.ID {
+			t.Errorf("unexpected second job: %+v", second)
+		}
+		if len(enqueuer.jobs) != 0 {
+			t.Errorf("expected no single pushes, got: %v", enqueuer.jobs)
+		}
+		if count := countJobs(t, db); count != 0 {
+			t.Errorf("expected the relay jobs to be removed, got %d jobs", count)
+		}
+		if len(x.batches) != 0 {
+			t.Errorf("expected no pending batches, got %d", len(x.batches))
+		}
+	})
+
+	t.Run("should discard the batch on rollback", func(t *testing.T) {
+		db, _ := setupTestQueue(t)
+		defer db.Close()
+
+		enqueuer := &fakeBatchEnqueuer{}
+		x := NewExternal(enqueuer)
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := x.Enqueue(ctx, tx, "send-email", orderPayload{OrderID: 1})
+			if err != nil {
+				return err
+			}
+			return errors.New("fake error")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if len(enqueuer.batches) != 0 || len(x.batches) != 0 {
+			t.Errorf("expected no batches, got pushed: %v, pending: %d", enqueuer.batches, len(x.batches))
+		}
+	})
+}
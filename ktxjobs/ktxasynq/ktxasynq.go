@@ -0,0 +1,36 @@
+// Package ktxasynq adapts asynq clients to the ktxjobs.Enqueuer
+// interface, so tasks can be pushed into asynq after the commit
+// of a ktx transaction with ktxjobs.External.
+package ktxasynq
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hibiken/asynq"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// Enqueuer enqueues the jobs as asynq tasks whose type is
+// the kind of the job and whose payload is its JSON payload.
+type Enqueuer struct {
+	client *asynq.Client
+	opts   []asynq.Option
+}
+
+var _ ktxjobs.Enqueuer = (*Enqueuer)(nil)
+
+// New creates an Enqueuer for the input client, the
+// asynq.Options are used for all tasks.
+func New(client *asynq.Client, opts ...asynq.Option) *Enqueuer {
+	return &Enqueuer{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Enqueue enqueues a task of the input kind.
+func (e *Enqueuer) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	_, err := e.client.EnqueueContext(ctx, asynq.NewTask(kind, payload), e.opts...)
+	return err
+}
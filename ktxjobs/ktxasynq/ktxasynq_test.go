@@ -0,0 +1,34 @@
+package ktxasynq
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+)
+
+func TestEnqueuer(t *testing.T) {
+	redis := miniredis.RunT(t)
+
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: redis.Addr()})
+	defer func() { _ = client.Close() }()
+
+	e := New(client, asynq.Queue("emails"))
+	err := e.Enqueue(context.Background(), "send-email", json.RawMessage(`{"to":"john@example.com"}`))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redis.Addr()})
+	defer func() { _ = inspector.Close() }()
+
+	tasks, err := inspector.ListPendingTasks("emails")
+	if err != nil {
+		t.Fatalf("ListPendingTasks failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Type != "send-email" || string(tasks[0].Payload) != `{"to":"john@example.com"}` {
+		t.Errorf("unexpected tasks: %+v", tasks)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/vingarcia/ktx/ktxjobs/ktxaws
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.30.0
+	github.com/aws/aws-sdk-go-v2/service/sns v1.31.0
+	github.com/aws/aws-sdk-go-v2/service/sqs v1.34.0
+	github.com/vingarcia/ktx v0.0.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.12 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.12 // indirect
+	github.com/aws/smithy-go v1.20.2 // indirect
+)
+
+replace github.com/vingarcia/ktx => ../../
@@ -0,0 +1,134 @@
+// Package ktxaws adapts Amazon SQS queues and SNS topics to the
+// ktxjobs.BatchEnqueuer interface, so events can be published to
+// AWS after the commit of a ktx transaction with ktxjobs.External.
+package ktxaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// KindAttribute is the message attribute that carries the kind of the job.
+const KindAttribute = "ktx-kind"
+
+// maxBatchSize is the maximum number of entries accepted
+// by SendMessageBatch and PublishBatch.
+const maxBatchSize = 10
+
+// Option configures the SQS and SNS Enqueuers.
+type Option func(*config)
+
+type config struct {
+	attributes func(kind string, payload json.RawMessage) map[string]string
+	groupID    func(kind string, payload json.RawMessage) string
+}
+
+// WithAttributes adds string message attributes derived from each job to
+// its message, besides KindAttribute, e.g. for SNS subscription filters.
+//
+// AWS accepts at most 10 attributes per message.
+func WithAttributes(attributes func(kind string, payload json.RawMessage) map[string]string) Option {
+	return func(c *config) {
+		c.attributes = attributes
+	}
+}
+
+// WithMessageGroup sets the message group of each job, which is required
+// by FIFO queues and topics, and uses ktxjobs.MessageIDFromContext as the
+// deduplication ID, so the push after the commit and the push of its relay
+// job are only delivered once within the deduplication interval.
+func WithMessageGroup(groupID func(kind string, payload json.RawMessage) string) Option {
+	return func(c *config) {
+		c.groupID = groupID
+	}
+}
+
+// message is the representation of a job shared by SQS and SNS.
+type message struct {
+	id         string
+	body       string
+	attributes map[string]string
+	groupID    *string
+	dedupID    *string
+}
+
+func (c config) message(job ktxjobs.ExternalJob) message {
+	attributes := map[string]string{KindAttribute: job.Kind}
+	if c.attributes != nil {
+		for name, value := range c.attributes(job.Kind, job.Payload) {
+			attributes[name] = value
+		}
+	}
+
+	msg := message{
+		id:         job.ID,
+		body:       string(job.Payload),
+		attributes: attributes,
+	}
+	if c.groupID != nil {
+		msg.groupID = aws.String(c.groupID(job.Kind, job.Payload))
+		if job.ID != "" {
+			msg.dedupID = aws.String(job.ID)
+		}
+	}
+	return msg
+}
+
+// contextJob builds the ExternalJob of the jobs pushed one by one.
+func contextJob(ctx context.Context, kind string, payload json.RawMessage) ktxjobs.ExternalJob {
+	id, _ := ktxjobs.MessageIDFromContext(ctx)
+	return ktxjobs.ExternalJob{
+		ID:      id,
+		Kind:    kind,
+		Payload: payload,
+	}
+}
+
+// chunks splits the jobs into the batches accepted by AWS.
+func chunks(jobs []ktxjobs.ExternalJob) [][]ktxjobs.ExternalJob {
+	var batches [][]ktxjobs.ExternalJob
+	for len(jobs) > maxBatchSize {
+		batches = append(batches, jobs[:maxBatchSize])
+		jobs = jobs[maxBatchSize:]
+	}
+	if len(jobs) > 0 {
+		batches = append(batches, jobs)
+	}
+	return batches
+}
+
+// entryID returns the ID of the i-th entry of a batch, which only
+// needs to be unique within the batch.
+func entryID(i int) *string {
+	return aws.String(strconv.Itoa(i))
+}
+
+// batchFailure describes the entries of a batch rejected by AWS.
+type batchFailure struct {
+	id      string
+	code    string
+	message string
+}
+
+func batchError(batch []ktxjobs.ExternalJob, failures []batchFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, len(failures))
+	for i, failure := range failures {
+		kind := failure.id
+		if idx, err := strconv.Atoi(failure.id); err == nil && idx < len(batch) {
+			kind = batch[idx].Kind
+		}
+		descriptions[i] = fmt.Sprintf("'%s': %s: %s", kind, failure.code, failure.message)
+	}
+
+	return fmt.Errorf("%d of %d jobs failed to be pushed: %s", len(failures), len(batch), strings.Join(descriptions, ", "))
+}
@@ -0,0 +1,105 @@
+package ktxaws
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// SNSAPI is the subset of *sns.Client used by the SNS Enqueuer.
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+	PublishBatch(ctx context.Context, params *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error)
+}
+
+// SNS publishes the jobs as messages of an SNS topic whose body is the
+// JSON payload of the job, with its kind on the KindAttribute attribute.
+type SNS struct {
+	client   SNSAPI
+	topicARN string
+	cfg      config
+}
+
+var _ ktxjobs.BatchEnqueuer = (*SNS)(nil)
+
+// NewSNS creates an SNS Enqueuer that publishes the jobs to the topic of
+// the input ARN, the jobs of each transaction are sent with PublishBatch.
+func NewSNS(client SNSAPI, topicARN string, opts ...Option) *SNS {
+	e := &SNS{
+		client:   client,
+		topicARN: topicARN,
+	}
+	for _, opt := range opts {
+		opt(&e.cfg)
+	}
+	return e
+}
+
+// Enqueue publishes a message for the job of the input kind.
+func (e *SNS) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	msg := e.cfg.message(contextJob(ctx, kind, payload))
+
+	_, err := e.client.Publish(ctx, &sns.PublishInput{
+		TopicArn:               aws.String(e.topicARN),
+		Message:                aws.String(msg.body),
+		MessageAttributes:      snsAttributes(msg.attributes),
+		MessageGroupId:         msg.groupID,
+		MessageDeduplicationId: msg.dedupID,
+	})
+	return err
+}
+
+// EnqueueBatch publishes the jobs with PublishBatch,
+// in batches of up to 10 messages.
+func (e *SNS) EnqueueBatch(ctx context.Context, jobs []ktxjobs.ExternalJob) error {
+	for _, batch := range chunks(jobs) {
+		entries := make([]types.PublishBatchRequestEntry, len(batch))
+		for i, job := range batch {
+			msg := e.cfg.message(job)
+			entries[i] = types.PublishBatchRequestEntry{
+				Id:                     entryID(i),
+				Message:                aws.String(msg.body),
+				MessageAttributes:      snsAttributes(msg.attributes),
+				MessageGroupId:         msg.groupID,
+				MessageDeduplicationId: msg.dedupID,
+			}
+		}
+
+		out, err := e.client.PublishBatch(ctx, &sns.PublishBatchInput{
+			TopicArn:                   aws.String(e.topicARN),
+			PublishBatchRequestEntries: entries,
+		})
+		if err != nil {
+			return err
+		}
+
+		failures := make([]batchFailure, len(out.Failed))
+		for i, failed := range out.Failed {
+			failures[i] = batchFailure{
+				id:      aws.ToString(failed.Id),
+				code:    aws.ToString(failed.Code),
+				message: aws.ToString(failed.Message),
+			}
+		}
+		err = batchError(batch, failures)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func snsAttributes(attributes map[string]string) map[string]types.MessageAttributeValue {
+	values := make(map[string]types.MessageAttributeValue, len(attributes))
+	for name, value := range attributes {
+		values[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	return values
+}
@@ -0,0 +1,60 @@
+package ktxaws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+type fakeSNS struct {
+	published []*sns.PublishInput
+	batches   []*sns.PublishBatchInput
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.published = append(f.published, params)
+	return &sns.PublishOutput{}, nil
+}
+
+func (f *fakeSNS) PublishBatch(ctx context.Context, params *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error) {
+	f.batches = append(f.batches, params)
+	return &sns.PublishBatchOutput{}, nil
+}
+
+func TestSNS(t *testing.T) {
+	ctx := context.Background()
+
+	client := &fakeSNS{}
+	e := NewSNS(client, "arn:aws:sns:us-east-1:123:orders")
+
+	err := e.Enqueue(ctx, "order-created", json.RawMessage(`{"order_id":1}`))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	err = e.EnqueueBatch(ctx, []ktxjobs.ExternalJob{
+		{ID: "a", Kind: "order-created", Payload: json.RawMessage(`{"order_id":2}`)},
+		{ID: "b", Kind: "order-shipped", Payload: json.RawMessage(`{"order_id":3}`)},
+	})
+	if err != nil {
+		t.Fatalf("EnqueueBatch failed: %v", err)
+	}
+
+	if len(client.published) != 1 || aws.ToString(client.published[0].Message) != `{"order_id":1}` ||
+		aws.ToString(client.published[0].TopicArn) != "arn:aws:sns:us-east-1:123:orders" {
+		t.Errorf("unexpected published messages: %+v", client.published)
+	}
+	if len(client.batches) != 1 || len(client.batches[0].PublishBatchRequestEntries) != 2 {
+		t.Fatalf("expected a single batch of 2 messages, got: %+v", client.batches)
+	}
+	entry := client.batches[0].PublishBatchRequestEntries[1]
+	if aws.ToString(entry.Message) != `{"order_id":3}` || aws.ToString(entry.MessageAttributes[KindAttribute].StringValue) != "order-shipped" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.MessageGroupId != nil || entry.MessageDeduplicationId != nil {
+		t.Errorf("expected no group outside of FIFO topics, got: %+v", entry)
+	}
+}
@@ -0,0 +1,105 @@
+package ktxaws
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// SQSAPI is the subset of *sqs.Client used by the SQS Enqueuer.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+// SQS sends the jobs as messages of an SQS queue whose body is the
+// JSON payload of the job, with its kind on the KindAttribute attribute.
+type SQS struct {
+	client   SQSAPI
+	queueURL string
+	cfg      config
+}
+
+var _ ktxjobs.BatchEnqueuer = (*SQS)(nil)
+
+// NewSQS creates an SQS Enqueuer that sends the jobs to the queue of the
+// input URL, the jobs of each transaction are sent with SendMessageBatch.
+func NewSQS(client SQSAPI, queueURL string, opts ...Option) *SQS {
+	e := &SQS{
+		client:   client,
+		queueURL: queueURL,
+	}
+	for _, opt := range opts {
+		opt(&e.cfg)
+	}
+	return e
+}
+
+// Enqueue sends a message for the job of the input kind.
+func (e *SQS) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	msg := e.cfg.message(contextJob(ctx, kind, payload))
+
+	_, err := e.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:               aws.String(e.queueURL),
+		MessageBody:            aws.String(msg.body),
+		MessageAttributes:      sqsAttributes(msg.attributes),
+		MessageGroupId:         msg.groupID,
+		MessageDeduplicationId: msg.dedupID,
+	})
+	return err
+}
+
+// EnqueueBatch sends the jobs with SendMessageBatch,
+// in batches of up to 10 messages.
+func (e *SQS) EnqueueBatch(ctx context.Context, jobs []ktxjobs.ExternalJob) error {
+	for _, batch := range chunks(jobs) {
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for i, job := range batch {
+			msg := e.cfg.message(job)
+			entries[i] = types.SendMessageBatchRequestEntry{
+				Id:                     entryID(i),
+				MessageBody:            aws.String(msg.body),
+				MessageAttributes:      sqsAttributes(msg.attributes),
+				MessageGroupId:         msg.groupID,
+				MessageDeduplicationId: msg.dedupID,
+			}
+		}
+
+		out, err := e.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(e.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+
+		failures := make([]batchFailure, len(out.Failed))
+		for i, failed := range out.Failed {
+			failures[i] = batchFailure{
+				id:      aws.ToString(failed.Id),
+				code:    aws.ToString(failed.Code),
+				message: aws.ToString(failed.Message),
+			}
+		}
+		err = batchError(batch, failures)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sqsAttributes(attributes map[string]string) map[string]types.MessageAttributeValue {
+	values := make(map[string]types.MessageAttributeValue, len(attributes))
+	for name, value := range attributes {
+		values[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	return values
+}
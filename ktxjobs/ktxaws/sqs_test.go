@@ -0,0 +1,107 @@
+package ktxaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+type fakeSQS struct {
+	sent    []*sqs.SendMessageInput
+	batches []*sqs.SendMessageBatchInput
+	failed  []types.BatchResultErrorEntry
+}
+
+func (f *fakeSQS) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.batches = append(f.batches, params)
+	return &sqs.SendMessageBatchOutput{Failed: f.failed}, nil
+}
+
+func TestSQS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should send the job with its attributes", func(t *testing.T) {
+		client := &fakeSQS{}
+		e := NewSQS(client, "https://sqs/orders.fifo",
+			WithAttributes(func(kind string, payload json.RawMessage) map[string]string {
+				return map[string]string{"tenant": "acme"}
+			}),
+			WithMessageGroup(func(kind string, payload json.RawMessage) string { return "orders" }),
+		)
+
+		err := e.Enqueue(ctx, "order-created", json.RawMessage(`{"order_id":42}`))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		if len(client.sent) != 1 {
+			t.Fatalf("expected 1 message, got %d", len(client.sent))
+		}
+		in := client.sent[0]
+		if aws.ToString(in.QueueUrl) != "https://sqs/orders.fifo" || aws.ToString(in.MessageBody) != `{"order_id":42}` {
+			t.Errorf("unexpected message: %+v", in)
+		}
+		if aws.ToString(in.MessageAttributes[KindAttribute].StringValue) != "order-created" || aws.ToString(in.MessageAttributes["tenant"].StringValue) != "acme" {
+			t.Errorf("unexpected attributes: %+v", in.MessageAttributes)
+		}
+		if aws.ToString(in.MessageGroupId) != "orders" || in.MessageDeduplicationId != nil {
+			t.Errorf("expected a group and no deduplication ID outside of External, got: %+v", in)
+		}
+	})
+
+	t.Run("should send the jobs in batches of 10", func(t *testing.T) {
+		client := &fakeSQS{}
+		e := NewSQS(client, "https://sqs/orders.fifo",
+			WithMessageGroup(func(kind string, payload json.RawMessage) string { return "orders" }),
+		)
+
+		var jobs []ktxjobs.ExternalJob
+		for i := 0; i < 12; i++ {
+			jobs = append(jobs, ktxjobs.ExternalJob{
+				ID:      fmt.Sprintf("id-%d", i),
+				Kind:    "order-created",
+				Payload: json.RawMessage(fmt.Sprintf(`{"order_id":%d}`, i)),
+			})
+		}
+
+		err := e.EnqueueBatch(ctx, jobs)
+		if err != nil {
+			t.Fatalf("EnqueueBatch failed: %v", err)
+		}
+
+		if len(client.batches) != 2 || len(client.batches[0].Entries) != 10 || len(client.batches[1].Entries) != 2 {
+			t.Fatalf("expected batches of 10 and 2 messages, got: %+v", client.batches)
+		}
+		last := client.batches[1].Entries[1]
+		if aws.ToString(last.Id) != "1" || aws.ToString(last.MessageBody) != `{"order_id":11}` || aws.ToString(last.MessageDeduplicationId) != "id-11" {
+			t.Errorf("unexpected entry: %+v", last)
+		}
+	})
+
+	t.Run("should report the failed entries", func(t *testing.T) {
+		client := &fakeSQS{failed: []types.BatchResultErrorEntry{
+			{Id: aws.String("1"), Code: aws.String("InvalidParameterValue"), Message: aws.String("too big")},
+		}}
+
+		err := NewSQS(client, "https://sqs/orders").EnqueueBatch(ctx, []ktxjobs.ExternalJob{
+			{Kind: "order-created", Payload: json.RawMessage(`{}`)},
+			{Kind: "order-shipped", Payload: json.RawMessage(`{}`)},
+		})
+
+		expected := "1 of 2 jobs failed to be pushed: 'order-shipped': InvalidParameterValue: too big"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %q, got: %v", expected, err)
+		}
+	})
+}
@@ -0,0 +1,353 @@
+// Package ktxjobs schedules delayed jobs inside ktx transactions.
+//
+// Jobs are rows on a table, so enqueueing a job in the same transaction
+// as a business write makes the scheduling atomic with that write: the
+// job only exists if the transaction commits.
+//
+// A Queue polls the table for due jobs and runs each of them in its own
+// transaction, together with the removal of the job, so the effects of a
+// handler on the database are applied exactly once.
+package ktxjobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Job is a job loaded from the table.
+type Job struct {
+	ID      int64
+	Kind    string
+	Payload json.RawMessage
+	RunAt   time.Time
+
+	// Attempts counts the previous failed attempts of the job.
+	Attempts int
+}
+
+// Handler runs a job inside the transaction that removes it from the table.
+//
+// The errors and panics of a Handler roll back its writes and
+// are recorded on the job, which is attempted again later.
+type Handler func(ctx context.Context, tx *ktx.Tx, job Job) error
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithTable sets the name of the jobs table, defaults to "ktx_jobs".
+func WithTable(table string) Option {
+	return func(q *Queue) {
+		q.table = table
+	}
+}
+
+// WithPollInterval sets how often Start checks for due jobs, defaults to 1s.
+func WithPollInterval(interval time.Duration) Option {
+	return func(q *Queue) {
+		q.pollInterval = interval
+	}
+}
+
+// WithMaxAttempts sets how many times a job is attempted before it is
+// marked as failed and no longer picked, defaults to 5.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets how long to wait before attempting again a job that
+// failed the input number of times, defaults to an exponential backoff
+// starting at 1s.
+func WithBackoff(backoff func(attempts int) time.Duration) Option {
+	return func(q *Queue) {
+		q.backoff = backoff
+	}
+}
+
+// Queue enqueues and runs delayed jobs.
+type Queue struct {
+	db           *sql.DB
+	dialect      ktx.Dialect
+	table        string
+	pollInterval time.Duration
+	maxAttempts  int
+	backoff      func(attempts int) time.Duration
+	lease        time.Duration
+	claimerID    string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New creates a Queue that stores its jobs on db.
+func New(db *sql.DB, dialect ktx.Dialect, opts ...Option) *Queue {
+	q := &Queue{
+		db:           db,
+		dialect:      dialect,
+		table:        "ktx_jobs",
+		pollInterval: time.Second,
+		maxAttempts:  5,
+		backoff:      defaultBackoff,
+		claimerID:    newToken(),
+		handlers:     map[string]Handler{},
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// CreateTable creates the jobs table if it doesn't exist.
+func (q *Queue) CreateTable(ctx context.Context) error {
+	var idColumn string
+	switch q.dialect.Name() {
+	case ktx.Postgres.Name():
+		idColumn = "id BIGSERIAL PRIMARY KEY"
+	case ktx.MySQL.Name():
+		idColumn = "id BIGINT AUTO_INCREMENT PRIMARY KEY"
+	default:
+		idColumn = "id INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+
+	_, err := q.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (%s, kind VARCHAR(255) NOT NULL, payload TEXT NOT NULL, run_at TIMESTAMP NOT NULL, attempts INTEGER NOT NULL, last_error TEXT, failed INTEGER NOT NULL, claimed_by VARCHAR(255), claimed_until TIMESTAMP)",
+		q.dialect.Quote(q.table), idColumn,
+	))
+	if err != nil {
+		return fmt.Errorf("error creating jobs table: %w", err)
+	}
+	return nil
+}
+
+// Register sets the Handler for the jobs of the input kind.
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.handlers[kind] = handler
+}
+
+// Enqueue inserts a job that should run at runAt, using db so that
+// it can be called inside the transaction of a business write.
+//
+// The payload is encoded as JSON.
+func (q *Queue) Enqueue(ctx context.Context, db ktx.DBRunner, kind string, payload interface{}, runAt time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding payload of job '%s': %w", kind, err)
+	}
+
+	p := q.dialect.Placeholder
+	_, err = db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (kind, payload, run_at, attempts, failed) VALUES (%s, %s, %s, 0, 0)",
+		q.dialect.Quote(q.table), p(0), p(1), p(2),
+	), kind, string(encoded), runAt.UTC())
+	if err != nil {
+		return fmt.Errorf("error enqueueing job '%s': %w", kind, err)
+	}
+	return nil
+}
+
+// Start polls for due jobs and runs them until ctx is canceled,
+// which makes it return ctx.Err().
+//
+// Errors from the handlers are recorded on the jobs, other errors
+// such as failures to connect to the database are sent to onError
+// if it is not nil, and the polling continues.
+func (q *Queue) Start(ctx context.Context, onError func(err error)) error {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := q.RunDue(ctx)
+		if err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs all the jobs that are due and returns how many of them
+// were attempted.
+func (q *Queue) RunDue(ctx context.Context) (attempted int, err error) {
+	for {
+		ran, err := q.runNext(ctx)
+		if err != nil || !ran {
+			return attempted, err
+		}
+		attempted++
+	}
+}
+
+// errNoDueJobs is used internally for finishing the
+// transaction of runNext when there are no jobs to run.
+var errNoDueJobs = errors.New("no due jobs")
+
+// jobError wraps the errors returned by the handlers.
+type jobError struct {
+	job Job
+	err error
+}
+
+func (e *jobError) Error() string {
+	return fmt.Sprintf("error running job %d of kind '%s': %s", e.job.ID, e.job.Kind, e.err)
+}
+
+func (e *jobError) Unwrap() error {
+	return e.err
+}
+
+func (q *Queue) runNext(ctx context.Context) (ran bool, err error) {
+	if q.lease > 0 {
+		return q.runNextLeased(ctx)
+	}
+
+	err = ktx.Run(ctx, q.db, func(tx *ktx.Tx) error {
+		job, found, err := q.nextDueJob(ctx, tx, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errNoDueJobs
+		}
+
+		// The handler runs on a savepoint so its failure is recorded while
+		// the job is still locked, otherwise another poller could pick it
+		// between the rollback and the recording of the failure:
+		err = ktx.Attempt(ctx, tx, func(tx *ktx.Tx) error {
+			return q.runHandler(ctx, tx, job)
+		})
+		var jobErr *jobError
+		if errors.As(err, &jobErr) {
+			return q.recordFailure(ctx, tx, job, jobErr.err)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE id = %s", q.dialect.Quote(q.table), q.dialect.Placeholder(0),
+		), job.ID)
+		return err
+	}, ktx.WithDialect(q.dialect))
+	if errors.Is(err, errNoDueJobs) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// runHandler runs the handler of the job, returning its errors
+// and panics, so they don't stop Start, as a *jobError.
+func (q *Queue) runHandler(ctx context.Context, tx *ktx.Tx, job Job) (err error) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+	if !ok {
+		return &jobError{job: job, err: fmt.Errorf("no handler registered for kind '%s'", job.Kind)}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &jobError{job: job, err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+
+	err = handler(ctx, tx, job)
+	if err != nil {
+		return &jobError{job: job, err: err}
+	}
+	return nil
+}
+
+func (q *Queue) nextDueJob(ctx context.Context, tx *ktx.Tx, now time.Time) (job Job, found bool, err error) {
+	// Concurrent pollers skip the jobs locked by each other on the databases
+	// that support it, SQLite doesn't need it since it serializes writers:
+	lockClause := ""
+	if q.dialect.Name() != ktx.SQLite.Name() {
+		lockClause = " FOR UPDATE SKIP LOCKED"
+	}
+
+	// The jobs claimed by other Queues configured WithLease are skipped:
+	leaseClause := ""
+	args := []interface{}{now}
+	if q.lease > 0 {
+		leaseClause = fmt.Sprintf(" AND (claimed_until IS NULL OR claimed_until < %s)", q.dialect.Placeholder(1))
+		args = append(args, now)
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, kind, payload, run_at, attempts FROM %s WHERE failed = 0 AND run_at <= %s%s ORDER BY run_at, id LIMIT 1%s",
+		q.dialect.Quote(q.table), q.dialect.Placeholder(0), leaseClause, lockClause,
+	), args...)
+	if err != nil {
+		return job, false, fmt.Errorf("error loading due jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		return job, false, rows.Err()
+	}
+
+	var payload string
+	err = rows.Scan(&job.ID, &job.Kind, &payload, &job.RunAt, &job.Attempts)
+	if err != nil {
+		return job, false, fmt.Errorf("error loading due jobs: %w", err)
+	}
+	job.Payload = json.RawMessage(payload)
+
+	return job, true, rows.Close()
+}
+
+// recordFailure schedules the next attempt of a job that failed,
+// or marks it as failed if it has no attempts left.
+func (q *Queue) recordFailure(ctx context.Context, db ktx.DBRunner, job Job, jobErr error) error {
+	attempts := job.Attempts + 1
+
+	failed := 0
+	if attempts >= q.maxAttempts {
+		failed = 1
+	}
+
+	p := q.dialect.Placeholder
+	query := fmt.Sprintf(
+		"UPDATE %s SET attempts = %s, run_at = %s, last_error = %s, failed = %s WHERE id = %s",
+		q.dialect.Quote(q.table), p(0), p(1), p(2), p(3), p(4),
+	)
+	args := []interface{}{attempts, time.Now().Add(q.backoff(attempts)).UTC(), jobErr.Error(), failed, job.ID}
+	if q.lease > 0 {
+		// Releases the lease, unless it was lost to another Queue:
+		query = fmt.Sprintf(
+			"UPDATE %s SET attempts = %s, run_at = %s, last_error = %s, failed = %s, claimed_by = NULL, claimed_until = NULL WHERE id = %s AND claimed_by = %s",
+			q.dialect.Quote(q.table), p(0), p(1), p(2), p(3), p(4), p(5),
+		)
+		args = append(args, q.claimerID)
+	}
+
+	_, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error recording failure of job %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
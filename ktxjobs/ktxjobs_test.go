@@ -0,0 +1,269 @@
+package ktxjobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestQueue(t *testing.T, opts ...Option) (*sql.DB, *Queue) {
+	// A file, since database/sql discards the connections of the statements
+	// canceled by the context, which would drop an in-memory schema:
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	q := New(db, ktx.SQLite, opts...)
+	err = q.CreateTable(context.Background())
+	if err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+
+	return db, q
+}
+
+type orderPayload struct {
+	OrderID int `json:"order_id"`
+}
+
+func countJobs(t *testing.T, db *sql.DB) int {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM ktx_jobs`).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	return count
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should only enqueue jobs of committed transactions", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status) VALUES (1, 'pending')`)
+			if err != nil {
+				return err
+			}
+			return q.Enqueue(ctx, tx, "expire-order", orderPayload{OrderID: 1}, time.Now())
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := q.Enqueue(ctx, tx, "expire-order", orderPayload{OrderID: 2}, time.Now())
+			if err != nil {
+				return err
+			}
+			return errors.New("fake error")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if count := countJobs(t, db); count != 1 {
+			t.Errorf("expected 1 job, got %d", count)
+		}
+	})
+
+	t.Run("should run due jobs in the same transaction that removes them", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		q.Register("expire-order", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			var payload orderPayload
+			err := json.Unmarshal(job.Payload, &payload)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE orders SET status = 'expired' WHERE id = ?`, payload.OrderID)
+			return err
+		})
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status) VALUES (1, 'pending'), (2, 'pending')`)
+			if err != nil {
+				return err
+			}
+
+			err = q.Enqueue(ctx, tx, "expire-order", orderPayload{OrderID: 1}, time.Now().Add(-time.Second))
+			if err != nil {
+				return err
+			}
+			return q.Enqueue(ctx, tx, "expire-order", orderPayload{OrderID: 2}, time.Now().Add(time.Hour))
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 1 {
+			t.Errorf("expected 1 job to run, got %d", attempted)
+		}
+
+		var statuses []string
+		rows, err := db.Query(`SELECT status FROM orders ORDER BY id`)
+		if err != nil {
+			t.Fatalf("Failed to query orders: %v", err)
+		}
+		for rows.Next() {
+			var status string
+			if err := rows.Scan(&status); err != nil {
+				t.Fatalf("Failed to scan order: %v", err)
+			}
+			statuses = append(statuses, status)
+		}
+		_ = rows.Close()
+
+		if len(statuses) != 2 || statuses[0] != "expired" || statuses[1] != "pending" {
+			t.Errorf("unexpected statuses: %v", statuses)
+		}
+		if count := countJobs(t, db); count != 1 {
+			t.Errorf("expected only the future job to remain, got %d jobs", count)
+		}
+	})
+
+	t.Run("should reschedule failed jobs until the max attempts", func(t *testing.T) {
+		db, q := setupTestQueue(t,
+			WithMaxAttempts(3),
+			WithBackoff(func(attempts int) time.Duration { return 0 }),
+		)
+		defer db.Close()
+
+		calls := 0
+		q.Register("flaky", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			if job.Attempts != calls {
+				t.Errorf("expected %d previous attempts, got %d", calls, job.Attempts)
+			}
+			calls++
+			return errors.New("fake error")
+		})
+
+		err := q.Enqueue(ctx, db, "flaky", nil, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 3 || calls != 3 {
+			t.Errorf("expected 3 attempts, got %d (calls: %d)", attempted, calls)
+		}
+
+		var failed int
+		var lastError string
+		err = db.QueryRow(`SELECT failed, last_error FROM ktx_jobs`).Scan(&failed, &lastError)
+		if err != nil {
+			t.Fatalf("Failed to load job: %v", err)
+		}
+		if failed != 1 || lastError != "fake error" {
+			t.Errorf("expected the job to be marked as failed, got failed: %d, last_error: %q", failed, lastError)
+		}
+	})
+
+	t.Run("should record the panics of the handlers as failures", func(t *testing.T) {
+		db, q := setupTestQueue(t)
+		defer db.Close()
+
+		q.Register("panicky", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			_, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status) VALUES (1, 'paid')`)
+			if err != nil {
+				return err
+			}
+			panic("fake panic")
+		})
+
+		err := q.Enqueue(ctx, db, "panicky", nil, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 1 {
+			t.Errorf("expected 1 attempt, got %d", attempted)
+		}
+
+		var attempts int
+		var lastError string
+		err = db.QueryRow(`SELECT attempts, last_error FROM ktx_jobs`).Scan(&attempts, &lastError)
+		if err != nil {
+			t.Fatalf("Failed to load job: %v", err)
+		}
+		if attempts != 1 || lastError != "panic: fake panic" {
+			t.Errorf("expected the panic to be recorded, got attempts: %d, last_error: %q", attempts, lastError)
+		}
+
+		var orders int
+		err = db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&orders)
+		if err != nil {
+			t.Fatalf("Failed to count orders: %v", err)
+		}
+		if orders != 0 {
+			t.Errorf("expected the writes of the handler to be rolled back, got %d orders", orders)
+		}
+	})
+
+	t.Run("should keep polling until the context is canceled", func(t *testing.T) {
+		db, q := setupTestQueue(t, WithPollInterval(10*time.Millisecond))
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		committed := make(chan struct{})
+		q.Register("notify", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			return ktx.AfterCommit(tx, func(ctx context.Context) {
+				close(committed)
+			})
+		})
+
+		err := q.Enqueue(ctx, db, "notify", nil, time.Now().Add(20*time.Millisecond))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- q.Start(ctx, func(err error) {
+				t.Errorf("unexpected error: %v", err)
+			})
+		}()
+
+		<-committed
+		cancel()
+
+		err = <-done
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+		if count := countJobs(t, db); count != 0 {
+			t.Errorf("expected the job to be removed, got %d jobs", count)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+// Package ktxkafka adapts Kafka writers to the ktxjobs.Enqueuer
+// interface, so events can be published to Kafka after the commit
+// of a ktx transaction with ktxjobs.External.
+package ktxkafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// KindHeader is the header that carries the kind of the job on the messages.
+const KindHeader = "ktx-kind"
+
+// Writer is the subset of *kafka.Writer used by the Enqueuer.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Option configures an Enqueuer.
+type Option func(*Enqueuer)
+
+// WithTopic maps the kind of each job to the topic it is published to,
+// which requires the Topic of the kafka.Writer to be empty. By default
+// the messages are published to the Topic of the kafka.Writer.
+func WithTopic(topic func(kind string) string) Option {
+	return func(e *Enqueuer) {
+		e.topic = topic
+	}
+}
+
+// WithKey derives the key of the messages, and therefore their partition
+// with the default balancer of the kafka.Writer, from the jobs, so that
+// the events of the same entity are consumed in order. By default the
+// messages have no key.
+func WithKey(key func(kind string, payload json.RawMessage) ([]byte, error)) Option {
+	return func(e *Enqueuer) {
+		e.key = key
+	}
+}
+
+// KeyField returns a key function for WithKey that uses a top-level
+// field of the JSON payload, e.g. KeyField("order_id").
+func KeyField(field string) func(kind string, payload json.RawMessage) ([]byte, error) {
+	return func(kind string, payload json.RawMessage) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal(payload, &fields)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding payload of job '%s': %w", kind, err)
+		}
+
+		value, found := fields[field]
+		if !found {
+			return nil, fmt.Errorf("the payload of job '%s' has no field '%s'", kind, field)
+		}
+
+		// Strings are used without their quotes:
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			return []byte(s), nil
+		}
+		return value, nil
+	}
+}
+
+// Enqueuer publishes the jobs as Kafka messages whose value is the
+// JSON payload of the job, with its kind on the KindHeader header.
+type Enqueuer struct {
+	writer Writer
+	topic  func(kind string) string
+	key    func(kind string, payload json.RawMessage) ([]byte, error)
+}
+
+var _ ktxjobs.Enqueuer = (*Enqueuer)(nil)
+
+// New creates an Enqueuer for the input writer, usually a *kafka.Writer.
+func New(writer Writer, opts ...Option) *Enqueuer {
+	e := &Enqueuer{
+		writer: writer,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Enqueue publishes a message for the job of the input kind.
+func (e *Enqueuer) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	msg := kafka.Message{
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: KindHeader, Value: []byte(kind)},
+		},
+	}
+
+	if e.topic != nil {
+		msg.Topic = e.topic(kind)
+	}
+
+	if e.key != nil {
+		var err error
+		msg.Key, err = e.key(kind, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.writer.WriteMessages(ctx, msg)
+}
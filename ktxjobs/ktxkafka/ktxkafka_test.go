@@ -0,0 +1,81 @@
+package ktxkafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type fakeWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestEnqueuer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should publish the payload with the kind header", func(t *testing.T) {
+		writer := &fakeWriter{}
+
+		err := New(writer).Enqueue(ctx, "order-created", json.RawMessage(`{"order_id":42}`))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		if len(writer.msgs) != 1 {
+			t.Fatalf("expected 1 message, got %d", len(writer.msgs))
+		}
+		msg := writer.msgs[0]
+		if string(msg.Value) != `{"order_id":42}` || msg.Topic != "" || msg.Key != nil {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+		if len(msg.Headers) != 1 || msg.Headers[0].Key != KindHeader || string(msg.Headers[0].Value) != "order-created" {
+			t.Errorf("unexpected headers: %+v", msg.Headers)
+		}
+	})
+
+	t.Run("should map the topic and the key from the job", func(t *testing.T) {
+		writer := &fakeWriter{}
+		e := New(writer,
+			WithTopic(func(kind string) string { return "events." + kind }),
+			WithKey(KeyField("order_id")),
+		)
+
+		err := e.Enqueue(ctx, "order-created", json.RawMessage(`{"order_id":42}`))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		err = e.Enqueue(ctx, "order-shipped", json.RawMessage(`{"order_id":"a-42"}`))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		if len(writer.msgs) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(writer.msgs))
+		}
+		if writer.msgs[0].Topic != "events.order-created" || string(writer.msgs[0].Key) != "42" {
+			t.Errorf("unexpected message: %+v", writer.msgs[0])
+		}
+		if writer.msgs[1].Topic != "events.order-shipped" || string(writer.msgs[1].Key) != "a-42" {
+			t.Errorf("unexpected message: %+v", writer.msgs[1])
+		}
+	})
+
+	t.Run("should fail when the key field is missing", func(t *testing.T) {
+		writer := &fakeWriter{}
+
+		err := New(writer, WithKey(KeyField("order_id"))).Enqueue(ctx, "order-created", json.RawMessage(`{}`))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if len(writer.msgs) != 0 {
+			t.Errorf("expected no messages, got %d", len(writer.msgs))
+		}
+	})
+}
@@ -0,0 +1,20 @@
+module github.com/vingarcia/ktx/ktxjobs/ktxnats
+
+go 1.21
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.17
+	github.com/nats-io/nats.go v1.37.0
+	github.com/vingarcia/ktx v0.0.0
+)
+
+require (
+	github.com/klauspost/compress v1.17.2 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+replace github.com/vingarcia/ktx => ../../
@@ -0,0 +1,75 @@
+// Package ktxnats adapts NATS JetStream to the ktxjobs.Enqueuer
+// interface, so events can be published to JetStream after the
+// commit of a ktx transaction with ktxjobs.External.
+package ktxnats
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// KindHeader is the header that carries the kind of the job on the messages.
+const KindHeader = "Ktx-Kind"
+
+// Publisher is the subset of jetstream.JetStream used by the Enqueuer.
+type Publisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Option configures an Enqueuer.
+type Option func(*Enqueuer)
+
+// WithSubject maps the kind of each job to the subject it is published
+// to, by default the kind itself is used as the subject.
+func WithSubject(subject func(kind string) string) Option {
+	return func(e *Enqueuer) {
+		e.subject = subject
+	}
+}
+
+// Enqueuer publishes the jobs as JetStream messages whose data is the
+// JSON payload of the job, with its kind on the KindHeader header.
+//
+// The Nats-Msg-Id header is set to ktxjobs.MessageIDFromContext, so when
+// a job is pushed both after the commit and by its relay job, because of
+// ktxjobs.WithFallback, JetStream discards the duplicate as long as both
+// pushes happen within the Duplicates window of the stream.
+type Enqueuer struct {
+	publisher Publisher
+	subject   func(kind string) string
+}
+
+var _ ktxjobs.Enqueuer = (*Enqueuer)(nil)
+
+// New creates an Enqueuer for the input publisher, usually a jetstream.JetStream.
+func New(publisher Publisher, opts ...Option) *Enqueuer {
+	e := &Enqueuer{
+		publisher: publisher,
+		subject: func(kind string) string {
+			return kind
+		},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Enqueue publishes a message for the job of the input kind and
+// waits for its acknowledgement by the stream.
+func (e *Enqueuer) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	msg := nats.NewMsg(e.subject(kind))
+	msg.Data = payload
+	msg.Header.Set(KindHeader, kind)
+
+	if id, ok := ktxjobs.MessageIDFromContext(ctx); ok {
+		msg.Header.Set(jetstream.MsgIDHeader, id)
+	}
+
+	_, err := e.publisher.PublishMsg(ctx, msg)
+	return err
+}
@@ -0,0 +1,118 @@
+package ktxnats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/vingarcia/ktx"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// fakeStream mimics the deduplication of JetStream by Nats-Msg-Id.
+type fakeStream struct {
+	err  error
+	msgs []*nats.Msg
+	seen map[string]bool
+}
+
+func (s *fakeStream) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	id := msg.Header.Get(jetstream.MsgIDHeader)
+	if id != "" && s.seen[id] {
+		return &jetstream.PubAck{Duplicate: true}, nil
+	}
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[id] = true
+	s.msgs = append(s.msgs, msg)
+	return &jetstream.PubAck{}, nil
+}
+
+func TestEnqueuer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should publish the payload on the subject of the kind", func(t *testing.T) {
+		stream := &fakeStream{}
+		e := New(stream, WithSubject(func(kind string) string { return "events." + kind }))
+
+		err := e.Enqueue(ctx, "order-created", json.RawMessage(`{"order_id":42}`))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		if len(stream.msgs) != 1 {
+			t.Fatalf("expected 1 message, got %d", len(stream.msgs))
+		}
+		msg := stream.msgs[0]
+		if msg.Subject != "events.order-created" || string(msg.Data) != `{"order_id":42}` || msg.Header.Get(KindHeader) != "order-created" {
+			t.Errorf("unexpected message: %+v", msg)
+		}
+	})
+
+	t.Run("should deduplicate the jobs relayed after a failed push", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer func() { _ = db.Close() }()
+		db.SetMaxOpenConns(1)
+
+		queue := ktxjobs.New(db, ktx.SQLite)
+		err = queue.CreateTable(ctx)
+		if err != nil {
+			t.Fatalf("CreateTable failed: %v", err)
+		}
+
+		// The push after the commit reaches the stream but its
+		// acknowledgement is lost, so the relay job is kept:
+		stream := &fakeStream{}
+		lostAck := &lostAckPublisher{Publisher: stream, lose: true}
+		external := ktxjobs.NewExternal(New(lostAck), ktxjobs.WithFallback(queue, "nats", 0))
+
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			return external.Enqueue(ctx, tx, "order-created", map[string]int{"order_id": 42})
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		lostAck.lose = false
+		attempted, err := queue.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 1 {
+			t.Fatalf("expected the relay job to run, got %d attempts", attempted)
+		}
+
+		if len(stream.msgs) != 1 || stream.msgs[0].Header.Get(jetstream.MsgIDHeader) == "" {
+			t.Errorf("expected a single message with an ID, got: %v", stream.msgs)
+		}
+	})
+}
+
+type lostAckPublisher struct {
+	Publisher
+	lose bool
+}
+
+func (p *lostAckPublisher) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	_, err := p.Publisher.PublishMsg(ctx, msg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if p.lose {
+		return nil, errors.New("timeout waiting for ack")
+	}
+	return &jetstream.PubAck{}, nil
+}
@@ -0,0 +1,59 @@
+// Package ktxriver adapts River clients to the ktxjobs.Enqueuer
+// interface, so jobs can be pushed into River after the commit
+// of a ktx transaction with ktxjobs.External.
+//
+// When the application already uses River with the same database,
+// inserting the job with InsertTx on the transaction itself is usually
+// preferable, this adapter is meant for River instances running on a
+// different database.
+package ktxriver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/riverqueue/river"
+	"github.com/vingarcia/ktx/ktxjobs"
+)
+
+// Enqueuer inserts the jobs into River.
+type Enqueuer[TTx any] struct {
+	client *river.Client[TTx]
+	opts   *river.InsertOpts
+}
+
+var _ ktxjobs.Enqueuer = (*Enqueuer[any])(nil)
+
+// New creates an Enqueuer for the input client, the InsertOpts
+// are used for all jobs and can be nil.
+func New[TTx any](client *river.Client[TTx], opts *river.InsertOpts) *Enqueuer[TTx] {
+	return &Enqueuer[TTx]{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Enqueue inserts a job of the input kind whose args are
+// the JSON payload.
+func (e *Enqueuer[TTx]) Enqueue(ctx context.Context, kind string, payload json.RawMessage) error {
+	_, err := e.client.Insert(ctx, rawArgs{kind: kind, payload: payload}, e.opts)
+	return err
+}
+
+// rawArgs implements river.JobArgs for an already encoded payload,
+// the workers decode it into their own JobArgs types.
+type rawArgs struct {
+	kind    string
+	payload json.RawMessage
+}
+
+func (a rawArgs) Kind() string {
+	return a.kind
+}
+
+func (a rawArgs) MarshalJSON() ([]byte, error) {
+	if len(a.payload) == 0 {
+		return []byte("{}"), nil
+	}
+	return a.payload, nil
+}
@@ -0,0 +1,32 @@
+package ktxriver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/riverqueue/river"
+)
+
+func TestRawArgs(t *testing.T) {
+	var args river.JobArgs = rawArgs{kind: "send-email", payload: json.RawMessage(`{"to":"john@example.com"}`)}
+
+	if args.Kind() != "send-email" {
+		t.Errorf("unexpected kind: %q", args.Kind())
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(encoded) != `{"to":"john@example.com"}` {
+		t.Errorf("unexpected args: %s", encoded)
+	}
+
+	encoded, err = json.Marshal(rawArgs{kind: "empty"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(encoded) != `{}` {
+		t.Errorf("expected an empty object for empty payloads, got: %s", encoded)
+	}
+}
@@ -0,0 +1,125 @@
+package ktxjobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// errLeaseLost rolls back the jobs whose lease expired, and
+// was taken by another Queue, before their handlers finished.
+var errLeaseLost = errors.New("the lease of the job expired before it finished")
+
+// WithLease makes the Queue claim each job for the input duration, by
+// setting its claimed_by and claimed_until columns in a short transaction,
+// and then run it on a separate transaction, instead of holding a row
+// lock on the job while its handler runs.
+//
+// This lets several replicas poll the same table concurrently, including
+// on databases without `FOR UPDATE SKIP LOCKED`, without running the same
+// job twice, such as the relay jobs of External. The jobs claimed by a
+// replica that crashed are picked again once their lease expires.
+//
+// The lease must be longer than the handlers take to run, since the
+// transaction of a job whose lease expired and was claimed by another
+// Queue before it finished is rolled back, leaving the job to that Queue.
+//
+// Tables created before leasing was available need the columns:
+//
+//	ALTER TABLE ktx_jobs ADD COLUMN claimed_by VARCHAR(255);
+//	ALTER TABLE ktx_jobs ADD COLUMN claimed_until TIMESTAMP;
+func WithLease(duration time.Duration) Option {
+	return func(q *Queue) {
+		q.lease = duration
+	}
+}
+
+// WithClaimerID sets the value written to the claimed_by column of the jobs
+// claimed by the Queue when it is configured WithLease, which defaults
+// to a random ID and must be unique among the replicas.
+func WithClaimerID(id string) Option {
+	return func(q *Queue) {
+		q.claimerID = id
+	}
+}
+
+// runNextLeased claims the next due job and runs it on its own transaction,
+// which only removes the job if the Queue still holds its lease.
+func (q *Queue) runNextLeased(ctx context.Context) (ran bool, err error) {
+	job, found, err := q.claimNextJob(ctx)
+	if err != nil || !found {
+		return false, err
+	}
+
+	err = ktx.Run(ctx, q.db, func(tx *ktx.Tx) error {
+		err := q.runHandler(ctx, tx, job)
+		if err != nil {
+			return err
+		}
+
+		p := q.dialect.Placeholder
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE id = %s AND claimed_by = %s",
+			q.dialect.Quote(q.table), p(0), p(1),
+		), job.ID, q.claimerID)
+		if err != nil {
+			return err
+		}
+
+		removed, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			return errLeaseLost
+		}
+		return nil
+	})
+
+	if errors.Is(err, errLeaseLost) {
+		return true, nil
+	}
+
+	var jobErr *jobError
+	if errors.As(err, &jobErr) {
+		// The lease keeps other Queues from picking the job meanwhile:
+		return true, q.recordFailure(ctx, q.db, jobErr.job, jobErr.err)
+	}
+
+	return true, err
+}
+
+// claimNextJob claims the next due job that is not claimed
+// by another Queue or whose lease has expired.
+func (q *Queue) claimNextJob(ctx context.Context) (job Job, found bool, err error) {
+	err = ktx.Run(ctx, q.db, func(tx *ktx.Tx) error {
+		now := time.Now().UTC()
+		job, found, err = q.nextDueJob(ctx, tx, now)
+		if err != nil || !found {
+			return err
+		}
+
+		// The condition is checked again so concurrent claims on
+		// databases without SKIP LOCKED don't take the same job:
+		p := q.dialect.Placeholder
+		claimable := fmt.Sprintf("(claimed_until IS NULL OR claimed_until < %s)", p(3))
+		result, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET claimed_by = %s, claimed_until = %s WHERE id = %s AND %s",
+			q.dialect.Quote(q.table), p(0), p(1), p(2), claimable,
+		), q.claimerID, now.Add(q.lease), job.ID, now)
+		if err != nil {
+			return fmt.Errorf("error claiming job %d: %w", job.ID, err)
+		}
+
+		claimed, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error claiming job %d: %w", job.ID, err)
+		}
+		found = claimed == 1
+		return nil
+	})
+	return job, found, err
+}
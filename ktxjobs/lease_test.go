@@ -0,0 +1,141 @@
+package ktxjobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+func loadClaim(t *testing.T, db *sql.DB) (claimedBy *string, attempts int) {
+	err := db.QueryRow(`SELECT claimed_by, attempts FROM ktx_jobs`).Scan(&claimedBy, &attempts)
+	if err != nil {
+		t.Fatalf("Failed to load job: %v", err)
+	}
+	return claimedBy, attempts
+}
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should skip jobs claimed by other replicas until the lease expires", func(t *testing.T) {
+		db, crashed := setupTestQueue(t, WithLease(time.Hour), WithClaimerID("crashed"))
+		defer db.Close()
+
+		q := New(db, ktx.SQLite, WithLease(time.Hour), WithClaimerID("alive"))
+		calls := 0
+		q.Register("notify", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			calls++
+			return nil
+		})
+
+		err := q.Enqueue(ctx, db, "notify", nil, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		// Simulates a replica that crashed right after claiming the job:
+		_, found, err := crashed.claimNextJob(ctx)
+		if err != nil || !found {
+			t.Fatalf("expected the job to be claimed, got found: %v, err: %v", found, err)
+		}
+		if claimedBy, _ := loadClaim(t, db); claimedBy == nil || *claimedBy != "crashed" {
+			t.Fatalf("expected the job to be claimed by the crashed replica, got %v", claimedBy)
+		}
+
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 0 || calls != 0 {
+			t.Fatalf("expected the claimed job to be skipped, got %d attempts", attempted)
+		}
+
+		_, err = db.Exec(`UPDATE ktx_jobs SET claimed_until = ?`, time.Now().Add(-time.Second).UTC())
+		if err != nil {
+			t.Fatalf("Failed to expire the lease: %v", err)
+		}
+
+		attempted, err = q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 1 || calls != 1 {
+			t.Fatalf("expected the expired job to be reclaimed, got %d attempts", attempted)
+		}
+		if count := countJobs(t, db); count != 0 {
+			t.Errorf("expected the job to be removed, got %d jobs", count)
+		}
+	})
+
+	t.Run("should rollback jobs whose lease was lost", func(t *testing.T) {
+		db, q := setupTestQueue(t, WithLease(time.Hour), WithClaimerID("slow"))
+		defer db.Close()
+
+		q.Register("expire-order", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			_, err := tx.ExecContext(ctx, `INSERT INTO orders (id, status) VALUES (1, 'expired')`)
+			if err != nil {
+				return err
+			}
+
+			// Simulates another replica claiming the job after the lease expired:
+			_, err = tx.ExecContext(ctx, `UPDATE ktx_jobs SET claimed_by = 'other'`)
+			return err
+		})
+
+		err := q.Enqueue(ctx, db, "expire-order", nil, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		_, err = q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+
+		var orders int
+		err = db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&orders)
+		if err != nil {
+			t.Fatalf("Failed to count orders: %v", err)
+		}
+		if orders != 0 {
+			t.Errorf("expected the effects of the job to be rolled back, got %d orders", orders)
+		}
+		if count := countJobs(t, db); count != 1 {
+			t.Errorf("expected the job to be kept, got %d jobs", count)
+		}
+	})
+
+	t.Run("should release the lease of failed jobs", func(t *testing.T) {
+		db, q := setupTestQueue(t,
+			WithLease(time.Hour),
+			WithBackoff(func(attempts int) time.Duration { return time.Hour }),
+		)
+		defer db.Close()
+
+		q.Register("flaky", func(ctx context.Context, tx *ktx.Tx, job Job) error {
+			return errors.New("fake error")
+		})
+
+		err := q.Enqueue(ctx, db, "flaky", nil, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+
+		attempted, err := q.RunDue(ctx)
+		if err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+		if attempted != 1 {
+			t.Fatalf("expected 1 attempt, got %d", attempted)
+		}
+
+		claimedBy, attempts := loadClaim(t, db)
+		if claimedBy != nil || attempts != 1 {
+			t.Errorf("expected the failure to be recorded and the lease released, got claimed_by: %v, attempts: %d", claimedBy, attempts)
+		}
+	})
+}
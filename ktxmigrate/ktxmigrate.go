@@ -0,0 +1,231 @@
+// Package ktxmigrate applies SQL migrations using ktx transactions.
+//
+// Migrations are read from the *.sql files at the root of an fs.FS,
+// usually an embed.FS, and are applied in the lexicographic order of
+// their names, so names like "0001_create_users.sql" are recommended.
+// The name of each file without the extension is its version, which is
+// recorded on a table once the migration is applied.
+//
+// Concurrent calls to Up, e.g. from several replicas being deployed at
+// the same time, are serialized by a lock held on the database, which is
+// also available for other migration tools through AcquireLock and WithLock.
+package ktxmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithTable sets the name of the table where the applied versions are
+// recorded, defaults to "ktx_migrations".
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLockOptions configures the lock used for serializing concurrent
+// calls to Up, see AcquireLock.
+func WithLockOptions(opts ...LockOption) Option {
+	return func(m *Migrator) {
+		m.lockOpts = opts
+	}
+}
+
+// WithSingleTransaction makes Up apply all pending migrations in a single
+// transaction, so either all of them are applied or none is.
+//
+// It is only supported by dialects with transactional DDL, e.g. Postgres
+// and SQLite, since MySQL implicitly commits on most DDL statements.
+func WithSingleTransaction() Option {
+	return func(m *Migrator) {
+		m.singleTx = true
+	}
+}
+
+// Migrator applies the migrations of an fs.FS to a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    ktx.Dialect
+	migrations fs.FS
+	table      string
+	singleTx   bool
+	lockOpts   []LockOption
+}
+
+// New creates a Migrator for the migrations on the input fs.FS.
+func New(db *sql.DB, dialect ktx.Dialect, migrations fs.FS, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		table:      "ktx_migrations",
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+type migration struct {
+	version string
+	sql     string
+}
+
+// Up applies all the migrations that were not applied yet and returns
+// the versions applied by this call.
+//
+// Each migration runs in its own transaction together with the insertion
+// of its version on the migrations table, unless WithSingleTransaction is used.
+func (m *Migrator) Up(ctx context.Context) (applied []string, err error) {
+	if m.singleTx && m.dialect.Name() == ktx.MySQL.Name() {
+		return nil, fmt.Errorf("WithSingleTransaction is not supported on %s since it has no transactional DDL", m.dialect.Name())
+	}
+
+	migrations, err := m.readMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := AcquireLock(ctx, m.db, m.dialect, "ktxmigrate:"+m.table, m.lockOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		releaseErr := lock.Release()
+		if err == nil {
+			err = releaseErr
+		}
+	}()
+
+	err = m.createTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []migration
+	for _, mig := range migrations {
+		if !done[mig.version] {
+			pending = append(pending, mig)
+		}
+	}
+
+	if m.singleTx {
+		err = ktx.Run(ctx, m.db, func(tx *ktx.Tx) error {
+			for _, mig := range pending {
+				err := m.apply(ctx, tx, mig)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mig := range pending {
+			applied = append(applied, mig.version)
+		}
+		return applied, nil
+	}
+
+	for _, mig := range pending {
+		err = ktx.Run(ctx, m.db, func(tx *ktx.Tx) error {
+			return m.apply(ctx, tx, mig)
+		})
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, mig.version)
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) readMigrations() ([]migration, error) {
+	names, err := fs.Glob(m.migrations, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("error listing migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		content, err := fs.ReadFile(m.migrations, name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", name, err)
+		}
+
+		migrations = append(migrations, migration{
+			version: strings.TrimSuffix(path.Base(name), ".sql"),
+			sql:     string(content),
+		})
+	}
+
+	return migrations, nil
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version VARCHAR(255) PRIMARY KEY, applied_at TIMESTAMP NOT NULL)",
+		m.dialect.Quote(m.table),
+	))
+	if err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[string]bool, error) {
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", m.dialect.Quote(m.table)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading applied migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	done := map[string]bool{}
+	for rows.Next() {
+		var version string
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, fmt.Errorf("error reading applied migrations: %w", err)
+		}
+		done[version] = true
+	}
+
+	return done, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, tx *ktx.Tx, mig migration) error {
+	_, err := tx.ExecContext(ctx, mig.sql)
+	if err != nil {
+		return fmt.Errorf("error applying migration %s: %w", mig.version, err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (version, applied_at) VALUES (%s, CURRENT_TIMESTAMP)",
+		m.dialect.Quote(m.table),
+		m.dialect.Placeholder(0),
+	), mig.version)
+	if err != nil {
+		return fmt.Errorf("error recording migration %s: %w", mig.version, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package ktxmigrate
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// Each connection to an in-memory database sees a different database:
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrator_Up(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	migrations := fstest.MapFS{
+		"0002_add_users_email.sql": {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT")},
+		"0001_create_users.sql":    {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")},
+		"README.md":                {Data: []byte("not a migration")},
+	}
+
+	applied, err := New(db, ktx.SQLite, migrations).Up(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(applied, ",") != "0001_create_users,0002_add_users_email" {
+		t.Fatalf("unexpected applied migrations: %v", applied)
+	}
+
+	_, err = db.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+	if err != nil {
+		t.Fatalf("expected the migrations to be applied, got: %v", err)
+	}
+
+	// Running again should apply only the new migrations:
+	migrations["0003_create_posts.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY)")}
+	applied, err = New(db, ktx.SQLite, migrations).Up(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(applied, ",") != "0003_create_posts" {
+		t.Fatalf("unexpected applied migrations: %v", applied)
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM ktx_migrations").Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 recorded migrations, got %d", count)
+	}
+}
+
+func TestMigrator_UpStopsOnFailure(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	migrations := fstest.MapFS{
+		"0001_create_users.sql": {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY)")},
+		"0002_broken.sql":       {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY); NOT VALID SQL")},
+		"0003_create_tags.sql":  {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY)")},
+	}
+
+	applied, err := New(db, ktx.SQLite, migrations).Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("expected an error on the broken migration, got: %v", err)
+	}
+	if strings.Join(applied, ",") != "0001_create_users" {
+		t.Fatalf("unexpected applied migrations: %v", applied)
+	}
+
+	// The broken migration should have been rolled back:
+	_, err = db.Exec("SELECT * FROM posts")
+	if err == nil {
+		t.Fatal("expected the broken migration to be rolled back")
+	}
+}
+
+func TestMigrator_UpWithSingleTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	migrations := fstest.MapFS{
+		"0001_create_users.sql": {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY)")},
+		"0002_broken.sql":       {Data: []byte("NOT VALID SQL")},
+	}
+
+	_, err := New(db, ktx.SQLite, migrations, WithSingleTransaction(), WithTable("schema_versions")).Up(context.Background())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	_, err = db.Exec("SELECT * FROM users")
+	if err == nil {
+		t.Fatal("expected all migrations to be rolled back")
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM schema_versions").Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no recorded migrations, got %d", count)
+	}
+
+	_, err = New(db, ktx.MySQL, migrations, WithSingleTransaction()).Up(context.Background())
+	if err == nil {
+		t.Fatal("expected WithSingleTransaction to be rejected on MySQL")
+	}
+}
@@ -0,0 +1,357 @@
+package ktxmigrate
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// LockOption configures how a Lock is acquired.
+type LockOption func(*lockConfig)
+
+type lockConfig struct {
+	useTable     bool
+	table        string
+	lease        time.Duration
+	pollInterval time.Duration
+}
+
+// WithLockTable makes the lock use a row on the input table instead of
+// the advisory locks of the database. The lock is held for the duration
+// of the lease and is renewed in the background while it is held, so
+// the lock of a crashed process expires after at most one lease.
+//
+// This is the default strategy for databases without advisory locks,
+// e.g. SQLite, using a table named "ktx_locks" and a lease of 1 minute.
+//
+// The expiration of the leases is computed using the clock of the
+// processes, so their clocks should be reasonably in sync.
+//
+// Non-positive leases are ignored and the default of 1 minute is used.
+func WithLockTable(table string, lease time.Duration) LockOption {
+	return func(c *lockConfig) {
+		c.useTable = true
+		c.table = table
+		if lease > 0 {
+			c.lease = lease
+		}
+	}
+}
+
+// WithPollInterval sets how often a lock held by another process is
+// checked again when using the lock table, defaults to 1 second.
+func WithPollInterval(interval time.Duration) LockOption {
+	return func(c *lockConfig) {
+		c.pollInterval = interval
+	}
+}
+
+// Lock is a lock held on the database, used for serializing
+// migrations among several processes.
+type Lock struct {
+	releaseOnce sync.Once
+	releaseErr  error
+	release     func() error
+
+	lost <-chan struct{}
+}
+
+// Lost returns a channel that is closed if the lease of a lock held on
+// the lock table could not be renewed, e.g. because the database was
+// unreachable for longer than the lease or because the row was taken
+// over by another owner, in which case the lock is no longer held.
+//
+// For advisory locks the channel is never closed.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release releases the lock, calling it more than once has no effect.
+func (l *Lock) Release() error {
+	l.releaseOnce.Do(func() {
+		l.releaseErr = l.release()
+	})
+	return l.releaseErr
+}
+
+// AcquireLock blocks until the lock with the input name is acquired
+// or the context is canceled.
+//
+// On Postgres and MySQL it uses a session-level advisory lock held on a
+// dedicated connection, on other databases or when WithLockTable is used
+// it uses a row with a lease on a lock table.
+//
+// It can be used to serialize other migration tools, e.g. golang-migrate
+// or goose, among several replicas, see WithLock.
+func AcquireLock(ctx context.Context, db *sql.DB, dialect ktx.Dialect, name string, opts ...LockOption) (*Lock, error) {
+	cfg := lockConfig{
+		table:        "ktx_locks",
+		lease:        time.Minute,
+		pollInterval: time.Second,
+	}
+	switch dialect.Name() {
+	case ktx.Postgres.Name(), ktx.MySQL.Name():
+	default:
+		cfg.useTable = true
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var release func() error
+	var lost <-chan struct{}
+	var err error
+	if cfg.useTable {
+		release, lost, err = acquireTableLock(ctx, db, dialect, name, cfg)
+	} else {
+		release, err = acquireAdvisoryLock(ctx, db, dialect, name)
+		lost = make(chan struct{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lock{release: release, lost: lost}, nil
+}
+
+// WithLock runs fn while holding the lock with the input name,
+// e.g. for running the migrations of another migration tool:
+//
+//	err := ktxmigrate.WithLock(ctx, db, ktx.Postgres, "migrations", func() error {
+//		return m.Up() // a golang-migrate *migrate.Migrate
+//	})
+func WithLock(ctx context.Context, db *sql.DB, dialect ktx.Dialect, name string, fn func() error, opts ...LockOption) (err error) {
+	lock, err := AcquireLock(ctx, db, dialect, name, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		releaseErr := lock.Release()
+		if err == nil {
+			err = releaseErr
+		}
+	}()
+
+	return fn()
+}
+
+func acquireAdvisoryLock(ctx context.Context, db *sql.DB, dialect ktx.Dialect, name string) (release func() error, err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection for the lock: %w", err)
+	}
+
+	switch dialect.Name() {
+	case ktx.Postgres.Name():
+		key := advisoryLockKey(name)
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key)
+		release = func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			closeLockConn(conn, err)
+			return err
+		}
+
+	case ktx.MySQL.Name():
+		var acquired sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired)
+		if err == nil && acquired.Int64 != 1 {
+			err = fmt.Errorf("GET_LOCK returned %v", acquired)
+		}
+		release = func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			closeLockConn(conn, err)
+			return err
+		}
+
+	default:
+		err = fmt.Errorf("advisory locks are not supported on %s", dialect.Name())
+	}
+	if err != nil {
+		// The lock might have been granted right before the statement
+		// was canceled, so the session must not go back to the pool:
+		closeLockConn(conn, err)
+		return nil, fmt.Errorf("error acquiring lock '%s': %w", name, err)
+	}
+
+	return func() error {
+		err := release()
+		if err != nil {
+			return fmt.Errorf("error releasing lock '%s': %w", name, err)
+		}
+		return nil
+	}, nil
+}
+
+// closeLockConn returns the connection of an advisory lock to the pool,
+// unless err is not nil, in which case the session might still be holding
+// the lock, so the connection is discarded instead, which ends the session
+// and releases its locks.
+func closeLockConn(conn *sql.Conn, err error) {
+	if err != nil {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	_ = conn.Close()
+}
+
+// advisoryLockKey converts the lock name into the
+// integer key expected by the Postgres advisory locks.
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+var errLockHeld = errors.New("lock is held by another owner")
+
+func acquireTableLock(ctx context.Context, db *sql.DB, dialect ktx.Dialect, name string, cfg lockConfig) (release func() error, lost <-chan struct{}, err error) {
+	table := dialect.Quote(cfg.table)
+	p := dialect.Placeholder
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) PRIMARY KEY, owner VARCHAR(255) NOT NULL, expires_at BIGINT NOT NULL)",
+		table,
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating lock table: %w", err)
+	}
+
+	owner, err := newOwnerID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tryAcquire := func() error {
+		return ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			now := time.Now()
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"DELETE FROM %s WHERE name = %s AND expires_at < %s", table, p(0), p(1),
+			), name, now.UnixNano())
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT owner FROM %s WHERE name = %s", table, p(0)), name)
+			if err != nil {
+				return err
+			}
+			held := rows.Next()
+			err = rows.Close()
+			if err != nil {
+				return err
+			}
+			if held {
+				return errLockHeld
+			}
+
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(
+				"INSERT INTO %s (name, owner, expires_at) VALUES (%s, %s, %s)", table, p(0), p(1), p(2),
+			), name, owner, now.Add(cfg.lease).UnixNano())
+			return err
+		})
+	}
+
+	for {
+		err := tryAcquire()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockHeld) && !lockRowExists(ctx, db, dialect, cfg.table, name) {
+			return nil, nil, fmt.Errorf("error acquiring lock '%s': %w", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("error acquiring lock '%s': %w", name, ctx.Err())
+		case <-time.After(cfg.pollInterval):
+		}
+	}
+
+	renew := func() (renewed bool, err error) {
+		result, err := db.ExecContext(context.Background(), fmt.Sprintf(
+			"UPDATE %s SET expires_at = %s WHERE name = %s AND owner = %s", table, p(0), p(1), p(2),
+		), time.Now().Add(cfg.lease).UnixNano(), name, owner)
+		if err != nil {
+			return false, err
+		}
+		n, err := result.RowsAffected()
+		return n > 0, err
+	}
+
+	interval := cfg.lease / 3
+	if interval <= 0 {
+		interval = cfg.lease
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	lostCh := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		expiresAt := time.Now().Add(cfg.lease)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				renewed, err := renew()
+				if err == nil && !renewed {
+					// The row expired and was taken over or removed:
+					close(lostCh)
+					return
+				}
+				if err != nil {
+					// Transient errors are retried until the lease expires:
+					if !now.Before(expiresAt) {
+						close(lostCh)
+						return
+					}
+					continue
+				}
+				expiresAt = now.Add(cfg.lease)
+			}
+		}
+	}()
+
+	return func() error {
+		close(stop)
+		<-done
+
+		_, err := db.ExecContext(context.Background(), fmt.Sprintf(
+			"DELETE FROM %s WHERE name = %s AND owner = %s", table, p(0), p(1),
+		), name, owner)
+		if err != nil {
+			return fmt.Errorf("error releasing lock '%s': %w", name, err)
+		}
+		return nil
+	}, lostCh, nil
+}
+
+func lockRowExists(ctx context.Context, db *sql.DB, dialect ktx.Dialect, table string, name string) bool {
+	var count int
+	err := db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE name = %s", dialect.Quote(table), dialect.Placeholder(0),
+	), name).Scan(&count)
+	return err == nil && count > 0
+}
+
+func newOwnerID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error generating lock owner ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,189 @@
+package ktxmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+func setupFileDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "lock.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func TestAcquireLock_LockTable(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	opts := []LockOption{WithPollInterval(10 * time.Millisecond)}
+
+	lock, err := AcquireLock(ctx, db, ktx.SQLite, "migrations", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = AcquireLock(timeoutCtx, db, ktx.SQLite, "migrations", opts...)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the lock to be held, got: %v", err)
+	}
+
+	// Other names should not be affected:
+	other, err := AcquireLock(ctx, db, ktx.SQLite, "other", opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := other.Release(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = lock.Release()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = lock.Release()
+	if err != nil {
+		t.Fatalf("expected Release to be idempotent, got: %v", err)
+	}
+
+	lock, err = AcquireLock(ctx, db, ktx.SQLite, "migrations", opts...)
+	if err != nil {
+		t.Fatalf("expected the lock to be acquired after release, got: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireLock_ExpiredLease(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	lock, err := AcquireLock(ctx, db, ktx.SQLite, "migrations")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = lock.Release()
+
+	// Simulate a crashed process that never released its lock:
+	_, err = db.Exec(
+		"INSERT INTO ktx_locks (name, owner, expires_at) VALUES (?, ?, ?)",
+		"migrations", "crashed-process", time.Now().Add(-time.Second).UnixNano(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	lock, err = AcquireLock(timeoutCtx, db, ktx.SQLite, "migrations")
+	if err != nil {
+		t.Fatalf("expected the expired lock to be taken over, got: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireLock_LostLease(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	lock, err := AcquireLock(ctx, db, ktx.SQLite, "migrations", WithLockTable("ktx_locks", 30*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = lock.Release() }()
+
+	select {
+	case <-lock.Lost():
+		t.Fatalf("expected the lease to be renewed while the row is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Simulate another process taking over the lock:
+	_, err = db.Exec("UPDATE ktx_locks SET owner = 'other-process' WHERE name = 'migrations'")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatalf("expected the lock to be reported as lost")
+	}
+}
+
+func TestAcquireLock_InvalidLease(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	for _, lease := range []time.Duration{0, -time.Second, time.Nanosecond} {
+		lock, err := AcquireLock(ctx, db, ktx.SQLite, "migrations", WithLockTable("ktx_locks", lease))
+		if err != nil {
+			t.Fatalf("unexpected error for lease %v: %v", lease, err)
+		}
+		if err := lock.Release(); err != nil {
+			t.Fatalf("unexpected error for lease %v: %v", lease, err)
+		}
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	testError := errors.New("test error")
+
+	err := WithLock(ctx, db, ktx.SQLite, "migrations", func() error {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM ktx_locks WHERE name = 'migrations'").Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Fatalf("expected the lock to be held, got %d rows", count)
+		}
+		return testError
+	})
+	if err != testError {
+		t.Fatalf("expected test error, got: %v", err)
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM ktx_locks").Scan(&count)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected the lock to be released, got %d rows", count)
+	}
+}
+
+func TestAcquireLock_AdvisoryLockFailure(t *testing.T) {
+	db := setupFileDB(t)
+	defer func() { _ = db.Close() }()
+
+	// SQLite has no advisory locks, so the lock statement fails:
+	_, err := acquireAdvisoryLock(context.Background(), db, ktx.Postgres, "migrations")
+	if err == nil {
+		t.Fatal("expected the lock statement to fail")
+	}
+
+	if open := db.Stats().OpenConnections; open != 0 {
+		t.Fatalf("expected the connection of the lock to be discarded, got %d open connections", open)
+	}
+}
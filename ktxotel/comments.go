@@ -0,0 +1,25 @@
+package ktxotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// CommentTags returns the traceparent and tracestate of the span in
+// ctx, as propagated by W3C Trace Context, for the comments added by
+// ktx.WithSQLCommenter:
+//
+//	err := ktx.Run(ctx, db, fn, ktx.WithName("create-user"), instrumentation.Option(),
+//		ktx.WithSQLCommenter(ktx.SQLCommenterOptions{Tags: ktxotel.CommentTags}),
+//	)
+//
+// The Option of the Instrumentation must come before WithSQLCommenter for
+// the span in ctx to be the span of the transaction, since its middleware
+// sets it on the ctx of the statements. It returns an empty map when ctx
+// has no valid span.
+func CommentTags(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier
+}
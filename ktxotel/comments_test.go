@@ -0,0 +1,88 @@
+package ktxotel
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/vingarcia/ktx"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCommentTags(t *testing.T) {
+	t.Run("should return the traceparent of the span in the context", func(t *testing.T) {
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+		tags := CommentTags(ctx)
+		expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		if tags["traceparent"] != expected {
+			t.Errorf("expected traceparent %q, got: %v", expected, tags)
+		}
+	})
+
+	t.Run("should return no tags without a span", func(t *testing.T) {
+		tags := CommentTags(context.Background())
+		if len(tags) != 0 {
+			t.Errorf("expected no tags, got: %v", tags)
+		}
+	})
+
+	t.Run("should tag the statements with the span of the transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		recorder := tracetest.NewSpanRecorder()
+		instrumentation, err := New(WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+
+		ctx := context.Background()
+		var query string
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := tx.ExecContext(ctx, `DELETE FROM users`)
+			return err
+		}, instrumentation.Option(), ktx.WithSQLCommenter(ktx.SQLCommenterOptions{Tags: CommentTags}),
+			ktx.WithMiddleware(func(next ktx.DBRunner) ktx.DBRunner {
+				return queryRecorder{next: next, query: &query}
+			}),
+		)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		spanID := recorder.Ended()[0].SpanContext().SpanID().String()
+		if !strings.Contains(query, "traceparent='00-") || !strings.Contains(query, spanID) {
+			t.Errorf("expected the traceparent of span %s, got: %q", spanID, query)
+		}
+	})
+}
+
+// queryRecorder is a middleware that records the last statement.
+type queryRecorder struct {
+	next  ktx.DBRunner
+	query *string
+}
+
+func (r queryRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*r.query = query
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r queryRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*r.query = query
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r queryRecorder) Unwrap() ktx.DBRunner {
+	return r.next
+}
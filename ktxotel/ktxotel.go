@@ -0,0 +1,481 @@
+// Package ktxotel instruments ktx transactions with OpenTelemetry.
+//
+// It is kept on a separate module so the core of ktx doesn't
+// depend on the OpenTelemetry SDK.
+package ktxotel
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/vingarcia/ktx/ktxotel"
+
+// Option configures the Instrumentation.
+type Option func(*config)
+
+type config struct {
+	meterProvider  metric.MeterProvider
+	tracerProvider trace.TracerProvider
+	loggerProvider log.LoggerProvider
+	spanLevel      SpanLevel
+	sampler        Sampler
+}
+
+// WithMeterProvider sets the MeterProvider used for creating the
+// instruments, defaults to the global one.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithTracerProvider sets the TracerProvider used for creating the
+// spans, defaults to the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// SpanLevel controls which spans are created for each transaction.
+type SpanLevel int
+
+const (
+	// TransactionSpans creates a single span for each transaction.
+	TransactionSpans SpanLevel = iota
+	// StatementSpans also creates a child span for each statement.
+	StatementSpans
+	// NoSpans disables tracing, leaving only the metrics.
+	NoSpans
+)
+
+// WithSpanLevel controls which spans are created,
+// defaults to TransactionSpans.
+func WithSpanLevel(level SpanLevel) Option {
+	return func(c *config) {
+		c.spanLevel = level
+	}
+}
+
+// Sampler decides whether the spans of a transaction are recorded. It is
+// called once the transaction finishes with its name, duration and the
+// error that caused the rollback, if any.
+type Sampler func(name string, duration time.Duration, err error) bool
+
+// WithSampler sets the Sampler of the spans, by default
+// the spans of every transaction are recorded.
+//
+// This sampling happens before the one configured on the TracerProvider,
+// which still applies to the spans that are recorded. Since the spans are
+// only created once the transactions finish, they are not set on the ctx
+// of the statements nor returned by ContextWithSpan.
+func WithSampler(s Sampler) Option {
+	return func(c *config) {
+		c.sampler = s
+	}
+}
+
+// SlowerThan returns a Sampler that only records the transactions that
+// took longer than threshold or that were rolled back.
+func SlowerThan(threshold time.Duration) Sampler {
+	return func(name string, duration time.Duration, err error) bool {
+		return err != nil || duration > threshold
+	}
+}
+
+// Instrumentation records OpenTelemetry metrics for the
+// transactions that use the ktx.Option it returns.
+//
+// The following instruments are created:
+//
+//   - ktx.transaction.duration: histogram with the duration of each
+//     transaction in seconds, with the "ktx.outcome" attribute set to
+//     either "commit" or "rollback"
+//   - ktx.transaction.active: up-down counter with the number of
+//     transactions currently running
+//   - ktx.transaction.retries: counter with the number of times the
+//     transactions were retried, incremented automatically for the
+//     transactions configured with ktx.WithRetry and with RecordRetry
+//
+// It also records a span for each transaction named after ktx.WithName,
+// which is started with the transaction and set on the ctx of its
+// statements; ContextWithSpan sets it on the ctx used by the callback.
+// Since WithSampler can only decide once the transaction is finished,
+// its spans are created retroactively at that point instead, which
+// means that they can't be the parents of other spans.
+//
+// WithLogs and WithLoggerProvider also make it emit the lifecycle events
+// of the transactions as logs: begin, commit, rollback, retry, leak and
+// the errors of the callbacks registered with ktx.IgnoreOnError.
+type Instrumentation struct {
+	cfg      config
+	tracer   trace.Tracer
+	logger   log.Logger
+	duration metric.Float64Histogram
+	active   metric.Int64UpDownCounter
+	retries  metric.Int64Counter
+
+	// states maps each running *ktx.Tx to its txState.
+	states sync.Map
+}
+
+type txState struct {
+	start time.Time
+
+	// span is the span of the transaction, unless it is created once
+	// the transaction finishes for WithSampler, and parent is the span
+	// in the ctx that started the transaction.
+	span   trace.Span
+	parent trace.SpanContext
+
+	// statements are buffered for the spans created retroactively:
+	mu         sync.Mutex
+	statements []statementRecord
+}
+
+type statementRecord struct {
+	fingerprint string
+	start       time.Time
+	end         time.Time
+	err         error
+}
+
+// New creates the instruments of the Instrumentation.
+func New(opts ...Option) (*Instrumentation, error) {
+	cfg := config{
+		meterProvider:  otel.GetMeterProvider(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+
+	duration, err := meter.Float64Histogram(
+		"ktx.transaction.duration",
+		metric.WithDescription("Duration of the transactions, from begin to commit or rollback"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating duration histogram: %w", err)
+	}
+
+	active, err := meter.Int64UpDownCounter(
+		"ktx.transaction.active",
+		metric.WithDescription("Number of transactions currently running"),
+		metric.WithUnit("{transaction}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating active transactions counter: %w", err)
+	}
+
+	retries, err := meter.Int64Counter(
+		"ktx.transaction.retries",
+		metric.WithDescription("Number of times the transactions were retried"),
+		metric.WithUnit("{retry}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating retries counter: %w", err)
+	}
+
+	var logger log.Logger
+	if cfg.loggerProvider != nil {
+		logger = cfg.loggerProvider.Logger(instrumentationName)
+	}
+
+	return &Instrumentation{
+		cfg:      cfg,
+		tracer:   cfg.tracerProvider.Tracer(instrumentationName),
+		logger:   logger,
+		duration: duration,
+		active:   active,
+		retries:  retries,
+	}, nil
+}
+
+// Option returns the ktx.Option that instruments a transaction,
+// it can be reused on any number of transactions:
+//
+//	err := ktx.Run(ctx, db, fn, ktx.WithName("create-user"), instrumentation.Option())
+func (i *Instrumentation) Option() ktx.Option {
+	hooks := ktx.WithHooks(ktx.Hooks{
+		OnBegin: func(ctx context.Context, tx *ktx.Tx) {
+			state := i.begin(ctx, tx)
+			i.active.Add(ctx, 1)
+			i.logBegin(state.context(ctx), tx, state.start)
+		},
+		OnCommit: func(ctx context.Context, tx *ktx.Tx) {
+			i.finish(ctx, tx, nil)
+		},
+		OnRollback: func(ctx context.Context, tx *ktx.Tx, err error) {
+			i.finish(ctx, tx, err)
+		},
+		OnCommitAmbiguous: func(ctx context.Context, tx *ktx.Tx, err error) {
+			i.finish(ctx, tx, err)
+		},
+		OnRetry: func(ctx context.Context, attempt int, err error) {
+			i.retries.Add(ctx, 1)
+			i.logRetry(ctx, attempt, err)
+		},
+		OnLeak:          i.logLeak,
+		OnCallbackError: i.logCallbackError,
+	})
+
+	if i.cfg.spanLevel == NoSpans {
+		return hooks
+	}
+
+	middleware := ktx.WithMiddleware(func(next ktx.DBRunner) ktx.DBRunner {
+		tx, err := ktx.TxFromRunner(next)
+		if err != nil {
+			return next
+		}
+		// The state is only loaded by the statements since
+		// the transaction might fail to begin after this:
+		return statementTracer{next: next, i: i, tx: tx}
+	})
+
+	return ktx.WithOptions(hooks, middleware)
+}
+
+// ContextWithSpan returns a copy of ctx carrying the span of tx, so the
+// spans started by the callback of the transaction, and the requests it
+// propagates them to, are children of the span of the transaction:
+//
+//	err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+//		ctx := instrumentation.ContextWithSpan(ctx, tx)
+//		// ...
+//	}, ktx.WithName("create-user"), instrumentation.Option())
+//
+// It returns ctx unchanged if tx is not instrumented or its span is only
+// created once it finishes, which is the case WithSampler.
+func (i *Instrumentation) ContextWithSpan(ctx context.Context, tx *ktx.Tx) context.Context {
+	s, ok := i.states.Load(tx)
+	if !ok {
+		return ctx
+	}
+	return s.(*txState).context(ctx)
+}
+
+// RecordRetry increments the ktx.transaction.retries counter, it should be
+// called by the code that retries a failed transaction before each retry:
+//
+//	for attempt := 1; ; attempt++ {
+//		err = ktx.Run(ctx, db, fn, instrumentation.Option())
+//		if err == nil || attempt == maxAttempts || !isRetryable(err) {
+//			break
+//		}
+//		instrumentation.RecordRetry(ctx)
+//	}
+func (i *Instrumentation) RecordRetry(ctx context.Context) {
+	i.retries.Add(ctx, 1)
+}
+
+// begin registers the state of tx, which is deleted by finish, and
+// starts its span unless it must be sampled once it finishes.
+func (i *Instrumentation) begin(ctx context.Context, tx *ktx.Tx) *txState {
+	state := &txState{start: time.Now()}
+	if i.cfg.spanLevel != NoSpans && i.cfg.sampler == nil {
+		state.parent = trace.SpanContextFromContext(ctx)
+		_, state.span = i.tracer.Start(ctx, spanName(tx),
+			trace.WithTimestamp(state.start),
+			trace.WithSpanKind(trace.SpanKindClient),
+		)
+	}
+
+	i.states.Store(tx, state)
+	return state
+}
+
+// context returns ctx with the span of the transaction, if any.
+func (s *txState) context(ctx context.Context) context.Context {
+	if s.span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, s.span)
+}
+
+func spanName(tx *ktx.Tx) string {
+	if tx.Name() == "" {
+		return "ktx.transaction"
+	}
+	return tx.Name()
+}
+
+func (i *Instrumentation) finish(ctx context.Context, tx *ktx.Tx, err error) {
+	end := time.Now()
+	i.active.Add(ctx, -1)
+
+	s, ok := i.states.LoadAndDelete(tx)
+	if !ok {
+		return
+	}
+	state := s.(*txState)
+
+	outcome := "commit"
+	if err != nil {
+		outcome = "rollback"
+	}
+
+	duration := end.Sub(state.start)
+	i.duration.Record(ctx, duration.Seconds(),
+		metric.WithAttributes(attribute.String("ktx.outcome", outcome)),
+	)
+
+	spanCtx := i.recordSpan(ctx, tx, state, end, duration, err)
+	i.logFinish(spanCtx, tx, end, duration, err)
+}
+
+// recordSpan ends the span of the transaction, or records it if it is
+// sampled once finished, and returns ctx with the span so the logs of
+// the transaction can refer to it.
+func (i *Instrumentation) recordSpan(ctx context.Context, tx *ktx.Tx, state *txState, end time.Time, duration time.Duration, err error) context.Context {
+	if i.cfg.spanLevel == NoSpans {
+		return ctx
+	}
+
+	outcome := "commit"
+	if err != nil {
+		outcome = "rollback"
+	}
+
+	stats := tx.Stats()
+	attrs := []attribute.KeyValue{
+		attribute.String("ktx.outcome", outcome),
+		attribute.Int("ktx.statements", stats.Statements),
+		attribute.Int64("ktx.rows_affected", stats.RowsAffected),
+	}
+
+	if state.span != nil {
+		state.span.SetAttributes(attrs...)
+		endSpan(state.span, end, err)
+		return state.context(ctx)
+	}
+
+	if !i.cfg.sampler(tx.Name(), duration, err) {
+		return ctx
+	}
+
+	spanCtx, span := i.tracer.Start(ctx, spanName(tx),
+		trace.WithTimestamp(state.start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	state.mu.Lock()
+	statements := state.statements
+	state.mu.Unlock()
+
+	for _, stmt := range statements {
+		_, stmtSpan := i.tracer.Start(spanCtx, "ktx.statement",
+			trace.WithTimestamp(stmt.start),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.query.text", stmt.fingerprint)),
+		)
+		endSpan(stmtSpan, stmt.end, stmt.err)
+	}
+
+	endSpan(span, end, err)
+	return spanCtx
+}
+
+func endSpan(span trace.Span, end time.Time, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// statementTracer sets the span of the transaction on the ctx of its
+// statements and creates their spans WithSpanLevel(StatementSpans),
+// buffering them when the span of the transaction is sampled once
+// it finishes.
+type statementTracer struct {
+	next ktx.DBRunner
+	i    *Instrumentation
+	tx   *ktx.Tx
+}
+
+func (r statementTracer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, end := r.start(ctx, query)
+	result, err := r.next.ExecContext(ctx, query, args...)
+	end(err)
+	return result, err
+}
+
+func (r statementTracer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, end := r.start(ctx, query)
+	rows, err := r.next.QueryContext(ctx, query, args...)
+	end(err)
+	return rows, err
+}
+
+func (r statementTracer) Unwrap() ktx.DBRunner {
+	return r.next
+}
+
+func (r statementTracer) start(ctx context.Context, query string) (context.Context, func(err error)) {
+	s, ok := r.i.states.Load(r.tx)
+	if !ok {
+		return ctx, func(error) {}
+	}
+	state := s.(*txState)
+	statementSpans := r.i.cfg.spanLevel == StatementSpans
+
+	if state.span == nil {
+		if !statementSpans {
+			return ctx, func(error) {}
+		}
+		start := time.Now()
+		return ctx, func(err error) {
+			state.record(query, start, err)
+		}
+	}
+
+	// The spans started by the callback from the
+	// span of the transaction are kept as parents:
+	if parent := trace.SpanContextFromContext(ctx); !parent.IsValid() || parent.Equal(state.parent) {
+		ctx = state.context(ctx)
+	}
+	if !statementSpans {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := r.i.tracer.Start(ctx, "ktx.statement",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.query.text", ktx.Fingerprint(query))),
+	)
+	return ctx, func(err error) {
+		endSpan(span, time.Now(), err)
+	}
+}
+
+func (s *txState) record(query string, start time.Time, err error) {
+	end := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statements = append(s.statements, statementRecord{
+		// The fingerprint is used instead of the raw query
+		// so the spans don't carry the literals of the statements:
+		fingerprint: ktx.Fingerprint(query),
+		start:       start,
+		end:         end,
+		err:         err,
+	})
+}
@@ -0,0 +1,341 @@
+package ktxotel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to create test table: %v", err)
+	}
+
+	return db
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	err := reader.Collect(context.Background(), &rm)
+	if err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+
+	metrics := map[string]metricdata.Aggregation{}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+// spanRecorder is a middleware that records the
+// span in the ctx of each statement.
+type spanRecorder struct {
+	next  ktx.DBRunner
+	spans *[]trace.SpanContext
+}
+
+func (r spanRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*r.spans = append(*r.spans, trace.SpanContextFromContext(ctx))
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r spanRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*r.spans = append(*r.spans, trace.SpanContextFromContext(ctx))
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r spanRecorder) Unwrap() ktx.DBRunner {
+	return r.next
+}
+
+// failingAppNameDialect makes the transactions
+// started WithApplicationName fail to begin.
+type failingAppNameDialect struct {
+	ktx.Dialect
+}
+
+func (failingAppNameDialect) ApplicationNameStmt() string {
+	return "SELECT missing_function(?)"
+}
+
+func TestInstrumentation(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	reader := sdkmetric.NewManualReader()
+	instrumentation, err := New(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var activeDuringTx int64
+	err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		metrics := collectMetrics(t, reader)
+		active := metrics["ktx.transaction.active"].(metricdata.Sum[int64])
+		activeDuringTx = active.DataPoints[0].Value
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO users (name) VALUES (?)`, "John")
+		return err
+	}, instrumentation.Option())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		return errors.New("fake error")
+	}, instrumentation.Option())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	attempts := 0
+	err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("database is locked")
+		}
+		return nil
+	}, ktx.WithIdempotent(), ktx.WithRetry(ktx.RetryPolicy{MaxAttempts: 3}), instrumentation.Option())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	instrumentation.RecordRetry(ctx)
+
+	if activeDuringTx != 1 {
+		t.Errorf("expected 1 active transaction during the callback, got %d", activeDuringTx)
+	}
+
+	metrics := collectMetrics(t, reader)
+
+	active := metrics["ktx.transaction.active"].(metricdata.Sum[int64])
+	if active.DataPoints[0].Value != 0 {
+		t.Errorf("expected no active transactions, got %d", active.DataPoints[0].Value)
+	}
+
+	duration := metrics["ktx.transaction.duration"].(metricdata.Histogram[float64])
+	counts := map[string]uint64{}
+	for _, dp := range duration.DataPoints {
+		outcome, _ := dp.Attributes.Value("ktx.outcome")
+		counts[outcome.AsString()] += dp.Count
+	}
+	if counts["commit"] != 2 || counts["rollback"] != 3 {
+		t.Errorf("expected 2 commits and 3 rollbacks, got: %v", counts)
+	}
+
+	retries := metrics["ktx.transaction.retries"].(metricdata.Sum[int64])
+	if retries.DataPoints[0].Value != 3 {
+		t.Errorf("expected 3 retries, got %d", retries.DataPoints[0].Value)
+	}
+}
+
+func TestInstrumentationSpans(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	newInstrumentation := func(t *testing.T, opts ...Option) (*Instrumentation, *tracetest.SpanRecorder) {
+		recorder := tracetest.NewSpanRecorder()
+		opts = append(opts, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+
+		instrumentation, err := New(opts...)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return instrumentation, recorder
+	}
+
+	insertUser := func(tx *ktx.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO users (name) VALUES (?)`, "John")
+		return err
+	}
+
+	t.Run("should create a span per transaction by default", func(t *testing.T) {
+		instrumentation, recorder := newInstrumentation(t)
+
+		err := ktx.Run(ctx, db, insertUser, ktx.WithName("create-user"), instrumentation.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Fatalf("expected 1 span, got %d", len(spans))
+		}
+		if spans[0].Name() != "create-user" {
+			t.Errorf("expected span named after the transaction, got %q", spans[0].Name())
+		}
+		if !spans[0].EndTime().After(spans[0].StartTime()) {
+			t.Errorf("expected the span to cover the transaction")
+		}
+	})
+
+	t.Run("should create a child span per statement", func(t *testing.T) {
+		instrumentation, recorder := newInstrumentation(t, WithSpanLevel(StatementSpans))
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			err := insertUser(tx)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO missing_table VALUES (1)`)
+			return err
+		}, instrumentation.Option())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 3 {
+			t.Fatalf("expected 3 spans, got %d", len(spans))
+		}
+
+		var txSpan sdktrace.ReadOnlySpan
+		var queries []string
+		for _, span := range spans {
+			if span.Name() == "ktx.transaction" {
+				txSpan = span
+				continue
+			}
+			for _, attr := range span.Attributes() {
+				if attr.Key == "db.query.text" {
+					queries = append(queries, attr.Value.AsString())
+				}
+			}
+		}
+		if txSpan == nil || txSpan.Status().Code != codes.Error {
+			t.Fatalf("expected a failed transaction span, got: %v", txSpan)
+		}
+		for _, span := range spans {
+			if span != txSpan && span.Parent().SpanID() != txSpan.SpanContext().SpanID() {
+				t.Errorf("expected statement spans to be children of the transaction span")
+			}
+		}
+
+		if len(queries) != 2 || queries[0] != "insert into users (name) values (...)" || queries[1] != "insert into missing_table values (...)" {
+			t.Errorf("unexpected statement spans: %v", queries)
+		}
+	})
+
+	t.Run("should be the parent of the spans of the callback and of the statements", func(t *testing.T) {
+		instrumentation, recorder := newInstrumentation(t)
+
+		var statementSpans []trace.SpanContext
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			ctx := instrumentation.ContextWithSpan(ctx, tx)
+			_, child := trace.SpanFromContext(ctx).TracerProvider().Tracer("test").Start(ctx, "child")
+			child.End()
+
+			return insertUser(tx)
+		}, ktx.WithName("create-user"), instrumentation.Option(), ktx.WithMiddleware(func(next ktx.DBRunner) ktx.DBRunner {
+			return spanRecorder{next: next, spans: &statementSpans}
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 2 || spans[0].Name() != "child" || spans[1].Name() != "create-user" {
+			t.Fatalf("expected the child and the transaction spans, got %d spans", len(spans))
+		}
+		txSpan := spans[1].SpanContext()
+		if spans[0].Parent().SpanID() != txSpan.SpanID() {
+			t.Errorf("expected the span of the callback to be a child of the transaction span")
+		}
+		if len(statementSpans) != 1 || statementSpans[0].SpanID() != txSpan.SpanID() {
+			t.Errorf("expected the statements to carry the transaction span, got: %v", statementSpans)
+		}
+	})
+
+	t.Run("should not keep the state of the transactions that fail to begin", func(t *testing.T) {
+		instrumentation, recorder := newInstrumentation(t, WithSpanLevel(StatementSpans))
+
+		err := ktx.Run(ctx, db, insertUser, ktx.WithDialect(failingAppNameDialect{ktx.SQLite}),
+			ktx.WithApplicationName("users-api"), instrumentation.Option(),
+		)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		instrumentation.states.Range(func(key, value interface{}) bool {
+			t.Errorf("expected no states, got: %v", value)
+			return false
+		})
+		if len(recorder.Ended()) != 0 {
+			t.Errorf("expected no spans")
+		}
+	})
+
+	t.Run("should only record the sampled transactions", func(t *testing.T) {
+		var sampled []string
+		instrumentation, recorder := newInstrumentation(t, WithSampler(func(name string, duration time.Duration, err error) bool {
+			sampled = append(sampled, name)
+			return name == "important"
+		}))
+
+		for _, name := range []string{"important", "noisy"} {
+			err := ktx.Run(ctx, db, insertUser, ktx.WithName(name), instrumentation.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 1 || spans[0].Name() != "important" {
+			t.Errorf("expected only the important transaction to be recorded, got %d spans", len(spans))
+		}
+		if len(sampled) != 2 {
+			t.Errorf("expected the sampler to be called twice, got: %v", sampled)
+		}
+	})
+
+	t.Run("should sample slow or failed transactions with SlowerThan", func(t *testing.T) {
+		sampler := SlowerThan(time.Second)
+
+		if sampler("tx", time.Millisecond, nil) {
+			t.Errorf("expected fast transactions to be skipped")
+		}
+		if !sampler("tx", 2*time.Second, nil) {
+			t.Errorf("expected slow transactions to be sampled")
+		}
+		if !sampler("tx", time.Millisecond, errors.New("fake error")) {
+			t.Errorf("expected failed transactions to be sampled")
+		}
+	})
+
+	t.Run("should not create spans with NoSpans", func(t *testing.T) {
+		instrumentation, recorder := newInstrumentation(t, WithSpanLevel(NoSpans))
+
+		err := ktx.Run(ctx, db, insertUser, instrumentation.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(recorder.Ended()) != 0 {
+			t.Errorf("expected no spans")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package ktxotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/vingarcia/ktx"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// WithLogs makes the Instrumentation emit the lifecycle events of the
+// transactions as OpenTelemetry logs through the global LoggerProvider.
+func WithLogs() Option {
+	return func(c *config) {
+		c.loggerProvider = global.GetLoggerProvider()
+	}
+}
+
+// WithLoggerProvider makes the Instrumentation emit the lifecycle
+// events of the transactions as OpenTelemetry logs through lp.
+func WithLoggerProvider(lp log.LoggerProvider) Option {
+	return func(c *config) {
+		c.loggerProvider = lp
+	}
+}
+
+// The events emitted as logs, which are set as their body
+// and as their "event.name" attribute.
+const (
+	eventBegin         = "ktx.transaction.begin"
+	eventCommit        = "ktx.transaction.commit"
+	eventRollback      = "ktx.transaction.rollback"
+	eventRetry         = "ktx.transaction.retry"
+	eventLeak          = "ktx.transaction.leak"
+	eventCallbackError = "ktx.callback.error"
+)
+
+// emit does nothing unless the logs are enabled.
+//
+// The trace and span IDs of the logs are taken from ctx by the
+// LoggerProvider, so the commit and rollback logs are emitted with
+// the context of the span of the transaction when there is one.
+func (i *Instrumentation) emit(ctx context.Context, timestamp time.Time, severity log.Severity, event string, attrs ...log.KeyValue) {
+	if i.logger == nil {
+		return
+	}
+
+	if !i.logger.Enabled(ctx, log.EnabledParameters{Severity: severity}) {
+		return
+	}
+
+	var record log.Record
+	record.SetTimestamp(timestamp)
+	record.SetSeverity(severity)
+	record.SetBody(log.StringValue(event))
+	record.AddAttributes(log.String("event.name", event))
+	record.AddAttributes(attrs...)
+	i.logger.Emit(ctx, record)
+}
+
+func txAttributes(tx *ktx.Tx) []log.KeyValue {
+	if tx.Name() == "" {
+		return nil
+	}
+	return []log.KeyValue{log.String("ktx.transaction.name", tx.Name())}
+}
+
+func errorAttribute(err error) log.KeyValue {
+	return log.String("exception.message", err.Error())
+}
+
+func (i *Instrumentation) logBegin(ctx context.Context, tx *ktx.Tx, start time.Time) {
+	i.emit(ctx, start, log.SeverityDebug, eventBegin, txAttributes(tx)...)
+}
+
+func (i *Instrumentation) logFinish(ctx context.Context, tx *ktx.Tx, end time.Time, duration time.Duration, err error) {
+	stats := tx.Stats()
+	attrs := append(txAttributes(tx),
+		log.Float64("ktx.transaction.duration", duration.Seconds()),
+		log.Int("ktx.statements", stats.Statements),
+		log.Int64("ktx.rows_affected", stats.RowsAffected),
+	)
+
+	if err == nil {
+		i.emit(ctx, end, log.SeverityInfo, eventCommit, attrs...)
+		return
+	}
+	i.emit(ctx, end, log.SeverityWarn, eventRollback, append(attrs, errorAttribute(err))...)
+}
+
+func (i *Instrumentation) logRetry(ctx context.Context, attempt int, err error) {
+	i.emit(ctx, time.Now(), log.SeverityWarn, eventRetry,
+		log.Int("ktx.attempt", attempt),
+		errorAttribute(err),
+	)
+}
+
+func (i *Instrumentation) logLeak(ctx context.Context, tx *ktx.Tx, leak ktx.Leak) {
+	i.emit(ctx, time.Now(), log.SeverityWarn, eventLeak, append(txAttributes(tx),
+		log.Float64("ktx.leak.open_for", leak.OpenFor.Seconds()),
+		log.Float64("ktx.leak.threshold", leak.Threshold.Seconds()),
+	)...)
+}
+
+func (i *Instrumentation) logCallbackError(ctx context.Context, tx *ktx.Tx, err error) {
+	i.emit(ctx, time.Now(), log.SeverityError, eventCallbackError, append(txAttributes(tx), errorAttribute(err))...)
+}
@@ -0,0 +1,130 @@
+package ktxotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vingarcia/ktx"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/logtest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func recordedLogs(recorder *logtest.Recorder) []logtest.EmittedRecord {
+	var records []logtest.EmittedRecord
+	for _, scope := range recorder.Result() {
+		records = append(records, scope.Records...)
+	}
+	return records
+}
+
+func logAttribute(record logtest.EmittedRecord, key string) log.Value {
+	var value log.Value
+	record.WalkAttributes(func(kv log.KeyValue) bool {
+		if kv.Key == key {
+			value = kv.Value
+			return false
+		}
+		return true
+	})
+	return value
+}
+
+func TestInstrumentationLogs(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should emit the lifecycle events", func(t *testing.T) {
+		recorder := logtest.NewRecorder()
+		instrumentation, err := New(WithLoggerProvider(recorder))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+
+		attempts := 0
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("SQLSTATE 40001")
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO users (name) VALUES (?)`, "John")
+			return err
+		}, ktx.WithName("create-user"), instrumentation.Option(), ktx.WithIdempotent(), ktx.WithRetry(ktx.RetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     func(int) time.Duration { return 0 },
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		var events []string
+		for _, record := range recordedLogs(recorder) {
+			events = append(events, record.Body().AsString())
+		}
+		expected := []string{eventBegin, eventRollback, eventRetry, eventBegin, eventCommit}
+		if len(events) != len(expected) {
+			t.Fatalf("expected events %v, got %v", expected, events)
+		}
+		for i := range expected {
+			if events[i] != expected[i] {
+				t.Fatalf("expected events %v, got %v", expected, events)
+			}
+		}
+
+		records := recordedLogs(recorder)
+		rollback := records[1]
+		if rollback.Severity() != log.SeverityWarn || logAttribute(rollback, "exception.message").AsString() != "SQLSTATE 40001" {
+			t.Errorf("unexpected rollback log: %v", rollback)
+		}
+
+		commit := records[4]
+		if logAttribute(commit, "ktx.transaction.name").AsString() != "create-user" || logAttribute(commit, "ktx.statements").AsInt64() != 1 {
+			t.Errorf("unexpected commit log: %v", commit)
+		}
+	})
+
+	t.Run("should correlate the logs with the span of the transaction", func(t *testing.T) {
+		recorder := logtest.NewRecorder()
+		spans := tracetest.NewSpanRecorder()
+		instrumentation, err := New(
+			WithLoggerProvider(recorder),
+			WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+
+		err = ktx.Run(ctx, db, func(tx *ktx.Tx) error { return nil }, instrumentation.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		ended := spans.Ended()
+		if len(ended) != 1 {
+			t.Fatalf("expected 1 span, got %d", len(ended))
+		}
+
+		records := recordedLogs(recorder)
+		commit := records[len(records)-1]
+		spanContext := trace.SpanContextFromContext(commit.Context())
+		if spanContext.SpanID() != ended[0].SpanContext().SpanID() {
+			t.Errorf("expected the commit log to refer to the span of the transaction")
+		}
+	})
+
+	t.Run("should not emit logs by default", func(t *testing.T) {
+		instrumentation, err := New()
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if instrumentation.logger != nil {
+			t.Errorf("expected the logs to be disabled")
+		}
+	})
+}
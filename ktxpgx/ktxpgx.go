@@ -0,0 +1,124 @@
+// Package ktxpgx exposes features of the pgx driver that are not
+// available through database/sql to the transactions of ktx, such as
+// bulk loading with the COPY protocol and pipelining of batches.
+//
+// It requires the database to be opened with the pgx driver for
+// database/sql, i.e. github.com/jackc/pgx/v5/stdlib, and it is kept on
+// a separate module so the core of ktx doesn't depend on pgx.
+package ktxpgx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/vingarcia/ktx"
+)
+
+// ErrSessionRequired is returned when the transaction was not started
+// WithSession, which is what pins it to a connection that can be used
+// with the pgx API.
+var ErrSessionRequired = errors.New("the transaction must be started with ktx.WithSession for using the pgx connection")
+
+var errNotPgxConn = errors.New("expected a connection of the pgx driver")
+
+// CopyFrom bulk loads the rows into the table using the COPY protocol,
+// which is much faster than INSERT statements for large amounts of rows,
+// and returns the number of rows copied.
+//
+// The copy runs inside the transaction, so the rows are only visible
+// after it commits and are discarded if it rolls back. The transaction
+// must be started with ktx.WithSession, e.g.:
+//
+//	err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+//		_, err := ktxpgx.CopyFrom(ctx, tx, pgx.Identifier{"users"}, []string{"name", "age"}, pgx.CopyFromRows(rows))
+//		return err
+//	}, ktx.WithSession(ktx.Session{}))
+//
+// The statements executed this way bypass the middlewares of the transaction.
+func CopyFrom(ctx context.Context, db ktx.DBRunner, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	var n int64
+	err := withConn(db, func(conn *pgx.Conn) (err error) {
+		n, err = conn.CopyFrom(ctx, table, columns, rows)
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("error copying rows into %s: %w", table.Sanitize(), err)
+	}
+	return n, nil
+}
+
+// ExecBatch is a ktx.BatchExecutor that sends all the statements of the
+// batch to Postgres in a single round trip with the batch protocol of pgx:
+//
+//	err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+//		_, err := ktx.ExecBatch(ctx, tx, batch)
+//		return err
+//	}, ktx.WithSession(ktx.Session{}), ktx.WithBatchExecutor(ktxpgx.ExecBatch))
+//
+// When the database is not opened with the pgx driver it returns
+// ktx.ErrBatchNotSupported, so ktx.ExecBatch executes the statements
+// one by one. As with CopyFrom the transaction must be started with
+// ktx.WithSession and the statements bypass its middlewares.
+func ExecBatch(ctx context.Context, tx *ktx.Tx, b *ktx.Batch) ([]sql.Result, error) {
+	results := make([]sql.Result, 0, b.Len())
+	err := withConn(tx, func(conn *pgx.Conn) error {
+		batch := &pgx.Batch{}
+		for _, stmt := range b.Statements() {
+			batch.Queue(stmt.Query, stmt.Args...)
+		}
+
+		br := conn.SendBatch(ctx, batch)
+		for i := 0; i < b.Len(); i++ {
+			tag, err := br.Exec()
+			if err != nil {
+				_ = br.Close()
+				return fmt.Errorf("error executing statement %d of the batch: %w", i, err)
+			}
+			results = append(results, commandTagResult{tag})
+		}
+		return br.Close()
+	})
+	if errors.Is(err, errNotPgxConn) {
+		return nil, fmt.Errorf("%w: %s", ktx.ErrBatchNotSupported, err)
+	}
+	return results, err
+}
+
+// commandTagResult adapts the command tag of pgx to sql.Result.
+type commandTagResult struct {
+	tag pgconn.CommandTag
+}
+
+func (r commandTagResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by Postgres, use RETURNING instead")
+}
+
+func (r commandTagResult) RowsAffected() (int64, error) {
+	return r.tag.RowsAffected(), nil
+}
+
+// withConn calls fn with the pgx connection the transaction is running on.
+func withConn(db ktx.DBRunner, fn func(conn *pgx.Conn) error) error {
+	tx, err := ktx.TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	conn := tx.Conn()
+	if conn == nil {
+		return ErrSessionRequired
+	}
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("%w, got: %T", errNotPgxConn, driverConn)
+		}
+		return fn(c.Conn())
+	})
+}
@@ -0,0 +1,103 @@
+package ktxpgx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	return db
+}
+
+func TestCopyFrom(t *testing.T) {
+	ctx := context.Background()
+	rows := pgx.CopyFromRows([][]interface{}{{"John", 42}})
+
+	t.Run("should require a transaction managed by ktx", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		_, err := CopyFrom(ctx, db, pgx.Identifier{"users"}, []string{"name", "age"}, rows)
+		if !errors.Is(err, ktx.ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+
+	t.Run("should require a session", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := CopyFrom(ctx, tx, pgx.Identifier{"users"}, []string{"name", "age"}, rows)
+			return err
+		})
+		if !errors.Is(err, ErrSessionRequired) {
+			t.Errorf("expected ErrSessionRequired, got: %v", err)
+		}
+	})
+
+	t.Run("should require the pgx driver", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := CopyFrom(ctx, tx, pgx.Identifier{"users"}, []string{"name", "age"}, rows)
+			return err
+		}, ktx.WithSession(ktx.Session{}))
+		if err == nil || !strings.Contains(err.Error(), "pgx driver") {
+			t.Errorf("expected an error about the driver, got: %v", err)
+		}
+	})
+}
+
+func TestExecBatch(t *testing.T) {
+	ctx := context.Background()
+
+	newBatch := func() *ktx.Batch {
+		b := &ktx.Batch{}
+		b.Queue("CREATE TABLE users (name TEXT)")
+		b.Queue("INSERT INTO users VALUES (?)", "John")
+		return b
+	}
+
+	t.Run("should fall back to sequential execution on other drivers", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var results []sql.Result
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) (err error) {
+			results, err = ktx.ExecBatch(ctx, tx, newBatch())
+			return err
+		}, ktx.WithSession(ktx.Session{}), ktx.WithBatchExecutor(ExecBatch))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(results))
+		}
+	})
+
+	t.Run("should require a session", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := ktx.ExecBatch(ctx, tx, newBatch())
+			return err
+		}, ktx.WithBatchExecutor(ExecBatch))
+		if !errors.Is(err, ErrSessionRequired) {
+			t.Errorf("expected ErrSessionRequired, got: %v", err)
+		}
+	})
+}
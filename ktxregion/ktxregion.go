@@ -0,0 +1,224 @@
+// Package ktxregion routes ktx transactions to the primary database of the
+// region that owns each key, e.g. each tenant, on globally distributed
+// deployments.
+//
+// The region of each key is looked up with a Resolver, usually backed by a
+// control plane or a topology table, and cached. When a transaction fails in
+// a way that suggests the primary has moved, e.g. because the database became
+// read-only after a failover, the cached region of its key is invalidated so
+// the next transaction resolves it again.
+package ktxregion
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// ErrUnknownRegion is returned when the Resolver returns
+// a region that was not registered on the Router.
+var ErrUnknownRegion = errors.New("unknown region")
+
+// Resolver finds the region whose primary owns the input key.
+type Resolver interface {
+	Resolve(ctx context.Context, key string) (region string, err error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(ctx context.Context, key string) (region string, err error)
+
+// Resolve calls fn.
+func (fn ResolverFunc) Resolve(ctx context.Context, key string) (region string, err error) {
+	return fn(ctx, key)
+}
+
+// Option configures a Router.
+type Option func(*config)
+
+type config struct {
+	cacheTTL   time.Duration
+	isFailover func(err error) bool
+}
+
+// WithCacheTTL configures for how long the region of a key
+// is cached before it is resolved again.
+//
+// Defaults to 1 minute.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithFailoverClassifier configures which errors returned by the
+// transactions invalidate the cached region of their keys.
+//
+// Defaults to IsFailoverError.
+func WithFailoverClassifier(isFailover func(err error) bool) Option {
+	return func(c *config) {
+		c.isFailover = isFailover
+	}
+}
+
+// Router starts transactions on the primary of the region of each key.
+type Router struct {
+	regions  map[string]*sql.DB
+	resolver Resolver
+	cfg      config
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	region    string
+	expiresAt time.Time
+}
+
+// New creates a Router for the input regional primaries, indexed by region name.
+func New(regions map[string]*sql.DB, resolver Resolver, opts ...Option) *Router {
+	cfg := config{
+		cacheTTL:   time.Minute,
+		isFailover: IsFailoverError,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Router{
+		regions:  regions,
+		resolver: resolver,
+		cfg:      cfg,
+		now:      time.Now,
+		cache:    map[string]cacheEntry{},
+	}
+}
+
+// Run runs fn inside a transaction on the primary of the region
+// of key using ktx.Run with the input Options.
+//
+// If the transaction fails to start, the region of the key is resolved
+// again and, if it changed, the transaction is started on the new region,
+// which is safe since fn was not called yet. Errors returned after the
+// transaction started only invalidate the cached region when they are
+// classified as failovers.
+func (r *Router) Run(ctx context.Context, key string, fn func(tx *ktx.Tx) error, opts ...ktx.Option) error {
+	region, err := r.resolve(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	started, err := r.run(ctx, region, fn, opts)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	if !started || r.cfg.isFailover(err) {
+		r.Invalidate(key)
+	}
+	if started {
+		return err
+	}
+
+	newRegion, resolveErr := r.resolve(ctx, key)
+	if resolveErr != nil || newRegion == region {
+		return err
+	}
+
+	_, err = r.run(ctx, newRegion, fn, opts)
+	return err
+}
+
+func (r *Router) run(ctx context.Context, region string, fn func(tx *ktx.Tx) error, opts []ktx.Option) (started bool, err error) {
+	db, ok := r.regions[region]
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+	}
+
+	err = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+		started = true
+		return fn(tx)
+	}, opts...)
+	return started, err
+}
+
+// Region returns the region of the key, resolving it if it is not cached.
+func (r *Router) Region(ctx context.Context, key string) (string, error) {
+	return r.resolve(ctx, key)
+}
+
+// Invalidate removes the cached region of the key,
+// so it is resolved again on the next transaction.
+func (r *Router) Invalidate(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.cache, key)
+}
+
+func (r *Router) resolve(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && r.now().Before(entry.expiresAt) {
+		return entry.region, nil
+	}
+
+	region, err := r.resolver.Resolve(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("error resolving the region of key %q: %w", key, err)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = cacheEntry{
+		region:    region,
+		expiresAt: r.now().Add(r.cfg.cacheTTL),
+	}
+	r.mu.Unlock()
+
+	return region, nil
+}
+
+// IsFailoverError reports whether err suggests that the database is no
+// longer the primary, i.e. that it became read-only, is shutting down
+// or that the connection to it was lost.
+func IsFailoverError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "25006", // read_only_sql_transaction
+			"57P01",          // admin_shutdown
+			"08000", "08006": // connection failures
+			return true
+		}
+	}
+
+	msg := err.Error()
+	for _, s := range []string{
+		"read-only transaction", // Postgres
+		"--read-only",           // MySQL Error 1290
+		"--super-read-only",     // MySQL Error 1290
+		"SQLSTATE 25006",        // Postgres
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,195 @@
+package ktxregion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vingarcia/ktx"
+)
+
+func openRegionDB(t *testing.T, region string) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE region (name TEXT); INSERT INTO region VALUES (?)", region)
+	if err != nil {
+		t.Fatalf("Failed to create region table: %v", err)
+	}
+	return db
+}
+
+func openBrokenDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:/non/existent/dir/region.db?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+// fakeResolver resolves every key to the current region and counts the calls.
+type fakeResolver struct {
+	mu     sync.Mutex
+	region string
+	calls  int
+}
+
+func (f *fakeResolver) Resolve(ctx context.Context, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.region, nil
+}
+
+func (f *fakeResolver) set(region string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.region = region
+}
+
+func currentRegion(t *testing.T, r *Router, key string) string {
+	var region string
+	err := r.Run(context.Background(), key, func(tx *ktx.Tx) error {
+		rows, err := tx.QueryContext(context.Background(), "SELECT name FROM region")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+
+		rows.Next()
+		return rows.Scan(&region)
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return region
+}
+
+func TestRouter_RoutesAndCaches(t *testing.T) {
+	us := openRegionDB(t, "us")
+	defer func() { _ = us.Close() }()
+	eu := openRegionDB(t, "eu")
+	defer func() { _ = eu.Close() }()
+
+	resolver := &fakeResolver{region: "eu"}
+	r := New(map[string]*sql.DB{"us": us, "eu": eu}, resolver)
+
+	if got := currentRegion(t, r, "tenant-1"); got != "eu" {
+		t.Fatalf("expected the transaction to run on eu, got %s", got)
+	}
+	if got := currentRegion(t, r, "tenant-1"); got != "eu" {
+		t.Fatalf("expected the transaction to run on eu, got %s", got)
+	}
+	if resolver.calls != 1 {
+		t.Errorf("expected the region to be cached, got %d resolutions", resolver.calls)
+	}
+}
+
+func TestRouter_CacheExpires(t *testing.T) {
+	us := openRegionDB(t, "us")
+	defer func() { _ = us.Close() }()
+
+	resolver := &fakeResolver{region: "us"}
+	r := New(map[string]*sql.DB{"us": us}, resolver, WithCacheTTL(time.Minute))
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	currentRegion(t, r, "tenant-1")
+	now = now.Add(2 * time.Minute)
+	currentRegion(t, r, "tenant-1")
+
+	if resolver.calls != 2 {
+		t.Errorf("expected the region to be resolved again, got %d resolutions", resolver.calls)
+	}
+}
+
+func TestRouter_MovesToTheNewPrimaryWhenBeginFails(t *testing.T) {
+	us := openBrokenDB(t)
+	defer func() { _ = us.Close() }()
+	eu := openRegionDB(t, "eu")
+	defer func() { _ = eu.Close() }()
+
+	resolver := &fakeResolver{region: "us"}
+	r := New(map[string]*sql.DB{"us": us, "eu": eu}, resolver)
+
+	_, err := r.Region(context.Background(), "tenant-1")
+	if err != nil {
+		t.Fatalf("Region failed: %v", err)
+	}
+
+	// The primary failed over to eu after the region was cached:
+	resolver.set("eu")
+
+	if got := currentRegion(t, r, "tenant-1"); got != "eu" {
+		t.Errorf("expected the transaction to move to eu, got %s", got)
+	}
+}
+
+func TestRouter_InvalidatesOnFailoverErrors(t *testing.T) {
+	us := openRegionDB(t, "us")
+	defer func() { _ = us.Close() }()
+
+	resolver := &fakeResolver{region: "us"}
+	r := New(map[string]*sql.DB{"us": us}, resolver)
+
+	ctx := context.Background()
+	failoverErr := errors.New("cannot execute INSERT in a read-only transaction")
+	calls := 0
+	err := r.Run(ctx, "tenant-1", func(tx *ktx.Tx) error {
+		calls++
+		return failoverErr
+	})
+	if !errors.Is(err, failoverErr) {
+		t.Fatalf("expected the failover error, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the callback not to be retried, got %d calls", calls)
+	}
+
+	err = r.Run(ctx, "tenant-1", func(tx *ktx.Tx) error { return errors.New("business error") })
+	if err == nil {
+		t.Fatal("expected the business error")
+	}
+	currentRegion(t, r, "tenant-1")
+
+	if resolver.calls != 2 {
+		t.Errorf("expected only the failover error to invalidate the cache, got %d resolutions", resolver.calls)
+	}
+}
+
+func TestRouter_UnknownRegion(t *testing.T) {
+	r := New(map[string]*sql.DB{}, ResolverFunc(func(ctx context.Context, key string) (string, error) {
+		return "mars", nil
+	}))
+
+	err := r.Run(context.Background(), "tenant-1", func(tx *ktx.Tx) error { return nil })
+	if !errors.Is(err, ErrUnknownRegion) {
+		t.Fatalf("expected ErrUnknownRegion, got: %v", err)
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: errors.New("duplicate key"), expected: false},
+		{err: errors.New("ERROR: cannot execute UPDATE in a read-only transaction (SQLSTATE 25006)"), expected: true},
+		{err: errors.New("Error 1290 (HY000): The MySQL server is running with the --read-only option"), expected: true},
+	}
+
+	for _, test := range tests {
+		if got := IsFailoverError(test.err); got != test.expected {
+			t.Errorf("IsFailoverError(%v): expected %v, got %v", test.err, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package ktxshard routes ktx transactions to one of several database shards.
+//
+// Each shard is guarded by a circuit breaker that is fed by background health
+// checks and by failures to start transactions, so that transactions against
+// a shard that is known to be down fail fast instead of waiting for the
+// connection timeouts of the driver.
+package ktxshard
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// ErrUnknownShard is returned when a transaction is requested for a shard
+// name that was not registered on the Router.
+var ErrUnknownShard = errors.New("unknown shard")
+
+// ErrShardUnavailable is the sentinel matched by errors.Is for every
+// *UnavailableError returned by the Router.
+var ErrShardUnavailable = errors.New("shard unavailable")
+
+// UnavailableError is returned when the circuit breaker of a shard is open.
+type UnavailableError struct {
+	Shard string
+	// Until is the end of the cooldown of the breaker, after which
+	// a single transaction is let through as a probe.
+	Until time.Time
+	// Cause is the last error observed on the shard, if any.
+	Cause error
+}
+
+func (e *UnavailableError) Error() string {
+	msg := fmt.Sprintf("shard %q is unavailable until %s", e.Shard, e.Until.Format(time.RFC3339))
+	if e.Cause != nil {
+		msg += fmt.Sprintf(", last error: %s", e.Cause)
+	}
+	return msg
+}
+
+// Is allows errors.Is(err, ErrShardUnavailable) to match this error.
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrShardUnavailable
+}
+
+// Unwrap returns the last error observed on the shard.
+func (e *UnavailableError) Unwrap() error {
+	return e.Cause
+}
+
+// Option configures a Router.
+type Option func(*config)
+
+type config struct {
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	failureThreshold    int
+	cooldown            time.Duration
+}
+
+// WithHealthCheck configures how often each shard is pinged in the background
+// and how long each ping may take. An interval of zero disables health checks.
+//
+// Defaults to an interval of 10s and a timeout of 2s.
+func WithHealthCheck(interval time.Duration, timeout time.Duration) Option {
+	return func(c *config) {
+		c.healthCheckInterval = interval
+		c.healthCheckTimeout = timeout
+	}
+}
+
+// WithCircuitBreaker configures after how many consecutive failures the
+// breaker of a shard opens and for how long it stays open before a single
+// transaction is let through as a probe. The breaker closes if the probe
+// starts its transaction, and opens again for another cooldown otherwise.
+//
+// Defaults to 5 failures and a cooldown of 30s.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(c *config) {
+		c.failureThreshold = failureThreshold
+		c.cooldown = cooldown
+	}
+}
+
+// Router starts transactions on a named shard.
+type Router struct {
+	shards map[string]*shard
+	cfg    config
+	now    func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a Router for the input shards, indexed by name.
+//
+// If health checks are enabled (the default) a background goroutine is
+// started, so Close should be called once the Router is no longer needed.
+func New(shards map[string]*sql.DB, opts ...Option) *Router {
+	cfg := config{
+		healthCheckInterval: 10 * time.Second,
+		healthCheckTimeout:  2 * time.Second,
+		failureThreshold:    5,
+		cooldown:            30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Router{
+		shards: make(map[string]*shard, len(shards)),
+		cfg:    cfg,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+	for name, db := range shards {
+		r.shards[name] = &shard{name: name, db: db}
+	}
+
+	if cfg.healthCheckInterval > 0 {
+		r.wg.Add(1)
+		go r.healthCheckLoop()
+	}
+
+	return r
+}
+
+// Close stops the background health checks. It does not close the
+// underlying databases.
+func (r *Router) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	r.wg.Wait()
+	return nil
+}
+
+// Transaction runs fn inside a transaction on the named shard using
+// ktx.Transaction.
+//
+// If the breaker of the shard is open an *UnavailableError is returned
+// immediately without touching the database.
+func (r *Router) Transaction(ctx context.Context, shardName string, fn func(db *sql.Tx) error) error {
+	s, ok := r.shards[shardName]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownShard, shardName)
+	}
+
+	probe, err := s.allow(r.now())
+	if err != nil {
+		return err
+	}
+
+	started := false
+	err = ktx.Transaction(ctx, s.db, func(tx *sql.Tx) error {
+		started = true
+		s.recordSuccess()
+		return fn(tx)
+	})
+
+	// Only failures to start the transaction say something about the
+	// health of the shard, errors from the callback are business as usual.
+	switch {
+	case started:
+	case err != nil && ctx.Err() == nil:
+		s.recordFailure(r.now(), err, r.cfg)
+	case probe:
+		// The probe was canceled by the caller, so the next
+		// transaction is let through as a probe instead:
+		s.releaseProbe()
+	}
+
+	return err
+}
+
+// Healthy reports whether the breaker of the named shard is currently closed,
+// which after a cooldown only happens once a probe starts its transaction.
+func (r *Router) Healthy(shardName string) bool {
+	s, ok := r.shards[shardName]
+	if !ok {
+		return false
+	}
+	return s.closed()
+}
+
+func (r *Router) healthCheckLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.cfg.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkShards()
+		}
+	}
+}
+
+func (r *Router) checkShards() {
+	for _, s := range r.shards {
+		ctx, cancel := context.WithTimeout(context.Background(), r.cfg.healthCheckTimeout)
+		err := s.db.PingContext(ctx)
+		cancel()
+
+		if err != nil {
+			s.recordFailure(r.now(), err, r.cfg)
+			continue
+		}
+		s.recordSuccess()
+	}
+}
+
+type shard struct {
+	name string
+	db   *sql.DB
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+	lastErr   error
+}
+
+// allow reports whether a transaction can start on the shard
+// and whether it is the probe of a half-open breaker.
+func (s *shard) allow(now time.Time) (probe bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.openUntil.IsZero() {
+		return false, nil
+	}
+
+	if now.Before(s.openUntil) || s.probing {
+		return false, &UnavailableError{
+			Shard: s.name,
+			Until: s.openUntil,
+			Cause: s.lastErr,
+		}
+	}
+
+	s.probing = true
+	return true, nil
+}
+
+func (s *shard) closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.openUntil.IsZero()
+}
+
+func (s *shard) releaseProbe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.probing = false
+}
+
+func (s *shard) recordSuccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = 0
+	s.openUntil = time.Time{}
+	s.probing = false
+	s.lastErr = nil
+}
+
+func (s *shard) recordFailure(now time.Time, err error, cfg config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastErr = err
+	if !s.openUntil.IsZero() {
+		// A failed probe, or a failed health check while
+		// the breaker is open, restarts the cooldown:
+		s.openUntil = now.Add(cfg.cooldown)
+		s.probing = false
+		return
+	}
+
+	s.failures++
+	if s.failures >= cfg.failureThreshold {
+		s.openUntil = now.Add(cfg.cooldown)
+	}
+}
@@ -0,0 +1,211 @@
+package ktxshard
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openHealthyDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func openBrokenDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:/non/existent/dir/shard.db?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+func TestRouter_HealthyShard(t *testing.T) {
+	db := openHealthyDB(t)
+	defer func() { _ = db.Close() }()
+
+	r := New(map[string]*sql.DB{"a": db}, WithHealthCheck(0, 0))
+	defer func() { _ = r.Close() }()
+
+	called := false
+	err := r.Transaction(context.Background(), "a", func(tx *sql.Tx) error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Fatal("expected callback to be called")
+	}
+}
+
+func TestRouter_UnknownShard(t *testing.T) {
+	r := New(map[string]*sql.DB{}, WithHealthCheck(0, 0))
+	defer func() { _ = r.Close() }()
+
+	err := r.Transaction(context.Background(), "missing", func(tx *sql.Tx) error {
+		return nil
+	})
+	if !errors.Is(err, ErrUnknownShard) {
+		t.Fatalf("expected ErrUnknownShard, got: %v", err)
+	}
+}
+
+func TestRouter_BreakerOpensAfterBeginFailures(t *testing.T) {
+	db := openBrokenDB(t)
+	defer func() { _ = db.Close() }()
+
+	r := New(map[string]*sql.DB{"a": db},
+		WithHealthCheck(0, 0),
+		WithCircuitBreaker(2, time.Minute),
+	)
+	defer func() { _ = r.Close() }()
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	noop := func(tx *sql.Tx) error { return nil }
+
+	for i := 0; i < 2; i++ {
+		err := r.Transaction(ctx, "a", noop)
+		if err == nil || errors.Is(err, ErrShardUnavailable) {
+			t.Fatalf("expected begin error on attempt %d, got: %v", i, err)
+		}
+	}
+
+	err := r.Transaction(ctx, "a", noop)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected *UnavailableError, got: %v", err)
+	}
+	if unavailable.Shard != "a" || unavailable.Cause == nil {
+		t.Fatalf("unexpected error fields: %+v", unavailable)
+	}
+	if !errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected error to match ErrShardUnavailable")
+	}
+	if r.Healthy("a") {
+		t.Fatal("expected shard to be reported as unhealthy")
+	}
+
+	// After the cooldown a new attempt reaches the database again:
+	now = now.Add(2 * time.Minute)
+	err = r.Transaction(ctx, "a", noop)
+	if err == nil || errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected begin error after cooldown, got: %v", err)
+	}
+
+	// And a single failure is enough to open the breaker again:
+	err = r.Transaction(ctx, "a", noop)
+	if !errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected ErrShardUnavailable, got: %v", err)
+	}
+}
+
+func TestRouter_HalfOpenProbe(t *testing.T) {
+	broken := openBrokenDB(t)
+	defer func() { _ = broken.Close() }()
+	healthy := openHealthyDB(t)
+	defer func() { _ = healthy.Close() }()
+
+	r := New(map[string]*sql.DB{"a": broken},
+		WithHealthCheck(0, 0),
+		WithCircuitBreaker(1, time.Minute),
+	)
+	defer func() { _ = r.Close() }()
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	noop := func(tx *sql.Tx) error { return nil }
+
+	err := r.Transaction(ctx, "a", noop)
+	if err == nil || errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected begin error, got: %v", err)
+	}
+
+	// The shard recovers, but it is only healthy again after a probe:
+	r.shards["a"].db = healthy
+	now = now.Add(2 * time.Minute)
+	if r.Healthy("a") {
+		t.Fatal("expected shard to be unhealthy before the probe")
+	}
+
+	probe, err := r.shards["a"].allow(now)
+	if !probe || err != nil {
+		t.Fatalf("expected a probe to be let through, got: %v, %v", probe, err)
+	}
+	err = r.Transaction(ctx, "a", noop)
+	if !errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected a single probe at a time, got: %v", err)
+	}
+	r.shards["a"].releaseProbe()
+
+	err = r.Transaction(ctx, "a", noop)
+	if err != nil {
+		t.Fatalf("expected the probe to succeed, got: %v", err)
+	}
+	if !r.Healthy("a") {
+		t.Fatal("expected shard to be healthy after the probe")
+	}
+}
+
+func TestRouter_CallbackErrorsDoNotOpenBreaker(t *testing.T) {
+	db := openHealthyDB(t)
+	defer func() { _ = db.Close() }()
+
+	r := New(map[string]*sql.DB{"a": db},
+		WithHealthCheck(0, 0),
+		WithCircuitBreaker(1, time.Minute),
+	)
+	defer func() { _ = r.Close() }()
+
+	testErr := errors.New("test error")
+	for i := 0; i < 3; i++ {
+		err := r.Transaction(context.Background(), "a", func(tx *sql.Tx) error {
+			return testErr
+		})
+		if err != testErr {
+			t.Fatalf("expected test error, got: %v", err)
+		}
+	}
+}
+
+func TestRouter_HealthChecks(t *testing.T) {
+	healthy := openHealthyDB(t)
+	defer func() { _ = healthy.Close() }()
+	broken := openBrokenDB(t)
+	defer func() { _ = broken.Close() }()
+
+	r := New(map[string]*sql.DB{"healthy": healthy, "broken": broken},
+		WithHealthCheck(0, time.Second),
+		WithCircuitBreaker(1, time.Minute),
+	)
+	defer func() { _ = r.Close() }()
+
+	r.checkShards()
+
+	if !r.Healthy("healthy") {
+		t.Fatal("expected healthy shard to be reported as healthy")
+	}
+	if r.Healthy("broken") {
+		t.Fatal("expected broken shard to be reported as unhealthy")
+	}
+
+	err := r.Transaction(context.Background(), "broken", func(tx *sql.Tx) error {
+		t.Fatal("callback should not be called")
+		return nil
+	})
+	if !errors.Is(err, ErrShardUnavailable) {
+		t.Fatalf("expected ErrShardUnavailable, got: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package ktxshard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// RingOption configures a Ring.
+type RingOption func(*Ring)
+
+// WithVirtualNodes configures how many points each shard has on the ring,
+// more points spread the keys more evenly across the shards.
+//
+// Defaults to 160.
+func WithVirtualNodes(n int) RingOption {
+	return func(r *Ring) {
+		r.vnodes = n
+	}
+}
+
+// Ring maps keys, such as tenant IDs, to shard names with consistent
+// hashing, so adding or removing a shard only moves the keys of a
+// fraction of the ring proportional to its share, instead of
+// reshuffling most keys as with a modulo of the number of shards.
+//
+// Keys can also be pinned to a shard with Override, e.g. for moving a
+// large tenant to a dedicated shard. It is safe for concurrent use.
+type Ring struct {
+	vnodes int
+
+	mu        sync.RWMutex
+	points    []ringPoint
+	shards    map[string]bool
+	overrides map[string]string
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard string
+}
+
+// NewRing creates a Ring with the input shards.
+func NewRing(shards []string, opts ...RingOption) *Ring {
+	r := &Ring{
+		vnodes:    160,
+		shards:    map[string]bool{},
+		overrides: map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for _, shard := range shards {
+		r.Add(shard)
+	}
+	return r
+}
+
+// Add adds a shard to the ring, it has no effect if the shard is already there.
+func (r *Ring) Add(shard string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.shards[shard] {
+		return
+	}
+	r.shards[shard] = true
+
+	for i := 0; i < r.vnodes; i++ {
+		r.points = append(r.points, ringPoint{
+			hash:  hashKey(shard + "#" + strconv.Itoa(i)),
+			shard: shard,
+		})
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+}
+
+// Remove removes a shard from the ring, its keys are spread across
+// the remaining shards. The overrides to the shard are kept.
+func (r *Ring) Remove(shard string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.shards[shard] {
+		return
+	}
+	delete(r.shards, shard)
+
+	points := r.points[:0]
+	for _, p := range r.points {
+		if p.shard != shard {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+}
+
+// Override pins the key to the shard regardless of its position on the ring.
+func (r *Ring) Override(key string, shard string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.overrides[key] = shard
+}
+
+// RemoveOverride makes the key be mapped by the ring again.
+func (r *Ring) RemoveOverride(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.overrides, key)
+}
+
+// Shard returns the name of the shard of the key,
+// or an empty string if the ring has no shards.
+func (r *Ring) Shard(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if shard, ok := r.overrides[key]; ok {
+		return shard
+	}
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].shard
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	// FNV alone clusters similar keys such as "shard#1" and "shard#2",
+	// so the bits are mixed with the finalizer of MurmurHash3:
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,81 @@
+package ktxshard
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRing_SpreadsKeys(t *testing.T) {
+	r := NewRing([]string{"a", "b", "c", "d"})
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[r.Shard("tenant-"+strconv.Itoa(i))]++
+	}
+
+	for _, shard := range []string{"a", "b", "c", "d"} {
+		// Each shard should get roughly a quarter of the keys:
+		if counts[shard] < 1500 || counts[shard] > 3500 {
+			t.Errorf("unbalanced ring, shard %s got %d of 10000 keys: %v", shard, counts[shard], counts)
+		}
+	}
+}
+
+func TestRing_AddingShardMovesFewKeys(t *testing.T) {
+	r := NewRing([]string{"a", "b", "c", "d"})
+
+	before := map[string]string{}
+	for i := 0; i < 10000; i++ {
+		key := "tenant-" + strconv.Itoa(i)
+		before[key] = r.Shard(key)
+	}
+
+	r.Add("e")
+
+	moved := 0
+	for key, shard := range before {
+		newShard := r.Shard(key)
+		if newShard != shard {
+			moved++
+			if newShard != "e" {
+				t.Fatalf("key %s moved between existing shards: %s -> %s", key, shard, newShard)
+			}
+		}
+	}
+
+	// Ideally a fifth of the keys move to the new shard:
+	if moved < 1000 || moved > 3000 {
+		t.Errorf("expected about 2000 keys to move, got %d", moved)
+	}
+}
+
+func TestRing_RemovingShard(t *testing.T) {
+	r := NewRing([]string{"a", "b"})
+	r.Remove("a")
+
+	for i := 0; i < 100; i++ {
+		if shard := r.Shard("tenant-" + strconv.Itoa(i)); shard != "b" {
+			t.Fatalf("expected all keys on b, got %s", shard)
+		}
+	}
+
+	r.Remove("b")
+	if shard := r.Shard("tenant-1"); shard != "" {
+		t.Errorf("expected no shard on an empty ring, got %s", shard)
+	}
+}
+
+func TestRing_Overrides(t *testing.T) {
+	r := NewRing([]string{"a", "b"}, WithVirtualNodes(10))
+
+	original := r.Shard("big-tenant")
+	r.Override("big-tenant", "dedicated")
+	if shard := r.Shard("big-tenant"); shard != "dedicated" {
+		t.Errorf("expected the override to prevail, got %s", shard)
+	}
+
+	r.RemoveOverride("big-tenant")
+	if shard := r.Shard("big-tenant"); shard != original {
+		t.Errorf("expected the key to go back to %s, got %s", original, shard)
+	}
+}
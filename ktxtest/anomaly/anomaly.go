@@ -0,0 +1,203 @@
+// Package anomaly checks which isolation anomalies the transactional code
+// of an application is exposed to, by running concurrent workloads against
+// a real database at a given isolation level and checking their outcome:
+//
+//	report, err := anomaly.Run(ctx, db, anomaly.LostUpdate(counter), anomaly.Options{
+//		Isolation: sql.LevelReadCommitted,
+//	})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	if report.Anomalies > 0 {
+//		t.Errorf("lost updates detected: %s", report)
+//	}
+//
+// The transactions of each round are interleaved deterministically: all of
+// them run their Read phase concurrently and only then each one runs its
+// Write phase and finishes, in the order they are listed. This reproduces
+// the anomalies allowed by the isolation level on every round without
+// depending on timing. Databases that lock the rows they read, such as
+// MySQL on SERIALIZABLE, make the Write phases wait for the lock timeout,
+// so a low lock timeout is recommended on them.
+//
+// It helps picking the isolation level and the retry configuration of
+// each code path, since an isolation level that prevents an anomaly
+// usually does it by failing some transactions, which can be retried
+// by passing ktx.WithRetry on Options.RunOptions.
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Tx is one of the concurrent transactions of a Workload.
+type Tx struct {
+	// Read runs first, concurrently with the Read phases
+	// of the other transactions of the round.
+	Read func(ctx context.Context, tx *ktx.Tx) error
+
+	// Write runs after all the Read phases, once the previous
+	// transactions of the round finished.
+	Write func(ctx context.Context, tx *ktx.Tx) error
+}
+
+// Workload describes the transactions run on each round and how
+// to detect whether they produced an anomaly.
+type Workload struct {
+	// Name describes the anomaly checked by the workload.
+	Name string
+
+	// Setup resets the data used by the workload before each round.
+	Setup func(ctx context.Context, db ktx.DBRunner) error
+
+	// Txs run concurrently, each on its own transaction.
+	Txs []Tx
+
+	// Check reports whether the round produced an anomaly, receiving
+	// which of the transactions were committed.
+	Check func(ctx context.Context, db ktx.DBRunner, committed []bool) (anomaly bool, err error)
+}
+
+// Options configures Run.
+type Options struct {
+	// Rounds is how many times the workload runs, defaults to 20.
+	Rounds int
+
+	// Isolation is the isolation level of the transactions.
+	Isolation sql.IsolationLevel
+
+	// RunOptions are passed to ktx.Run for each transaction,
+	// e.g. ktx.WithRetry with ktx.WithIdempotent.
+	RunOptions []ktx.Option
+}
+
+// Report summarizes the rounds run by Run.
+type Report struct {
+	Workload string
+	Rounds   int
+
+	// Anomalies counts the rounds that produced the anomaly.
+	Anomalies int
+
+	// Commits and Failures count the transactions that were committed
+	// and the ones that failed, e.g. with serialization failures.
+	Commits  int
+	Failures int
+
+	// Errors has the errors of the first failed transactions.
+	Errors []error
+}
+
+// maxErrors limits how many errors are kept on the Report.
+const maxErrors = 10
+
+// String returns the report in a human-readable format.
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"%s: %d anomalies in %d rounds, %d commits, %d failures",
+		r.Workload, r.Anomalies, r.Rounds, r.Commits, r.Failures,
+	)
+}
+
+// Run runs the rounds of the workload and reports how many of them
+// produced the anomaly. It only returns an error when the Setup
+// or the Check of the workload fail.
+func Run(ctx context.Context, db ktx.TxBeginner, w Workload, opts Options) (Report, error) {
+	if opts.Rounds <= 0 {
+		opts.Rounds = 20
+	}
+	runOpts := append([]ktx.Option{ktx.WithIsolation(opts.Isolation)}, opts.RunOptions...)
+
+	report := Report{Workload: w.Name}
+	for round := 0; round < opts.Rounds; round++ {
+		if w.Setup != nil {
+			err := w.Setup(ctx, db)
+			if err != nil {
+				return report, fmt.Errorf("error setting up round %d of %s: %w", round, w.Name, err)
+			}
+		}
+
+		errs := runRound(ctx, db, w.Txs, runOpts)
+
+		committed := make([]bool, len(errs))
+		for i, err := range errs {
+			committed[i] = err == nil
+			if err == nil {
+				report.Commits++
+				continue
+			}
+			report.Failures++
+			if len(report.Errors) < maxErrors {
+				report.Errors = append(report.Errors, err)
+			}
+		}
+
+		anomaly, err := w.Check(ctx, db, committed)
+		if err != nil {
+			return report, fmt.Errorf("error checking round %d of %s: %w", round, w.Name, err)
+		}
+		if anomaly {
+			report.Anomalies++
+		}
+		report.Rounds++
+	}
+
+	return report, nil
+}
+
+// runRound runs the transactions of a round
+// and returns the error of each one of them.
+func runRound(ctx context.Context, db ktx.TxBeginner, txs []Tx, runOpts []ktx.Option) []error {
+	var reads sync.WaitGroup
+	reads.Add(len(txs))
+
+	// turns[i] is closed once the transactions before i finished:
+	turns := make([]chan struct{}, len(txs)+1)
+	for i := range turns {
+		turns[i] = make(chan struct{})
+	}
+	close(turns[0])
+
+	errs := make([]error, len(txs))
+	var wg sync.WaitGroup
+	for i, t := range txs {
+		wg.Add(1)
+		go func(i int, t Tx) {
+			defer wg.Done()
+
+			var once sync.Once
+			readDone := func() { once.Do(reads.Done) }
+
+			errs[i] = ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+				var err error
+				if t.Read != nil {
+					err = t.Read(ctx, tx)
+				}
+				readDone()
+				if err != nil {
+					return err
+				}
+
+				reads.Wait()
+				<-turns[i]
+				if t.Write == nil {
+					return nil
+				}
+				return t.Write(ctx, tx)
+			}, runOpts...)
+
+			// The transaction may fail before reaching its Read phase:
+			readDone()
+			<-turns[i]
+			close(turns[i+1])
+		}(i, t)
+	}
+	wg.Wait()
+
+	return errs
+}
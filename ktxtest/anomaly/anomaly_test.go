@@ -0,0 +1,142 @@
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/vingarcia/ktx"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// memState emulates a database without any isolation, where every
+// transaction sees and overwrites the latest values.
+type memState struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (m *memState) get(key string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key]
+}
+
+func (m *memState) set(key string, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+}
+
+func (m *memState) reset(ctx context.Context, db ktx.DBRunner) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = map[string]int64{"doctors_on_call": 2}
+	return nil
+}
+
+func openDB(t *testing.T, dsn string) *sql.DB {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should detect anomalies without isolation", func(t *testing.T) {
+		// The transactions only carry the workloads, which use memState:
+		db := openDB(t, ":memory:")
+		state := &memState{}
+
+		workloads := []Workload{
+			LostUpdate(Counter{
+				Reset: state.reset,
+				Get: func(ctx context.Context, db ktx.DBRunner) (int64, error) {
+					return state.get("counter"), nil
+				},
+				Set: func(ctx context.Context, db ktx.DBRunner, value int64) error {
+					state.set("counter", value)
+					return nil
+				},
+			}),
+			WriteSkew(Constraint{
+				Reset: state.reset,
+				Allowed: func(ctx context.Context, tx ktx.DBRunner, i int) (bool, error) {
+					return state.get("doctors_on_call") >= 2, nil
+				},
+				Write: func(ctx context.Context, tx ktx.DBRunner, i int) error {
+					state.mu.Lock()
+					defer state.mu.Unlock()
+					state.values["doctors_on_call"]--
+					return nil
+				},
+				Holds: func(ctx context.Context, db ktx.DBRunner) (bool, error) {
+					return state.get("doctors_on_call") >= 1, nil
+				},
+			}),
+			PhantomRead(Predicate{
+				Reset: state.reset,
+				Count: func(ctx context.Context, db ktx.DBRunner) (int64, error) {
+					return state.get("orders"), nil
+				},
+				Insert: func(ctx context.Context, db ktx.DBRunner) error {
+					state.mu.Lock()
+					defer state.mu.Unlock()
+					state.values["orders"]++
+					return nil
+				},
+			}),
+		}
+		for _, w := range workloads {
+			report, err := Run(ctx, db, w, Options{Rounds: 5})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if report.Rounds != 5 || report.Anomalies != 5 || report.Commits != 10 || report.Failures != 0 {
+				t.Errorf("expected an anomaly on every round, got: %s", report)
+			}
+		}
+	})
+
+	t.Run("should not detect lost updates on serializable databases", func(t *testing.T) {
+		db := openDB(t, "file:"+filepath.Join(t.TempDir(), "anomaly.db")+"?_journal_mode=WAL")
+		_, err := db.Exec("CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER NOT NULL)")
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		report, err := Run(ctx, db, LostUpdate(Counter{
+			Reset: func(ctx context.Context, db ktx.DBRunner) error {
+				_, err := db.ExecContext(ctx, "INSERT OR REPLACE INTO counters (id, value) VALUES (1, 0)")
+				return err
+			},
+			Get: func(ctx context.Context, db ktx.DBRunner) (value int64, err error) {
+				rows, err := db.QueryContext(ctx, "SELECT value FROM counters WHERE id = 1")
+				if err != nil {
+					return 0, err
+				}
+				defer func() { _ = rows.Close() }()
+				rows.Next()
+				err = rows.Scan(&value)
+				return value, err
+			},
+			Set: func(ctx context.Context, db ktx.DBRunner, value int64) error {
+				_, err := db.ExecContext(ctx, "UPDATE counters SET value = ? WHERE id = 1", value)
+				return err
+			},
+		}), Options{Rounds: 5, Isolation: sql.LevelSerializable})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if report.Anomalies != 0 || report.Commits != 5 || report.Failures != 5 || len(report.Errors) != 5 {
+			t.Errorf("expected one of the increments to fail on every round, got: %s", report)
+		}
+	})
+}
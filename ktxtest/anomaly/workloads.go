@@ -0,0 +1,156 @@
+package anomaly
+
+import (
+	"context"
+
+	"github.com/vingarcia/ktx"
+)
+
+// Counter is a value that is incremented by reading it and then
+// writing it back, e.g. the balance of an account.
+type Counter struct {
+	// Reset sets the counter to 0.
+	Reset func(ctx context.Context, db ktx.DBRunner) error
+
+	Get func(ctx context.Context, db ktx.DBRunner) (int64, error)
+	Set func(ctx context.Context, db ktx.DBRunner, value int64) error
+}
+
+// LostUpdate builds a workload where two transactions increment the
+// counter, which produces a lost update when both read the same value
+// and one of the increments is overwritten by the other.
+func LostUpdate(c Counter) Workload {
+	increment := func() Tx {
+		var value int64
+		return Tx{
+			Read: func(ctx context.Context, tx *ktx.Tx) (err error) {
+				value, err = c.Get(ctx, tx)
+				return err
+			},
+			Write: func(ctx context.Context, tx *ktx.Tx) error {
+				return c.Set(ctx, tx, value+1)
+			},
+		}
+	}
+
+	return Workload{
+		Name:  "lost update",
+		Setup: c.Reset,
+		Txs:   []Tx{increment(), increment()},
+		Check: func(ctx context.Context, db ktx.DBRunner, committed []bool) (bool, error) {
+			value, err := c.Get(ctx, db)
+			if err != nil {
+				return false, err
+			}
+			return value != int64(countTrue(committed)), nil
+		},
+	}
+}
+
+// Constraint is an invariant that holds across rows, e.g. that at least
+// one doctor is on call, which each of the two transactions of WriteSkew
+// preserves on its own by checking it before writing.
+type Constraint struct {
+	// Reset prepares the rows before each round, e.g. with two doctors on call.
+	Reset func(ctx context.Context, db ktx.DBRunner) error
+
+	// Allowed reports whether the write of the transaction i,
+	// which is 0 or 1, keeps the invariant, e.g. if there are at
+	// least 2 doctors on call, given what the transaction sees.
+	Allowed func(ctx context.Context, tx ktx.DBRunner, i int) (bool, error)
+
+	// Write is the write of the transaction i, e.g. taking
+	// the doctor i off call, which only runs if it is Allowed.
+	Write func(ctx context.Context, tx ktx.DBRunner, i int) error
+
+	// Holds checks the invariant after the transactions finished.
+	Holds func(ctx context.Context, db ktx.DBRunner) (bool, error)
+}
+
+// WriteSkew builds a workload where two transactions check the constraint
+// and then write to different rows, which produces a write skew when
+// both see the state before the other write and break the invariant.
+func WriteSkew(c Constraint) Workload {
+	writer := func(i int) Tx {
+		var allowed bool
+		return Tx{
+			Read: func(ctx context.Context, tx *ktx.Tx) (err error) {
+				allowed, err = c.Allowed(ctx, tx, i)
+				return err
+			},
+			Write: func(ctx context.Context, tx *ktx.Tx) error {
+				if !allowed {
+					return nil
+				}
+				return c.Write(ctx, tx, i)
+			},
+		}
+	}
+
+	return Workload{
+		Name:  "write skew",
+		Setup: c.Reset,
+		Txs:   []Tx{writer(0), writer(1)},
+		Check: func(ctx context.Context, db ktx.DBRunner, committed []bool) (bool, error) {
+			holds, err := c.Holds(ctx, db)
+			return !holds, err
+		},
+	}
+}
+
+// Predicate is a set of rows selected by a condition, e.g. the orders
+// of a customer, to which new rows can be inserted.
+type Predicate struct {
+	// Reset prepares the rows before each round.
+	Reset func(ctx context.Context, db ktx.DBRunner) error
+
+	// Count returns how many rows match the condition.
+	Count func(ctx context.Context, db ktx.DBRunner) (int64, error)
+
+	// Insert inserts a row that matches the condition.
+	Insert func(ctx context.Context, db ktx.DBRunner) error
+}
+
+// PhantomRead builds a workload where a transaction inserts a row matching
+// the predicate and commits between the two times another transaction
+// counts the rows, which produces a phantom read when the counts differ.
+func PhantomRead(p Predicate) Workload {
+	var first, second int64
+	return Workload{
+		Name: "phantom read",
+		Setup: func(ctx context.Context, db ktx.DBRunner) error {
+			first, second = 0, 0
+			return p.Reset(ctx, db)
+		},
+		Txs: []Tx{
+			{
+				Write: func(ctx context.Context, tx *ktx.Tx) error {
+					return p.Insert(ctx, tx)
+				},
+			},
+			{
+				Read: func(ctx context.Context, tx *ktx.Tx) (err error) {
+					first, err = p.Count(ctx, tx)
+					return err
+				},
+				Write: func(ctx context.Context, tx *ktx.Tx) (err error) {
+					second, err = p.Count(ctx, tx)
+					return err
+				},
+			},
+		},
+		Check: func(ctx context.Context, db ktx.DBRunner, committed []bool) (bool, error) {
+			return committed[0] && committed[1] && first != second, nil
+		},
+	}
+}
+
+func countTrue(values []bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,640 @@
+package ktxtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FakeDB is an in-memory database for unit testing the orchestration of
+// transactions, e.g. which callbacks run or what is committed when a step
+// fails, without cgo, SQLite or containers.
+//
+// It embeds a *sql.DB so it can be passed to ktx.Run, but it only
+// understands two kinds of statements:
+//
+//	INSERT INTO table (col1, col2) VALUES (?, ?)
+//	SELECT col1, col2 FROM table WHERE col1 = ? AND col2 = 'value'
+//
+// The tables are created on the first insert and accept any columns.
+// SELECT also accepts `*` and `COUNT(*)`, and the values can be given
+// as `?` or `$1` placeholders or as string, integer or NULL literals.
+//
+// The rows inserted by a transaction are only visible to other
+// connections after it commits and are discarded on rollback,
+// but there is no locking between concurrent transactions.
+type FakeDB struct {
+	*sql.DB
+
+	store *fakeStore
+}
+
+// NewFakeDB returns an empty FakeDB.
+func NewFakeDB() *FakeDB {
+	store := &fakeStore{tables: map[string]*fakeTable{}}
+	return &FakeDB{
+		DB:    sql.OpenDB(fakeConnector{store: store}),
+		store: store,
+	}
+}
+
+// Rows returns the committed rows of a table, in insertion order.
+func (db *FakeDB) Rows(table string) []map[string]interface{} {
+	db.store.mu.Lock()
+	defer db.store.mu.Unlock()
+
+	t := db.store.tables[strings.ToLower(table)]
+	if t == nil {
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, 0, len(t.rows))
+	for _, row := range t.rows {
+		copied := make(map[string]interface{}, len(row))
+		for col, v := range row {
+			copied[col] = v
+		}
+		rows = append(rows, copied)
+	}
+	return rows
+}
+
+// Statements returns the statements executed so far, in order,
+// including BEGIN, COMMIT and ROLLBACK.
+func (db *FakeDB) Statements() []string {
+	db.store.mu.Lock()
+	defer db.store.mu.Unlock()
+
+	return append([]string{}, db.store.statements...)
+}
+
+// Commits returns how many transactions were committed.
+func (db *FakeDB) Commits() int {
+	return db.countStatements("COMMIT")
+}
+
+// Rollbacks returns how many transactions were rolled back.
+func (db *FakeDB) Rollbacks() int {
+	return db.countStatements("ROLLBACK")
+}
+
+func (db *FakeDB) countStatements(stmt string) int {
+	db.store.mu.Lock()
+	defer db.store.mu.Unlock()
+
+	count := 0
+	for _, s := range db.store.statements {
+		if s == stmt {
+			count++
+		}
+	}
+	return count
+}
+
+type fakeStore struct {
+	mu         sync.Mutex
+	tables     map[string]*fakeTable
+	statements []string
+}
+
+type fakeTable struct {
+	columns []string
+	rows    []map[string]driver.Value
+}
+
+// insert must be called with the lock of the store held
+// if the table belongs to the store.
+func (t *fakeTable) insert(row map[string]driver.Value, columns []string) {
+	for _, col := range columns {
+		if !containsString(t.columns, col) {
+			t.columns = append(t.columns, col)
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+func (s *fakeStore) record(stmt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statements = append(s.statements, stmt)
+}
+
+type fakeConnector struct {
+	store *fakeStore
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{store: c.store}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("ktxtest: the fake driver can only be used through NewFakeDB")
+}
+
+type fakeConn struct {
+	store *fakeStore
+
+	// pending holds the rows inserted by the open
+	// transaction of the connection, if any:
+	pending map[string]*fakeTable
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.pending != nil {
+		return nil, errors.New("ktxtest: the connection already has an open transaction")
+	}
+
+	c.store.record("BEGIN")
+	c.pending = map[string]*fakeTable{}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.store.record("COMMIT")
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	for name, pending := range c.pending {
+		t := c.store.tables[name]
+		if t == nil {
+			t = &fakeTable{}
+			c.store.tables[name] = t
+		}
+		for _, row := range pending.rows {
+			t.insert(row, pending.columns)
+		}
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.store.record("ROLLBACK")
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.store.record(query)
+
+	stmt, err := parseFakeStatement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.insert == nil {
+		return nil, fmt.Errorf("ktxtest: ExecContext only supports INSERT statements, got: %s", query)
+	}
+
+	if c.pending == nil {
+		c.store.mu.Lock()
+		defer c.store.mu.Unlock()
+
+		t := c.store.tables[stmt.table]
+		if t == nil {
+			t = &fakeTable{}
+			c.store.tables[stmt.table] = t
+		}
+		t.insert(stmt.insert, stmt.columns)
+		return driver.RowsAffected(1), nil
+	}
+
+	t := c.pending[stmt.table]
+	if t == nil {
+		t = &fakeTable{}
+		c.pending[stmt.table] = t
+	}
+	t.insert(stmt.insert, stmt.columns)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.store.record(query)
+
+	stmt, err := parseFakeStatement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.insert != nil {
+		return nil, fmt.Errorf("ktxtest: QueryContext only supports SELECT statements, got: %s", query)
+	}
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	var columns []string
+	var matches []map[string]driver.Value
+	for _, t := range []*fakeTable{c.store.tables[stmt.table], c.pending[stmt.table]} {
+		if t == nil {
+			continue
+		}
+		for _, col := range t.columns {
+			if !containsString(columns, col) {
+				columns = append(columns, col)
+			}
+		}
+		for _, row := range t.rows {
+			if stmt.matches(row) {
+				matches = append(matches, row)
+			}
+		}
+	}
+
+	if stmt.count {
+		return &fakeRows{
+			columns: []string{"count"},
+			values:  [][]driver.Value{{int64(len(matches))}},
+		}, nil
+	}
+
+	if len(stmt.columns) > 0 {
+		columns = stmt.columns
+	}
+	rows := &fakeRows{columns: columns}
+	for _, match := range matches {
+		values := make([]driver.Value, len(columns))
+		for i, col := range columns {
+			values[i] = match[col]
+		}
+		rows.values = append(rows.values, values)
+	}
+	return rows, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// fakeStatement is a parsed INSERT or SELECT statement.
+type fakeStatement struct {
+	table string
+
+	// columns are the inserted or the selected columns,
+	// empty for `SELECT *`:
+	columns []string
+
+	// insert is the inserted row, nil for SELECT statements:
+	insert map[string]driver.Value
+
+	count bool
+	where map[string]driver.Value
+}
+
+func (s fakeStatement) matches(row map[string]driver.Value) bool {
+	for col, v := range s.where {
+		if !equalValues(row[col], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFakeStatement(query string, args []driver.NamedValue) (fakeStatement, error) {
+	p := &fakeParser{tokens: fakeTokenize(query), args: args}
+
+	var stmt fakeStatement
+	var err error
+	switch strings.ToUpper(p.peek()) {
+	case "INSERT":
+		stmt, err = p.parseInsert()
+	case "SELECT":
+		stmt, err = p.parseSelect()
+	default:
+		err = errors.New("only INSERT and SELECT statements are supported")
+	}
+	if err == nil && p.peek() == ";" {
+		p.next()
+	}
+	if err == nil && p.peek() != "" {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return fakeStatement{}, fmt.Errorf("ktxtest: unable to parse statement %q: %w", query, err)
+	}
+	return stmt, nil
+}
+
+type fakeParser struct {
+	tokens []string
+	args   []driver.NamedValue
+
+	// nextArg is the index of the arg used by the next `?`:
+	nextArg int
+}
+
+func (p *fakeParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *fakeParser) next() string {
+	token := p.peek()
+	if len(p.tokens) > 0 {
+		p.tokens = p.tokens[1:]
+	}
+	return token
+}
+
+func (p *fakeParser) expect(tokens ...string) error {
+	for _, expected := range tokens {
+		token := p.next()
+		if !strings.EqualFold(token, expected) {
+			return fmt.Errorf("expected %q but got %q", expected, token)
+		}
+	}
+	return nil
+}
+
+func (p *fakeParser) identifier() (string, error) {
+	token := p.next()
+	if token == "" || !isIdentifierStart(rune(token[0])) {
+		return "", fmt.Errorf("expected an identifier but got %q", token)
+	}
+	return strings.ToLower(token), nil
+}
+
+// identifiers parses a comma separated list of identifiers.
+func (p *fakeParser) identifiers() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+
+		if p.peek() != "," {
+			return names, nil
+		}
+		p.next()
+	}
+}
+
+func (p *fakeParser) value() (driver.Value, error) {
+	token := p.next()
+	switch {
+	case token == "?":
+		if p.nextArg >= len(p.args) {
+			return nil, errors.New("not enough arguments for the placeholders")
+		}
+		p.nextArg++
+		return p.args[p.nextArg-1].Value, nil
+	case strings.HasPrefix(token, "$"):
+		i, err := strconv.Atoi(token[1:])
+		if err != nil || i < 1 || i > len(p.args) {
+			return nil, fmt.Errorf("invalid placeholder %q", token)
+		}
+		return p.args[i-1].Value, nil
+	case len(token) >= 2 && strings.HasPrefix(token, "'") && strings.HasSuffix(token, "'"):
+		return strings.ReplaceAll(token[1:len(token)-1], "''", "'"), nil
+	case strings.EqualFold(token, "NULL"):
+		return nil, nil
+	}
+
+	i, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected a value but got %q", token)
+	}
+	return i, nil
+}
+
+func (p *fakeParser) parseInsert() (fakeStatement, error) {
+	err := p.expect("INSERT", "INTO")
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	table, err := p.identifier()
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	err = p.expect("(")
+	if err != nil {
+		return fakeStatement{}, err
+	}
+	columns, err := p.identifiers()
+	if err != nil {
+		return fakeStatement{}, err
+	}
+	err = p.expect(")", "VALUES", "(")
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	row := map[string]driver.Value{}
+	for i, col := range columns {
+		if i > 0 {
+			err = p.expect(",")
+			if err != nil {
+				return fakeStatement{}, err
+			}
+		}
+		row[col], err = p.value()
+		if err != nil {
+			return fakeStatement{}, err
+		}
+	}
+
+	return fakeStatement{table: table, columns: columns, insert: row}, p.expect(")")
+}
+
+func (p *fakeParser) parseSelect() (fakeStatement, error) {
+	err := p.expect("SELECT")
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	var stmt fakeStatement
+	switch {
+	case p.peek() == "*":
+		p.next()
+	case strings.EqualFold(p.peek(), "COUNT"):
+		err = p.expect("COUNT", "(", "*", ")")
+		stmt.count = true
+	default:
+		stmt.columns, err = p.identifiers()
+	}
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	err = p.expect("FROM")
+	if err != nil {
+		return fakeStatement{}, err
+	}
+	stmt.table, err = p.identifier()
+	if err != nil {
+		return fakeStatement{}, err
+	}
+
+	if !strings.EqualFold(p.peek(), "WHERE") {
+		return stmt, nil
+	}
+	p.next()
+
+	stmt.where = map[string]driver.Value{}
+	for {
+		col, err := p.identifier()
+		if err != nil {
+			return fakeStatement{}, err
+		}
+		err = p.expect("=")
+		if err != nil {
+			return fakeStatement{}, err
+		}
+		stmt.where[col], err = p.value()
+		if err != nil {
+			return fakeStatement{}, err
+		}
+
+		if !strings.EqualFold(p.peek(), "AND") {
+			return stmt, nil
+		}
+		p.next()
+	}
+}
+
+// fakeTokenize splits a statement into identifiers, numbers,
+// placeholders, quoted strings and single character symbols.
+func fakeTokenize(query string) []string {
+	var tokens []string
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '\'':
+			i++
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case r == '$' || r == '-' || unicode.IsDigit(r):
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+		case isIdentifierStart(r):
+			for i < len(runes) && (isIdentifierStart(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, string(runes[start:min(i, len(runes))]))
+	}
+	return tokens
+}
+
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func equalValues(a, b driver.Value) bool {
+	if a == nil || b == nil {
+		// NULL is not equal to anything in SQL:
+		return false
+	}
+	if bytes, ok := a.([]byte); ok {
+		a = string(bytes)
+	}
+	if bytes, ok := b.([]byte); ok {
+		b = string(bytes)
+	}
+	return a == b
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package ktxtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vingarcia/ktx"
+)
+
+func TestFakeDB(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should commit the inserted rows", func(t *testing.T) {
+		db := NewFakeDB()
+		defer func() { _ = db.Close() }()
+
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (id, name) VALUES (?, ?)", 1, "John")
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (id, name) VALUES ($1, 'Jane O''Neil')", 2)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		rows := db.Rows("users")
+		if len(rows) != 2 || rows[0]["name"] != "John" || rows[1]["name"] != "Jane O'Neil" {
+			t.Errorf("unexpected rows: %v", rows)
+		}
+		if db.Commits() != 1 || db.Rollbacks() != 0 {
+			t.Errorf("expected 1 commit and no rollbacks, got: %v", db.Statements())
+		}
+	})
+
+	t.Run("should discard the inserted rows on rollback", func(t *testing.T) {
+		db := NewFakeDB()
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (id) VALUES (1)")
+			if err != nil {
+				return err
+			}
+
+			// The pending rows are visible inside the transaction:
+			count, err := countUsers(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if count != 1 {
+				t.Errorf("expected 1 pending row, got %d", count)
+			}
+
+			// But not outside of it:
+			count, err = countUsers(ctx, db)
+			if err != nil {
+				return err
+			}
+			if count != 0 {
+				t.Errorf("expected the pending row to be invisible outside of the transaction, got %d", count)
+			}
+
+			return fakeErr
+		})
+		if err != fakeErr {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+
+		if rows := db.Rows("users"); len(rows) != 0 {
+			t.Errorf("expected no rows, got: %v", rows)
+		}
+		if db.Commits() != 0 || db.Rollbacks() != 1 {
+			t.Errorf("expected 1 rollback and no commits, got: %v", db.Statements())
+		}
+	})
+
+	t.Run("should select rows by equality", func(t *testing.T) {
+		db := NewFakeDB()
+		defer func() { _ = db.Close() }()
+
+		for _, user := range []struct {
+			id   int
+			name string
+			team string
+		}{
+			{1, "John", "a"},
+			{2, "Jane", "b"},
+			{3, "Mary", "a"},
+		} {
+			_, err := db.ExecContext(ctx, "INSERT INTO users (id, name, team) VALUES (?, ?, ?)", user.id, user.name, user.team)
+			if err != nil {
+				t.Fatalf("failed to insert user: %v", err)
+			}
+		}
+
+		rows, err := db.QueryContext(ctx, "SELECT id, name FROM users WHERE team = ? AND id = 3", "a")
+		if err != nil {
+			t.Fatalf("failed to query users: %v", err)
+		}
+		defer func() { _ = rows.Close() }()
+
+		var names []string
+		for rows.Next() {
+			var id int
+			var name string
+			err := rows.Scan(&id, &name)
+			if err != nil {
+				t.Fatalf("failed to scan user: %v", err)
+			}
+			names = append(names, name)
+		}
+		if rows.Err() != nil {
+			t.Fatalf("failed to iterate users: %v", rows.Err())
+		}
+		if len(names) != 1 || names[0] != "Mary" {
+			t.Errorf("expected only Mary, got: %v", names)
+		}
+
+		var count int
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE team = 'a'").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 users, got %d", count)
+		}
+	})
+
+	t.Run("should reject unsupported statements", func(t *testing.T) {
+		db := NewFakeDB()
+		defer func() { _ = db.Close() }()
+
+		for _, query := range []string{
+			"UPDATE users SET name = 'John'",
+			"SELECT id FROM users WHERE id > 1",
+			"INSERT INTO users (id, name) VALUES (?)",
+		} {
+			_, err := db.ExecContext(ctx, query, 1)
+			if err == nil {
+				t.Errorf("expected an error for %q", query)
+			}
+		}
+	})
+}
+
+func countUsers(ctx context.Context, db ktx.DBRunner) (count int, err error) {
+	rows, err := db.QueryContext(ctx, "SELECT COUNT(*) FROM users")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	rows.Next()
+	err = rows.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, rows.Close()
+}
@@ -0,0 +1,173 @@
+// Package ktxtest contains helpers for testing and benchmarking
+// the transactional code paths of an application.
+package ktxtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vingarcia/ktx"
+)
+
+// LoadOptions configures Load.
+type LoadOptions struct {
+	// Concurrency is how many transactions run at the same time,
+	// defaults to 10.
+	Concurrency int
+
+	// Rate limits how many transactions start per second across all the
+	// workers, by default they start as soon as a worker is free.
+	Rate float64
+
+	// Duration is how long the load runs, defaults to 10 seconds.
+	Duration time.Duration
+
+	// Transactions stops the load after that many transactions,
+	// even if the Duration didn't elapse.
+	Transactions int
+
+	// RunOptions are passed to ktx.Run for each transaction,
+	// e.g. ktx.WithRetry with ktx.WithIdempotent.
+	RunOptions []ktx.Option
+}
+
+// LoadReport summarizes the transactions run by Load.
+type LoadReport struct {
+	// Transactions counts the transactions that finished,
+	// which are either committed or failed.
+	Transactions int
+	Commits      int
+	Failures     int
+
+	// Duration is how long the load ran and Throughput
+	// is the number of transactions per second.
+	Duration   time.Duration
+	Throughput float64
+
+	// The latency percentiles of the transactions,
+	// including the time spent on retries.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+
+	// Retries counts the attempts beyond the first one of each
+	// transaction and RetryRate is the average per transaction.
+	Retries   int
+	RetryRate float64
+
+	// Errors counts the failures by their error messages.
+	Errors map[string]int
+}
+
+// String returns the report in a human-readable format.
+func (r LoadReport) String() string {
+	return fmt.Sprintf(
+		"%d transactions in %s (%.1f/s): %d commits, %d failures, %.2f retries per transaction, p50 %s, p90 %s, p99 %s, max %s",
+		r.Transactions, r.Duration.Round(time.Millisecond), r.Throughput, r.Commits, r.Failures, r.RetryRate,
+		r.P50, r.P90, r.P99, r.Max,
+	)
+}
+
+// Load runs fn on concurrent transactions against db for capacity planning,
+// reporting their latency percentiles, retry rate and errors:
+//
+//	report := ktxtest.Load(ctx, db, transfer, ktxtest.LoadOptions{
+//		Concurrency: 50,
+//		Duration:    time.Minute,
+//		RunOptions:  []ktx.Option{ktx.WithRetry(policy), ktx.WithIdempotent()},
+//	})
+//	fmt.Println(report)
+//
+// It stops when the Duration elapses, after the number of Transactions
+// is reached or when ctx is done, waiting for the running transactions.
+func Load(ctx context.Context, db ktx.TxBeginner, fn func(tx *ktx.Tx) error, opts LoadOptions) LoadReport {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = 10 * time.Second
+	}
+
+	// The transactions run on ctx so the ones running
+	// when the Duration elapses can finish normally:
+	stop, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var tokens <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	var started int64
+	var mu sync.Mutex
+	var latencies []time.Duration
+	report := LoadReport{Errors: map[string]int{}}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-stop.Done():
+						return
+					}
+				}
+				if stop.Err() != nil {
+					return
+				}
+				if opts.Transactions > 0 && atomic.AddInt64(&started, 1) > int64(opts.Transactions) {
+					return
+				}
+
+				txStart := time.Now()
+				result, err := ktx.RunWithResult(ctx, db, fn, opts.RunOptions...)
+				latency := time.Since(txStart)
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				report.Transactions++
+				report.Retries += max(result.Attempts-1, 0)
+				if err != nil {
+					report.Failures++
+					report.Errors[err.Error()]++
+				} else {
+					report.Commits++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	if report.Transactions > 0 {
+		report.Throughput = float64(report.Transactions) / report.Duration.Seconds()
+		report.RetryRate = float64(report.Retries) / float64(report.Transactions)
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.P50 = percentile(latencies, 0.50)
+		report.P90 = percentile(latencies, 0.90)
+		report.P99 = percentile(latencies, 0.99)
+		report.Max = latencies[len(latencies)-1]
+	}
+
+	return report
+}
+
+// percentile returns the p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(idx, 0), len(sorted)-1)]
+}
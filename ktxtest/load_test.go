@@ -0,0 +1,79 @@
+package ktxtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vingarcia/ktx"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	t.Run("should report the transactions, retries and errors", func(t *testing.T) {
+		errTransient := errors.New("transient error")
+		errPermanent := errors.New("permanent error")
+
+		var calls int64
+		report := Load(ctx, db, func(tx *ktx.Tx) error {
+			time.Sleep(time.Millisecond)
+			switch atomic.AddInt64(&calls, 1) % 4 {
+			case 1:
+				return errTransient
+			case 2:
+				return errPermanent
+			}
+			return nil
+		}, LoadOptions{
+			Concurrency:  4,
+			Transactions: 20,
+			RunOptions: []ktx.Option{ktx.WithIdempotent(), ktx.WithRetry(ktx.RetryPolicy{
+				MaxAttempts: 2,
+				Backoff:     func(attempt int) time.Duration { return 0 },
+				ShouldRetry: func(err error) bool { return err == errTransient },
+			})},
+		})
+
+		if report.Transactions != 20 || report.Commits+report.Failures != 20 {
+			t.Fatalf("expected 20 transactions, got: %s", report)
+		}
+		if report.Retries == 0 || report.RetryRate <= 0 {
+			t.Errorf("expected the transient errors to be retried, got: %s", report)
+		}
+		if report.Failures == 0 || report.Errors[errPermanent.Error()] == 0 {
+			t.Errorf("expected the permanent errors to be reported, got: %v", report.Errors)
+		}
+		if report.P50 < time.Millisecond || report.P50 > report.P90 || report.P90 > report.P99 || report.P99 > report.Max {
+			t.Errorf("unexpected latency percentiles: %s", report)
+		}
+	})
+
+	t.Run("should limit the rate and the duration", func(t *testing.T) {
+		report := Load(ctx, db, func(tx *ktx.Tx) error {
+			return nil
+		}, LoadOptions{
+			Concurrency: 4,
+			Rate:        100,
+			Duration:    200 * time.Millisecond,
+		})
+
+		if report.Transactions < 5 || report.Transactions > 25 {
+			t.Errorf("expected about 20 transactions at 100/s for 200ms, got: %s", report)
+		}
+		if report.Failures != 0 {
+			t.Errorf("expected no failures, got: %v", report.Errors)
+		}
+	})
+}
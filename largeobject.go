@@ -0,0 +1,169 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LargeObjectMode is the mode in which a Postgres large object is opened.
+type LargeObjectMode int32
+
+// The modes for opening large objects, they can be combined with `|`.
+const (
+	LargeObjectRead  LargeObjectMode = 0x40000
+	LargeObjectWrite LargeObjectMode = 0x20000
+)
+
+// LargeObject is a Postgres large object opened inside a transaction.
+//
+// It implements io.Reader, io.Writer, io.Seeker and io.Closer over the
+// lo_* functions of Postgres, so it can be used with io.Copy for
+// streaming large contents without loading them in memory.
+//
+// Since these interfaces don't receive a context, all operations use the
+// context passed to OpenLargeObject. The object is only valid until the
+// transaction finishes and must not be used concurrently.
+type LargeObject struct {
+	ctx context.Context
+	db  DBRunner
+	fd  int32
+}
+
+// CreateLargeObject creates an empty large object and returns its OID.
+//
+// As all large object functions it must run inside a transaction
+// managed by ktx, so the object is discarded if the transaction rolls back.
+func CreateLargeObject(ctx context.Context, db DBRunner) (oid uint32, err error) {
+	_, err = TxFromRunner(db)
+	if err != nil {
+		return 0, err
+	}
+
+	err = queryValue(ctx, db, &oid, "SELECT lo_create(0)")
+	if err != nil {
+		return 0, fmt.Errorf("error creating large object: %w", err)
+	}
+	return oid, nil
+}
+
+// OpenLargeObject opens the large object with the input OID.
+func OpenLargeObject(ctx context.Context, db DBRunner, oid uint32, mode LargeObjectMode) (*LargeObject, error) {
+	_, err := TxFromRunner(db)
+	if err != nil {
+		return nil, err
+	}
+
+	lo := &LargeObject{
+		ctx: ctx,
+		db:  db,
+	}
+	err = queryValue(ctx, db, &lo.fd, "SELECT lo_open($1, $2)", oid, int32(mode))
+	if err != nil {
+		return nil, fmt.Errorf("error opening large object %d: %w", oid, err)
+	}
+	return lo, nil
+}
+
+// UnlinkLargeObject deletes the large object with the input OID.
+func UnlinkLargeObject(ctx context.Context, db DBRunner, oid uint32) error {
+	_, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+
+	var result int32
+	err = queryValue(ctx, db, &result, "SELECT lo_unlink($1)", oid)
+	if err != nil {
+		return fmt.Errorf("error deleting large object %d: %w", oid, err)
+	}
+	return nil
+}
+
+// Read reads up to len(p) bytes from the current position of the object,
+// returning io.EOF once its end is reached.
+func (lo *LargeObject) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	var data []byte
+	err := queryValue(lo.ctx, lo.db, &data, "SELECT loread($1, $2)", lo.fd, len(p))
+	if err != nil {
+		return 0, fmt.Errorf("error reading large object: %w", err)
+	}
+	if len(data) == 0 {
+		return 0, io.EOF
+	}
+
+	return copy(p, data), nil
+}
+
+// Write writes p at the current position of the object.
+func (lo *LargeObject) Write(p []byte) (int, error) {
+	var n int
+	err := queryValue(lo.ctx, lo.db, &n, "SELECT lowrite($1, $2)", lo.fd, p)
+	if err != nil {
+		return 0, fmt.Errorf("error writing large object: %w", err)
+	}
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Seek sets the position of the next Read or Write,
+// whence follows the constants of the io package.
+func (lo *LargeObject) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	err := queryValue(lo.ctx, lo.db, &pos, "SELECT lo_lseek64($1, $2, $3)", lo.fd, offset, whence)
+	if err != nil {
+		return 0, fmt.Errorf("error seeking large object: %w", err)
+	}
+	return pos, nil
+}
+
+// Truncate changes the size of the object, filling it with zeros if it grows.
+func (lo *LargeObject) Truncate(size int64) error {
+	var result int32
+	err := queryValue(lo.ctx, lo.db, &result, "SELECT lo_truncate64($1, $2)", lo.fd, size)
+	if err != nil {
+		return fmt.Errorf("error truncating large object: %w", err)
+	}
+	return nil
+}
+
+// Close closes the object, which is also done by Postgres
+// when the transaction finishes.
+func (lo *LargeObject) Close() error {
+	var result int32
+	err := queryValue(lo.ctx, lo.db, &result, "SELECT lo_close($1)", lo.fd)
+	if err != nil {
+		return fmt.Errorf("error closing large object: %w", err)
+	}
+	return nil
+}
+
+// queryValue scans the single value returned by the query into dest.
+func queryValue(ctx context.Context, db Queryer, dest interface{}, query string, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return errors.New("no rows returned")
+	}
+
+	err = rows.Scan(dest)
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
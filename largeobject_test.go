@@ -0,0 +1,253 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// fakeLargeObjects implements the lo_* functions of Postgres
+// as SQLite functions so LargeObject can be tested on SQLite.
+type fakeLargeObjects struct {
+	mu      sync.Mutex
+	objects map[int64][]byte
+	fds     map[int64]*fakeFD
+	nextID  int64
+}
+
+type fakeFD struct {
+	oid int64
+	pos int64
+}
+
+var fakeLOs = &fakeLargeObjects{}
+
+func init() {
+	sql.Register("sqlite3_lo", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			funcs := map[string]interface{}{
+				"lo_create":     fakeLOs.create,
+				"lo_open":       fakeLOs.open,
+				"loread":        fakeLOs.read,
+				"lowrite":       fakeLOs.write,
+				"lo_lseek64":    fakeLOs.seek,
+				"lo_truncate64": fakeLOs.truncate,
+				"lo_close":      fakeLOs.close,
+				"lo_unlink":     fakeLOs.unlink,
+			}
+			for name, fn := range funcs {
+				err := conn.RegisterFunc(name, fn, false)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+func (f *fakeLargeObjects) create(int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	f.objects[f.nextID] = []byte{}
+	return f.nextID
+}
+
+func (f *fakeLargeObjects) open(oid int64, mode int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[oid]; !ok {
+		return 0, errors.New("large object does not exist")
+	}
+	f.nextID++
+	f.fds[f.nextID] = &fakeFD{oid: oid}
+	return f.nextID, nil
+}
+
+func (f *fakeLargeObjects) read(fd int64, n int64) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.fds[fd]
+	data := f.objects[d.oid]
+	end := min(d.pos+n, int64(len(data)))
+	if d.pos >= end {
+		return []byte{}
+	}
+	chunk := append([]byte{}, data[d.pos:end]...)
+	d.pos = end
+	return chunk
+}
+
+func (f *fakeLargeObjects) write(fd int64, p []byte) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.fds[fd]
+	data := f.objects[d.oid]
+	for int64(len(data)) < d.pos+int64(len(p)) {
+		data = append(data, 0)
+	}
+	copy(data[d.pos:], p)
+	f.objects[d.oid] = data
+	d.pos += int64(len(p))
+	return int64(len(p))
+}
+
+func (f *fakeLargeObjects) seek(fd int64, offset int64, whence int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.fds[fd]
+	switch whence {
+	case io.SeekStart:
+		d.pos = offset
+	case io.SeekCurrent:
+		d.pos += offset
+	case io.SeekEnd:
+		d.pos = int64(len(f.objects[d.oid])) + offset
+	}
+	return d.pos
+}
+
+func (f *fakeLargeObjects) truncate(fd int64, size int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.fds[fd]
+	data := f.objects[d.oid]
+	for int64(len(data)) < size {
+		data = append(data, 0)
+	}
+	f.objects[d.oid] = data[:size]
+	return 0
+}
+
+func (f *fakeLargeObjects) close(fd int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.fds, fd)
+	return 0
+}
+
+func (f *fakeLargeObjects) unlink(oid int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, oid)
+	return 1
+}
+
+func TestLargeObject(t *testing.T) {
+	fakeLOs.objects = map[int64][]byte{}
+	fakeLOs.fds = map[int64]*fakeFD{}
+
+	db, err := sql.Open("sqlite3_lo", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("large object "), 1000)
+
+	t.Run("should stream contents in and out of the object", func(t *testing.T) {
+		var read []byte
+		err := Run(ctx, db, func(tx *Tx) error {
+			oid, err := CreateLargeObject(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			lo, err := OpenLargeObject(ctx, tx, oid, LargeObjectRead|LargeObjectWrite)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = lo.Close() }()
+
+			_, err = io.Copy(lo, bytes.NewReader(content))
+			if err != nil {
+				return err
+			}
+
+			_, err = lo.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+
+			read, err = io.ReadAll(lo)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if !bytes.Equal(read, content) {
+			t.Errorf("expected to read back %d bytes, got %d", len(content), len(read))
+		}
+	})
+
+	t.Run("should truncate and unlink objects", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			oid, err := CreateLargeObject(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			lo, err := OpenLargeObject(ctx, tx, oid, LargeObjectWrite)
+			if err != nil {
+				return err
+			}
+
+			_, err = lo.Write(content)
+			if err != nil {
+				return err
+			}
+
+			err = lo.Truncate(5)
+			if err != nil {
+				return err
+			}
+
+			size, err := lo.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+			if size != 5 {
+				t.Errorf("expected size 5 after truncating, got %d", size)
+			}
+
+			err = lo.Close()
+			if err != nil {
+				return err
+			}
+
+			err = UnlinkLargeObject(ctx, tx, oid)
+			if err != nil {
+				return err
+			}
+
+			_, err = OpenLargeObject(ctx, tx, oid, LargeObjectRead)
+			if err == nil {
+				t.Errorf("expected an error opening an unlinked object")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("should require a transaction", func(t *testing.T) {
+		_, err := CreateLargeObject(ctx, db)
+		if !errors.Is(err, ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+
+		_, err = OpenLargeObject(ctx, db, 1, LargeObjectRead)
+		if !errors.Is(err, ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package ktx
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Leak describes a transaction suspected of being leaked,
+// reported by the OnLeak hook.
+type Leak struct {
+	// OpenFor is how long the transaction had been open
+	// when it was reported.
+	OpenFor time.Duration
+
+	// Threshold is the duration that was exceeded.
+	Threshold time.Duration
+
+	// Stack is the stack trace of the goroutine that started
+	// the transaction, only set with LeakDetectorOptions.CaptureStack.
+	Stack []byte
+}
+
+// LeakDetectorOptions configures a LeakDetector.
+type LeakDetectorOptions struct {
+	// Multiplier of the p99 duration of the recent transactions
+	// above which a transaction is reported, defaults to 10.
+	Multiplier float64
+
+	// MinThreshold is the minimum duration for reporting a transaction,
+	// which also applies while there are too few transactions for
+	// computing the p99, defaults to 1 second.
+	MinThreshold time.Duration
+
+	// CaptureStack makes the detector capture the stack trace of the
+	// goroutine that starts each transaction, which makes it easy to find
+	// the leaking code path at the cost of some overhead on every begin.
+	CaptureStack bool
+}
+
+// The number of recent durations used for computing the p99
+// and how many of them are needed before it is used.
+const (
+	leakWindow     = 1000
+	leakMinSamples = 100
+)
+
+// LeakDetector reports, through the OnLeak hook, the transactions that
+// stay open much longer than the others, which helps finding callbacks
+// that block forever or never return.
+//
+// A single LeakDetector should be shared by all the transactions it
+// watches, since the threshold for each transaction is computed from
+// the durations of the previous ones:
+//
+//	detector := ktx.NewLeakDetector(ktx.LeakDetectorOptions{CaptureStack: true})
+//
+//	err := ktx.Run(ctx, db, fn, detector.Option(), ktx.WithHooks(ktx.Hooks{
+//		OnLeak: func(ctx context.Context, tx *ktx.Tx, leak ktx.Leak) {
+//			log.Printf("transaction %s open for %s, started at:\n%s", tx.Name(), leak.OpenFor, leak.Stack)
+//		},
+//	}))
+//
+// Each transaction is reported at most once.
+type LeakDetector struct {
+	opts LeakDetectorOptions
+
+	mu        sync.Mutex
+	active    map[*Tx]leakEntry
+	durations []time.Duration
+	next      int
+	recorded  int
+	threshold time.Duration
+}
+
+type leakEntry struct {
+	start time.Time
+	timer *time.Timer
+}
+
+// NewLeakDetector creates a LeakDetector.
+func NewLeakDetector(opts LeakDetectorOptions) *LeakDetector {
+	if opts.Multiplier <= 0 {
+		opts.Multiplier = 10
+	}
+	if opts.MinThreshold <= 0 {
+		opts.MinThreshold = time.Second
+	}
+
+	return &LeakDetector{
+		opts:      opts,
+		active:    map[*Tx]leakEntry{},
+		durations: make([]time.Duration, leakWindow),
+		threshold: opts.MinThreshold,
+	}
+}
+
+// Option returns the Option that makes the detector watch a transaction.
+func (d *LeakDetector) Option() Option {
+	return WithHooks(Hooks{
+		OnBegin: d.begin,
+		OnCommit: func(ctx context.Context, tx *Tx) {
+			d.finish(tx)
+		},
+		OnRollback: func(ctx context.Context, tx *Tx, err error) {
+			d.finish(tx)
+		},
+		OnCommitAmbiguous: func(ctx context.Context, tx *Tx, err error) {
+			d.finish(tx)
+		},
+	})
+}
+
+func (d *LeakDetector) begin(ctx context.Context, tx *Tx) {
+	var stack []byte
+	if d.opts.CaptureStack {
+		stack = debug.Stack()
+	}
+
+	start := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	threshold := d.threshold
+	timer := time.AfterFunc(threshold, func() {
+		d.mu.Lock()
+		_, active := d.active[tx]
+		d.mu.Unlock()
+		if !active {
+			return
+		}
+
+		tx.onLeak(ctx, Leak{
+			OpenFor:   time.Since(start),
+			Threshold: threshold,
+			Stack:     stack,
+		})
+	})
+	d.active[tx] = leakEntry{
+		start: start,
+		timer: timer,
+	}
+}
+
+func (d *LeakDetector) finish(tx *Tx) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.active[tx]
+	if !ok {
+		return
+	}
+	entry.timer.Stop()
+	delete(d.active, tx)
+
+	d.durations[d.next] = time.Since(entry.start)
+	d.next = (d.next + 1) % leakWindow
+	d.recorded++
+
+	// The threshold is recomputed periodically instead of on every
+	// transaction to keep the sorting out of the common path:
+	if d.recorded >= leakMinSamples && d.recorded%leakMinSamples == 0 {
+		d.threshold = d.computeThreshold()
+	}
+}
+
+// computeThreshold must be called with d.mu locked.
+func (d *LeakDetector) computeThreshold() time.Duration {
+	n := min(d.recorded, leakWindow)
+	sorted := append([]time.Duration(nil), d.durations[:n]...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	p99 := sorted[(n*99)/100]
+	return max(time.Duration(float64(p99)*d.opts.Multiplier), d.opts.MinThreshold)
+}
@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeakDetector(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should report transactions open for longer than the threshold", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		detector := NewLeakDetector(LeakDetectorOptions{
+			MinThreshold: 20 * time.Millisecond,
+			CaptureStack: true,
+		})
+
+		var mu sync.Mutex
+		var leaks []Leak
+		hooks := WithHooks(Hooks{
+			OnLeak: func(ctx context.Context, tx *Tx, leak Leak) {
+				mu.Lock()
+				defer mu.Unlock()
+				leaks = append(leaks, leak)
+			},
+		})
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, detector.Option(), hooks)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		err = Run(ctx, db, func(tx *Tx) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}, detector.Option(), hooks)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(leaks) != 1 {
+			t.Fatalf("expected 1 leak to be reported, got %d", len(leaks))
+		}
+		if leaks[0].Threshold != 20*time.Millisecond || leaks[0].OpenFor < leaks[0].Threshold {
+			t.Errorf("unexpected leak durations: %+v", leaks[0])
+		}
+		if !bytes.Contains(leaks[0].Stack, []byte("TestLeakDetector")) {
+			t.Errorf("expected the stack to include the caller of Run, got:\n%s", leaks[0].Stack)
+		}
+	})
+
+	t.Run("should compute the threshold from the p99", func(t *testing.T) {
+		detector := NewLeakDetector(LeakDetectorOptions{
+			Multiplier:   2,
+			MinThreshold: time.Millisecond,
+		})
+
+		for i := 0; i < leakMinSamples; i++ {
+			detector.durations[i] = time.Duration(i+1) * time.Millisecond
+		}
+		detector.recorded = leakMinSamples
+
+		if threshold := detector.computeThreshold(); threshold != 200*time.Millisecond {
+			t.Errorf("expected a threshold of 200ms, got %s", threshold)
+		}
+
+		detector.opts.MinThreshold = time.Second
+		if threshold := detector.computeThreshold(); threshold != time.Second {
+			t.Errorf("expected the threshold to be at least MinThreshold, got %s", threshold)
+		}
+	})
+}
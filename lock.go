@@ -0,0 +1,102 @@
+package ktx
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// RowKey identifies a row to be locked by LockInOrder by
+// the value of a unique column of its table.
+type RowKey struct {
+	Table  string
+	Column string
+	Value  interface{}
+}
+
+// LockInOrder locks the rows identified by keys for the rest of the
+// transaction, with one `SELECT ... FOR UPDATE` statement per row, in a
+// canonical order: sorted by table, column and value.
+//
+// Transactions that lock the same rows, e.g. the two accounts of a transfer,
+// in whatever order they receive them can deadlock each other, which doesn't
+// happen when all of them lock the rows through LockInOrder:
+//
+//	err := ktx.LockInOrder(ctx, tx, ktx.Postgres,
+//		ktx.RowKey{Table: "accounts", Column: "id", Value: from},
+//		ktx.RowKey{Table: "accounts", Column: "id", Value: to},
+//	)
+//
+// Repeated keys are only locked once and keys of rows that don't exist are
+// ignored. The lock syntax of each dialect is the one of LockForUpdate.
+func LockInOrder(ctx context.Context, db Queryer, dialect Dialect, keys ...RowKey) error {
+	keys = slices.Clone(keys)
+	slices.SortFunc(keys, compareRowKeys)
+	keys = slices.CompactFunc(keys, func(a, b RowKey) bool {
+		return compareRowKeys(a, b) == 0
+	})
+
+	for _, key := range keys {
+		table, suffix := LockForUpdate(dialect, key.Table)
+		query := "SELECT 1 FROM " + table + " WHERE " + dialect.Quote(key.Column) + " = " + dialect.Placeholder(0) + suffix
+
+		rows, err := db.QueryContext(ctx, query, key.Value)
+		if err != nil {
+			return fmt.Errorf("error locking row of table '%s' with %s = %v: %w", key.Table, key.Column, key.Value, err)
+		}
+		// Some databases only lock the rows as they are read:
+		for rows.Next() {
+		}
+		err = rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("error locking row of table '%s' with %s = %v: %w", key.Table, key.Column, key.Value, err)
+		}
+	}
+
+	return nil
+}
+
+func compareRowKeys(a, b RowKey) int {
+	if c := cmp.Compare(a.Table, b.Table); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Column, b.Column); c != 0 {
+		return c
+	}
+	return compareValues(a.Value, b.Value)
+}
+
+// compareValues orders the values of the same type by their natural
+// order and the values of different types by the names of their types.
+func compareValues(a, b interface{}) int {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		return cmp.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+	}
+
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(va.Float(), vb.Float())
+	case reflect.String:
+		return cmp.Compare(va.String(), vb.String())
+	}
+
+	switch x := a.(type) {
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	}
+	return cmp.Compare(fmt.Sprintf("%#v", a), fmt.Sprintf("%#v", b))
+}
@@ -0,0 +1,75 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestLockInOrder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should lock the rows in a canonical order", func(t *testing.T) {
+		fake := &procedureConnector{row: []driver.Value{int64(1)}}
+		db := sql.OpenDB(fake)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return LockInOrder(ctx, tx, Postgres,
+				RowKey{Table: "accounts", Column: "id", Value: 42},
+				RowKey{Table: "ledgers", Column: "id", Value: 1},
+				RowKey{Table: "accounts", Column: "id", Value: 7},
+				RowKey{Table: "accounts", Column: "id", Value: 42},
+			)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{
+			`SELECT 1 FROM "accounts" WHERE "id" = $1 FOR UPDATE [7]`,
+			`SELECT 1 FROM "accounts" WHERE "id" = $1 FOR UPDATE [42]`,
+			`SELECT 1 FROM "ledgers" WHERE "id" = $1 FOR UPDATE [1]`,
+		}
+		if got := fake.statements(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected statements %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("should lock existing rows on SQLite", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			if err != nil {
+				return err
+			}
+			return LockInOrder(ctx, tx, SQLite,
+				RowKey{Table: "users", Column: "email", Value: "john@example.com"},
+				RowKey{Table: "users", Column: "email", Value: "missing@example.com"},
+			)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{a: 2, b: 10, expected: -1},
+		{a: "b", b: "a", expected: 1},
+		{a: []byte("a"), b: []byte("a"), expected: 0},
+		{a: int64(1), b: "1", expected: -1},
+	}
+	for _, test := range tests {
+		if got := compareValues(test.a, test.b); got != test.expected {
+			t.Errorf("expected compareValues(%#v, %#v) to be %d, got %d", test.a, test.b, test.expected, got)
+		}
+	}
+}
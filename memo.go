@@ -0,0 +1,327 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memo is a DBRunner wrapper that memoizes the results of identical
+// queries executed with its Select method.
+//
+// It is meant to be created inside a transaction callback so its cache
+// lives as long as the transaction, avoiding repeated round trips when
+// layered code reads the same rows more than once:
+//
+//	err := ktx.Run(ctx, db, func(tx *ktx.Tx) error {
+//		memo := ktx.Memoize(tx)
+//		// pass memo to the repositories instead of tx
+//	})
+//
+// Statements executed with ExecContext or QueryContext are not cached
+// and clear the cache, since they might modify the memoized rows.
+type Memo struct {
+	db DBRunner
+
+	mu    sync.Mutex
+	cache map[string]*memoResult
+}
+
+type memoResult struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// Memoize returns a Memo wrapping the input db.
+func Memoize(db DBRunner) *Memo {
+	return &Memo{
+		db:    db,
+		cache: map[string]*memoResult{},
+	}
+}
+
+// ExecContext executes a statement and clears the cache.
+func (m *Memo) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.Reset()
+	return m.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query without caching it and clears the cache.
+func (m *Memo) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	m.Reset()
+	return m.db.QueryContext(ctx, query, args...)
+}
+
+// Unwrap returns the wrapped DBRunner.
+func (m *Memo) Unwrap() DBRunner {
+	return m.db
+}
+
+// Reset clears the cache.
+func (m *Memo) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cache = map[string]*memoResult{}
+}
+
+// Select executes a query and memoizes its results, so subsequent calls
+// with the same query and arguments are served without querying the
+// database again.
+func (m *Memo) Select(ctx context.Context, query string, args ...interface{}) (*MemoRows, error) {
+	key := memoKey(query, args)
+
+	m.mu.Lock()
+	result, found := m.cache[key]
+	m.mu.Unlock()
+
+	if !found {
+		var err error
+		result, err = m.load(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		m.cache[key] = result
+		m.mu.Unlock()
+	}
+
+	return &MemoRows{
+		result: result,
+		cursor: -1,
+	}, nil
+}
+
+func (m *Memo) load(ctx context.Context, query string, args []interface{}) (*memoResult, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &memoResult{columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+
+		err := rows.Scan(ptrs...)
+		if err != nil {
+			return nil, err
+		}
+		result.rows = append(result.rows, values)
+	}
+
+	return result, rows.Err()
+}
+
+// memoKey identifies a query by the values that are sent to the driver,
+// so pointers are keyed by the values they point to and driver.Valuers
+// by the values they return.
+func memoKey(query string, args []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(query)
+	for _, arg := range unwrapSensitive(args) {
+		// Types only supported by specific drivers are kept as is:
+		if v, err := driver.DefaultParameterConverter.ConvertValue(arg); err == nil {
+			arg = v
+		}
+		fmt.Fprintf(&sb, "\x00%T:%v", arg, arg)
+	}
+	return sb.String()
+}
+
+// MemoRows iterates over the memoized results of Memo.Select
+// and mimics the API of *sql.Rows.
+type MemoRows struct {
+	result *memoResult
+	cursor int
+}
+
+// Next prepares the next row for reading with Scan.
+func (r *MemoRows) Next() bool {
+	if r.cursor+1 >= len(r.result.rows) {
+		r.cursor = len(r.result.rows)
+		return false
+	}
+	r.cursor++
+	return true
+}
+
+// Scan copies the columns of the current row into the values pointed at by dest.
+func (r *MemoRows) Scan(dest ...interface{}) error {
+	if r.cursor < 0 || r.cursor >= len(r.result.rows) {
+		return fmt.Errorf("scan called without calling Next")
+	}
+
+	row := r.result.rows[r.cursor]
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(row), len(dest))
+	}
+
+	for i := range dest {
+		err := assignValue(dest[i], row[i])
+		if err != nil {
+			return fmt.Errorf("error scanning column '%s': %w", r.result.columns[i], err)
+		}
+	}
+
+	return nil
+}
+
+// Columns returns the column names.
+func (r *MemoRows) Columns() []string {
+	return r.result.columns
+}
+
+// Err always returns nil since the rows were fully read before being memoized,
+// it exists for compatibility with the *sql.Rows API.
+func (r *MemoRows) Err() error {
+	return nil
+}
+
+// Close exists for compatibility with the *sql.Rows API.
+func (r *MemoRows) Close() error {
+	return nil
+}
+
+// assignValue copies a value as returned by the database/sql drivers
+// into dest, converting it when necessary.
+func assignValue(dest interface{}, src interface{}) error {
+	if b, ok := src.([]byte); ok {
+		// Scanners and interfaces might retain the slice,
+		// so it must not be shared with the cache:
+		switch dest.(type) {
+		case sql.Scanner, *interface{}:
+			src = append([]byte(nil), b...)
+		}
+	}
+
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	if d, ok := dest.(*interface{}); ok {
+		*d = src
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer, got: %T", dest)
+	}
+	dv = dv.Elem()
+
+	return assignReflectValue(dv, src)
+}
+
+func assignReflectValue(dv reflect.Value, src interface{}) error {
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Type())
+	}
+
+	if dv.Kind() == reflect.Ptr {
+		v := reflect.New(dv.Type().Elem())
+		err := assignReflectValue(v.Elem(), src)
+		if err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	}
+
+	if b, ok := src.([]byte); ok {
+		switch {
+		case dv.Kind() == reflect.String:
+			dv.SetString(string(b))
+			return nil
+		case dv.Kind() == reflect.Slice && dv.Type().Elem().Kind() == reflect.Uint8:
+			dv.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		src = string(b)
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+
+	if s, ok := src.(string); ok {
+		return assignString(dv, s)
+	}
+
+	if _, isTime := src.(time.Time); !isTime && isNumeric(sv.Kind()) && isNumeric(dv.Kind()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+
+	if dv.Kind() == reflect.String {
+		dv.SetString(fmt.Sprint(src))
+		return nil
+	}
+
+	return fmt.Errorf("unsupported conversion from %T to %s", src, dv.Type())
+}
+
+func assignString(dv reflect.Value, s string) error {
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		dv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported conversion from string to %s", dv.Type())
+	}
+	return nil
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+type countingRunner struct {
+	DBRunner
+	queries int
+}
+
+func (c *countingRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.queries++
+	return c.DBRunner.QueryContext(ctx, query, args...)
+}
+
+func (c *countingRunner) Unwrap() DBRunner {
+	return c.DBRunner
+}
+
+func TestMemo_Select(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+	if err != nil {
+		t.Fatalf("Failed to insert initial record: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		counter := &countingRunner{DBRunner: tx}
+		memo := Memoize(counter)
+
+		for i := 0; i < 3; i++ {
+			rows, err := memo.Select(ctx, "SELECT id, name, email FROM users WHERE email = ?", "john@example.com")
+			if err != nil {
+				return err
+			}
+
+			var id int
+			var name string
+			var email *string
+			if !rows.Next() {
+				t.Fatal("expected a row")
+			}
+			err = rows.Scan(&id, &name, &email)
+			if err != nil {
+				return err
+			}
+			if id != 1 || name != "John" || email == nil || *email != "john@example.com" {
+				t.Fatalf("unexpected values: %v %v %v", id, name, email)
+			}
+			if rows.Next() {
+				t.Fatal("expected a single row")
+			}
+		}
+
+		if counter.queries != 1 {
+			t.Fatalf("expected a single query to reach the database, got %d", counter.queries)
+		}
+
+		_, err := memo.Select(ctx, "SELECT id, name, email FROM users WHERE email = ?", "jane@example.com")
+		if err != nil {
+			return err
+		}
+		if counter.queries != 2 {
+			t.Fatalf("expected different args to reach the database, got %d queries", counter.queries)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestMemo_SelectWithPointerArgs(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com'), ('Jane', 'jane@example.com')")
+	if err != nil {
+		t.Fatalf("Failed to insert initial records: %v", err)
+	}
+
+	memo := Memoize(db)
+	email := "john@example.com"
+	names := []string{}
+	for _, e := range []string{"john@example.com", "jane@example.com"} {
+		email = e
+		rows, err := memo.Select(ctx, "SELECT name FROM users WHERE email = ?", &email)
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+
+		var name string
+		if !rows.Next() {
+			t.Fatal("expected a row")
+		}
+		err = rows.Scan(&name)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		names = append(names, name)
+	}
+
+	if names[0] != "John" || names[1] != "Jane" {
+		t.Fatalf("expected the value pointed by the arg to be part of the key, got: %v", names)
+	}
+}
+
+func TestMemo_ExecClearsCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		memo := Memoize(tx)
+
+		count := func() (n int) {
+			rows, err := memo.Select(ctx, "SELECT COUNT(*) FROM users")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rows.Next()
+			err = rows.Scan(&n)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return n
+		}
+
+		if n := count(); n != 0 {
+			t.Fatalf("expected 0 users, got %d", n)
+		}
+
+		_, err := memo.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+		if err != nil {
+			return err
+		}
+
+		if n := count(); n != 1 {
+			t.Fatalf("expected 1 user after the cache was cleared, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestMemoRows_ScanConversions(t *testing.T) {
+	rows := &MemoRows{
+		result: &memoResult{
+			columns: []string{"a", "b", "c", "d", "e"},
+			rows: [][]interface{}{
+				{int64(42), []byte("text"), "3.5", nil, int64(1)},
+			},
+		},
+		cursor: -1,
+	}
+
+	var a int32
+	var b string
+	var c float64
+	var d sql.NullString
+	var e interface{}
+
+	err := rows.Scan(&a, &b, &c, &d, &e)
+	if err == nil {
+		t.Fatal("expected an error when scanning before Next")
+	}
+
+	rows.Next()
+	err = rows.Scan(&a, &b, &c, &d, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a != 42 || b != "text" || c != 3.5 || d.Valid || e != int64(1) {
+		t.Fatalf("unexpected values: %v %v %v %v %v", a, b, c, d, e)
+	}
+
+	var raw interface{}
+	err = rows.Scan(&a, &raw, &c, &d, &e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw.([]byte)[0] = 'T'
+	if string(rows.result.rows[0][1].([]byte)) != "text" {
+		t.Fatalf("expected the bytes scanned into an interface{} to be copied, got: %q", rows.result.rows[0][1])
+	}
+
+	var notNullable int
+	err = rows.Scan(&a, &b, &c, &notNullable, &e)
+	if err == nil {
+		t.Fatal("expected an error when scanning NULL into a non-nullable type")
+	}
+}
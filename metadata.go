@@ -0,0 +1,76 @@
+package ktx
+
+import "context"
+
+type metadataCtxKey struct{}
+
+// WithMetadata attaches a key/value pair to the transaction.
+//
+// The metadata is set once when the transaction begins and can be read
+// from the callback or from hooks with tx.Metadata, which allows
+// middlewares to attach information such as request or user IDs
+// for later consumption by auditing or metrics hooks.
+func WithMetadata(key string, value interface{}) Option {
+	return func(c *config) {
+		if c.metadata == nil {
+			c.metadata = map[string]interface{}{}
+		}
+		c.metadata[key] = value
+	}
+}
+
+// ContextWithMetadata returns a copy of ctx carrying the input key/value pair.
+//
+// Every transaction started by Run with this context will have this pair
+// in its metadata, unless the same key is also set with WithMetadata,
+// in which case the value from the Option prevails.
+func ContextWithMetadata(ctx context.Context, key string, value interface{}) context.Context {
+	parent, _ := ctx.Value(metadataCtxKey{}).(map[string]interface{})
+
+	m := make(map[string]interface{}, len(parent)+1)
+	for k, v := range parent {
+		m[k] = v
+	}
+	m[key] = value
+
+	return context.WithValue(ctx, metadataCtxKey{}, m)
+}
+
+// MetadataFromContext reads a value attached to ctx with ContextWithMetadata.
+func MetadataFromContext(ctx context.Context, key string) (value interface{}, ok bool) {
+	m, _ := ctx.Value(metadataCtxKey{}).(map[string]interface{})
+	value, ok = m[key]
+	return value, ok
+}
+
+// Metadata reads a value from the metadata of the transaction.
+func (tx *Tx) Metadata(key string) (value interface{}, ok bool) {
+	value, ok = tx.metadata[key]
+	return value, ok
+}
+
+// contextWithConfigMetadata returns a copy of ctx that also carries the
+// metadata set with WithMetadata, for the hooks that read it from the
+// context before the transaction starts.
+func contextWithConfigMetadata(ctx context.Context, cfg *config) context.Context {
+	if len(cfg.metadata) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, metadataCtxKey{}, buildMetadata(ctx, cfg.metadata))
+}
+
+func buildMetadata(ctx context.Context, fromOptions map[string]interface{}) map[string]interface{} {
+	fromCtx, _ := ctx.Value(metadataCtxKey{}).(map[string]interface{})
+	if len(fromCtx) == 0 {
+		return fromOptions
+	}
+
+	m := make(map[string]interface{}, len(fromCtx)+len(fromOptions))
+	for k, v := range fromCtx {
+		m[k] = v
+	}
+	for k, v := range fromOptions {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,61 @@
+package ktx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun_Metadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := ContextWithMetadata(context.Background(), "request_id", "fake-request-id")
+	ctx = ContextWithMetadata(ctx, "user_id", "overridden")
+
+	var fromHook interface{}
+	err := Run(ctx, db, func(tx *Tx) error {
+		v, ok := tx.Metadata("request_id")
+		if !ok || v != "fake-request-id" {
+			t.Errorf("expected request_id from context, got: %v", v)
+		}
+
+		v, ok = tx.Metadata("user_id")
+		if !ok || v != 42 {
+			t.Errorf("expected user_id from option, got: %v", v)
+		}
+
+		_, ok = tx.Metadata("missing")
+		if ok {
+			t.Error("expected missing key to not be found")
+		}
+		return nil
+	},
+		WithMetadata("user_id", 42),
+		WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				fromHook, _ = tx.Metadata("request_id")
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if fromHook != "fake-request-id" {
+		t.Errorf("expected hook to read the metadata, got: %v", fromHook)
+	}
+}
+
+func TestContextWithMetadata(t *testing.T) {
+	parent := ContextWithMetadata(context.Background(), "a", 1)
+	child := ContextWithMetadata(parent, "b", 2)
+
+	if _, ok := MetadataFromContext(parent, "b"); ok {
+		t.Fatal("expected parent context to not be modified")
+	}
+
+	v, ok := MetadataFromContext(child, "a")
+	if !ok || v != 1 {
+		t.Fatalf("expected child to inherit parent metadata, got: %v", v)
+	}
+}
@@ -0,0 +1,32 @@
+package ktx
+
+// Middleware wraps the DBRunner used by a transaction for executing
+// statements, so it can observe or change each statement before it
+// reaches the database.
+//
+// The runner received by the Middleware executes the statement on the
+// transaction, possibly going through other middlewares first.
+//
+// The runners returned by middlewares should implement `Unwrap() DBRunner`
+// returning the next runner, so that TxFromRunner can find the transaction
+// from the runners received by the middlewares registered before them.
+type Middleware func(next DBRunner) DBRunner
+
+// WithMiddleware registers middlewares that will wrap every statement
+// executed through the *Tx received by the callback of Run.
+//
+// It can be used more than once. The first middleware registered is the
+// outermost one, i.e. it is the first to see each statement.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, mws...)
+	}
+}
+
+func buildRunner(base DBRunner, mws []Middleware) DBRunner {
+	runner := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		runner = mws[i](runner)
+	}
+	return runner
+}
@@ -0,0 +1,131 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+type recordingRunner struct {
+	next  DBRunner
+	name  string
+	calls *[]string
+}
+
+func (r recordingRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*r.calls = append(*r.calls, r.name+": "+query)
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r recordingRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*r.calls = append(*r.calls, r.name+": "+query)
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next DBRunner) DBRunner {
+		return recordingRunner{next: next, name: name, calls: calls}
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should run statements through the middlewares in order", func(t *testing.T) {
+		var calls []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		},
+			WithMiddleware(recordingMiddleware("outer", &calls)),
+			WithMiddleware(recordingMiddleware("inner", &calls)),
+		)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{
+			"outer: INSERT INTO users (name, email) VALUES (?, ?)",
+			"inner: INSERT INTO users (name, email) VALUES (?, ?)",
+			"outer: SELECT id FROM users",
+			"inner: SELECT id FROM users",
+		}
+		if len(calls) != len(expected) {
+			t.Fatalf("expected calls %v, got %v", expected, calls)
+		}
+		for i := range expected {
+			if calls[i] != expected[i] {
+				t.Errorf("expected call %d to be %q, got %q", i, expected[i], calls[i])
+			}
+		}
+	})
+
+	t.Run("should also apply to nested transactions and deferred statements", func(t *testing.T) {
+		var calls []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Defer(tx, "DELETE FROM users WHERE name = ?", "nobody")
+			if err != nil {
+				return err
+			}
+
+			return Run(ctx, tx, func(nested *Tx) error {
+				_, err := nested.ExecContext(ctx, "UPDATE users SET name = name")
+				return err
+			})
+		}, WithMiddleware(recordingMiddleware("mw", &calls)))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(calls) != 2 || calls[0] != "mw: UPDATE users SET name = name" || calls[1] != "mw: DELETE FROM users WHERE name = ?" {
+			t.Fatalf("unexpected calls: %v", calls)
+		}
+	})
+}
+
+func TestTxFromRunner(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var found []*Tx
+	var calls []string
+	err := Run(ctx, db, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE users SET name = name")
+		if err != nil {
+			return err
+		}
+
+		if len(found) != 1 || found[0] != tx {
+			t.Errorf("expected the middleware to find the transaction, got: %v", found)
+		}
+		return nil
+	}, WithMiddleware(func(next DBRunner) DBRunner {
+		tx, err := TxFromRunner(next)
+		if err != nil {
+			t.Errorf("TxFromRunner failed: %v", err)
+		}
+		found = append(found, tx)
+		return recordingRunner{next: next, name: "mw", calls: &calls}
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	_, err = TxFromRunner(db)
+	if err != ErrTxNotManaged {
+		t.Errorf("expected ErrTxNotManaged for a *sql.DB, got: %v", err)
+	}
+}
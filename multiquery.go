@@ -0,0 +1,49 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MultiQuery executes a query that returns several result sets in a
+// single round trip, e.g. a stored procedure, and calls each handler
+// with the *sql.Rows positioned on the corresponding result set:
+//
+//	err := ktx.MultiQuery(ctx, tx, "CALL get_order(?)", []interface{}{orderID},
+//		func(rows *sql.Rows) error {
+//			// scan the order
+//		},
+//		func(rows *sql.Rows) error {
+//			// scan the items of the order
+//		},
+//	)
+//
+// The handlers don't need to read all rows of their result sets, the
+// remaining ones are discarded. An error is returned if the query returns
+// fewer result sets than handlers, and the extra result sets are ignored.
+//
+// Whether multiple result sets are supported depends on the driver.
+func MultiQuery(ctx context.Context, db Queryer, query string, args []interface{}, handlers ...func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for i, handler := range handlers {
+		if i > 0 && !rows.NextResultSet() {
+			if rows.Err() != nil {
+				return fmt.Errorf("error moving to result set #%d: %w", i+1, rows.Err())
+			}
+			return fmt.Errorf("expected %d result sets but the query returned %d", len(handlers), i)
+		}
+
+		err := handler(rows)
+		if err != nil {
+			return fmt.Errorf("error handling result set #%d: %w", i+1, err)
+		}
+	}
+
+	return rows.Close()
+}
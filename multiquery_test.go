@@ -0,0 +1,136 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMultiQuery(t *testing.T) {
+	db := sql.OpenDB(multiResultConnector{
+		sets: [][]string{
+			{"order-1"},
+			{"item-1", "item-2"},
+			{"ignored"},
+		},
+	})
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	readAll := func(dest *[]string) func(rows *sql.Rows) error {
+		return func(rows *sql.Rows) error {
+			for rows.Next() {
+				var value string
+				err := rows.Scan(&value)
+				if err != nil {
+					return err
+				}
+				*dest = append(*dest, value)
+			}
+			return rows.Err()
+		}
+	}
+
+	t.Run("should call each handler with its result set", func(t *testing.T) {
+		var orders, items []string
+		err := Run(ctx, db, func(tx *Tx) error {
+			return MultiQuery(ctx, tx, "CALL get_order(?)", []interface{}{1},
+				readAll(&orders),
+				readAll(&items),
+			)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if strings.Join(orders, ",") != "order-1" {
+			t.Errorf("unexpected orders: %v", orders)
+		}
+		if strings.Join(items, ",") != "item-1,item-2" {
+			t.Errorf("unexpected items: %v", items)
+		}
+	})
+
+	t.Run("should allow handlers to skip rows", func(t *testing.T) {
+		var items []string
+		err := MultiQuery(ctx, db, "CALL get_order(?)", []interface{}{1},
+			func(rows *sql.Rows) error { return nil },
+			readAll(&items),
+		)
+		if err != nil {
+			t.Fatalf("MultiQuery failed: %v", err)
+		}
+
+		if strings.Join(items, ",") != "item-1,item-2" {
+			t.Errorf("unexpected items: %v", items)
+		}
+	})
+
+	t.Run("should report missing result sets", func(t *testing.T) {
+		noop := func(rows *sql.Rows) error { return nil }
+		err := MultiQuery(ctx, db, "CALL get_order(?)", []interface{}{1}, noop, noop, noop, noop)
+		if err == nil || !strings.Contains(err.Error(), "expected 4 result sets but the query returned 3") {
+			t.Errorf("expected an error about the missing result set, got: %v", err)
+		}
+	})
+}
+
+// multiResultConnector creates connections whose queries return the
+// configured result sets, each with a single "value" column.
+type multiResultConnector struct {
+	sets [][]string
+}
+
+func (c multiResultConnector) Connect(context.Context) (driver.Conn, error) {
+	return multiResultConn(c), nil
+}
+
+func (multiResultConnector) Driver() driver.Driver { return nil }
+
+type multiResultConn multiResultConnector
+
+func (multiResultConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (multiResultConn) Close() error                        { return nil }
+func (c multiResultConn) Begin() (driver.Tx, error)         { return c, nil }
+func (multiResultConn) Commit() error                       { return nil }
+func (multiResultConn) Rollback() error                     { return nil }
+
+func (c multiResultConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &multiResultRows{sets: c.sets}, nil
+}
+
+type multiResultRows struct {
+	sets [][]string
+	set  int
+	row  int
+}
+
+func (r *multiResultRows) Columns() []string { return []string{"value"} }
+func (r *multiResultRows) Close() error      { return nil }
+
+func (r *multiResultRows) Next(dest []driver.Value) error {
+	if r.row >= len(r.sets[r.set]) {
+		return io.EOF
+	}
+	dest[0] = r.sets[r.set][r.row]
+	r.row++
+	return nil
+}
+
+func (r *multiResultRows) HasNextResultSet() bool {
+	return r.set+1 < len(r.sets)
+}
+
+func (r *multiResultRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.set++
+	r.row = 0
+	return nil
+}
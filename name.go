@@ -0,0 +1,14 @@
+package ktx
+
+// WithName names the transaction, e.g. after the operation it performs,
+// so it can be told apart by hooks, logs and metrics.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// Name returns the name set with WithName or an empty string.
+func (tx *Tx) Name() string {
+	return tx.cfg.name
+}
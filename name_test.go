@@ -0,0 +1,49 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestWithName(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var names []string
+	err := Run(ctx, db, func(tx *Tx) error {
+		names = append(names, tx.Name())
+
+		// Nested transactions keep the name of the outer one:
+		return Run(ctx, tx, func(nested *Tx) error {
+			names = append(names, nested.Name())
+			return nil
+		}, WithName("ignored"))
+	}, WithName("create-user"), WithHooks(Hooks{
+		OnCommit: func(ctx context.Context, tx *Tx) {
+			names = append(names, tx.Name())
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(names) != 3 || names[0] != "create-user" || names[1] != "create-user" || names[2] != "create-user" {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	err = Transaction(ctx, db, func(sqlTx *sql.Tx) error {
+		return Run(ctx, sqlTx, func(tx *Tx) error {
+			names = append(names, tx.Name())
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transaction failed: %v", err)
+	}
+	if names[3] != "" {
+		t.Errorf("expected an empty name, got %q", names[3])
+	}
+}
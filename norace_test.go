@@ -0,0 +1,5 @@
+//go:build !race
+
+package ktx
+
+const raceEnabled = false
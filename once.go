@@ -0,0 +1,168 @@
+package ktx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OnceTable is the table used by Once for storing the keys of the
+// operations that were already executed and their results.
+const OnceTable = "ktx_once"
+
+// onceTables records on which databases the table of Once
+// was already created so it is only created once.
+var onceTables sync.Map
+
+// errOnceKeyTaken is used internally by Once when the key is recorded
+// by a concurrent transaction while the callback is running.
+var errOnceKeyTaken = errors.New("idempotency key was taken by a concurrent transaction")
+
+// Once runs fn inside a transaction at most once for each key, which is
+// useful for handlers that need exactly-once effects, such as payment
+// processing or webhooks that may be delivered more than once.
+//
+// The key is recorded together with the JSON encoding of the result of fn
+// in the same transaction as the effects of fn, and the following calls
+// with the same key return the decoded result without calling fn again.
+//
+// If fn fails nothing is recorded, so the operation can be retried with the
+// same key. If two calls with the same key run concurrently only one of
+// them commits, the other one is rolled back and returns the result
+// recorded by the first. On SQLite the second one fails with a busy
+// error instead, since its snapshot is stale, so WithRetry is required
+// for it to find the recorded result.
+//
+// The Options are passed to Run and the table is created on the first
+// call for each db if it doesn't exist.
+func Once[T any](ctx context.Context, db DBRunner, dialect Dialect, key string, fn func(tx *Tx) (T, error), opts ...Option) (result T, err error) {
+	err = createOnceTable(ctx, db, dialect)
+	if err != nil {
+		return result, err
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		stored, found, err := loadOnceResult(ctx, tx, dialect, key)
+		if err != nil {
+			return err
+		}
+		if found {
+			return json.Unmarshal([]byte(stored), &result)
+		}
+
+		result, err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("error encoding result of operation '%s': %w", key, err)
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (idempotency_key, result, created_at) VALUES (%s, %s, %s)",
+			dialect.Quote(OnceTable), dialect.Placeholder(0), dialect.Placeholder(1), dialect.Placeholder(2),
+		), key, string(encoded), time.Now().UTC())
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %w", errOnceKeyTaken, err)
+		}
+		if err != nil {
+			return fmt.Errorf("error recording operation '%s': %w", key, err)
+		}
+
+		return nil
+	}, opts...)
+	if !errors.Is(err, errOnceKeyTaken) {
+		return result, err
+	}
+
+	// The transaction was rolled back because a concurrent
+	// call recorded the key first, so we use its result:
+	stored, found, loadErr := loadOnceResult(ctx, db, dialect, key)
+	if loadErr != nil || !found {
+		return result, err
+	}
+
+	var storedResult T
+	return storedResult, json.Unmarshal([]byte(stored), &storedResult)
+}
+
+// isUniqueViolation reports whether err was caused by inserting
+// a key that already exists.
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) && stateErr.SQLState() == "23505" {
+		return true
+	}
+
+	// Drivers that don't expose the error codes with a method:
+	msg := err.Error()
+	for _, s := range []string{
+		"Error 1062",                   // MySQL duplicate entry
+		"SQLSTATE 23505",               // Postgres unique violation
+		"duplicate key value violates", // Postgres unique violation (lib/pq)
+		"UNIQUE constraint failed",     // SQLite
+		"PRIMARY KEY must be unique",   // SQLite before 3.8.2
+		"Violation of PRIMARY KEY",     // SQL Server 2627
+		"Cannot insert duplicate key",  // SQL Server 2601
+		"ORA-00001",                    // Oracle unique constraint violated
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func createOnceTable(ctx context.Context, db DBRunner, dialect Dialect) error {
+	if _, created := onceTables.Load(db); created {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (idempotency_key VARCHAR(255) PRIMARY KEY, result TEXT NOT NULL, created_at TIMESTAMP NOT NULL)",
+		dialect.Quote(OnceTable),
+	))
+	if err != nil {
+		return fmt.Errorf("error creating table %s: %w", OnceTable, err)
+	}
+
+	// When db is a transaction the table might still be rolled
+	// back, so we only remember the tables created on pools:
+	if _, ok := db.(TxBeginner); ok {
+		onceTables.Store(db, true)
+	}
+	return nil
+}
+
+func loadOnceResult(ctx context.Context, db Queryer, dialect Dialect, key string) (result string, found bool, err error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT result FROM %s WHERE idempotency_key = %s",
+		dialect.Quote(OnceTable), dialect.Placeholder(0),
+	), key)
+	if err != nil {
+		return "", false, fmt.Errorf("error loading result of operation '%s': %w", key, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+
+	err = rows.Scan(&result)
+	if err != nil {
+		return "", false, fmt.Errorf("error loading result of operation '%s': %w", key, err)
+	}
+
+	return result, true, rows.Close()
+}
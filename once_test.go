@@ -0,0 +1,160 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type paymentResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestOnce(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	countUsers := func(t *testing.T) int {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		return count
+	}
+
+	t.Run("should run the callback only once for each key", func(t *testing.T) {
+		calls := 0
+		pay := func(tx *Tx) (paymentResult, error) {
+			calls++
+			result, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "once@example.com")
+			if err != nil {
+				return paymentResult{}, err
+			}
+			id, err := result.LastInsertId()
+			return paymentResult{ID: int(id), Status: "paid"}, err
+		}
+
+		first, err := Once(ctx, db, SQLite, "payment-1", pay)
+		if err != nil {
+			t.Fatalf("Once failed: %v", err)
+		}
+
+		second, err := Once(ctx, db, SQLite, "payment-1", pay)
+		if err != nil {
+			t.Fatalf("Once failed: %v", err)
+		}
+
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+		if first.Status != "paid" || first != second {
+			t.Errorf("expected the stored result on the second call, got %+v and %+v", first, second)
+		}
+		if countUsers(t) != 1 {
+			t.Errorf("expected a single user")
+		}
+	})
+
+	t.Run("should not record the key when the callback fails", func(t *testing.T) {
+		calls := 0
+		fakeErr := errors.New("fake error")
+		fn := func(tx *Tx) (string, error) {
+			calls++
+			if calls == 1 {
+				return "", fakeErr
+			}
+			return "done", nil
+		}
+
+		_, err := Once(ctx, db, SQLite, "failing-op", fn)
+		if err != fakeErr {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+
+		result, err := Once(ctx, db, SQLite, "failing-op", fn)
+		if err != nil {
+			t.Fatalf("Once failed: %v", err)
+		}
+		if calls != 2 || result != "done" {
+			t.Errorf("expected the callback to run again, calls: %d, result: %q", calls, result)
+		}
+	})
+
+	t.Run("should return the errors that are not unique violations as is", func(t *testing.T) {
+		_, err := Once(ctx, db, SQLite, "dropped-table", func(tx *Tx) (string, error) {
+			_, err := tx.ExecContext(ctx, "DROP TABLE "+OnceTable)
+			return "done", err
+		})
+		if errors.Is(err, errOnceKeyTaken) || err == nil || !strings.Contains(err.Error(), "no such table") {
+			t.Fatalf("expected the error of the insert, got: %v", err)
+		}
+
+		// The drop was rolled back with the transaction:
+		result, err := Once(ctx, db, SQLite, "dropped-table", func(tx *Tx) (string, error) {
+			return "done", nil
+		})
+		if err != nil || result != "done" {
+			t.Fatalf("expected Once to succeed, got %q and error: %v", result, err)
+		}
+	})
+
+	t.Run("should use the result of a concurrent call that recorded the key first", func(t *testing.T) {
+		fileDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "once.db")+"?_journal_mode=WAL&_busy_timeout=100")
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		defer func() { _ = fileDB.Close() }()
+
+		calls := 0
+		result, err := Once(ctx, fileDB, SQLite, "concurrent-op", func(tx *Tx) (string, error) {
+			calls++
+
+			// Simulates a concurrent call that records the key
+			// while this callback is running:
+			_, err := Once(ctx, fileDB, SQLite, "concurrent-op", func(tx *Tx) (string, error) {
+				return "first", nil
+			})
+			return "second", err
+		}, WithRetry(RetryPolicy{MaxAttempts: 2}), WithIdempotent())
+		if err != nil {
+			t.Fatalf("Once failed: %v", err)
+		}
+
+		if calls != 1 || result != "first" {
+			t.Errorf("expected the result of the concurrent call, got %q", result)
+		}
+	})
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: errors.New("some error"), expected: false},
+		{err: sqlStateError("23505"), expected: true},
+		{err: fmt.Errorf("wrapped: %w", sqlStateError("23505")), expected: true},
+		{err: sqlStateError("23503"), expected: false},
+		{err: errors.New("Error 1062 (23000): Duplicate entry 'key' for key 'PRIMARY'"), expected: true},
+		{err: errors.New("Error 1146 (42S02): Table 'ktx_once' doesn't exist"), expected: false},
+		{err: errors.New("UNIQUE constraint failed: ktx_once.idempotency_key"), expected: true},
+		{err: errors.New("database is locked"), expected: false},
+		{err: errors.New("ORA-00001: unique constraint (KTX_ONCE_PK) violated"), expected: true},
+	}
+
+	for _, test := range tests {
+		if got := isUniqueViolation(test.err); got != test.expected {
+			t.Errorf("isUniqueViolation(%v): expected %v, got %v", test.err, test.expected, got)
+		}
+	}
+}
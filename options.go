@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Option configures how Run starts and executes a transaction.
+type Option func(*config)
+
+type config struct {
+	name     string
+	id       string
+	session  *Session
+	hooks    []Hooks
+	metadata map[string]interface{}
+
+	validators []func(ctx context.Context) error
+	actor      *ActorOptions
+	breaker    *CircuitBreaker
+	quota      *Quota
+
+	middlewares   []Middleware
+	retry         *RetryPolicy
+	idempotent    bool
+	readOnly      bool
+	isolation     sql.IsolationLevel
+	readOnlyGuard bool
+	priority      Priority
+
+	applicationName string
+
+	readOnlyDetector *ReadOnlyDetector
+	resultCache      *ResultCache
+	resultCacheKey   string
+
+	argsValidation bool
+
+	dialect       Dialect
+	batchExecutor BatchExecutor
+	invalidator   Invalidator
+	changeCapture Dialect
+
+	leakTimeout      time.Duration
+	statementTimeout time.Duration
+	commitTimeout    time.Duration
+	rollbackTimeout  time.Duration
+	maxParallelism   int
+
+	commitVerifier  CommitVerifier
+	canary          func(ctx context.Context, tx *Tx) error
+	lockDiagnostics *LockDiagnosticsOptions
+
+	maxRowsAffected      int64
+	maxTotalRowsAffected int64
+
+	heartbeatInterval time.Duration
+	heartbeat         func(ctx context.Context, tx *Tx, hb Heartbeat)
+
+	eventLog   *EventLog
+	sloTracker *SLOTracker
+	result     *TxResult
+}
+
+func (c *config) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(c)
+	}
+}
+
+// WithOptions groups several Options into a single one,
+// which is useful for packages that need to configure
+// more than one aspect of the transaction.
+func WithOptions(opts ...Option) Option {
+	return func(c *config) {
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
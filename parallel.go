@@ -0,0 +1,74 @@
+package ktx
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMaxParallelism is the limit of tx.Parallel
+// when WithMaxParallelism is not used.
+const defaultMaxParallelism = 4
+
+// WithMaxParallelism limits how many functions
+// each call to tx.Parallel runs at once.
+func WithMaxParallelism(n int) Option {
+	return func(c *config) {
+		c.maxParallelism = n
+	}
+}
+
+// Parallel runs the functions concurrently, at most 4 at once or as set
+// WithMaxParallelism, and waits for all of them, returning the first error.
+//
+// The context passed to the functions is cancelled as soon as one
+// of them fails, so the others can stop early, as with errgroup.
+//
+// It is meant for queries that don't need to see the writes of the
+// transaction and run on other connections of the pool, e.g. for
+// loading reference data while the transaction is open, since the
+// statements of the transaction itself run on a single connection.
+func (tx *Tx) Parallel(ctx context.Context, fns ...func(ctx context.Context) error) error {
+	limit := tx.cfg.maxParallelism
+	if limit <= 0 {
+		limit = defaultMaxParallelism
+	}
+	sem := make(chan struct{}, limit)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for _, fn := range fns {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(fn func(ctx context.Context) error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := fn(ctx)
+			if err != nil {
+				fail(err)
+			}
+		}(fn)
+	}
+
+	wg.Wait()
+	return firstErr
+}
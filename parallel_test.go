@@ -0,0 +1,72 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTx_Parallel(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should bound the parallelism", func(t *testing.T) {
+		var running, maxRunning int32
+		fn := func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		}
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return tx.Parallel(ctx, fn, fn, fn, fn, fn, fn)
+		}, WithMaxParallelism(2))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if maxRunning != 2 {
+			t.Errorf("expected at most 2 functions at once, got %d", maxRunning)
+		}
+	})
+
+	t.Run("should cancel the siblings on the first error", func(t *testing.T) {
+		fakeErr := errors.New("fake error")
+		var cancelled int32
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			return tx.Parallel(ctx,
+				func(ctx context.Context) error {
+					return fakeErr
+				},
+				func(ctx context.Context) error {
+					select {
+					case <-ctx.Done():
+						atomic.AddInt32(&cancelled, 1)
+					case <-time.After(time.Second):
+					}
+					return ctx.Err()
+				},
+				func(ctx context.Context) error {
+					return nil
+				},
+			)
+		}, WithMaxParallelism(2))
+		if !errors.Is(err, fakeErr) {
+			t.Fatalf("expected the first error, got: %v", err)
+		}
+		if cancelled != 1 {
+			t.Errorf("expected the running sibling to be cancelled")
+		}
+	})
+}
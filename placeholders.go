@@ -0,0 +1,164 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrArgsMismatch is returned by the statements whose number of arguments
+// doesn't match their placeholders on transactions started
+// WithArgsValidation.
+var ErrArgsMismatch = errors.New("number of arguments doesn't match the placeholders")
+
+// WithArgsValidation checks, before sending each statement to the driver,
+// that the number of arguments matches the placeholders of the statement,
+// failing with ErrArgsMismatch and the Fingerprint of the statement
+// instead of the cryptic errors some drivers return for it.
+//
+// The placeholders are parsed according to the Dialect set WithDialect,
+// which defaults to the `?` placeholders of MySQL and SQLite. Statements
+// that receive sql.NamedArg arguments are not validated.
+func WithArgsValidation() Option {
+	return func(c *config) {
+		c.argsValidation = true
+	}
+}
+
+type argsValidationRunner struct {
+	next    DBRunner
+	dialect Dialect
+}
+
+func (r argsValidationRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := validateArgs(r.dialect, query, args); err != nil {
+		return nil, err
+	}
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r argsValidationRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := validateArgs(r.dialect, query, args); err != nil {
+		return nil, err
+	}
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r argsValidationRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func validateArgs(dialect Dialect, query string, args []interface{}) error {
+	for _, arg := range args {
+		if _, ok := arg.(sql.NamedArg); ok {
+			return nil
+		}
+	}
+
+	expected := countPlaceholders(dialect, query)
+	if expected != len(args) {
+		return fmt.Errorf(
+			"%w: statement '%s' has %d placeholders but received %d arguments",
+			ErrArgsMismatch, Fingerprint(query), expected, len(args),
+		)
+	}
+	return nil
+}
+
+// countPlaceholders returns the number of arguments expected by the query,
+// which is the number of placeholders for dialects whose placeholders are
+// all the same, e.g. "?", and the highest index for numbered ones, e.g. "$1".
+func countPlaceholders(dialect Dialect, query string) int {
+	first := dialect.Placeholder(0)
+	prefix := strings.TrimSuffix(first, "1")
+	numbered := prefix != first && dialect.Placeholder(1) == prefix+"2"
+
+	runes := []rune(query)
+	count := 0
+	i := 0
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(runes, i, c)
+
+		case c == '[' && dialect.Name() == SQLServer.Name():
+			for i < len(runes) && runes[i] != ']' {
+				i++
+			}
+			i++
+
+		case c == '$' && prefix == "$" && (i+1 == len(runes) || !unicode.IsDigit(runes[i+1])):
+			i = skipDollarQuoted(runes, i)
+
+		case !numbered && hasPrefixAt(runes, i, first):
+			count++
+			i += len([]rune(first))
+
+		case numbered && hasPrefixAt(runes, i, prefix) && (i == 0 || !isWordRune(runes[i-1]) && runes[i-1] != ':'):
+			start := i + len([]rune(prefix))
+			end := start
+			for end < len(runes) && unicode.IsDigit(runes[end]) {
+				end++
+			}
+			if end == start {
+				i++
+				continue
+			}
+			n, _ := strconv.Atoi(string(runes[start:end]))
+			count = max(count, n)
+			i = end
+
+		default:
+			i++
+		}
+	}
+	return count
+}
+
+// skipDollarQuoted returns the position right after the Postgres dollar
+// quoted string that starts at runes[start], e.g. $$text$$ or $tag$text$tag$,
+// or the next position if it is not one.
+func skipDollarQuoted(runes []rune, start int) int {
+	end := start + 1
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	if end == len(runes) || runes[end] != '$' {
+		return start + 1
+	}
+
+	tag := string(runes[start : end+1])
+	rest := string(runes[end+1:])
+	closing := strings.Index(rest, tag)
+	if closing < 0 {
+		return len(runes)
+	}
+	return end + 1 + len([]rune(rest[:closing])) + len([]rune(tag))
+}
+
+func hasPrefixAt(runes []rune, i int, prefix string) bool {
+	for _, p := range prefix {
+		if i >= len(runes) || runes[i] != p {
+			return false
+		}
+		i++
+	}
+	return true
+}
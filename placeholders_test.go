@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		desc     string
+		dialect  Dialect
+		query    string
+		expected int
+	}{
+		{desc: "question marks", dialect: MySQL, query: "SELECT * FROM users WHERE id = ? AND name = ?", expected: 2},
+		{desc: "question marks in strings and comments", dialect: SQLite, query: "SELECT '?', \"a?\" -- ?\nFROM t /* ? */ WHERE id = ?", expected: 1},
+		{desc: "numbered", dialect: Postgres, query: "SELECT * FROM users WHERE id = $2 OR parent_id = $1 OR id = $2", expected: 2},
+		{desc: "casts and dollar quotes", dialect: Postgres, query: "SELECT $1::int, $$ $5 $$, $fn$ $6 $fn$", expected: 1},
+		{desc: "sql server", dialect: SQLServer, query: "SELECT [@p9] FROM users WHERE id = @p1", expected: 1},
+		{desc: "oracle", dialect: Oracle, query: "SELECT * FROM users WHERE id = :1 AND name = :2", expected: 2},
+		{desc: "no placeholders", dialect: Postgres, query: "SELECT 1", expected: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := countPlaceholders(test.dialect, test.query); got != test.expected {
+				t.Fatalf("expected %d placeholders, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestArgsValidation(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	t.Run("should reject statements with missing arguments", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John")
+			return err
+		}, WithArgsValidation())
+		if !errors.Is(err, ErrArgsMismatch) {
+			t.Fatalf("expected ErrArgsMismatch, got: %v", err)
+		}
+		expected := "number of arguments doesn't match the placeholders: statement 'insert into users (name, email) values (...)' has 2 placeholders but received 1 arguments"
+		if err.Error() != expected {
+			t.Fatalf("unexpected error message: %v", err)
+		}
+	})
+
+	t.Run("should reject queries with extra arguments", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			rows, err := tx.QueryContext(ctx, "SELECT * FROM users WHERE id = $1", 1, 2)
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithArgsValidation(), WithDialect(Postgres))
+		if !errors.Is(err, ErrArgsMismatch) {
+			t.Fatalf("expected ErrArgsMismatch, got: %v", err)
+		}
+	})
+
+	t.Run("should run valid statements", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE users SET name = :name", sql.Named("name", "Jane"))
+			return err
+		}, WithArgsValidation())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		assertUserCount(t, db, 1)
+	})
+}
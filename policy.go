@@ -0,0 +1,172 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrStatementDenied is returned by the statements rejected
+// by the StatementPolicy of the transaction.
+var ErrStatementDenied = errors.New("statement denied by policy")
+
+// StatementMatcher decides whether a statement belongs to a category,
+// which is described by its Name on the errors of StatementPolicy.
+type StatementMatcher struct {
+	Name  string
+	Match func(query string) bool
+}
+
+// StatementPolicy restricts the statements that can be executed inside
+// a transaction started WithStatementPolicy.
+type StatementPolicy struct {
+	// Allow, when not empty, lists the only statements that can be
+	// executed, i.e. the ones that match at least one of them.
+	Allow []StatementMatcher
+
+	// Deny lists the statements that can't be executed,
+	// even if they are matched by Allow.
+	Deny []StatementMatcher
+}
+
+// WithStatementPolicy rejects the statements denied by the policy with
+// ErrStatementDenied before they reach the database, as a defense in depth
+// against code paths that should never run some kinds of statements:
+//
+//	ktx.WithStatementPolicy(ktx.StatementPolicy{
+//		Deny: []ktx.StatementMatcher{
+//			ktx.MatchDDL(),
+//			ktx.MatchKeywords("TRUNCATE"),
+//			ktx.MatchOtherSchemas("public"),
+//		},
+//	})
+//
+// The matchers work on the text of the statements, so they don't see
+// the statements executed by functions or procedures.
+func WithStatementPolicy(policy StatementPolicy) Option {
+	return WithMiddleware(func(next DBRunner) DBRunner {
+		return policyRunner{next: next, policy: policy}
+	})
+}
+
+// check returns ErrStatementDenied if the policy denies the query.
+func (p StatementPolicy) check(query string) error {
+	for _, m := range p.Deny {
+		if m.Match(query) {
+			return fmt.Errorf("%w: matches %s", ErrStatementDenied, m.Name)
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, m := range p.Allow {
+		if m.Match(query) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: not allowed", ErrStatementDenied)
+}
+
+// MatchKeywords matches the statements that start with any of the
+// keywords, case-insensitively, e.g. MatchKeywords("TRUNCATE", "GRANT").
+//
+// For WITH statements the keyword of the main statement
+// and of the data-modifying CTEs are also checked.
+func MatchKeywords(keywords ...string) StatementMatcher {
+	set := map[string]bool{}
+	for _, k := range keywords {
+		set[strings.ToLower(k)] = true
+	}
+
+	return StatementMatcher{
+		Name: strings.ToUpper(strings.Join(keywords, "/")),
+		Match: func(query string) bool {
+			_, ok := leadingKeyword(tokenize(query), set)
+			return ok
+		},
+	}
+}
+
+// MatchDDL matches the statements that change the schema:
+// CREATE, ALTER, DROP, RENAME and COMMENT.
+func MatchDDL() StatementMatcher {
+	m := MatchKeywords("CREATE", "ALTER", "DROP", "RENAME", "COMMENT")
+	m.Name = "DDL"
+	return m
+}
+
+// MatchOtherSchemas matches the statements that reference tables qualified
+// with a schema other than the allowed ones, e.g. `FROM billing.invoices`
+// with MatchOtherSchemas("public"). Unqualified tables are never matched.
+//
+// Only the tables following FROM, JOIN, INTO, UPDATE and TABLE are checked.
+func MatchOtherSchemas(allowed ...string) StatementMatcher {
+	set := map[string]bool{}
+	for _, s := range allowed {
+		set[strings.ToLower(s)] = true
+	}
+
+	return StatementMatcher{
+		Name: fmt.Sprintf("schema other than %s", strings.Join(allowed, ", ")),
+		Match: func(query string) bool {
+			tokens := tokenize(query)
+			for i := 0; i+3 < len(tokens); i++ {
+				if !tableKeywords[tokens[i].text] || tokens[i].kind != wordToken {
+					continue
+				}
+
+				schema, dot := tokens[i+1], tokens[i+2]
+				if schema.kind != wordToken || dot.text != "." {
+					continue
+				}
+				if !set[strings.ToLower(strings.Trim(schema.text, "\"`[]"))] {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+var tableKeywords = map[string]bool{
+	"from":   true,
+	"join":   true,
+	"into":   true,
+	"update": true,
+	"table":  true,
+}
+
+// MatchRegexp matches the statements matched by re.
+func MatchRegexp(re *regexp.Regexp) StatementMatcher {
+	return StatementMatcher{
+		Name:  re.String(),
+		Match: re.MatchString,
+	}
+}
+
+type policyRunner struct {
+	next   DBRunner
+	policy StatementPolicy
+}
+
+func (r policyRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := r.policy.check(query); err != nil {
+		return nil, err
+	}
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r policyRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := r.policy.check(query); err != nil {
+		return nil, err
+	}
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r policyRunner) Unwrap() DBRunner {
+	return r.next
+}
@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestStatementPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	policy := StatementPolicy{
+		Deny: []StatementMatcher{
+			MatchDDL(),
+			MatchKeywords("TRUNCATE"),
+			MatchOtherSchemas("main"),
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		policy  StatementPolicy
+		query   string
+		allowed bool
+	}{
+		{desc: "select", policy: policy, query: "SELECT COUNT(*) FROM users u WHERE u.name = 'John'", allowed: true},
+		{desc: "allowed schema", policy: policy, query: "SELECT COUNT(*) FROM main.users", allowed: true},
+		{desc: "DDL", policy: policy, query: "create index users_name ON users (name)"},
+		{desc: "truncate", policy: policy, query: "TRUNCATE TABLE users"},
+		{desc: "other schema", policy: policy, query: "SELECT COUNT(*) FROM users JOIN billing.invoices i ON i.user_id = users.id"},
+		{desc: "other quoted schema", policy: policy, query: `DELETE FROM "billing".invoices`},
+		{
+			desc:    "allowlist match",
+			policy:  StatementPolicy{Allow: []StatementMatcher{MatchKeywords("SELECT")}},
+			query:   "SELECT COUNT(*) FROM users",
+			allowed: true,
+		},
+		{
+			desc:   "allowlist miss",
+			policy: StatementPolicy{Allow: []StatementMatcher{MatchKeywords("SELECT")}},
+			query:  "DELETE FROM users",
+		},
+		{
+			desc:   "regexp",
+			policy: StatementPolicy{Deny: []StatementMatcher{MatchRegexp(regexp.MustCompile(`(?i)\bpg_sleep\b`))}},
+			query:  "SELECT pg_sleep(10)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := Run(ctx, db, func(tx *Tx) error {
+				rows, err := tx.QueryContext(ctx, test.query)
+				if err != nil {
+					return err
+				}
+				return rows.Close()
+			}, WithStatementPolicy(test.policy))
+			if test.allowed && err != nil {
+				t.Fatalf("expected the statement to be allowed, got: %v", err)
+			}
+			if !test.allowed && !errors.Is(err, ErrStatementDenied) {
+				t.Fatalf("expected ErrStatementDenied, got: %v", err)
+			}
+		})
+	}
+
+	t.Run("should reject denied statements executed with ExecContext", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE users")
+			return err
+		}, WithStatementPolicy(policy))
+		if !errors.Is(err, ErrStatementDenied) {
+			t.Fatalf("expected ErrStatementDenied, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+}
@@ -0,0 +1,72 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priority is the priority of a transaction on the databases that use
+// it for deciding which transactions to abort on contention.
+type Priority string
+
+// The priorities supported by CockroachDB.
+const (
+	PriorityLow    Priority = "LOW"
+	PriorityNormal Priority = "NORMAL"
+	PriorityHigh   Priority = "HIGH"
+)
+
+// PriorityDialect is implemented by the dialects
+// that support transaction priorities.
+type PriorityDialect interface {
+	Dialect
+
+	// PriorityStmt returns the statement that sets
+	// the priority of the current transaction.
+	PriorityStmt(p Priority) string
+}
+
+// CockroachDB is the dialect of CockroachDB, which is compatible with the
+// Postgres dialect, including its Name, so every helper that supports
+// Postgres also supports it, but also implements PriorityDialect.
+var CockroachDB Dialect = cockroachDialect{}
+
+type cockroachDialect struct {
+	postgresDialect
+}
+
+func (cockroachDialect) PriorityStmt(p Priority) string {
+	return "SET TRANSACTION PRIORITY " + string(p)
+}
+
+// WithPriority sets the priority of the transaction, so background jobs
+// can yield to the interactive traffic during contention:
+//
+//	err := ktx.Run(ctx, db, fn, ktx.WithDialect(ktx.CockroachDB), ktx.WithPriority(ktx.PriorityLow))
+//
+// It requires the transaction to be started WithDialect with a dialect that
+// implements PriorityDialect, such as CockroachDB, and is ignored otherwise,
+// so the same code can run on Postgres e.g. during tests.
+func WithPriority(p Priority) Option {
+	return func(c *config) {
+		c.priority = p
+	}
+}
+
+// setPriority sets the priority of the transaction on the database.
+func (tx *Tx) setPriority(ctx context.Context) error {
+	if tx.cfg.priority == "" {
+		return nil
+	}
+
+	dialect, ok := tx.cfg.dialect.(PriorityDialect)
+	if !ok {
+		return nil
+	}
+
+	_, err := tx.sqlTx.ExecContext(ctx, dialect.PriorityStmt(tx.cfg.priority))
+	if err != nil {
+		return fmt.Errorf("error setting the priority of the transaction to %s: %w", tx.cfg.priority, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestWithPriority(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		desc     string
+		dialect  Dialect
+		expected []string
+		priority Priority
+	}{
+		{
+			desc:     "should set the priority on CockroachDB",
+			dialect:  CockroachDB,
+			priority: PriorityLow,
+			expected: []string{"SET TRANSACTION PRIORITY LOW []", "SELECT 1 []"},
+		},
+		{
+			desc:     "should ignore the priority on Postgres",
+			dialect:  Postgres,
+			priority: PriorityHigh,
+			expected: []string{"SELECT 1 []"},
+		},
+		{
+			desc:     "should not set a priority by default",
+			dialect:  CockroachDB,
+			expected: []string{"SELECT 1 []"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fake := &procedureConnector{}
+			db := sql.OpenDB(fake)
+
+			opts := []Option{WithDialect(test.dialect)}
+			if test.priority != "" {
+				opts = append(opts, WithPriority(test.priority))
+			}
+			err := Run(ctx, db, func(tx *Tx) error {
+				_, err := tx.ExecContext(ctx, "SELECT 1")
+				return err
+			}, opts...)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if got := fake.statements(); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("expected statements %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Out marks an argument of Call as an OUT parameter of the procedure,
+// whose value is written to dest once the procedure returns.
+func Out(dest interface{}) sql.Out {
+	return sql.Out{Dest: dest}
+}
+
+// InOut marks an argument of Call as an INOUT parameter of the procedure,
+// the current value of dest is passed to the procedure and replaced by
+// the value it returns.
+func InOut(dest interface{}) sql.Out {
+	return sql.Out{Dest: dest, In: true}
+}
+
+// Call calls a stored procedure with the input arguments, writing the
+// values of the OUT parameters, marked with Out or InOut, to their
+// destinations:
+//
+//	var total int
+//	err := ktx.Call(ctx, tx, ktx.Postgres, "order_total", orderID, ktx.Out(&total))
+//
+// On Postgres the OUT values are read from the row returned by CALL,
+// on SQL Server and Oracle they are bound natively by the driver with
+// EXEC and an anonymous PL/SQL block respectively, and on MySQL they are
+// passed through session variables, so it must run inside a transaction.
+func Call(ctx context.Context, db DBRunner, dialect Dialect, procedure string, args ...interface{}) error {
+	if dialect == nil {
+		return fmt.Errorf("error calling procedure %s: the dialect is required", procedure)
+	}
+
+	var err error
+	switch dialect.Name() {
+	case Postgres.Name():
+		err = callPostgres(ctx, db, procedure, args)
+	case MySQL.Name():
+		err = callMySQL(ctx, db, procedure, args)
+	case SQLServer.Name():
+		err = callSQLServer(ctx, db, procedure, args)
+	case Oracle.Name():
+		err = callOracle(ctx, db, procedure, args)
+	default:
+		err = fmt.Errorf("stored procedures are not supported by the %s dialect", dialect.Name())
+	}
+	if err != nil {
+		return fmt.Errorf("error calling procedure %s: %w", procedure, err)
+	}
+	return nil
+}
+
+// callPostgres passes the OUT parameters as NULL, as required by
+// CALL, and scans the row returned with their values.
+func callPostgres(ctx context.Context, db DBRunner, procedure string, args []interface{}) error {
+	placeholders := make([]string, len(args))
+	values := make([]interface{}, len(args))
+	var dests []interface{}
+	for i, arg := range args {
+		placeholders[i] = Postgres.Placeholder(i)
+		values[i] = arg
+		if out, ok := arg.(sql.Out); ok {
+			values[i] = nil
+			if out.In {
+				values[i] = derefValue(out.Dest)
+			}
+			dests = append(dests, out.Dest)
+		}
+	}
+
+	query := fmt.Sprintf("CALL %s(%s)", procedure, strings.Join(placeholders, ", "))
+	if len(dests) == 0 {
+		_, err := db.ExecContext(ctx, query, values...)
+		return err
+	}
+
+	return scanRow(ctx, db, dests, query, values)
+}
+
+// callMySQL binds the OUT parameters to session
+// variables and selects them after the CALL.
+func callMySQL(ctx context.Context, db DBRunner, procedure string, args []interface{}) error {
+	placeholders := make([]string, len(args))
+	var values []interface{}
+	var variables []string
+	var dests []interface{}
+	for i, arg := range args {
+		out, ok := arg.(sql.Out)
+		if !ok {
+			placeholders[i] = "?"
+			values = append(values, arg)
+			continue
+		}
+
+		// The variables are only safe to use on the connection of a transaction:
+		if len(dests) == 0 {
+			_, err := TxFromRunner(db)
+			if err != nil {
+				return err
+			}
+		}
+
+		variable := fmt.Sprintf("@ktx_out_%d", i+1)
+		placeholders[i] = variable
+		variables = append(variables, variable)
+		dests = append(dests, out.Dest)
+
+		var initial interface{}
+		if out.In {
+			initial = derefValue(out.Dest)
+		}
+		_, err := db.ExecContext(ctx, "SET "+variable+" = ?", initial)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("CALL %s(%s)", procedure, strings.Join(placeholders, ", ")), values...)
+	if err != nil {
+		return err
+	}
+
+	if len(dests) == 0 {
+		return nil
+	}
+
+	return scanRow(ctx, db, dests, "SELECT "+strings.Join(variables, ", "), nil)
+}
+
+// callSQLServer marks the OUT parameters with the OUTPUT keyword
+// and lets the driver write their values to the destinations.
+func callSQLServer(ctx context.Context, db DBRunner, procedure string, args []interface{}) error {
+	params := make([]string, len(args))
+	for i, arg := range args {
+		params[i] = SQLServer.Placeholder(i)
+		if _, ok := arg.(sql.Out); ok {
+			params[i] += " OUTPUT"
+		}
+	}
+
+	query := "EXEC " + procedure
+	if len(params) > 0 {
+		query += " " + strings.Join(params, ", ")
+	}
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// callOracle calls the procedure inside an anonymous PL/SQL
+// block and lets the driver write the OUT values to the destinations.
+func callOracle(ctx context.Context, db DBRunner, procedure string, args []interface{}) error {
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = Oracle.Placeholder(i)
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("BEGIN %s(%s); END;", procedure, strings.Join(placeholders, ", ")), args...)
+	return err
+}
+
+// scanRow scans the first row returned by the query into dests.
+func scanRow(ctx context.Context, db Queryer, dests []interface{}, query string, args []interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return errors.New("no OUT values returned")
+	}
+
+	err = rows.Scan(dests...)
+	if err != nil {
+		return fmt.Errorf("error scanning OUT values: %w", err)
+	}
+
+	return rows.Close()
+}
+
+// derefValue returns the value pointed by dest.
+func derefValue(dest interface{}) interface{} {
+	return reflect.ValueOf(dest).Elem().Interface()
+}
@@ -0,0 +1,225 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should read the OUT values from the row returned by CALL on postgres", func(t *testing.T) {
+		fake := &procedureConnector{row: []driver.Value{int64(42), "updated"}}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		var total int
+		status := "pending"
+		err := Call(ctx, db, Postgres, "order_total", 7, Out(&total), InOut(&status))
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+
+		if total != 42 || status != "updated" {
+			t.Errorf("unexpected OUT values: %d, %s", total, status)
+		}
+		expected := []string{"CALL order_total($1, $2, $3) [7 <nil> pending]"}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should pass the OUT values through session variables on mysql", func(t *testing.T) {
+		fake := &procedureConnector{row: []driver.Value{int64(42), "updated"}}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		var total int
+		status := "pending"
+		err := Run(ctx, db, func(tx *Tx) error {
+			return Call(ctx, tx, MySQL, "order_total", 7, Out(&total), InOut(&status))
+		})
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+
+		if total != 42 || status != "updated" {
+			t.Errorf("unexpected OUT values: %d, %s", total, status)
+		}
+		expected := []string{
+			"SET @ktx_out_2 = ? [<nil>]",
+			"SET @ktx_out_3 = ? [pending]",
+			"CALL order_total(?, @ktx_out_2, @ktx_out_3) [7]",
+			"SELECT @ktx_out_2, @ktx_out_3 []",
+		}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should mark the OUT parameters with EXEC on sqlserver", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		var total int
+		err := Call(ctx, db, SQLServer, "order_total", 7, Out(&total))
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+
+		expected := []string{fmt.Sprintf("EXEC order_total @p1, @p2 OUTPUT [7 %v]", Out(&total))}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should bind the OUT parameters in a PL/SQL block on oracle", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		var total int
+		status := "pending"
+		err := Call(ctx, db, Oracle, "order_total", 7, Out(&total), InOut(&status))
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+
+		expected := []string{fmt.Sprintf("BEGIN order_total(:1, :2, :3); END; [7 %v %v]", Out(&total), InOut(&status))}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should require a transaction for OUT parameters on mysql", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{})
+		defer func() { _ = db.Close() }()
+
+		var total int
+		err := Call(ctx, db, MySQL, "order_total", Out(&total))
+		if !errors.Is(err, ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+
+	t.Run("should report unsupported dialects", func(t *testing.T) {
+		db := sql.OpenDB(&procedureConnector{})
+		defer func() { _ = db.Close() }()
+
+		err := Call(ctx, db, SQLite, "order_total")
+		if err == nil {
+			t.Errorf("expected an error for SQLite")
+		}
+	})
+
+	t.Run("should report a nil dialect", func(t *testing.T) {
+		conn := &procedureConnector{}
+		db := sql.OpenDB(conn)
+		defer func() { _ = db.Close() }()
+
+		err := Call(ctx, db, nil, "order_total")
+		if err == nil {
+			t.Errorf("expected an error for a nil dialect")
+		}
+		if len(conn.stmts) != 0 {
+			t.Errorf("expected no statements, got: %v", conn.stmts)
+		}
+	})
+}
+
+// procedureConnector records the statements it receives
+// and returns row as the result of every query.
+type procedureConnector struct {
+	row []driver.Value
+
+	// hang blocks Commit and Rollback until it is closed, if not nil.
+	hang chan struct{}
+
+	// commitErr is returned by Commit, if not nil.
+	commitErr error
+
+	mu    sync.Mutex
+	stmts []string
+}
+
+func (c *procedureConnector) Connect(context.Context) (driver.Conn, error) {
+	return procedureConn{c}, nil
+}
+
+func (c *procedureConnector) Driver() driver.Driver { return nil }
+
+func (c *procedureConnector) record(query string, args []driver.NamedValue) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stmts = append(c.stmts, fmt.Sprintf("%s %v", query, values))
+}
+
+func (c *procedureConnector) statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.stmts...)
+}
+
+type procedureConn struct {
+	c *procedureConnector
+}
+
+func (procedureConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (procedureConn) Close() error                        { return nil }
+func (c procedureConn) Begin() (driver.Tx, error)         { return c, nil }
+
+func (c procedureConn) Commit() error {
+	if c.c.hang != nil {
+		<-c.c.hang
+	}
+	return c.c.commitErr
+}
+
+func (c procedureConn) Rollback() error {
+	if c.c.hang != nil {
+		<-c.c.hang
+	}
+	return nil
+}
+
+func (procedureConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c procedureConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.c.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (c procedureConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.c.record(query, args)
+	return &procedureRows{row: c.c.row}, nil
+}
+
+type procedureRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *procedureRows) Columns() []string { return make([]string, len(r.row)) }
+func (r *procedureRows) Close() error      { return nil }
+
+func (r *procedureRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.done = true
+	return nil
+}
@@ -0,0 +1,175 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned instead of starting a transaction
+// when its tenant has exceeded one of the limits of its Quota.
+var ErrQuotaExceeded = errors.New("transaction quota exceeded")
+
+// QuotaLimits are the limits of the transactions of a tenant,
+// where zero means unlimited.
+type QuotaLimits struct {
+	// MaxConcurrent is the maximum number of open transactions.
+	MaxConcurrent int
+
+	// PerSecond is the rate of transactions that can be started per
+	// second, and Burst how many can be started at once, which
+	// defaults to the rate rounded up.
+	PerSecond float64
+	Burst     int
+}
+
+// QuotaOptions configures a Quota.
+type QuotaOptions struct {
+	// Tenant extracts the tenant of the transaction from its context,
+	// the transactions without a tenant are not limited.
+	//
+	// The context also carries the metadata set with WithMetadata, and it
+	// defaults to reading the "tenant" key of the metadata.
+	Tenant func(ctx context.Context) (tenant string, ok bool)
+
+	// Default are the limits of the tenants that are not in Overrides.
+	Default QuotaLimits
+
+	// Overrides are the limits of specific tenants.
+	Overrides map[string]QuotaLimits
+}
+
+// Quota limits the concurrency and the rate of the transactions of each
+// tenant, so one tenant's batch job can't starve the shared database.
+// The transactions over the limits fail with an error wrapping
+// ErrQuotaExceeded without waiting.
+//
+// The same Quota should be shared by all the transactions on a database.
+type Quota struct {
+	opts QuotaOptions
+
+	mu      sync.Mutex
+	tenants map[string]*tenantQuota
+	sweepAt int
+}
+
+type tenantQuota struct {
+	active int
+	tokens float64
+	last   time.Time
+}
+
+// NewQuota creates a Quota, whose Option must be
+// passed to the transactions it limits.
+func NewQuota(opts QuotaOptions) *Quota {
+	if opts.Tenant == nil {
+		opts.Tenant = func(ctx context.Context) (string, bool) {
+			tenant, ok := MetadataFromContext(ctx, "tenant")
+			if !ok {
+				return "", false
+			}
+			return fmt.Sprint(tenant), true
+		}
+	}
+
+	return &Quota{
+		opts:    opts,
+		tenants: map[string]*tenantQuota{},
+		sweepAt: 64,
+	}
+}
+
+// Option returns the Option that limits a transaction with the quota.
+func (q *Quota) Option() Option {
+	return func(c *config) {
+		c.quota = q
+	}
+}
+
+// acquire reserves a slot for a transaction of the tenant of ctx,
+// returning the function that releases it.
+func (q *Quota) acquire(ctx context.Context) (release func(), err error) {
+	tenant, ok := q.opts.Tenant(ctx)
+	if !ok {
+		return func() {}, nil
+	}
+
+	limits := q.limits(tenant)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	t, ok := q.tenants[tenant]
+	if !ok {
+		if len(q.tenants) >= q.sweepAt {
+			q.sweep(now)
+		}
+
+		t = &tenantQuota{
+			tokens: float64(burst(limits)),
+			last:   now,
+		}
+		q.tenants[tenant] = t
+	}
+
+	if limits.MaxConcurrent > 0 && t.active >= limits.MaxConcurrent {
+		return nil, fmt.Errorf("%w: tenant '%s' has %d open transactions", ErrQuotaExceeded, tenant, t.active)
+	}
+
+	if limits.PerSecond > 0 {
+		t.tokens = min(t.tokens+now.Sub(t.last).Seconds()*limits.PerSecond, float64(burst(limits)))
+		t.last = now
+		if t.tokens < 1 {
+			return nil, fmt.Errorf("%w: tenant '%s' exceeded %g transactions per second", ErrQuotaExceeded, tenant, limits.PerSecond)
+		}
+		t.tokens--
+	}
+
+	t.active++
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		t.active--
+	}, nil
+}
+
+// limits returns the limits of the tenant.
+func (q *Quota) limits(tenant string) QuotaLimits {
+	limits, ok := q.opts.Overrides[tenant]
+	if !ok {
+		limits = q.opts.Default
+	}
+	return limits
+}
+
+// sweep deletes the tenants without open transactions whose buckets are
+// full, which are in the same state as the tenants that were never seen,
+// so services with many tenants don't keep all of them in memory.
+func (q *Quota) sweep(now time.Time) {
+	for tenant, t := range q.tenants {
+		if t.active > 0 {
+			continue
+		}
+
+		limits := q.limits(tenant)
+		if limits.PerSecond > 0 && t.tokens+now.Sub(t.last).Seconds()*limits.PerSecond < float64(burst(limits)) {
+			continue
+		}
+		delete(q.tenants, tenant)
+	}
+	q.sweepAt = max(64, 2*len(q.tenants))
+}
+
+func burst(limits QuotaLimits) int {
+	if limits.Burst > 0 {
+		return limits.Burst
+	}
+	b := int(limits.PerSecond)
+	if float64(b) < limits.PerSecond {
+		b++
+	}
+	return b
+}
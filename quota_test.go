@@ -0,0 +1,129 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	acme := ContextWithMetadata(context.Background(), "tenant", "acme")
+	globex := ContextWithMetadata(context.Background(), "tenant", "globex")
+	noop := func(tx *Tx) error { return nil }
+
+	t.Run("should limit the concurrent transactions of each tenant", func(t *testing.T) {
+		quota := NewQuota(QuotaOptions{
+			Default: QuotaLimits{MaxConcurrent: 1},
+		})
+
+		err := Run(acme, db, func(tx *Tx) error {
+			err := Run(acme, db, noop, quota.Option())
+			if !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("expected ErrQuotaExceeded, got: %v", err)
+			}
+
+			// Other tenants are not affected:
+			return Run(globex, db, noop, quota.Option())
+		}, quota.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		// The slot is released once the transaction finishes:
+		err = Run(acme, db, noop, quota.Option())
+		if err != nil {
+			t.Errorf("expected the slot to be released, got: %v", err)
+		}
+	})
+
+	t.Run("should limit the rate of transactions of each tenant", func(t *testing.T) {
+		quota := NewQuota(QuotaOptions{
+			Default: QuotaLimits{PerSecond: 0.001, Burst: 2},
+			Overrides: map[string]QuotaLimits{
+				"globex": {},
+			},
+		})
+
+		for i := 0; i < 2; i++ {
+			err := Run(acme, db, noop, quota.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+		err := Run(acme, db, noop, quota.Option())
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("expected ErrQuotaExceeded, got: %v", err)
+		}
+
+		for i := 0; i < 5; i++ {
+			err := Run(globex, db, noop, quota.Option())
+			if err != nil {
+				t.Fatalf("expected the override to be unlimited, got: %v", err)
+			}
+		}
+	})
+
+	t.Run("should read the tenant set with WithMetadata", func(t *testing.T) {
+		quota := NewQuota(QuotaOptions{
+			Default: QuotaLimits{MaxConcurrent: 1},
+		})
+
+		err := Run(context.Background(), db, func(tx *Tx) error {
+			err := Run(acme, db, noop, quota.Option())
+			if !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("expected ErrQuotaExceeded, got: %v", err)
+			}
+			return nil
+		}, WithMetadata("tenant", "acme"), quota.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("should forget the idle tenants with full buckets", func(t *testing.T) {
+		quota := NewQuota(QuotaOptions{
+			Default: QuotaLimits{MaxConcurrent: 1},
+			Overrides: map[string]QuotaLimits{
+				"acme": {PerSecond: 0.001},
+			},
+		})
+
+		err := Run(acme, db, noop, quota.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			ctx := ContextWithMetadata(context.Background(), "tenant", fmt.Sprint("tenant-", i))
+			err := Run(ctx, db, noop, quota.Option())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		}
+
+		quota.mu.Lock()
+		defer quota.mu.Unlock()
+		if len(quota.tenants) > 64 {
+			t.Errorf("expected the idle tenants to be forgotten, got %d tenants", len(quota.tenants))
+		}
+		if _, ok := quota.tenants["acme"]; !ok {
+			t.Errorf("expected the tenant with an empty bucket to be kept")
+		}
+	})
+
+	t.Run("should not limit transactions without a tenant", func(t *testing.T) {
+		quota := NewQuota(QuotaOptions{
+			Default: QuotaLimits{MaxConcurrent: 1},
+		})
+
+		err := Run(context.Background(), db, func(tx *Tx) error {
+			return Run(context.Background(), db, noop, quota.Option())
+		}, quota.Option())
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+}
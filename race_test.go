@@ -0,0 +1,7 @@
+//go:build race
+
+package ktx
+
+// raceEnabled skips the allocation tests that depend on sync.Pool,
+// which randomly drops items when the race detector is enabled.
+const raceEnabled = true
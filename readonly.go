@@ -0,0 +1,260 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPrimaryUnavailable is returned by ReadOnlyFallback for the
+// transactions that need to write while the primary is down.
+var ErrPrimaryUnavailable = errors.New("the primary database is unavailable")
+
+// ErrWriteInReadOnly is returned by the statements that write inside
+// transactions started WithReadOnlyGuard.
+var ErrWriteInReadOnly = errors.New("write statement in read-only transaction")
+
+// WithReadOnly starts the transaction in read-only mode, which lets the
+// database reject writes and lets ReadOnlyFallback serve it from a replica.
+//
+// If the transaction executes no statements with ExecContext it is rolled
+// back instead of committed, which saves the work of the commit on the
+// database and is reported by TxStats.CommitSkipped and SkippedCommits.
+// This is transparent to the caller: the transaction still goes through
+// the AfterCommit callbacks and the OnCommit hooks.
+func WithReadOnly() Option {
+	return func(c *config) {
+		c.readOnly = true
+	}
+}
+
+// WithIsolation starts the transaction with the input isolation level,
+// instead of the default level of the database.
+func WithIsolation(level sql.IsolationLevel) Option {
+	return func(c *config) {
+		c.isolation = level
+	}
+}
+
+// WithReadOnlyGuard starts the transaction WithReadOnly and also rejects
+// the INSERT, UPDATE, DELETE and DDL statements executed through it with
+// ErrWriteInReadOnly before they reach the driver, which gives clearer
+// failures on the databases and drivers that don't enforce read-only
+// transactions strictly, such as SQLite.
+//
+// Writes done by functions or procedures called by a SELECT are
+// not detected and are left for the database to reject.
+func WithReadOnlyGuard() Option {
+	return func(c *config) {
+		c.readOnly = true
+		c.readOnlyGuard = true
+	}
+}
+
+// skippedCommits counts the commits replaced by rollbacks
+// on the read-only transactions that executed nothing.
+var skippedCommits atomic.Uint64
+
+// SkippedCommits returns how many transactions started WithReadOnly were
+// rolled back instead of committed since the process started, because
+// they executed no statements with ExecContext.
+func SkippedCommits() uint64 {
+	return skippedCommits.Load()
+}
+
+// canSkipCommit reports whether rolling back the transaction has the same
+// effect as committing it. Only the Execs are considered, since the writes
+// executed with QueryContext, e.g. with RETURNING, are already rejected by
+// the database on read-only transactions.
+func (tx *Tx) canSkipCommit() bool {
+	if !tx.cfg.readOnly {
+		return false
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.stats.Execs == 0
+}
+
+// skipCommit finishes the transaction with a rollback in place of its commit.
+func (tx *Tx) skipCommit(ctx context.Context) error {
+	err := tx.rollback(ctx)
+	if err != nil {
+		return fmt.Errorf("error rolling back read-only transaction instead of committing: %w", err)
+	}
+
+	tx.mu.Lock()
+	tx.stats.CommitSkipped = true
+	tx.mu.Unlock()
+
+	skippedCommits.Add(1)
+	return nil
+}
+
+// ReadOnlyFallbackOptions configures a ReadOnlyFallback.
+type ReadOnlyFallbackOptions struct {
+	// RecheckInterval is how long the primary is considered down after it
+	// fails to start a transaction, before it is tried again, defaults to 5 seconds.
+	RecheckInterval time.Duration
+}
+
+// ReadOnlyFallback is a TxBeginner that starts transactions on the primary
+// database and, while it is down, transparently starts the ones marked
+// WithReadOnly on a replica, failing the others fast with an error wrapping
+// ErrPrimaryUnavailable, which keeps read-heavy applications available
+// during outages of the primary:
+//
+//	db := ktx.NewReadOnlyFallback(primary, replica, ktx.ReadOnlyFallbackOptions{})
+//	err := ktx.Run(ctx, db, listOrders, ktx.WithReadOnly())
+//
+// The statements executed outside of transactions always go to the primary.
+type ReadOnlyFallback struct {
+	primary TxBeginner
+	replica TxBeginner
+	opts    ReadOnlyFallbackOptions
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// NewReadOnlyFallback creates a ReadOnlyFallback.
+func NewReadOnlyFallback(primary TxBeginner, replica TxBeginner, opts ReadOnlyFallbackOptions) *ReadOnlyFallback {
+	if opts.RecheckInterval <= 0 {
+		opts.RecheckInterval = 5 * time.Second
+	}
+
+	return &ReadOnlyFallback{
+		primary: primary,
+		replica: replica,
+		opts:    opts,
+	}
+}
+
+// PrimaryDown reports whether the primary is currently considered down.
+func (f *ReadOnlyFallback) PrimaryDown() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return time.Now().Before(f.downUntil)
+}
+
+// BeginTx starts the transaction on the primary, or on the replica if
+// the primary is down and the transaction is read-only.
+func (f *ReadOnlyFallback) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	readOnly := opts != nil && opts.ReadOnly
+
+	if f.PrimaryDown() {
+		if !readOnly {
+			return nil, ErrPrimaryUnavailable
+		}
+		return f.replica.BeginTx(ctx, opts)
+	}
+
+	sqlTx, err := f.primary.BeginTx(ctx, opts)
+	if err == nil || ctx.Err() != nil {
+		return sqlTx, err
+	}
+
+	f.mu.Lock()
+	f.downUntil = time.Now().Add(f.opts.RecheckInterval)
+	f.mu.Unlock()
+
+	if !readOnly {
+		return nil, fmt.Errorf("%w: %w", ErrPrimaryUnavailable, err)
+	}
+	return f.replica.BeginTx(ctx, opts)
+}
+
+// ExecContext executes the statement on the primary.
+func (f *ReadOnlyFallback) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return f.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes the query on the primary.
+func (f *ReadOnlyFallback) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return f.primary.QueryContext(ctx, query, args...)
+}
+
+type readOnlyGuardRunner struct {
+	next DBRunner
+}
+
+func (r readOnlyGuardRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if keyword, ok := writeKeyword(query); ok {
+		return nil, fmt.Errorf("%w: %s", ErrWriteInReadOnly, strings.ToUpper(keyword))
+	}
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r readOnlyGuardRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	// Writes with a RETURNING clause are executed as queries:
+	if keyword, ok := writeKeyword(query); ok {
+		return nil, fmt.Errorf("%w: %s", ErrWriteInReadOnly, strings.ToUpper(keyword))
+	}
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r readOnlyGuardRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+// writeKeyword returns the keyword that makes the statement a write, if
+// it starts with one, or if it is a WITH whose CTEs or main statement
+// modify data.
+func writeKeyword(query string) (keyword string, ok bool) {
+	tokens := tokenize(query)
+	if len(tokens) > 0 && tokens[0].text == "with" {
+		return leadingKeyword(tokens, dataModifyingKeywords)
+	}
+	return leadingKeyword(tokens, writeKeywords)
+}
+
+// leadingKeyword returns the first word of the statement if it is one of
+// the keywords, or for WITH statements the first of the keywords found
+// on its CTEs or main statement.
+func leadingKeyword(tokens []token, keywords map[string]bool) (keyword string, ok bool) {
+	if len(tokens) == 0 {
+		return "", false
+	}
+
+	if tokens[0].text != "with" {
+		return tokens[0].text, keywords[tokens[0].text]
+	}
+
+	for _, tok := range tokens[1:] {
+		if tok.kind == wordToken && keywords[tok.text] {
+			return tok.text, true
+		}
+	}
+	return "", false
+}
+
+var dataModifyingKeywords = map[string]bool{
+	"insert": true,
+	"update": true,
+	"delete": true,
+	"merge":  true,
+}
+
+var writeKeywords = map[string]bool{
+	"insert":   true,
+	"update":   true,
+	"delete":   true,
+	"merge":    true,
+	"replace":  true,
+	"upsert":   true,
+	"truncate": true,
+	"create":   true,
+	"alter":    true,
+	"drop":     true,
+	"rename":   true,
+	"comment":  true,
+	"grant":    true,
+	"revoke":   true,
+}
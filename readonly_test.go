@@ -0,0 +1,198 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyFallback(t *testing.T) {
+	ctx := context.Background()
+
+	primaryDB := setupTestDB(t)
+	defer func() { _ = primaryDB.Close() }()
+	primary := &flakyDB{DB: primaryDB}
+
+	replica := setupTestDB(t)
+	defer func() { _ = replica.Close() }()
+	_, err := replica.Exec("INSERT INTO users (name, email) VALUES ('Replica', 'replica@example.com')")
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	db := NewReadOnlyFallback(primary, replica, ReadOnlyFallbackOptions{})
+
+	countUsers := func(tx *Tx) (count int, err error) {
+		err = queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+		return count, err
+	}
+
+	t.Run("should use the primary while it is up", func(t *testing.T) {
+		var count int
+		err := Run(ctx, db, func(tx *Tx) (err error) {
+			count, err = countUsers(tx)
+			return err
+		}, WithReadOnly())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected to read from the primary, got %d users", count)
+		}
+	})
+
+	primary.down = true
+
+	t.Run("should serve read-only transactions from the replica", func(t *testing.T) {
+		var count int
+		err := Run(ctx, db, func(tx *Tx) (err error) {
+			count, err = countUsers(tx)
+			return err
+		}, WithReadOnly())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("expected to read from the replica, got %d users", count)
+		}
+		if !db.PrimaryDown() {
+			t.Error("expected the primary to be considered down")
+		}
+	})
+
+	t.Run("should fail writes fast", func(t *testing.T) {
+		called := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, ErrPrimaryUnavailable) {
+			t.Errorf("expected ErrPrimaryUnavailable, got: %v", err)
+		}
+		if called {
+			t.Error("the callback should not be called")
+		}
+	})
+}
+
+func TestReadOnlyGuard(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	tests := []struct {
+		desc    string
+		query   string
+		allowed bool
+	}{
+		{desc: "select", query: "SELECT COUNT(*) FROM users", allowed: true},
+		{desc: "read-only CTE", query: "WITH u AS (SELECT * FROM users) SELECT COUNT(*) FROM u", allowed: true},
+		{desc: "quoted keyword", query: `SELECT COUNT(*) AS "delete" FROM users WHERE name = 'update'`, allowed: true},
+		{desc: "insert", query: "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')"},
+		{desc: "update after a comment", query: "/* job */ update users SET name = 'Jane'"},
+		{desc: "delete", query: "DELETE FROM users"},
+		{desc: "data-modifying CTE", query: "WITH d AS (SELECT id FROM users) DELETE FROM users WHERE id IN (SELECT id FROM d)"},
+		{desc: "DDL", query: "DROP TABLE users"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := Run(ctx, db, func(tx *Tx) error {
+				rows, err := tx.QueryContext(ctx, test.query)
+				if err != nil {
+					return err
+				}
+				return rows.Close()
+			}, WithReadOnlyGuard())
+			if test.allowed && err != nil {
+				t.Fatalf("expected the statement to be allowed, got: %v", err)
+			}
+			if !test.allowed && !errors.Is(err, ErrWriteInReadOnly) {
+				t.Fatalf("expected ErrWriteInReadOnly, got: %v", err)
+			}
+		})
+	}
+
+	t.Run("should reject writes executed with ExecContext", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			return err
+		}, WithReadOnlyGuard())
+		if !errors.Is(err, ErrWriteInReadOnly) {
+			t.Fatalf("expected ErrWriteInReadOnly, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+}
+
+func TestReadOnlyCommitSkipping(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	t.Run("should roll back read-only transactions that executed nothing", func(t *testing.T) {
+		before := SkippedCommits()
+
+		var stats TxStats
+		committed := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			var count int
+			return queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+		}, WithReadOnly(), WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				committed = true
+				stats = tx.Stats()
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if !committed {
+			t.Errorf("expected the OnCommit hook to be called")
+		}
+		if !stats.CommitSkipped || stats.Execs != 0 || stats.Statements != 1 {
+			t.Errorf("expected the commit to be skipped, got: %+v", stats)
+		}
+		if SkippedCommits() != before+1 {
+			t.Errorf("expected SkippedCommits to be incremented, got %d after %d", SkippedCommits(), before)
+		}
+	})
+
+	t.Run("should commit read-only transactions that executed statements", func(t *testing.T) {
+		var stats TxStats
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "SELECT 1")
+			return err
+		}, WithReadOnly(), WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				stats = tx.Stats()
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if stats.CommitSkipped || stats.Execs != 1 {
+			t.Errorf("expected the transaction to be committed, got: %+v", stats)
+		}
+	})
+
+	t.Run("should commit the transactions that are not read-only", func(t *testing.T) {
+		var stats TxStats
+		err := Run(ctx, db, func(tx *Tx) error {
+			var count int
+			return queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+		}, WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				stats = tx.Stats()
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if stats.CommitSkipped {
+			t.Errorf("expected the transaction to be committed")
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrRecordNotFound is returned by the Repo methods when no row
+// matches the input ID.
+var ErrRecordNotFound = errors.New("record not found")
+
+// Repo implements basic CRUD operations for the struct type T
+// on a single table.
+//
+// The columns are mapped from the fields of T tagged with `ktx:"column_name"`,
+// fields without this tag are ignored:
+//
+//	type User struct {
+//		ID    int    `ktx:"id"`
+//		Name  string `ktx:"name"`
+//		Email string `ktx:"email"`
+//	}
+//
+//	users, err := ktx.NewRepo[User](ktx.Postgres, "users", "id")
+//
+// All methods receive the DBRunner they should use, so they can run
+// inside ktx transactions or directly on the database.
+type Repo[T any] struct {
+	dialect  Dialect
+	table    string
+	idColumn string
+	info     *structInfo
+
+	// The queries are built once by NewRepo since the
+	// methods are often called in loops with many rows:
+	insertQuery          string
+	insertGeneratedQuery string
+	insertReturningQuery string
+	updateQuery          string
+	deleteQuery          string
+	getQuery             string
+	existsQuery          string
+}
+
+// argsPool reuses the argument slices of the Repo methods
+// so calling them in loops doesn't allocate one per row.
+var argsPool = sync.Pool{
+	New: func() interface{} {
+		return new([]interface{})
+	},
+}
+
+func getArgs() *[]interface{} {
+	return argsPool.Get().(*[]interface{})
+}
+
+func putArgs(args *[]interface{}) {
+	// The values are cleared so the pool doesn't keep them alive:
+	clear(*args)
+	*args = (*args)[:0]
+	argsPool.Put(args)
+}
+
+// NewRepo creates a Repo for the input table whose primary key is idColumn.
+func NewRepo[T any](dialect Dialect, table string, idColumn string) (*Repo[T], error) {
+	info, err := getStructInfo(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := info.byColumn[idColumn]; !found {
+		return nil, fmt.Errorf("the ID column '%s' was not found on the tags of %T", idColumn, *new(T))
+	}
+
+	r := &Repo[T]{
+		dialect:  dialect,
+		table:    table,
+		idColumn: idColumn,
+		info:     info,
+	}
+	r.buildQueries()
+	return r, nil
+}
+
+func (r *Repo[T]) buildQueries() {
+	quotedTable := r.dialect.Quote(r.table)
+	quotedID := r.dialect.Quote(r.idColumn)
+
+	buildInsert := func(skipID bool) string {
+		var columns, placeholders []string
+		for _, column := range r.info.columns {
+			if skipID && column == r.idColumn {
+				continue
+			}
+			columns = append(columns, r.dialect.Quote(column))
+			placeholders = append(placeholders, r.dialect.Placeholder(len(placeholders)))
+		}
+		return fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			quotedTable,
+			strings.Join(columns, ", "),
+			strings.Join(placeholders, ", "),
+		)
+	}
+	r.insertQuery = buildInsert(false)
+	r.insertGeneratedQuery = buildInsert(true)
+	// MySQL and Oracle can't return the ID as a row, see execReturningID:
+	if r.dialect.Name() != MySQL.Name() && r.dialect.Name() != Oracle.Name() {
+		r.insertReturningQuery = returningQuery(r.dialect, r.insertGeneratedQuery, []string{quotedID})
+	}
+
+	var sets []string
+	for _, column := range r.info.columns {
+		if column == r.idColumn {
+			continue
+		}
+		sets = append(sets, r.dialect.Quote(column)+" = "+r.dialect.Placeholder(len(sets)))
+	}
+	// Types tagged only with the ID column have nothing to update:
+	if len(sets) > 0 {
+		r.updateQuery = fmt.Sprintf(
+			"UPDATE %s SET %s WHERE %s = %s",
+			quotedTable,
+			strings.Join(sets, ", "),
+			quotedID,
+			r.dialect.Placeholder(len(sets)),
+		)
+	}
+
+	r.deleteQuery = fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = %s",
+		quotedTable,
+		quotedID,
+		r.dialect.Placeholder(0),
+	)
+
+	columns := make([]string, len(r.info.columns))
+	for i, column := range r.info.columns {
+		columns[i] = r.dialect.Quote(column)
+	}
+	r.getQuery = fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = %s",
+		strings.Join(columns, ", "),
+		quotedTable,
+		quotedID,
+		r.dialect.Placeholder(0),
+	)
+
+	r.existsQuery = fmt.Sprintf(
+		"SELECT 1 FROM %s WHERE %s = %s",
+		quotedTable,
+		quotedID,
+		r.dialect.Placeholder(0),
+	)
+}
+
+// Insert inserts the record on the table.
+//
+// If the ID field of the record is set to its zero value it is omitted
+// from the INSERT so the database can generate it, and the generated ID
+// is written back to the record.
+func (r *Repo[T]) Insert(ctx context.Context, db DBRunner, record *T) error {
+	v := reflect.ValueOf(record).Elem()
+	idField := v.Field(r.info.byColumn[r.idColumn])
+	generateID := idField.IsZero()
+
+	args := getArgs()
+	defer putArgs(args)
+	for i, column := range r.info.columns {
+		if generateID && column == r.idColumn {
+			continue
+		}
+		*args = append(*args, v.Field(r.info.fieldIdx[i]).Interface())
+	}
+
+	if !generateID {
+		_, err := db.ExecContext(ctx, r.insertQuery, *args...)
+		if err != nil {
+			return fmt.Errorf("error inserting record on table '%s': %w", r.table, err)
+		}
+		return nil
+	}
+
+	var err error
+	if r.insertReturningQuery != "" {
+		err = queryReturnedID(ctx, db, idField.Addr().Interface(), r.insertReturningQuery, *args)
+	} else {
+		err = execReturningID(ctx, db, r.dialect, r.idColumn, idField.Addr().Interface(), r.insertGeneratedQuery, *args)
+	}
+	if err != nil {
+		return fmt.Errorf("error inserting record on table '%s': %w", r.table, err)
+	}
+
+	return nil
+}
+
+// Update updates all the columns of the row with the same ID as the record.
+//
+// ErrRecordNotFound is returned if no row has this ID. On MySQL, which
+// doesn't count the rows whose values didn't change as affected, the
+// existence of the row is then checked with a query.
+func (r *Repo[T]) Update(ctx context.Context, db DBRunner, record *T) error {
+	if r.updateQuery == "" {
+		return fmt.Errorf("error updating record on table '%s': %T has no columns besides the ID column '%s'", r.table, *record, r.idColumn)
+	}
+
+	v := reflect.ValueOf(record).Elem()
+
+	args := getArgs()
+	defer putArgs(args)
+	for i, column := range r.info.columns {
+		if column == r.idColumn {
+			continue
+		}
+		*args = append(*args, v.Field(r.info.fieldIdx[i]).Interface())
+	}
+	id := v.Field(r.info.byColumn[r.idColumn]).Interface()
+	*args = append(*args, id)
+
+	result, err := db.ExecContext(ctx, r.updateQuery, *args...)
+	if err != nil {
+		return fmt.Errorf("error updating record on table '%s': %w", r.table, err)
+	}
+
+	err = checkRowsAffected(result, r.table)
+	if errors.Is(err, ErrRecordNotFound) && r.dialect.Name() == MySQL.Name() {
+		// Unless the connection sets the CLIENT_FOUND_ROWS flag:
+		return r.checkExists(ctx, db, id)
+	}
+	return err
+}
+
+// checkExists returns ErrRecordNotFound if no row has the input ID.
+func (r *Repo[T]) checkExists(ctx context.Context, db Queryer, id interface{}) error {
+	rows, err := db.QueryContext(ctx, r.existsQuery, id)
+	if err != nil {
+		return fmt.Errorf("error reading record from table '%s': %w", r.table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return fmt.Errorf("error reading record from table '%s': %w", r.table, rows.Err())
+		}
+		return ErrRecordNotFound
+	}
+	return rows.Close()
+}
+
+// Delete deletes the row with the input ID.
+//
+// ErrRecordNotFound is returned if no row has this ID.
+func (r *Repo[T]) Delete(ctx context.Context, db Execer, id interface{}) error {
+	result, err := db.ExecContext(ctx, r.deleteQuery, id)
+	if err != nil {
+		return fmt.Errorf("error deleting record from table '%s': %w", r.table, err)
+	}
+
+	return checkRowsAffected(result, r.table)
+}
+
+// GetByID reads the row with the input ID.
+//
+// ErrRecordNotFound is returned if no row has this ID.
+func (r *Repo[T]) GetByID(ctx context.Context, db Queryer, id interface{}) (record T, err error) {
+	rows, err := db.QueryContext(ctx, r.getQuery, id)
+	if err != nil {
+		return record, fmt.Errorf("error reading record from table '%s': %w", r.table, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return record, fmt.Errorf("error reading record from table '%s': %w", r.table, rows.Err())
+		}
+		return record, ErrRecordNotFound
+	}
+
+	v := reflect.ValueOf(&record).Elem()
+	ptrs := getArgs()
+	defer putArgs(ptrs)
+	for i := range r.info.columns {
+		*ptrs = append(*ptrs, v.Field(r.info.fieldIdx[i]).Addr().Interface())
+	}
+
+	err = rows.Scan(*ptrs...)
+	if err != nil {
+		return record, fmt.Errorf("error scanning record from table '%s': %w", r.table, err)
+	}
+
+	return record, rows.Close()
+}
+
+func checkRowsAffected(result sql.Result, table string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading rows affected on table '%s': %w", table, err)
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+type structInfo struct {
+	columns  []string
+	fieldIdx []int
+	byColumn map[string]int
+}
+
+var structInfoCache sync.Map
+
+func getStructInfo(t reflect.Type) (*structInfo, error) {
+	if cached, ok := structInfoCache.Load(t); ok {
+		return cached.(*structInfo), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct type, got: %s", t)
+	}
+
+	info := &structInfo{
+		byColumn: map[string]int{},
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		column := strings.Split(field.Tag.Get("ktx"), ",")[0]
+		if column == "" || column == "-" || !field.IsExported() {
+			continue
+		}
+
+		if _, found := info.byColumn[column]; found {
+			return nil, fmt.Errorf("duplicated column '%s' on the tags of %s", column, t)
+		}
+
+		info.columns = append(info.columns, column)
+		info.fieldIdx = append(info.fieldIdx, i)
+		info.byColumn[column] = i
+	}
+
+	if len(info.columns) == 0 {
+		return nil, fmt.Errorf("no fields with the `ktx` tag were found on %s", t)
+	}
+
+	structInfoCache.Store(t, info)
+	return info, nil
+}
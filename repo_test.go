@@ -0,0 +1,256 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID    int    `ktx:"id"`
+	Name  string `ktx:"name"`
+	Email string `ktx:"email"`
+
+	Ignored string
+}
+
+func TestRepo(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	users, err := NewRepo[testUser](SQLite, "users", "id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var john testUser
+	err = Run(ctx, db, func(tx *Tx) error {
+		john = testUser{Name: "John", Email: "john@example.com"}
+		err := users.Insert(ctx, tx, &john)
+		if err != nil {
+			return err
+		}
+		if john.ID == 0 {
+			t.Fatal("expected the generated ID to be written to the record")
+		}
+
+		err = users.Insert(ctx, tx, &testUser{ID: 42, Name: "Jane", Email: "jane@example.com"})
+		if err != nil {
+			return err
+		}
+
+		john.Name = "Johnny"
+		return users.Update(ctx, tx, &john)
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	user, err := users.GetByID(ctx, db, john.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != john.ID || user.Name != "Johnny" || user.Email != "john@example.com" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	jane, err := users.GetByID(ctx, db, 42)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jane.Name != "Jane" {
+		t.Fatalf("unexpected user: %+v", jane)
+	}
+
+	err = users.Delete(ctx, db, john.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = users.GetByID(ctx, db, john.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got: %v", err)
+	}
+
+	err = users.Delete(ctx, db, john.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got: %v", err)
+	}
+
+	err = users.Update(ctx, db, &testUser{ID: 1000, Name: "Nobody", Email: "nobody@example.com"})
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got: %v", err)
+	}
+}
+
+func TestRepo_HooksKeepArgs(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	users, err := NewRepo[testUser](SQLite, "users", "id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var plans []Plan
+	err = Run(ctx, db, func(tx *Tx) error {
+		err := users.Insert(ctx, tx, &testUser{ID: 1, Name: "John", Email: "john@example.com"})
+		if err != nil {
+			return err
+		}
+		return users.Insert(ctx, tx, &testUser{ID: 2, Name: "Jane", Email: "jane@example.com"})
+	}, WithExplain(ExplainOptions{
+		Dialect: SQLite,
+		OnPlan: func(ctx context.Context, plan Plan) {
+			plans = append(plans, plan)
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(plans) != 2 {
+		t.Fatalf("expected 2 plans, got: %v", plans)
+	}
+	if plans[0].Args[1] != "John" || plans[1].Args[1] != "Jane" {
+		t.Fatalf("expected the args of each statement to be kept, got: %v and %v", plans[0].Args, plans[1].Args)
+	}
+}
+
+func TestRepo_Queryer(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	users, err := NewRepo[testUser](SQLite, "users", "id")
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+
+	err = users.Insert(ctx, db, &testUser{ID: 7, Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A read-only layer that can't execute statements:
+	reader := struct{ Queryer }{db}
+
+	user, err := users.GetByID(ctx, reader, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Name != "John" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+}
+
+// unchangedRowsRunner reports no rows affected by the statements,
+// as MySQL does for UPDATEs that don't change any values.
+type unchangedRowsRunner struct {
+	DBRunner
+}
+
+func (r unchangedRowsRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	_, err := r.DBRunner.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func TestRepo_UpdateUnchangedOnMySQL(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	// SQLite also accepts the backticks used by the MySQL dialect:
+	users, err := NewRepo[testUser](MySQL, "users", "id")
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+
+	john := testUser{ID: 7, Name: "John", Email: "john@example.com"}
+	err = users.Insert(ctx, db, &john)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = users.Update(ctx, unchangedRowsRunner{db}, &john)
+	if err != nil {
+		t.Fatalf("expected the existing row to be found, got: %v", err)
+	}
+
+	err = users.Update(ctx, unchangedRowsRunner{db}, &testUser{ID: 1000})
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got: %v", err)
+	}
+}
+
+func TestRepo_UpdateWithoutColumns(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	type userID struct {
+		ID int `ktx:"id"`
+	}
+	ids, err := NewRepo[userID](SQLite, "users", "id")
+	if err != nil {
+		t.Fatalf("NewRepo failed: %v", err)
+	}
+
+	err = ids.Update(context.Background(), db, &userID{ID: 1})
+	if err == nil || !strings.Contains(err.Error(), "no columns besides the ID column 'id'") {
+		t.Fatalf("expected an error about the missing columns, got: %v", err)
+	}
+}
+
+func TestNewRepo_InvalidTypes(t *testing.T) {
+	_, err := NewRepo[testUser](SQLite, "users", "missing_id")
+	if err == nil {
+		t.Fatal("expected an error for a missing ID column")
+	}
+
+	_, err = NewRepo[int](SQLite, "users", "id")
+	if err == nil {
+		t.Fatal("expected an error for a non-struct type")
+	}
+
+	_, err = NewRepo[struct{ Name string }](SQLite, "users", "id")
+	if err == nil {
+		t.Fatal("expected an error for a struct without tags")
+	}
+}
+
+func BenchmarkRepo_Insert(b *testing.B) {
+	db := setupTestDB(b)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	users, err := NewRepo[testUser](SQLite, "users", "id")
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := users.Insert(ctx, tx, &testUser{Name: "John", Email: strconv.Itoa(i)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Run failed: %v", err)
+	}
+}
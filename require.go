@@ -0,0 +1,63 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingMetadata is returned when a transaction is started
+// without some of the metadata required with WithRequiredMetadata.
+var ErrMissingMetadata = errors.New("required metadata missing from the transaction")
+
+// WithRequiredMetadata makes the transaction fail to start if any of the input
+// keys is missing from its metadata, set either with WithMetadata or on the
+// context with ContextWithMetadata, e.g. for making sure that every transaction
+// carries the request and actor IDs expected by the auditing hooks:
+//
+//	err := ktx.Run(ctx, db, fn, ktx.WithRequiredMetadata("request_id", "actor_id"))
+//
+// The error wraps ErrMissingMetadata and lists the missing keys.
+func WithRequiredMetadata(keys ...string) Option {
+	return WithContextValidator(func(ctx context.Context) error {
+		var missing []string
+		for _, key := range keys {
+			if _, ok := MetadataFromContext(ctx, key); !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: %s", ErrMissingMetadata, strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// WithContextValidator runs validate with the context of the transaction
+// before it starts, failing fast with the returned error, which is useful
+// for enforcing conventions such as the presence of a tenant on the context.
+//
+// The context received by validate also carries the metadata
+// set with WithMetadata, so it can be read with MetadataFromContext.
+func WithContextValidator(validate func(ctx context.Context) error) Option {
+	return func(c *config) {
+		c.validators = append(c.validators, validate)
+	}
+}
+
+// validateContext runs the validators of the transaction.
+func validateContext(ctx context.Context, cfg *config) error {
+	if len(cfg.validators) == 0 {
+		return nil
+	}
+
+	ctx = contextWithConfigMetadata(ctx, cfg)
+	for _, validate := range cfg.validators {
+		err := validate(ctx)
+		if err != nil {
+			return fmt.Errorf("error validating the context of the transaction: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithRequiredMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should fail fast when metadata is missing", func(t *testing.T) {
+		called := false
+		err := Run(ctx, db, func(tx *Tx) error {
+			called = true
+			return nil
+		}, WithRequiredMetadata("request_id", "actor_id", "tenant"), WithMetadata("tenant", "acme"))
+		if !errors.Is(err, ErrMissingMetadata) {
+			t.Fatalf("expected ErrMissingMetadata, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "request_id, actor_id") || strings.Contains(err.Error(), "tenant") {
+			t.Errorf("expected the error to list only the missing keys, got: %v", err)
+		}
+		if called {
+			t.Error("the callback should not be called")
+		}
+	})
+
+	t.Run("should accept metadata from the context and from the options", func(t *testing.T) {
+		ctx := ContextWithMetadata(ctx, "request_id", "fake-request")
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithRequiredMetadata("request_id", "actor_id"), WithMetadata("actor_id", 42))
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+}
+
+func TestWithContextValidator(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	type tenantKey struct{}
+	requireTenant := WithContextValidator(func(ctx context.Context) error {
+		if ctx.Value(tenantKey{}) == nil {
+			return errors.New("missing tenant")
+		}
+		return nil
+	})
+
+	err := Run(context.Background(), db, func(tx *Tx) error {
+		return nil
+	}, requireTenant)
+	if err == nil || !strings.Contains(err.Error(), "missing tenant") {
+		t.Errorf("expected the validation error, got: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	err = Run(ctx, db, func(tx *Tx) error {
+		return nil
+	}, requireTenant)
+	if err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package ktx
+
+import (
+	"context"
+	"time"
+)
+
+// TxResult describes how a transaction started by RunWithResult was executed.
+type TxResult struct {
+	// Attempts counts the attempts to run the transaction,
+	// which is more than 1 when it is retried WithRetry.
+	Attempts int
+
+	// Duration is the total time spent by RunWithResult,
+	// including the time spent between retries.
+	Duration time.Duration
+
+	// Statements counts the statements executed by the last attempt.
+	Statements int
+
+	// RolledBack is true when the last attempt was rolled back, it is
+	// false when the transaction was committed, failed to start or
+	// failed to commit with ErrCommitAmbiguous.
+	RolledBack bool
+
+	// CommitLatency is how long the commit of the last attempt took.
+	CommitLatency time.Duration
+}
+
+// RunWithResult works like Run but also returns a TxResult describing how
+// the transaction was executed, so callers can log or assert on it without
+// registering hooks:
+//
+//	result, err := ktx.RunWithResult(ctx, db, fn, ktx.WithRetry(policy), ktx.WithIdempotent())
+//	log.Printf("transaction took %s in %d attempts", result.Duration, result.Attempts)
+//
+// When db is already a transaction only the Duration is filled.
+func RunWithResult(ctx context.Context, db DBRunner, fn func(tx *Tx) error, opts ...Option) (TxResult, error) {
+	var result TxResult
+
+	start := time.Now()
+	// The full slice expression makes append copy opts, so a slice
+	// shared by concurrent callers is never written to:
+	err := Run(ctx, db, fn, append(opts[:len(opts):len(opts)], withResult(&result))...)
+	result.Duration = time.Since(start)
+
+	return result, err
+}
+
+func withResult(result *TxResult) Option {
+	return func(c *config) {
+		c.result = result
+	}
+}
+
+// recordAttempt must be called once the attempt of tx finishes.
+func (tx *Tx) recordAttempt() {
+	result := tx.config.result
+	if result == nil {
+		return
+	}
+
+	result.Attempts++
+	result.Statements = 0
+	result.RolledBack = false
+	result.CommitLatency = 0
+	if tx.managed {
+		stats := tx.Stats()
+		result.Statements = stats.Statements
+		result.RolledBack = !tx.committed && !tx.commitAmbiguous
+		result.CommitLatency = tx.commitLatency
+	}
+}
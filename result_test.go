@@ -0,0 +1,100 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunWithResult(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should describe committed transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		result, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "UPDATE users SET name = ? WHERE email = ?", "Jane", "john@example.com")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RunWithResult failed: %v", err)
+		}
+
+		if result.Attempts != 1 || result.Statements != 2 || result.RolledBack {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if result.CommitLatency <= 0 || result.Duration < result.CommitLatency {
+			t.Errorf("expected the commit latency to be part of the duration, got: %+v", result)
+		}
+	})
+
+	t.Run("should describe retried transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errTransient := errors.New("transient error")
+		calls := 0
+		result, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			calls++
+			_, err := tx.ExecContext(ctx, "SELECT 1")
+			if err != nil || calls == 3 {
+				return err
+			}
+			return errTransient
+		}, WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     func(attempt int) time.Duration { return 0 },
+			ShouldRetry: func(err error) bool { return errors.Is(err, errTransient) },
+		}))
+		if err != nil {
+			t.Fatalf("RunWithResult failed: %v", err)
+		}
+
+		if result.Attempts != 3 || result.Statements != 1 || result.RolledBack {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("should describe rolled back transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		errTest := errors.New("test error")
+		result, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			return errTest
+		})
+		if err != errTest {
+			t.Fatalf("expected the test error, got: %v", err)
+		}
+
+		if result.Attempts != 1 || result.Statements != 0 || !result.RolledBack || result.CommitLatency != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("should not write to the spare capacity of the options", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		// A slice shared by concurrent callers:
+		opts := make([]Option, 1, 2)
+		opts[0] = WithName("shared")
+
+		_, err := RunWithResult(ctx, db, func(tx *Tx) error {
+			return nil
+		}, opts...)
+		if err != nil {
+			t.Fatalf("RunWithResult failed: %v", err)
+		}
+
+		if opts[:2][1] != nil {
+			t.Errorf("expected the backing array of the options to be left untouched")
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrResultCacheNotReadOnly is returned by TransactionValue when
+// ResultCache.Option is used without WithReadOnly.
+var ErrResultCacheNotReadOnly = errors.New("result caching requires a transaction started WithReadOnly")
+
+// ErrResultCacheUnnamed is returned by TransactionValue when
+// ResultCache.Option is used without WithName.
+var ErrResultCacheUnnamed = errors.New("result caching requires a transaction started WithName")
+
+// ResultCacheOptions configures a ResultCache.
+type ResultCacheOptions struct {
+	// TTL is how long the values are reused after
+	// they are committed, defaults to 1 second.
+	TTL time.Duration
+
+	// Clock is used for expiring the values, defaults to the system clock.
+	Clock Clock
+}
+
+// ResultCache keeps the values returned by TransactionValue in memory for
+// a short time, keyed by the name of the transaction and the input key,
+// so dashboards polling the same aggregate queries don't reach the
+// database every time:
+//
+//	cache := ktx.NewResultCache(ktx.ResultCacheOptions{TTL: 5 * time.Second})
+//
+//	total, err := ktx.TransactionValue(ctx, db, countOrders,
+//		ktx.WithName("count-orders"), ktx.WithReadOnly(), cache.Option(tenantID),
+//	)
+//
+// Only the transactions explicitly started WithReadOnly can be cached,
+// since skipping the callback would also skip its writes, and values are
+// only stored once their transaction commits. WithName is also required,
+// so different callbacks using the same key don't share values. Concurrent misses for the
+// same key wait for the first one instead of running the transaction
+// again, and the key passed to Option must identify everything the
+// callback reads from outside of the transaction, such as the tenant.
+//
+// The cached values are shared, so callers must not modify them.
+// A single ResultCache should be shared by all the transactions using it.
+type ResultCache struct {
+	opts ResultCacheOptions
+
+	mu       sync.Mutex
+	entries  map[string]resultCacheEntry
+	inflight map[string]*resultCacheCall
+	sweepAt  int
+}
+
+type resultCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// resultCacheCall is a miss being loaded, which
+// the concurrent misses for the same key wait for.
+type resultCacheCall struct {
+	done  chan struct{}
+	value interface{}
+	ok    bool
+}
+
+// NewResultCache creates a ResultCache.
+func NewResultCache(opts ResultCacheOptions) *ResultCache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return &ResultCache{
+		opts:     opts,
+		entries:  map[string]resultCacheEntry{},
+		inflight: map[string]*resultCacheCall{},
+		sweepAt:  64,
+	}
+}
+
+// Option returns the Option that makes TransactionValue read the value
+// from the cache, or store it after the commit. It is ignored by Run
+// and the other functions that don't return the value of the callback.
+func (c *ResultCache) Option(key string) Option {
+	return func(cfg *config) {
+		cfg.resultCache = c
+		cfg.resultCacheKey = key
+	}
+}
+
+// Invalidate deletes the value cached for the input transaction name and key,
+// which is useful for showing the effects of a write without waiting for the TTL.
+func (c *ResultCache) Invalidate(name string, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, resultCacheKey(name, key))
+}
+
+func resultCacheKey(name string, key string) string {
+	return name + "\x00" + key
+}
+
+// load returns the cached value for the key if it didn't expire. Otherwise,
+// if no other miss for the key is being loaded, it returns the call that
+// the caller must finish; or else the call the caller should wait for.
+func (c *ResultCache) load(key string) (value interface{}, found bool, call *resultCacheCall, leader bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if found && c.opts.Clock.Now().Before(entry.expires) {
+		return entry.value, true, nil, false
+	}
+
+	if call, ok := c.inflight[key]; ok {
+		return nil, false, call, false
+	}
+
+	call = &resultCacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	return nil, false, call, true
+}
+
+// finish stores the value loaded by the call, if ok,
+// and releases the misses waiting for it.
+func (c *ResultCache) finish(key string, call *resultCacheCall, value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inflight, key)
+	call.value, call.ok = value, ok
+	close(call.done)
+
+	if !ok {
+		return
+	}
+
+	now := c.opts.Clock.Now()
+	c.entries[key] = resultCacheEntry{
+		value:   value,
+		expires: now.Add(c.opts.TTL),
+	}
+
+	if len(c.entries) >= c.sweepAt {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepAt = max(64, 2*len(c.entries))
+	}
+}
+
+func cachedValue[T any](ctx context.Context, db DBRunner, cfg *config, fn func(tx *Tx) (T, error), opts []Option) (value T, err error) {
+	if !cfg.readOnly {
+		return value, ErrResultCacheNotReadOnly
+	}
+	if cfg.name == "" {
+		return value, ErrResultCacheUnnamed
+	}
+
+	cache := cfg.resultCache
+	key := resultCacheKey(cfg.name, cfg.resultCacheKey)
+
+	cached, found, call, leader := cache.load(key)
+	if found {
+		if value, ok := cached.(T); ok {
+			return value, nil
+		}
+	}
+
+	if call != nil && !leader {
+		select {
+		case <-call.done:
+			if value, ok := call.value.(T); ok && call.ok {
+				return value, nil
+			}
+		case <-ctx.Done():
+			return value, ctx.Err()
+		}
+	}
+
+	// The panics of the callback are re-raised by Run,
+	// so the waiting misses must be released by a defer:
+	stored := false
+	if leader {
+		defer func() {
+			cache.finish(key, call, value, stored)
+		}()
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		value, err = fn(tx)
+		return err
+	}, opts...)
+	stored = err == nil
+	return value, err
+}
@@ -0,0 +1,231 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+
+	countUsers := func(calls *int) func(tx *Tx) (int, error) {
+		return func(tx *Tx) (count int, err error) {
+			*calls++
+			err = queryValue(ctx, tx, &count, "SELECT COUNT(*) FROM users")
+			return count, err
+		}
+	}
+
+	insertUser := func(t *testing.T, db DBRunner, email string) {
+		t.Helper()
+		_, err := db.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", email)
+		if err != nil {
+			t.Fatalf("error inserting user: %v", err)
+		}
+	}
+
+	t.Run("should reuse the value until the TTL expires", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		clock := &fakeClock{now: time.Now()}
+		cache := NewResultCache(ResultCacheOptions{TTL: 5 * time.Second, Clock: clock})
+
+		calls := 0
+		opts := []Option{WithName("count-users"), WithReadOnly(), cache.Option("all")}
+
+		count, err := TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil || count != 0 {
+			t.Fatalf("expected 0 users, got: %d, %v", count, err)
+		}
+
+		insertUser(t, db, "john@example.com")
+		clock.now = clock.now.Add(4 * time.Second)
+
+		count, err = TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil || count != 0 {
+			t.Fatalf("expected the cached count 0, got: %d, %v", count, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected the callback to run once, got: %d", calls)
+		}
+
+		clock.now = clock.now.Add(time.Second)
+
+		count, err = TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil || count != 1 {
+			t.Fatalf("expected 1 user after the TTL, got: %d, %v", count, err)
+		}
+		if calls != 2 {
+			t.Errorf("expected the callback to run again, got: %d calls", calls)
+		}
+	})
+
+	t.Run("should key the values by name and key", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+
+		calls := 0
+		for _, opts := range [][]Option{
+			{WithName("count-users"), cache.Option("a")},
+			{WithName("count-users"), cache.Option("b")},
+			{WithName("other"), cache.Option("a")},
+			{WithName("count-users"), cache.Option("a")},
+		} {
+			_, err := TransactionValue(ctx, db, countUsers(&calls), append(opts, WithReadOnly())...)
+			if err != nil {
+				t.Fatalf("TransactionValue failed: %v", err)
+			}
+		}
+		if calls != 3 {
+			t.Errorf("expected the callback to run 3 times, got: %d", calls)
+		}
+	})
+
+	t.Run("should delete the invalidated values", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+
+		calls := 0
+		opts := []Option{WithName("count-users"), WithReadOnly(), cache.Option("all")}
+
+		_, err := TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil {
+			t.Fatalf("TransactionValue failed: %v", err)
+		}
+
+		insertUser(t, db, "john@example.com")
+		cache.Invalidate("count-users", "all")
+
+		count, err := TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil || count != 1 {
+			t.Fatalf("expected 1 user after invalidating, got: %d, %v", count, err)
+		}
+	})
+
+	t.Run("should not cache the failures", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+		opts := []Option{WithName("count-users"), WithReadOnly(), cache.Option("all")}
+
+		fakeErr := errors.New("fake error")
+		_, err := TransactionValue(ctx, db, func(tx *Tx) (int, error) {
+			return 42, fakeErr
+		}, opts...)
+		if !errors.Is(err, fakeErr) {
+			t.Fatalf("expected fake error, got: %v", err)
+		}
+
+		calls := 0
+		count, err := TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil || count != 0 || calls != 1 {
+			t.Fatalf("expected the callback to run again, got: %d, %d calls, %v", count, calls, err)
+		}
+	})
+
+	t.Run("should require WithReadOnly", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+
+		calls := 0
+		_, err := TransactionValue(ctx, db, countUsers(&calls), WithName("count-users"), cache.Option("all"))
+		if !errors.Is(err, ErrResultCacheNotReadOnly) {
+			t.Fatalf("expected ErrResultCacheNotReadOnly, got: %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("expected the callback not to run, got: %d calls", calls)
+		}
+	})
+
+	t.Run("should require WithName", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+
+		calls := 0
+		_, err := TransactionValue(ctx, db, countUsers(&calls), WithReadOnly(), cache.Option("all"))
+		if !errors.Is(err, ErrResultCacheUnnamed) {
+			t.Fatalf("expected ErrResultCacheUnnamed, got: %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("expected the callback not to run, got: %d calls", calls)
+		}
+	})
+
+	t.Run("should bypass the cache inside other transactions", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{})
+
+		calls := 0
+		opts := []Option{WithName("count-users"), WithReadOnly(), cache.Option("all")}
+
+		_, err := TransactionValue(ctx, db, countUsers(&calls), opts...)
+		if err != nil {
+			t.Fatalf("TransactionValue failed: %v", err)
+		}
+
+		err = Run(ctx, db, func(tx *Tx) error {
+			insertUser(t, tx, "john@example.com")
+
+			count, err := TransactionValue(ctx, tx, countUsers(&calls), opts...)
+			if count != 1 {
+				t.Errorf("expected the count to include the insert, got: %d", count)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+
+	t.Run("should run concurrent misses only once", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		cache := NewResultCache(ResultCacheOptions{TTL: time.Minute})
+		opts := []Option{WithName("count-users"), WithReadOnly(), cache.Option("all")}
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				count, err := TransactionValue(ctx, db, func(tx *Tx) (int, error) {
+					calls.Add(1)
+					<-release
+					return 42, nil
+				}, opts...)
+				if err != nil || count != 42 {
+					t.Errorf("expected 42, got: %d, %v", count, err)
+				}
+			}()
+		}
+
+		// Give the goroutines time to reach the cache before releasing the first:
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls.Load() != 1 {
+			t.Errorf("expected the callback to run once, got: %d", calls.Load())
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned by Run when the deadline of the
+// context doesn't leave enough time for another attempt of a transaction
+// configured with WithRetry.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// ErrNotIdempotent is returned by Run when WithRetry is used
+// without WithIdempotent.
+var ErrNotIdempotent = errors.New("retries require the transaction to be marked with WithIdempotent")
+
+// RetryPolicy configures how WithRetry retries a transaction.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the transaction
+	// is attempted, including the first one.
+	MaxAttempts int
+
+	// Backoff returns how long to wait before the input attempt,
+	// which starts at 2 for the first retry.
+	//
+	// Defaults to an exponential backoff starting at 10ms and capped at 1s,
+	// with a random jitter of up to half of each delay so transactions that
+	// conflicted with each other don't retry at the same time.
+	Backoff func(attempt int) time.Duration
+
+	// ShouldRetry decides whether an error is transient,
+	// defaults to IsRetryable.
+	ShouldRetry func(err error) bool
+
+	// RollbackReserve is how much of the time left before the deadline of the
+	// context is reserved for rolling back the last attempt, defaults to 50ms.
+	RollbackReserve time.Duration
+
+	// Clock is used for waiting between the attempts and for computing
+	// the time left before the deadline, defaults to the system clock.
+	//
+	// Tests can replace it for asserting the exact retry schedule
+	// without sleeping.
+	Clock Clock
+
+	// Rand returns the random numbers in [0, 1) used for the jitter
+	// of the default Backoff, defaults to math/rand.Float64.
+	Rand func() float64
+
+	// Adaptive, when set, widens the delays returned by Backoff while
+	// the transactions with the same name are being retried often.
+	Adaptive *AdaptiveBackoff
+}
+
+// Clock abstracts the passage of time for WithRetry and ResultCache.
+type Clock interface {
+	Now() time.Time
+
+	// Sleep waits for d or until ctx is done, returning
+	// the error of ctx in the second case.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	return sleepCtx(ctx, d)
+}
+
+// WithRetry makes Run retry the whole transaction, including the
+// callback, when it fails with a transient error such as a
+// serialization failure or a deadlock.
+//
+// If the context has a deadline, the time left before it, minus the
+// RollbackReserve, is split evenly across the remaining attempts, so
+// that a slow attempt times out early enough to leave time for the
+// next ones. When there is no time left for another attempt Run
+// returns an error wrapping both ErrRetryBudgetExhausted and the
+// error of the last attempt.
+//
+// Since the callback may run more than once, WithIdempotent must also be
+// used, otherwise Run fails with ErrNotIdempotent without starting the
+// transaction.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = &p
+	}
+}
+
+// WithIdempotent declares that the callback of the transaction can safely
+// run more than once, i.e. that any side effects it has outside of the
+// transaction are idempotent, which is required by WithRetry.
+func WithIdempotent() Option {
+	return func(c *config) {
+		c.idempotent = true
+	}
+}
+
+// IsRetryable reports whether err is a transient error that is
+// likely to succeed if the transaction is attempted again, i.e.
+// serialization failures, deadlocks, lock timeouts and
+// ErrReadOnlyReverted.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrReadOnlyReverted) {
+		// The next attempt starts as read-write:
+		return true
+	}
+
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+
+	// Drivers that don't expose the error codes with a method:
+	msg := err.Error()
+	for _, s := range []string{
+		"Error 1213",         // MySQL deadlock
+		"Error 1205",         // MySQL lock wait timeout
+		"SQLSTATE 40001",     // Postgres serialization failure
+		"SQLSTATE 40P01",     // Postgres deadlock
+		"database is locked", // SQLite busy
+		"was deadlocked on",  // SQL Server deadlock
+		"ORA-00060",          // Oracle deadlock
+		"ORA-08177",          // Oracle serialization failure
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runWithRetry(ctx context.Context, db TxBeginner, cfg *config, fn func(tx *Tx) error) error {
+	policy := *cfg.retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Clock == nil {
+		policy.Clock = systemClock{}
+	}
+	if policy.Rand == nil {
+		policy.Rand = rand.Float64
+	}
+	if policy.Backoff == nil {
+		random := policy.Rand
+		policy.Backoff = func(attempt int) time.Duration {
+			return jitter(defaultBackoff(attempt), random())
+		}
+	}
+	if policy.ShouldRetry == nil {
+		policy.ShouldRetry = IsRetryable
+	}
+	if policy.RollbackReserve <= 0 {
+		policy.RollbackReserve = 50 * time.Millisecond
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			backoff := policy.Backoff(attempt)
+			if policy.Adaptive != nil {
+				backoff = policy.Adaptive.widen(cfg.name, backoff)
+			}
+			if hasDeadline && deadline.Sub(policy.Clock.Now())-policy.RollbackReserve-backoff <= 0 {
+				return budgetExhaustedError(attempt-1, lastErr)
+			}
+
+			err := policy.Clock.Sleep(ctx, backoff)
+			if err != nil {
+				return fmt.Errorf("error waiting to retry transaction: %w", errors.Join(err, lastErr))
+			}
+
+			// The factor only grows after the wait, so a single
+			// conflict is retried with the normal backoff:
+			if policy.Adaptive != nil {
+				policy.Adaptive.retried(cfg.name)
+			}
+		}
+
+		attemptCtx := ctx
+		cancel := func() {}
+		if hasDeadline {
+			remaining := deadline.Sub(policy.Clock.Now()) - policy.RollbackReserve
+			if remaining <= 0 {
+				return budgetExhaustedError(attempt-1, lastErr)
+			}
+
+			attemptsLeft := policy.MaxAttempts - attempt + 1
+			attemptCtx, cancel = context.WithTimeout(ctx, remaining/time.Duration(attemptsLeft))
+		}
+
+		err := runAttempt(attemptCtx, db, &Tx{config: *cfg}, fn)
+		budgetExceeded := attemptCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if err == nil {
+			if policy.Adaptive != nil {
+				policy.Adaptive.succeeded(cfg.name)
+			}
+			return nil
+		}
+
+		lastErr = err
+		if errors.Is(err, ErrCallbacksFailed) || errors.Is(err, ErrCommitAmbiguous) {
+			// The transaction was or might have been committed,
+			// so it must not run again:
+			return err
+		}
+		if !budgetExceeded && !policy.ShouldRetry(err) {
+			return err
+		}
+
+		if attempt == policy.MaxAttempts {
+			if budgetExceeded {
+				return budgetExhaustedError(attempt, err)
+			}
+			return fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		cfg.onRetry(ctx, attempt, err)
+	}
+
+	return lastErr
+}
+
+func budgetExhaustedError(attempts int, lastErr error) error {
+	if lastErr == nil {
+		return fmt.Errorf("%w: no time left before the context deadline", ErrRetryBudgetExhausted)
+	}
+	return fmt.Errorf("%w after %d attempts, last error: %w", ErrRetryBudgetExhausted, attempts, lastErr)
+}
+
+func defaultBackoff(attempt int) time.Duration {
+	d := 10 * time.Millisecond
+	for i := 2; i < attempt && d < time.Second; i++ {
+		d *= 2
+	}
+	return min(d, time.Second)
+}
+
+// jitter subtracts up to half of d from it, proportionally
+// to the random number r, which must be in [0, 1).
+func jitter(d time.Duration, r float64) time.Duration {
+	return d - time.Duration(float64(d/2)*r)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
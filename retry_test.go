@@ -0,0 +1,237 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sql error with state " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: errors.New("some error"), expected: false},
+		{err: sqlStateError("40001"), expected: true},
+		{err: fmt.Errorf("wrapped: %w", sqlStateError("40P01")), expected: true},
+		{err: sqlStateError("23505"), expected: false},
+		{err: errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), expected: true},
+		{err: errors.New("database is locked"), expected: true},
+		{err: errors.New("ORA-00060: deadlock detected while waiting for resource"), expected: true},
+		{err: errors.New("ORA-08177: can't serialize access for this transaction"), expected: true},
+		{err: errors.New("mssql: Transaction (Process ID 52) was deadlocked on lock resources with another process"), expected: true},
+	}
+
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.expected {
+			t.Errorf("IsRetryable(%v): expected %v, got %v", test.err, test.expected, got)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	noBackoff := func(int) time.Duration { return 0 }
+
+	t.Run("should retry transient errors", func(t *testing.T) {
+		attempts := 0
+		var retried []int
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", fmt.Sprintf("retry%d@example.com", attempts))
+			if err != nil {
+				return err
+			}
+			if attempts < 3 {
+				return sqlStateError("40001")
+			}
+			return nil
+		}, WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: noBackoff}), WithHooks(Hooks{
+			OnRetry: func(ctx context.Context, attempt int, err error) {
+				retried = append(retried, attempt)
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if attempts != 3 || len(retried) != 2 || retried[0] != 1 || retried[1] != 2 {
+			t.Errorf("unexpected attempts: %d, retries: %v", attempts, retried)
+		}
+
+		// Only the last attempt should be committed:
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users WHERE email LIKE 'retry%'").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		attempts := 0
+		fakeErr := errors.New("fake error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			return fakeErr
+		}, WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: noBackoff}))
+		if err != fakeErr {
+			t.Fatalf("expected the callback error, got: %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("should stop after the max attempts", func(t *testing.T) {
+		attempts := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			return sqlStateError("40001")
+		}, WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: noBackoff}))
+		if !errors.Is(err, sqlStateError("40001")) {
+			t.Fatalf("expected the last error, got: %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("should fail with ErrRetryBudgetExhausted when attempts time out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 350*time.Millisecond)
+		defer cancel()
+
+		var durations []time.Duration
+		err := Run(ctx, db, func(tx *Tx) error {
+			start := time.Now()
+			defer func() { durations = append(durations, time.Since(start)) }()
+
+			// Blocks until the attempt runs out of time:
+			for {
+				_, err := tx.ExecContext(ctx, "SELECT 1")
+				if err != nil {
+					return err
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}, WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: noBackoff, RollbackReserve: 50 * time.Millisecond}))
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Fatalf("expected ErrRetryBudgetExhausted, got: %v", err)
+		}
+		if ctx.Err() != nil {
+			t.Errorf("expected the attempts to finish before the parent deadline")
+		}
+
+		if len(durations) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(durations))
+		}
+		for _, d := range durations {
+			if d < 60*time.Millisecond || d > 200*time.Millisecond {
+				t.Errorf("expected each attempt to take about 100ms, got: %v", durations)
+			}
+		}
+	})
+
+	t.Run("should follow the schedule of the injected clock and random source", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			return sqlStateError("40001")
+		}, WithIdempotent(), WithRetry(RetryPolicy{
+			MaxAttempts: 5,
+			Clock:       clock,
+			Rand:        func() float64 { return 0.5 },
+		}))
+		if !errors.Is(err, sqlStateError("40001")) {
+			t.Fatalf("expected the last error, got: %v", err)
+		}
+		if attempts != 5 {
+			t.Errorf("expected 5 attempts, got %d", attempts)
+		}
+
+		expected := []time.Duration{
+			7500 * time.Microsecond,
+			15 * time.Millisecond,
+			30 * time.Millisecond,
+			60 * time.Millisecond,
+		}
+		if fmt.Sprint(clock.sleeps) != fmt.Sprint(expected) {
+			t.Errorf("expected sleeps %v, got %v", expected, clock.sleeps)
+		}
+	})
+
+	t.Run("should compute the retry budget with the injected clock", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		ctx, cancel := context.WithDeadline(ctx, clock.now.Add(time.Hour))
+		defer cancel()
+
+		attempts := 0
+		err := Run(ctx, db, func(tx *Tx) error {
+			attempts++
+			// Only the fake clock moves, so the real deadline is never reached:
+			clock.now = clock.now.Add(40 * time.Minute)
+			return sqlStateError("40001")
+		}, WithIdempotent(), WithRetry(RetryPolicy{MaxAttempts: 5, Backoff: noBackoff, Clock: clock}))
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Fatalf("expected ErrRetryBudgetExhausted, got: %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", attempts)
+		}
+	})
+}
+
+// fakeClock records the sleeps instead of waiting for them.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func TestWithIdempotent(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	called := false
+	err := Run(ctx, db, func(tx *Tx) error {
+		called = true
+		return nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	if err != ErrNotIdempotent {
+		t.Fatalf("expected ErrNotIdempotent, got: %v", err)
+	}
+	if called {
+		t.Errorf("expected the callback not to be called")
+	}
+}
@@ -0,0 +1,225 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrReturningNotSupported is returned by ExecReturning for
+// dialects that can't return the modified rows, i.e. MySQL.
+var ErrReturningNotSupported = errors.New("returning the modified rows is not supported by this dialect")
+
+// ExecReturningID executes an INSERT statement and returns the
+// value generated by the database for idColumn.
+//
+// It uses `RETURNING idColumn` on the dialects that support it, the
+// OUTPUT clause on SQL Server, `RETURNING idColumn INTO` with an OUT bind
+// on Oracle and LastInsertId on MySQL, so the statement must be a plain
+// INSERT without a RETURNING clause.
+func ExecReturningID(ctx context.Context, db DBRunner, dialect Dialect, idColumn string, query string, args ...interface{}) (id int64, err error) {
+	err = execReturningID(ctx, db, dialect, idColumn, &id, query, args)
+	return id, err
+}
+
+// execReturningID works like ExecReturningID writing the ID to dest,
+// which allows IDs of types other than int64 such as UUIDs.
+func execReturningID(ctx context.Context, db DBRunner, dialect Dialect, idColumn string, dest interface{}, query string, args []interface{}) error {
+	if dialect.Name() == MySQL.Name() {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("error reading generated ID: %w", err)
+		}
+		return assignValue(dest, id)
+	}
+
+	if dialect.Name() == Oracle.Name() {
+		query = returningIntoQuery(query, []string{dialect.Quote(idColumn)}, len(args))
+		_, err := db.ExecContext(ctx, query, append(args[:len(args):len(args)], sql.Out{Dest: dest})...)
+		return err
+	}
+
+	return queryReturnedID(ctx, db, dest, returningQuery(dialect, query, []string{dialect.Quote(idColumn)}), args)
+}
+
+// queryReturnedID runs a query that already has a RETURNING
+// clause for the ID and scans the ID into dest.
+func queryReturnedID(ctx context.Context, db Queryer, dest interface{}, query string, args []interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return errors.New("no ID returned by the statement")
+	}
+
+	err = rows.Scan(dest)
+	if err != nil {
+		return fmt.Errorf("error reading generated ID: %w", err)
+	}
+
+	return rows.Close()
+}
+
+// ExecReturning executes an INSERT, UPDATE or DELETE statement and
+// returns the modified rows, scanned into the fields of T tagged with
+// `ktx:"column_name"` as done by Repo.
+//
+// It adds a RETURNING clause, or OUTPUT on SQL Server, with the columns
+// of T to the statement, so it must not have one already. ErrReturningNotSupported is returned
+// for MySQL, use ExecReturningID for reading generated IDs instead.
+//
+// On Oracle the columns are bound to OUT parameters with `RETURNING INTO`,
+// so only statements that modify a single row are supported.
+func ExecReturning[T any](ctx context.Context, db DBRunner, dialect Dialect, query string, args ...interface{}) ([]T, error) {
+	if dialect.Name() == MySQL.Name() {
+		return nil, ErrReturningNotSupported
+	}
+
+	info, err := getStructInfo(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(info.columns))
+	for i, column := range info.columns {
+		columns[i] = dialect.Quote(column)
+	}
+
+	if dialect.Name() == Oracle.Name() {
+		return execReturningInto[T](ctx, db, info, returningIntoQuery(query, columns, len(args)), args)
+	}
+
+	rows, err := db.QueryContext(ctx, returningQuery(dialect, query, columns), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var records []T
+	for rows.Next() {
+		var record T
+		v := reflect.ValueOf(&record).Elem()
+		ptrs := make([]interface{}, len(info.columns))
+		for i := range info.columns {
+			ptrs[i] = v.Field(info.fieldIdx[i]).Addr().Interface()
+		}
+
+		err = rows.Scan(ptrs...)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning returned row: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, rows.Close()
+}
+
+// execReturningInto executes a statement with a RETURNING INTO
+// clause, binding the fields of a single record as OUT parameters.
+func execReturningInto[T any](ctx context.Context, db DBRunner, info *structInfo, query string, args []interface{}) ([]T, error) {
+	var record T
+	v := reflect.ValueOf(&record).Elem()
+	outs := append([]interface{}(nil), args...)
+	for i := range info.columns {
+		outs = append(outs, sql.Out{Dest: v.Field(info.fieldIdx[i]).Addr().Interface()})
+	}
+
+	result, err := db.ExecContext(ctx, query, outs...)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+	return []T{record}, nil
+}
+
+// returningIntoQuery adds the RETURNING INTO clause of Oracle to the
+// statement, binding the columns to the placeholders after its arguments.
+func returningIntoQuery(query string, quotedColumns []string, numArgs int) string {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	placeholders := make([]string, len(quotedColumns))
+	for i := range quotedColumns {
+		placeholders[i] = Oracle.Placeholder(numArgs + i)
+	}
+
+	return query + " RETURNING " + strings.Join(quotedColumns, ", ") + " INTO " + strings.Join(placeholders, ", ")
+}
+
+// returningQuery makes the statement return the input columns of the
+// rows it modifies, using RETURNING or the OUTPUT clause on SQL Server.
+func returningQuery(dialect Dialect, query string, quotedColumns []string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	if dialect.Name() != SQLServer.Name() {
+		return query + " RETURNING " + strings.Join(quotedColumns, ", ")
+	}
+
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return query
+	}
+
+	// The OUTPUT clause goes right before the first of these keywords,
+	// or at the end of the statement if none of them is found:
+	prefix := "INSERTED."
+	var before map[string]bool
+	switch tokens[0].text {
+	case "insert":
+		before = map[string]bool{"values": true, "select": true, "default": true}
+	case "update":
+		before = map[string]bool{"from": true, "where": true}
+	case "delete":
+		prefix = "DELETED."
+		before = map[string]bool{"where": true}
+		// The FROM right after DELETE is part of the target,
+		// only the FROM of the joined tables comes after OUTPUT:
+		if len(tokens) > 1 && tokens[1].text != "from" {
+			before["from"] = true
+		}
+	}
+
+	outputs := make([]string, len(quotedColumns))
+	for i, column := range quotedColumns {
+		outputs[i] = prefix + column
+	}
+	output := " OUTPUT " + strings.Join(outputs, ", ")
+
+	runes := []rune(query)
+	depth := 0
+	for i, tok := range tokens {
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth == 0 && i > 1 && tok.kind == wordToken && before[tok.text]:
+			end := tokens[i-1].end
+			return string(runes[:end]) + output + string(runes[end:])
+		}
+	}
+
+	return query + output
+}
@@ -0,0 +1,143 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestExecReturningID(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	var ids []int64
+	err := Run(ctx, db, func(tx *Tx) error {
+		for _, email := range []string{"returning1@example.com", "returning2@example.com"} {
+			id, err := ExecReturningID(ctx, tx, SQLite, "id", "INSERT INTO users (name, email) VALUES (?, ?);", "John", email)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(ids) != 2 || ids[0] == 0 || ids[1] != ids[0]+1 {
+		t.Errorf("unexpected IDs: %v", ids)
+	}
+}
+
+func TestExecReturning(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com'), ('Jane', 'jane@example.com')")
+	if err != nil {
+		t.Fatalf("failed to insert users: %v", err)
+	}
+
+	t.Run("should return the modified rows", func(t *testing.T) {
+		users, err := ExecReturning[testUser](ctx, db, SQLite, "UPDATE users SET name = name || '!' WHERE email LIKE ?", "%@example.com")
+		if err != nil {
+			t.Fatalf("ExecReturning failed: %v", err)
+		}
+
+		if len(users) != 2 {
+			t.Fatalf("expected 2 users, got: %+v", users)
+		}
+		for _, user := range users {
+			if user.ID == 0 || user.Name[len(user.Name)-1] != '!' || user.Email == "" {
+				t.Errorf("unexpected user: %+v", user)
+			}
+		}
+	})
+
+	t.Run("should not be supported on MySQL", func(t *testing.T) {
+		_, err := ExecReturning[testUser](ctx, db, MySQL, "DELETE FROM users")
+		if err != ErrReturningNotSupported {
+			t.Errorf("expected ErrReturningNotSupported, got: %v", err)
+		}
+	})
+}
+
+func TestReturningQuery(t *testing.T) {
+	tests := []struct {
+		desc     string
+		dialect  Dialect
+		query    string
+		expected string
+	}{
+		{
+			desc:     "should append RETURNING on postgres",
+			dialect:  Postgres,
+			query:    "UPDATE users SET name = $1 WHERE id = $2;",
+			expected: `UPDATE users SET name = $1 WHERE id = $2 RETURNING "id"`,
+		},
+		{
+			desc:     "should add OUTPUT before the VALUES of inserts on sqlserver",
+			dialect:  SQLServer,
+			query:    "INSERT INTO users (name, email) VALUES (@p1, @p2)",
+			expected: "INSERT INTO users (name, email) OUTPUT INSERTED.[id] VALUES (@p1, @p2)",
+		},
+		{
+			desc:     "should add OUTPUT before the WHERE of updates on sqlserver",
+			dialect:  SQLServer,
+			query:    "UPDATE users SET name = (SELECT name FROM admins WHERE id = @p1) WHERE id = @p2",
+			expected: "UPDATE users SET name = (SELECT name FROM admins WHERE id = @p1) OUTPUT INSERTED.[id] WHERE id = @p2",
+		},
+		{
+			desc:     "should output the deleted rows on sqlserver",
+			dialect:  SQLServer,
+			query:    "DELETE FROM users WHERE id = @p1",
+			expected: "DELETE FROM users OUTPUT DELETED.[id] WHERE id = @p1",
+		},
+		{
+			desc:     "should add OUTPUT at the end of statements without a WHERE on sqlserver",
+			dialect:  SQLServer,
+			query:    "UPDATE users SET active = 0",
+			expected: "UPDATE users SET active = 0 OUTPUT INSERTED.[id]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := returningQuery(test.dialect, test.query, []string{test.dialect.Quote("id")})
+			if got != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestReturningIntoQuery(t *testing.T) {
+	t.Run("should bind the ID as an OUT parameter on oracle", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		_, err := ExecReturningID(context.Background(), db, Oracle, "id", "INSERT INTO users (name) VALUES (:1)", "John")
+		if err != nil {
+			t.Fatalf("ExecReturningID failed: %v", err)
+		}
+
+		statements := fake.statements()
+		expected := `INSERT INTO users (name) VALUES (:1) RETURNING "id" INTO :2 [John {`
+		if len(statements) != 1 || !strings.HasPrefix(statements[0], expected) {
+			t.Errorf("expected a statement starting with %s, got %v", expected, statements)
+		}
+	})
+
+	got := returningIntoQuery("UPDATE users SET name = :1 WHERE id = :2;", []string{`"id"`, `"name"`}, 2)
+	expected := `UPDATE users SET name = :1 WHERE id = :2 RETURNING "id", "name" INTO :3, :4`
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
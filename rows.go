@@ -0,0 +1,98 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyRowsAffected is returned by the statements that affect more
+// rows than allowed by WithMaxRowsAffected or WithMaxTotalRowsAffected.
+var ErrTooManyRowsAffected = errors.New("too many rows affected")
+
+// WithMaxRowsAffected limits how many rows a single statement executed with
+// ExecContext can affect, as a safety net against a missing WHERE clause
+// wiping a table.
+//
+// The statement that exceeds the limit fails with ErrTooManyRowsAffected
+// and the transaction is rolled back even if the callback ignores the error.
+func WithMaxRowsAffected(n int64) Option {
+	return func(c *config) {
+		c.maxRowsAffected = n
+	}
+}
+
+// WithMaxTotalRowsAffected works like WithMaxRowsAffected but limits
+// the sum of the rows affected by all the statements of the transaction.
+func WithMaxTotalRowsAffected(n int64) Option {
+	return func(c *config) {
+		c.maxTotalRowsAffected = n
+	}
+}
+
+type rowsGuardRunner struct {
+	next DBRunner
+	tx   *Tx
+}
+
+func (r rowsGuardRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := r.next.ExecContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		// Drivers that don't report the rows affected can't be guarded:
+		return result, nil
+	}
+
+	cfg := r.tx.cfg
+	if cfg.maxRowsAffected > 0 && rows > cfg.maxRowsAffected {
+		return nil, r.tx.abort(fmt.Errorf(
+			"%w: statement affected %d rows, the limit is %d", ErrTooManyRowsAffected, rows, cfg.maxRowsAffected,
+		))
+	}
+
+	if cfg.maxTotalRowsAffected > 0 {
+		// The rows of this statement were already recorded
+		// by the statsRunner or by the captureRunner:
+		total := r.tx.rowsAffected()
+		if total > cfg.maxTotalRowsAffected {
+			return nil, r.tx.abort(fmt.Errorf(
+				"%w: transaction affected %d rows, the limit is %d", ErrTooManyRowsAffected, total, cfg.maxTotalRowsAffected,
+			))
+		}
+	}
+
+	return result, nil
+}
+
+func (r rowsGuardRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r rowsGuardRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+// abort makes the transaction roll back with err once the callback
+// returns, even if the callback doesn't return err itself.
+func (tx *Tx) abort(err error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.abortErr == nil {
+		tx.abortErr = err
+	}
+	return err
+}
+
+// abortError returns the error passed to abort, if any.
+func (tx *Tx) abortError() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.abortErr
+}
@@ -0,0 +1,99 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func insertUsers(t *testing.T, db DBRunner, n int) {
+	for i := 0; i < n; i++ {
+		_, err := db.ExecContext(context.Background(),
+			"INSERT INTO users (name, email) VALUES (?, ?)", "John", fmt.Sprintf("john%d@example.com", i),
+		)
+		if err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+}
+
+func TestMaxRowsAffected(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should abort statements that affect too many rows", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+		insertUsers(t, db, 3)
+
+		var stmtErr error
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, stmtErr = tx.ExecContext(ctx, "DELETE FROM users")
+			// Ignoring the error must not commit the delete:
+			return nil
+		}, WithMaxRowsAffected(2))
+		if !errors.Is(stmtErr, ErrTooManyRowsAffected) {
+			t.Fatalf("expected the statement to fail with ErrTooManyRowsAffected, got: %v", stmtErr)
+		}
+		if !errors.Is(err, ErrTooManyRowsAffected) {
+			t.Fatalf("expected Run to fail with ErrTooManyRowsAffected, got: %v", err)
+		}
+		assertUserCount(t, db, 3)
+	})
+
+	t.Run("should allow statements within the limit", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+		insertUsers(t, db, 3)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM users WHERE email = 'john0@example.com'")
+			return err
+		}, WithMaxRowsAffected(1))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		assertUserCount(t, db, 2)
+	})
+
+	t.Run("should abort transactions that affect too many rows in total", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			insertUsers(t, tx, 2)
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Jane', 'jane@example.com')")
+			return err
+		}, WithMaxRowsAffected(1), WithMaxTotalRowsAffected(2))
+		if !errors.Is(err, ErrTooManyRowsAffected) {
+			t.Fatalf("expected ErrTooManyRowsAffected, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+
+	t.Run("should guard the statements captured WithChangeCapture", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+		insertUsers(t, db, 3)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE users SET name = 'Jane'")
+			return err
+		}, WithMaxRowsAffected(1), WithChangeCapture(SQLite))
+		if !errors.Is(err, ErrTooManyRowsAffected) {
+			t.Fatalf("expected ErrTooManyRowsAffected, got: %v", err)
+		}
+
+		err = Run(ctx, db, func(tx *Tx) error {
+			return Steps(ctx, tx,
+				Step("rename", func(tx *Tx) error {
+					_, err := tx.ExecContext(ctx, "UPDATE users SET name = 'Jane' WHERE email = 'john0@example.com'")
+					return err
+				}).ExpectRowsAffected(1),
+			)
+		}, WithMaxRowsAffected(1), WithChangeCapture(SQLite))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+}
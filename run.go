@@ -0,0 +1,513 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTxNotManaged is returned by the helpers that need to interact with the
+// lifecycle of the transaction, such as AfterCommit, when they receive a
+// runner that is not a transaction started by ktx.
+var ErrTxNotManaged = errors.New("provided db is not a transaction managed by ktx")
+
+// ErrContextCancelled is returned, wrapping the error of the context,
+// when the context of a transaction is done by the time it would be
+// committed, in which case the transaction is rolled back instead.
+var ErrContextCancelled = errors.New("context done before commit, transaction rolled back")
+
+// managedTxs maps the *sql.Tx of the transactions started by Run to
+// their *Tx so the helpers of this package also work on the *sql.Tx
+// received by the callbacks of Transaction.
+//
+// Transactions are only registered once their *sql.Tx is exposed
+// by SQLTx, which keeps the map out of the path of Run.
+var managedTxs = struct {
+	sync.RWMutex
+	m map[*sql.Tx]*Tx
+}{
+	m: map[*sql.Tx]*Tx{},
+}
+
+func loadManagedTx(sqlTx *sql.Tx) (*Tx, bool) {
+	managedTxs.RLock()
+	defer managedTxs.RUnlock()
+
+	tx, ok := managedTxs.m[sqlTx]
+	return tx, ok
+}
+
+// Tx is the DBRunner received by the callbacks of Run.
+//
+// All statements executed through it run inside the transaction
+// managed by ktx.
+type Tx struct {
+	sqlTx       *sql.Tx
+	conn        *sql.Conn
+	runner      DBRunner
+	statsRunner statsRunner
+	cfg         *config
+	config      config
+	metadata    map[string]interface{}
+	managed     bool
+	registered  bool
+
+	mu            sync.Mutex
+	beforeCommit  []beforeCommitCallback
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context, err error)
+	invalidations []string
+	deferred      []deferredStmt
+	stats         TxStats
+	slowestQuery  string
+	changes       []Change
+	attempts      int
+
+	// doneCtx is canceled when the transaction finishes, releasing the
+	// contexts of the queries executed WithStatementTimeout:
+	doneCtx    context.Context
+	cancelDone context.CancelFunc
+
+	// buffers holds the pooled backing arrays of the slices above:
+	buffers *txBuffers
+
+	actor *Actor
+
+	// releaseQuota releases the slot of the transaction on its Quota:
+	releaseQuota func()
+
+	// sessionID is the ID of the session of the transaction
+	// on the database, only loaded WithLockDiagnostics:
+	sessionID string
+
+	// abortErr rolls back the transaction even if the callback succeeds:
+	abortErr error
+
+	// autoReadOnly is set when the transaction was started WithReadOnly
+	// by a ReadOnlyDetector, and wrote when it executed a write:
+	autoReadOnly bool
+	wrote        bool
+
+	committed     bool
+	commitLatency time.Duration
+
+	// commitAmbiguous is set when the commit failed with ErrCommitAmbiguous:
+	commitAmbiguous bool
+
+	// abandoned is set when the commit or the rollback timed out
+	// and is still holding the connection in the background:
+	abandoned bool
+}
+
+// ExecContext executes a statement inside the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.stmtRunner().ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query inside the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.stmtRunner().QueryContext(ctx, query, args...)
+}
+
+// stmtRunner returns the runner that goes through the
+// middlewares configured for the transaction.
+func (tx *Tx) stmtRunner() DBRunner {
+	if tx.runner == nil {
+		return tx.sqlTx
+	}
+	return tx.runner
+}
+
+// SQLTx returns the underlying *sql.Tx.
+//
+// It should not be committed or rolled back manually since
+// this is done by ktx when the callback returns.
+func (tx *Tx) SQLTx() *sql.Tx {
+	if !tx.managed {
+		return tx.sqlTx
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if !tx.registered {
+		managedTxs.Lock()
+		managedTxs.m[tx.sqlTx] = tx
+		managedTxs.Unlock()
+		tx.registered = true
+	}
+	return tx.sqlTx
+}
+
+// Run works like Transaction but accepts Options for configuring
+// how the transaction is started and executed.
+//
+// If the provided db is already a transaction (sql.Tx or ktx.Tx), it will be
+// reused without starting a new transaction and the Options are ignored.
+func Run(ctx context.Context, db DBRunner, fn func(tx *Tx) error, opts ...Option) error {
+	// Check if db is already a transaction
+	switch tx := db.(type) {
+	case *Tx:
+		return fn(tx)
+	case *ManualTx:
+		return fn(tx.Tx)
+	case *sql.Tx:
+		if managed, ok := loadManagedTx(tx); ok {
+			return fn(managed)
+		}
+		return fn(&Tx{sqlTx: tx, cfg: &config{}})
+	}
+
+	// Check if db can begin transactions
+	txBeginner, ok := db.(TxBeginner)
+	if !ok {
+		return fmt.Errorf("provided db does not implement TxBeginner interface")
+	}
+
+	// The options are applied directly on the Tx so
+	// the config doesn't need an allocation of its own:
+	tx := &Tx{}
+	tx.config.apply(opts)
+	if tx.config.applicationName != "" {
+		// Generated here so all the attempts share it:
+		tx.config.id = newEventID()
+	}
+	if tx.config.eventLog != nil || tx.config.sloTracker != nil {
+		return runObserved(ctx, txBeginner, tx, fn)
+	}
+
+	return runConfigured(ctx, txBeginner, tx, fn)
+}
+
+// runObserved runs the transaction of tx through the
+// EventLog and the SLOTracker of its config, if any.
+func runObserved(ctx context.Context, db TxBeginner, tx *Tx, fn func(tx *Tx) error) error {
+	run := func() error {
+		return runConfigured(ctx, db, tx, fn)
+	}
+	if tracker := tx.config.sloTracker; tracker != nil {
+		next := run
+		run = func() error {
+			return tracker.observe(ctx, &tx.config, next)
+		}
+	}
+	if tx.config.eventLog != nil {
+		return tx.config.eventLog.observe(&tx.config, run)
+	}
+	return run()
+}
+
+// runConfigured runs the transaction of tx, whose config must be
+// already set, including its canary and retries, if any.
+func runConfigured(ctx context.Context, db TxBeginner, tx *Tx, fn func(tx *Tx) error) error {
+	if tx.config.canary != nil {
+		if !tx.config.idempotent {
+			return fmt.Errorf("%w: WithCanary runs the callback twice", ErrNotIdempotent)
+		}
+		err := rehearse(ctx, db, &tx.config, fn)
+		if err != nil {
+			return err
+		}
+	}
+	if tx.config.retry != nil {
+		if !tx.config.idempotent {
+			return ErrNotIdempotent
+		}
+		return runWithRetry(ctx, db, &tx.config, fn)
+	}
+
+	return runAttempt(ctx, db, tx, fn)
+}
+
+// runAttempt starts the transaction of tx, whose config
+// must be already set, and runs fn inside it.
+func runAttempt(ctx context.Context, db TxBeginner, tx *Tx, fn func(tx *Tx) error) error {
+	defer tx.recordAttempt()
+
+	err := begin(ctx, db, tx)
+	if err != nil {
+		return err
+	}
+	defer tx.release()
+
+	return tx.run(ctx, fn)
+}
+
+// begin starts the transaction of tx, whose config must be already
+// set, and tx.release must be called once the transaction finishes.
+func begin(ctx context.Context, db TxBeginner, tx *Tx) error {
+	cfg := &tx.config
+	err := validateContext(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.quota != nil {
+		tx.releaseQuota, err = cfg.quota.acquire(contextWithConfigMetadata(ctx, cfg))
+		if err != nil {
+			return err
+		}
+	}
+
+	var start time.Time
+	var probe bool
+	if cfg.breaker != nil {
+		probe, err = cfg.breaker.allow()
+		if err != nil {
+			tx.freeQuota()
+			return err
+		}
+		start = time.Now()
+	}
+
+	tx.startReadOnly()
+	sqlTx, conn, err := startTx(ctx, db, cfg)
+	if cfg.breaker != nil {
+		cfg.breaker.record(probe, time.Since(start), err)
+	}
+	if err != nil {
+		tx.freeQuota()
+		return err
+	}
+	tx.acquireBuffers()
+
+	tx.sqlTx = sqlTx
+	tx.conn = conn
+	tx.cfg = cfg
+	tx.managed = true
+	tx.metadata = buildMetadata(ctx, cfg.metadata)
+	tx.statsRunner = statsRunner{next: sqlTx, tx: tx}
+
+	var base DBRunner = &tx.statsRunner
+	if cfg.statementTimeout > 0 {
+		base = timeoutRunner{next: base, tx: tx, timeout: cfg.statementTimeout}
+	}
+	if cfg.changeCapture != nil {
+		base = captureRunner{next: base, tx: tx, dialect: cfg.changeCapture}
+	}
+	// Above the captureRunner, which executes the writes as queries:
+	if cfg.maxRowsAffected > 0 || cfg.maxTotalRowsAffected > 0 {
+		base = rowsGuardRunner{next: base, tx: tx}
+	}
+	if cfg.argsValidation {
+		dialect := cfg.dialect
+		if dialect == nil {
+			dialect = SQLite
+		}
+		base = argsValidationRunner{next: base, dialect: dialect}
+	}
+	if cfg.readOnlyGuard {
+		base = readOnlyGuardRunner{next: base}
+	}
+	if cfg.readOnlyDetector != nil {
+		base = writeDetectionRunner{next: base, tx: tx}
+	}
+	tx.runner = buildRunner(base, cfg.middlewares)
+
+	err = tx.setActor(ctx)
+	if err == nil {
+		err = tx.setPriority(ctx)
+	}
+	if err == nil {
+		err = tx.setApplicationName(ctx)
+	}
+	if err == nil {
+		err = tx.loadSessionID(ctx)
+	}
+	if err != nil {
+		tx.freeQuota()
+		tx.releaseBuffers()
+		_ = sqlTx.Rollback()
+		if conn != nil {
+			closeSession(conn, *cfg.session)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// startTx starts the transaction, on the connection
+// of its session when it is started WithSession.
+func startTx(ctx context.Context, db TxBeginner, cfg *config) (*sql.Tx, *sql.Conn, error) {
+	txBeginner := db
+	var conn *sql.Conn
+	if cfg.session != nil {
+		var err error
+		conn, err = openSession(ctx, db, *cfg.session)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		txBeginner = conn
+	}
+
+	var txOpts *sql.TxOptions
+	if cfg.readOnly || cfg.isolation != sql.LevelDefault {
+		txOpts = &sql.TxOptions{ReadOnly: cfg.readOnly, Isolation: cfg.isolation}
+	}
+
+	sqlTx, err := txBeginner.BeginTx(ctx, txOpts)
+	if err != nil {
+		if conn != nil {
+			closeSession(conn, *cfg.session)
+		}
+		return nil, nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	return sqlTx, conn, nil
+}
+
+// freeQuota releases the slot of the transaction on its Quota, if any.
+func (tx *Tx) freeQuota() {
+	if tx.releaseQuota != nil {
+		tx.releaseQuota()
+		tx.releaseQuota = nil
+	}
+}
+
+// release unregisters the transaction and closes
+// its session, if any, once it finishes.
+func (tx *Tx) release() {
+	tx.mu.Lock()
+	registered := tx.registered
+	cancelDone := tx.cancelDone
+	tx.mu.Unlock()
+
+	if cancelDone != nil {
+		cancelDone()
+	}
+	tx.releaseBuffers()
+	tx.freeQuota()
+
+	if registered {
+		managedTxs.Lock()
+		delete(managedTxs.m, tx.sqlTx)
+		managedTxs.Unlock()
+	}
+
+	if tx.conn != nil {
+		if tx.abandoned {
+			// The teardown would wait for the connection to be released:
+			go closeSession(tx.conn, *tx.cfg.session)
+			return
+		}
+		closeSession(tx.conn, *tx.cfg.session)
+	}
+}
+
+func (tx *Tx) run(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	tx.onBegin(ctx)
+	defer tx.startHeartbeat(ctx)()
+
+	// Handle panics by rolling back the transaction
+	defer func() {
+		if r := recover(); r != nil {
+			rollbackErr := tx.rollback(ctx)
+			if rollbackErr != nil {
+				r = fmt.Errorf(
+					"unable to rollback after panic with value: %v, rollback error: %w",
+					r, rollbackErr,
+				)
+			}
+			panicErr := fmt.Errorf("panic: %v", r)
+			tx.runAfterRollback(ctx, panicErr)
+			tx.onRollback(ctx, panicErr)
+			panic(r)
+		}
+	}()
+
+	// Execute the callback with the transaction
+	return tx.finish(ctx, fn(tx))
+}
+
+// finish commits the transaction if err is nil and
+// rolls it back with err otherwise.
+func (tx *Tx) finish(ctx context.Context, err error) error {
+	var callbackErrs []error
+	if err == nil {
+		err = tx.abortError()
+	}
+	if err == nil {
+		callbackErrs, err = tx.runBeforeCommit(ctx)
+	}
+	if err == nil && ctx.Err() != nil {
+		// The caller would never observe the outcome of the commit:
+		err = fmt.Errorf("%w: %w", ErrContextCancelled, ctx.Err())
+	}
+	if err != nil {
+		err = tx.diagnoseLocks(ctx, err)
+		rollbackErr := tx.rollback(ctx)
+		if errors.Is(rollbackErr, sql.ErrTxDone) && ctx.Err() != nil {
+			// database/sql rolls back the transactions whose context is done:
+			rollbackErr = nil
+		}
+		if rollbackErr != nil {
+			err = fmt.Errorf(
+				"unable to rollback after error: %s, rollback error: %w",
+				err, rollbackErr,
+			)
+		}
+		tx.runAfterRollback(ctx, err)
+		tx.onRollback(ctx, err)
+		return err
+	}
+
+	// Commit the transaction
+	commitStart := time.Now()
+	err = tx.commit(ctx)
+	tx.commitLatency = time.Since(commitStart)
+	if err != nil {
+		err = tx.diagnoseLocks(ctx, tx.checkCommitErr(ctx, err))
+	}
+	if errors.Is(err, ErrCommitAmbiguous) {
+		// The transaction might have been committed,
+		// so the rollback callbacks must not run:
+		tx.commitAmbiguous = true
+		tx.onCommitAmbiguous(ctx, err)
+		return err
+	}
+	if err != nil {
+		tx.runAfterRollback(ctx, err)
+		tx.onRollback(ctx, err)
+		return err
+	}
+
+	tx.committed = true
+	tx.runAfterCommit(ctx)
+	tx.onCommit(ctx)
+	return joinCallbackErrors(callbackErrs)
+}
+
+// TxFromRunner returns the transaction managed by ktx behind db, which can
+// be a *Tx, the *sql.Tx received by the callbacks of Transaction or a
+// wrapper of one of them that implements `Unwrap() DBRunner`.
+//
+// It also works on the runner received by a Middleware as long as the
+// middlewares registered after it implement Unwrap as well.
+func TxFromRunner(db DBRunner) (*Tx, error) {
+	for {
+		switch r := db.(type) {
+		case *statsRunner:
+			return r.tx, nil
+		case *Tx:
+			if !r.managed {
+				return nil, ErrTxNotManaged
+			}
+			return r, nil
+		case *sql.Tx:
+			managed, ok := loadManagedTx(r)
+			if !ok {
+				return nil, ErrTxNotManaged
+			}
+			return managed, nil
+		case interface{ Unwrap() DBRunner }:
+			db = r.Unwrap()
+		default:
+			return nil, ErrTxNotManaged
+		}
+	}
+}
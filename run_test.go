@@ -0,0 +1,265 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun_Success(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	count := countDbUsers(t, db)
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+}
+
+func TestRun_RollbackOnError(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	testError := errors.New("test error")
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+		if err != nil {
+			return err
+		}
+		return testError
+	})
+	if err != testError {
+		t.Fatalf("Expected test error, got: %v", err)
+	}
+
+	count := countDbUsers(t, db)
+	if count != 0 {
+		t.Errorf("Expected 0 users (rollback should have occurred), got %d", count)
+	}
+}
+
+func TestRun_CancelledBeforeCommit(t *testing.T) {
+	t.Run("should rollback instead of committing", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var rolledBack error
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			cancel()
+			return err
+		}, WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				rolledBack = err
+			},
+		}))
+		if !errors.Is(err, ErrContextCancelled) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected ErrContextCancelled wrapping context.Canceled, got: %v", err)
+		}
+		if rolledBack != err {
+			t.Errorf("Expected the OnRollback hooks to receive the same error, got: %v", rolledBack)
+		}
+
+		count := countDbUsers(t, db)
+		if count != 0 {
+			t.Errorf("Expected 0 users (rollback should have occurred), got %d", count)
+		}
+	})
+
+	t.Run("should ignore the rollback done by database/sql", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			cancel()
+
+			// Lets database/sql roll the transaction back on its own:
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+		if !errors.Is(err, ErrContextCancelled) || errors.Is(err, sql.ErrTxDone) {
+			t.Fatalf("Expected only ErrContextCancelled, got: %v", err)
+		}
+	})
+}
+
+func TestRun_NestedWithTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+		if err != nil {
+			return err
+		}
+
+		return Transaction(ctx, tx, func(sqlTx *sql.Tx) error {
+			if sqlTx != tx.SQLTx() {
+				t.Fatal("expected the transaction to be reused")
+			}
+
+			return Run(ctx, sqlTx, func(tx2 *Tx) error {
+				_, err := tx2.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "Jane", "jane@example.com")
+				return err
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("Nested transaction failed: %v", err)
+	}
+
+	count := countDbUsers(t, db)
+	if count != 2 {
+		t.Errorf("Expected 2 users, got %d", count)
+	}
+}
+
+// TestRun_Allocations guards the allocation budget of the common path:
+// Run may only allocate the *Tx on top of what database/sql allocates,
+// and statements may not allocate anything on top of database/sql.
+//
+// A driver that does nothing is used so the allocations
+// of a real driver don't add noise to the measurements.
+func TestRun_Allocations(t *testing.T) {
+	db := sql.OpenDB(noopConnector{})
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	noop := func(tx *Tx) error { return nil }
+	query := "UPDATE users SET name = 'John' WHERE id = 1"
+
+	sqlAllocs := minAllocsPerRun(func() {
+		sqlTx, _ := db.BeginTx(ctx, nil)
+		_ = sqlTx.Commit()
+	})
+	ktxAllocs := minAllocsPerRun(func() {
+		_ = Run(ctx, db, noop)
+	})
+	if ktxAllocs > sqlAllocs+1 {
+		t.Errorf("expected Run to allocate at most %v times, got %v", sqlAllocs+1, ktxAllocs)
+	}
+
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	sqlAllocs = minAllocsPerRun(func() {
+		_, _ = sqlTx.ExecContext(ctx, query)
+	})
+	_ = sqlTx.Rollback()
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		ktxAllocs = minAllocsPerRun(func() {
+			_, _ = tx.ExecContext(ctx, query)
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if ktxAllocs > sqlAllocs {
+		t.Errorf("expected ExecContext to allocate at most %v times, got %v", sqlAllocs, ktxAllocs)
+	}
+}
+
+// noopConnector creates connections whose operations do nothing.
+type noopConnector struct{}
+
+func (noopConnector) Connect(context.Context) (driver.Conn, error) { return noopConn{}, nil }
+func (noopConnector) Driver() driver.Driver                        { return nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (noopConn) Close() error                        { return nil }
+func (noopConn) Begin() (driver.Tx, error)           { return noopConn{}, nil }
+func (noopConn) Commit() error                       { return nil }
+func (noopConn) Rollback() error                     { return nil }
+
+func (noopConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+// minAllocsPerRun returns the minimum of a few measurements of
+// testing.AllocsPerRun, so allocations made concurrently by the
+// goroutines of other tests don't affect the result.
+func minAllocsPerRun(fn func()) float64 {
+	allocs := testing.AllocsPerRun(1000, fn)
+	for i := 0; i < 4; i++ {
+		allocs = min(allocs, testing.AllocsPerRun(1000, fn))
+	}
+	return allocs
+}
+
+func BenchmarkRun(b *testing.B) {
+	db := setupTestDB(b)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	noop := func(tx *Tx) error { return nil }
+
+	b.Run("database/sql", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sqlTx, _ := db.BeginTx(ctx, nil)
+			_ = sqlTx.Commit()
+		}
+	})
+
+	b.Run("ktx.Run", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Run(ctx, db, noop)
+		}
+	})
+
+	b.Run("ktx.Run with options", func(b *testing.B) {
+		opts := []Option{WithName("benchmark"), WithHooks(Hooks{})}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Run(ctx, db, noop, opts...)
+		}
+	})
+
+	b.Run("ktx.Run with callbacks", func(b *testing.B) {
+		beforeCommit := func(ctx context.Context) error { return nil }
+		afterCommit := func(ctx context.Context) {}
+		afterRollback := func(ctx context.Context, err error) {}
+		withCallbacks := func(tx *Tx) error {
+			for i := 0; i < 4; i++ {
+				_ = BeforeCommit(tx, beforeCommit)
+				_ = AfterCommit(tx, afterCommit)
+				_ = AfterRollback(tx, afterRollback)
+			}
+			return nil
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = Run(ctx, db, withCallbacks)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Savepoint creates a savepoint with the input name inside the transaction,
+// so the statements executed after it can be undone with RollbackToSavepoint
+// without aborting the whole transaction.
+//
+// The name is quoted according to the dialect, so it can be any string,
+// a nil dialect uses the standard syntax and double-quoted names.
+func Savepoint(ctx context.Context, db DBRunner, dialect Dialect, name string) error {
+	return execSavepoint(ctx, db, savepointStmt(dialect, quoteSavepoint(dialect, name)))
+}
+
+// ReleaseSavepoint destroys the savepoint with the input name, keeping
+// the effects of the statements executed after it.
+//
+// SQL Server and Oracle have no way of releasing savepoints, so
+// it only checks that db is a transaction on these dialects.
+func ReleaseSavepoint(ctx context.Context, db DBRunner, dialect Dialect, name string) error {
+	return execSavepoint(ctx, db, releaseSavepointStmt(dialect, quoteSavepoint(dialect, name)))
+}
+
+// RollbackToSavepoint undoes the statements executed after the savepoint
+// with the input name was created. The savepoint itself is kept, so it can
+// be rolled back to again.
+//
+// On Postgres this is also what makes an aborted transaction usable again
+// after a statement fails.
+func RollbackToSavepoint(ctx context.Context, db DBRunner, dialect Dialect, name string) error {
+	return execSavepoint(ctx, db, rollbackToSavepointStmt(dialect, quoteSavepoint(dialect, name)))
+}
+
+// quoteSavepoint quotes the name according to the dialect,
+// or using the standard double quotes if the dialect is nil.
+func quoteSavepoint(dialect Dialect, name string) string {
+	if dialect == nil {
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return dialect.Quote(name)
+}
+
+// The statements for handling savepoints, where the dialect can be nil
+// for the standard syntax and the name must already be quoted if needed.
+
+func savepointStmt(dialect Dialect, name string) string {
+	if dialect != nil && dialect.Name() == SQLServer.Name() {
+		return "SAVE TRANSACTION " + name
+	}
+	return "SAVEPOINT " + name
+}
+
+func releaseSavepointStmt(dialect Dialect, name string) string {
+	if dialect != nil && (dialect.Name() == SQLServer.Name() || dialect.Name() == Oracle.Name()) {
+		return ""
+	}
+	return "RELEASE SAVEPOINT " + name
+}
+
+func rollbackToSavepointStmt(dialect Dialect, name string) string {
+	if dialect != nil && dialect.Name() == SQLServer.Name() {
+		return "ROLLBACK TRANSACTION " + name
+	}
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// execSavepoint executes the statement inside the transaction,
+// an empty statement is a no-op.
+func execSavepoint(ctx context.Context, db DBRunner, stmt string) error {
+	_, err := TxFromRunner(db)
+	if err != nil {
+		return err
+	}
+	if stmt == "" {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, stmt)
+	if err != nil {
+		return fmt.Errorf("error executing '%s': %w", stmt, err)
+	}
+	return nil
+}
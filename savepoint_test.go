@@ -0,0 +1,187 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSavepoint(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	countUsers := func(t *testing.T, db DBRunner) int {
+		rows, err := db.QueryContext(ctx, "SELECT COUNT(*) FROM users")
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		defer func() { _ = rows.Close() }()
+
+		var count int
+		rows.Next()
+		err = rows.Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		return count
+	}
+
+	t.Run("should rollback only the statements after the savepoint", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			if err != nil {
+				return err
+			}
+
+			err = Savepoint(ctx, tx, SQLite, `my "savepoint"`)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Jane', 'jane@example.com')")
+			if err != nil {
+				return err
+			}
+
+			err = RollbackToSavepoint(ctx, tx, SQLite, `my "savepoint"`)
+			if err != nil {
+				return err
+			}
+
+			return ReleaseSavepoint(ctx, tx, SQLite, `my "savepoint"`)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if count := countUsers(t, db); count != 1 {
+			t.Errorf("expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("should keep the statements of released savepoints", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Savepoint(ctx, tx, SQLite, "sp")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Jane', 'jane@example.com')")
+			if err != nil {
+				return err
+			}
+
+			return ReleaseSavepoint(ctx, tx, SQLite, "sp")
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if count := countUsers(t, db); count != 2 {
+			t.Errorf("expected 2 users, got %d", count)
+		}
+	})
+
+	t.Run("should report unknown savepoints", func(t *testing.T) {
+		err := Run(ctx, db, func(tx *Tx) error {
+			return RollbackToSavepoint(ctx, tx, SQLite, "unknown")
+		})
+		if err == nil {
+			t.Errorf("expected an error for an unknown savepoint")
+		}
+	})
+
+	t.Run("should use the standard syntax without a dialect", func(t *testing.T) {
+		before := countUsers(t, db)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Mary', 'mary@example.com')")
+			if err != nil {
+				return err
+			}
+
+			err = Savepoint(ctx, tx, nil, `my "savepoint"`)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('Mark', 'mark@example.com')")
+			if err != nil {
+				return err
+			}
+
+			err = RollbackToSavepoint(ctx, tx, nil, `my "savepoint"`)
+			if err != nil {
+				return err
+			}
+
+			return ReleaseSavepoint(ctx, tx, nil, `my "savepoint"`)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if count := countUsers(t, db); count != before+1 {
+			t.Errorf("expected %d users, got %d", before+1, count)
+		}
+	})
+
+	t.Run("should use SAVE TRANSACTION on sqlserver", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			for _, fn := range []func(context.Context, DBRunner, Dialect, string) error{
+				Savepoint, RollbackToSavepoint, ReleaseSavepoint,
+			} {
+				err := fn(ctx, tx, SQLServer, "sp")
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{"SAVE TRANSACTION [sp] []", "ROLLBACK TRANSACTION [sp] []"}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should not release savepoints on oracle", func(t *testing.T) {
+		fake := &procedureConnector{}
+		db := sql.OpenDB(fake)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			err := Savepoint(ctx, tx, Oracle, "sp")
+			if err != nil {
+				return err
+			}
+			return ReleaseSavepoint(ctx, tx, Oracle, "sp")
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		expected := []string{`SAVEPOINT "sp" []`}
+		if !reflect.DeepEqual(fake.statements(), expected) {
+			t.Errorf("expected statements %v, got %v", expected, fake.statements())
+		}
+	})
+
+	t.Run("should require a transaction", func(t *testing.T) {
+		err := Savepoint(ctx, db, SQLite, "sp")
+		if !errors.Is(err, ErrTxNotManaged) {
+			t.Errorf("expected ErrTxNotManaged, got: %v", err)
+		}
+	})
+}
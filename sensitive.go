@@ -0,0 +1,81 @@
+package ktx
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+)
+
+// redacted replaces the values of the Sensitive arguments
+// wherever they are formatted.
+const redacted = "[REDACTED]"
+
+// SensitiveValue is an argument wrapped by Sensitive.
+type SensitiveValue struct {
+	value interface{}
+}
+
+// Sensitive marks an argument as personal or secret data, so it is sent to
+// the database as usual but masked wherever it is formatted, such as on the
+// output of WithDebug, on the spans of ktxotel and on error messages:
+//
+//	_, err := tx.ExecContext(ctx, "UPDATE users SET ssn = ? WHERE id = ?", ktx.Sensitive(ssn), id)
+//
+// The value is unwrapped by the *Tx right before reaching the driver, so
+// middlewares only see the SensitiveValue. When used outside of a *Tx it is
+// converted by its driver.Valuer implementation, which only supports
+// the types accepted by database/sql.
+func Sensitive(v interface{}) SensitiveValue {
+	return SensitiveValue{value: v}
+}
+
+// Unwrap returns the value wrapped by Sensitive.
+func (s SensitiveValue) Unwrap() interface{} {
+	return s.value
+}
+
+// Value implements driver.Valuer.
+func (s SensitiveValue) Value() (driver.Value, error) {
+	return driver.DefaultParameterConverter.ConvertValue(s.value)
+}
+
+// Format masks the value on every fmt verb, including %#v.
+func (s SensitiveValue) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(redacted))
+}
+
+// String implements fmt.Stringer.
+func (s SensitiveValue) String() string {
+	return redacted
+}
+
+// LogValue implements slog.LogValuer.
+func (s SensitiveValue) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalJSON masks the value when the arguments are encoded as JSON.
+func (s SensitiveValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// unwrapSensitive returns the args with the SensitiveValues replaced by
+// their values, only allocating a new slice if there are any.
+func unwrapSensitive(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if _, ok := arg.(SensitiveValue); !ok {
+			continue
+		}
+
+		unwrapped := make([]interface{}, len(args))
+		copy(unwrapped, args[:i])
+		for j := i; j < len(args); j++ {
+			unwrapped[j] = args[j]
+			if s, ok := args[j].(SensitiveValue); ok {
+				unwrapped[j] = s.value
+			}
+		}
+		return unwrapped
+	}
+	return args
+}
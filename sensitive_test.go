@@ -0,0 +1,64 @@
+package ktx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSensitive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should mask the value when formatted", func(t *testing.T) {
+		s := Sensitive("123-45-6789")
+		for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q"} {
+			if got := fmt.Sprintf(verb, []interface{}{s}); strings.Contains(got, "6789") {
+				t.Errorf("expected %s to mask the value, got: %s", verb, got)
+			}
+		}
+
+		b, err := json.Marshal([]interface{}{s})
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if string(b) != `["[REDACTED]"]` {
+			t.Errorf("expected the JSON to mask the value, got: %s", b)
+		}
+	})
+
+	t.Run("should send the value to the database", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var debug bytes.Buffer
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", Sensitive("john@example.com"))
+			return err
+		}, WithDebug(&debug))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if strings.Contains(debug.String(), "john@example.com") {
+			t.Errorf("expected the debug output to mask the value, got: %s", debug.String())
+		}
+
+		// Outside of a *Tx the value is converted by driver.Valuer:
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", Sensitive("john@example.com")).Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 1 {
+			t.Fatalf("expected the value to be stored, got %d users", count)
+		}
+	})
+
+	t.Run("should not mix up different values on memo keys", func(t *testing.T) {
+		if memoKey("q", []interface{}{Sensitive(1)}) == memoKey("q", []interface{}{Sensitive(2)}) {
+			t.Fatal("expected different values to have different keys")
+		}
+	})
+}
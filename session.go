@@ -0,0 +1,95 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// ConnProvider represents a connection pool that can hand out dedicated
+// connections, such as *sql.DB.
+type ConnProvider interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// Session describes the connection-scoped state that should be prepared
+// on the dedicated connection used by WithSession.
+type Session struct {
+	// Setup statements run on the connection before the transaction
+	// begins, e.g. SET statements or the creation of temporary tables.
+	Setup []string
+
+	// Teardown statements run on the connection after the transaction
+	// finishes and before the connection is returned to the pool.
+	//
+	// If any of them fail the connection is discarded instead of being
+	// returned to the pool so the session state can't leak into other
+	// transactions.
+	Teardown []string
+}
+
+// WithSession makes Run begin the transaction on a dedicated *sql.Conn
+// and execute the Setup statements of the session on it before the
+// callback is called, which is needed for features that depend on
+// connection-scoped state.
+//
+// The db passed to Run must implement ConnProvider.
+func WithSession(s Session) Option {
+	return func(c *config) {
+		c.session = &s
+	}
+}
+
+// Conn returns the dedicated connection the transaction is running on
+// when it was started WithSession, or nil otherwise.
+//
+// It is useful for driver-specific features that need the underlying
+// driver connection, which can be reached with (*sql.Conn).Raw, but the
+// connection should not be closed since this is done by ktx.
+func (tx *Tx) Conn() *sql.Conn {
+	return tx.conn
+}
+
+func openSession(ctx context.Context, db DBRunner, s Session) (*sql.Conn, error) {
+	provider, ok := db.(ConnProvider)
+	if !ok {
+		return nil, fmt.Errorf("provided db does not implement ConnProvider interface required by WithSession")
+	}
+
+	conn, err := provider.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring session connection: %w", err)
+	}
+
+	for _, stmt := range s.Setup {
+		_, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			discardConn(conn)
+			return nil, fmt.Errorf("error running session setup statement '%s': %w", stmt, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func closeSession(conn *sql.Conn, s Session) {
+	for _, stmt := range s.Teardown {
+		_, err := conn.ExecContext(context.Background(), stmt)
+		if err != nil {
+			discardConn(conn)
+			return
+		}
+	}
+
+	_ = conn.Close()
+}
+
+// discardConn closes the connection and makes sure it is removed
+// from the pool instead of being reused.
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+	_ = conn.Close()
+}
@@ -0,0 +1,113 @@
+package ktx
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRun_WithSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	var value string
+	err := Run(ctx, db, func(tx *Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT value FROM session_data")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rows.Close() }()
+
+		rows.Next()
+		return rows.Scan(&value)
+	}, WithSession(Session{
+		Setup: []string{
+			"CREATE TEMP TABLE session_data (value TEXT)",
+			"INSERT INTO session_data VALUES ('fake-value')",
+		},
+		Teardown: []string{
+			"DROP TABLE temp.session_data",
+		},
+	}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if value != "fake-value" {
+		t.Errorf("Expected 'fake-value', got '%s'", value)
+	}
+
+	// The teardown should have removed the session state from the connection:
+	_, err = db.Exec("SELECT value FROM session_data")
+	if err == nil {
+		t.Fatal("expected session table to be dropped by the teardown")
+	}
+}
+
+func TestRun_WithSessionSetupError(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	called := false
+	err := Run(context.Background(), db, func(tx *Tx) error {
+		called = true
+		return nil
+	}, WithSession(Session{
+		Setup: []string{"NOT VALID SQL"},
+	}))
+	if err == nil || !strings.Contains(err.Error(), "NOT VALID SQL") {
+		t.Fatalf("expected setup error, got: %v", err)
+	}
+	if called {
+		t.Fatal("callback should not be called when setup fails")
+	}
+}
+
+func TestRun_WithSessionRequiresConnProvider(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	err = Run(context.Background(), conn, func(tx *Tx) error {
+		return nil
+	}, WithSession(Session{}))
+	if err == nil || !strings.Contains(err.Error(), "ConnProvider") {
+		t.Fatalf("expected ConnProvider error, got: %v", err)
+	}
+}
+
+func TestTx_Conn(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		if tx.Conn() != nil {
+			t.Errorf("expected no connection without WithSession")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		if tx.Conn() == nil {
+			t.Fatalf("expected the dedicated connection of the session")
+		}
+		return tx.Conn().Raw(func(driverConn interface{}) error {
+			return nil
+		})
+	}, WithSession(Session{}))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
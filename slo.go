@@ -0,0 +1,307 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// sloBuckets is how many buckets the window of an SLO is split into,
+// and sloShortBuckets how many of the most recent ones form the short
+// window, i.e. 1/12 of the window, e.g. 5 minutes of 1 hour.
+const (
+	sloBuckets      = 60
+	sloShortBuckets = 5
+)
+
+// SLO is the objective of the transactions with a given name.
+type SLO struct {
+	// Objective is the fraction of the transactions that must be good,
+	// e.g. 0.999. A transaction is bad when it fails or is slower than
+	// Latency.
+	Objective float64
+
+	// Latency is how long a transaction can take, including all of its
+	// attempts WithRetry, and still be good. Zero makes only the failures
+	// count as bad.
+	Latency time.Duration
+}
+
+// SLOBreach is reported to SLOTrackerOptions.OnBreach when the error
+// budget of the SLO of a transaction name is burning too fast.
+type SLOBreach struct {
+	Name string
+	SLO  SLO
+
+	// BurnRate is how many times faster than allowed by the SLO the error
+	// budget is being spent over the window, and ShortBurnRate over its
+	// last twelfth.
+	BurnRate      float64
+	ShortBurnRate float64
+}
+
+// SLOStatus is the compliance of a transaction name with its SLO.
+type SLOStatus struct {
+	// Total counts the transactions finished in the window
+	// and Bad how many of them failed or were too slow.
+	Total uint64
+	Bad   uint64
+
+	// Compliance is the fraction of good transactions in
+	// the window, or 1 if there were no transactions.
+	Compliance float64
+
+	BurnRate      float64
+	ShortBurnRate float64
+
+	// Breached is set from the moment OnBreach is called
+	// until one of the burn rates drops below the threshold.
+	Breached bool
+}
+
+// SLOTrackerOptions configures an SLOTracker.
+type SLOTrackerOptions struct {
+	// SLOs maps the names given WithName to their SLOs, the
+	// transactions with other names are not tracked.
+	SLOs map[string]SLO
+
+	// Window is the period over which the compliance and the
+	// burn rates are computed, defaults to 1 hour.
+	Window time.Duration
+
+	// BurnRate is the threshold that both burn rates must reach for
+	// OnBreach to be called, defaults to 14.4, i.e. spending 2% of the
+	// error budget of 30 days in 1 hour.
+	BurnRate float64
+
+	// MinTransactions is how many transactions must finish within the
+	// window before a breach is reported, defaults to 100, so a couple
+	// of failures on an idle name don't page anyone.
+	MinTransactions int
+
+	// OnBreach is called after the transaction that made the SLO
+	// of its name breach, once per breach.
+	OnBreach func(ctx context.Context, breach SLOBreach)
+
+	// IsFailure decides which errors make a transaction bad, defaults
+	// to all of them except ErrCallbacksFailed, since the transaction
+	// was committed, and the commits skipped by BeginFunc.
+	IsFailure func(err error) bool
+
+	// Clock is used for measuring the transactions and for
+	// the window, defaults to the system clock.
+	Clock Clock
+}
+
+// SLOTracker tracks the compliance of the transactions with the SLOs
+// declared for their names, and calls a hook when the error budget of one
+// of them burns too fast, making the transaction layer self-monitoring:
+//
+//	slos := ktx.NewSLOTracker(ktx.SLOTrackerOptions{
+//		SLOs: map[string]ktx.SLO{
+//			"create-order": {Objective: 0.999, Latency: 200 * time.Millisecond},
+//		},
+//		OnBreach: func(ctx context.Context, breach ktx.SLOBreach) {
+//			alerts.Page("SLO of %s burning %.1fx too fast", breach.Name, breach.BurnRate)
+//		},
+//	})
+//
+//	err := ktx.Run(ctx, db, createOrder, ktx.WithName("create-order"), slos.Option())
+//
+// Breaches use the multi-window approach: the burn rate must reach the
+// threshold both over the window and over its last twelfth, so the alert
+// fires quickly on a storm of failures and stops once it is over.
+//
+// A single SLOTracker should be shared by all the transactions it tracks.
+type SLOTracker struct {
+	opts   SLOTrackerOptions
+	bucket time.Duration
+
+	mu    sync.Mutex
+	names map[string]*sloWindow
+}
+
+type sloWindow struct {
+	buckets  [sloBuckets]sloBucket
+	breached bool
+}
+
+type sloBucket struct {
+	index int64
+	total uint64
+	bad   uint64
+}
+
+// NewSLOTracker creates an SLOTracker.
+func NewSLOTracker(opts SLOTrackerOptions) *SLOTracker {
+	if opts.Window <= 0 {
+		opts.Window = time.Hour
+	}
+	if opts.BurnRate <= 0 {
+		opts.BurnRate = 14.4
+	}
+	if opts.MinTransactions <= 0 {
+		opts.MinTransactions = 100
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isSLOFailure
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return &SLOTracker{
+		opts:   opts,
+		bucket: max(opts.Window/sloBuckets, time.Nanosecond),
+		names:  map[string]*sloWindow{},
+	}
+}
+
+// Option returns the Option that tracks a transaction,
+// it can be reused on any number of transactions.
+func (t *SLOTracker) Option() Option {
+	return func(c *config) {
+		c.sloTracker = t
+	}
+}
+
+// Status returns the compliance of the transactions with the input
+// name, and false if no SLO was declared for it.
+func (t *SLOTracker) Status(name string) (SLOStatus, bool) {
+	slo, ok := t.opts.SLOs[name]
+	if !ok {
+		return SLOStatus{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.names[name]
+	if w == nil {
+		return SLOStatus{Compliance: 1}, true
+	}
+	return t.status(w, slo, t.index(t.opts.Clock.Now())), true
+}
+
+func isSLOFailure(err error) bool {
+	return err != nil && err != ErrCommitSkipped && !errors.Is(err, ErrCallbacksFailed)
+}
+
+// observe runs the transaction configured with cfg with
+// run and records it on the SLO of its name, if any.
+func (t *SLOTracker) observe(ctx context.Context, cfg *config, run func() error) error {
+	slo, ok := t.opts.SLOs[cfg.name]
+	if !ok {
+		return run()
+	}
+
+	start := t.opts.Clock.Now()
+	err := run()
+	end := t.opts.Clock.Now()
+
+	bad := t.opts.IsFailure(err) || (slo.Latency > 0 && end.Sub(start) > slo.Latency)
+
+	breach, breached := t.record(cfg.name, slo, t.index(end), bad)
+	if breached && t.opts.OnBreach != nil {
+		t.opts.OnBreach(ctx, breach)
+	}
+
+	return err
+}
+
+// record counts the transaction on its bucket and reports
+// whether it made the SLO breach.
+func (t *SLOTracker) record(name string, slo SLO, index int64, bad bool) (breach SLOBreach, breached bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.names[name]
+	if w == nil {
+		w = &sloWindow{}
+		t.names[name] = w
+	}
+
+	b := &w.buckets[index%sloBuckets]
+	if b.index != index {
+		*b = sloBucket{index: index}
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+
+	status := t.status(w, slo, index)
+	burning := status.Total >= uint64(t.opts.MinTransactions) &&
+		status.BurnRate >= t.opts.BurnRate &&
+		status.ShortBurnRate >= t.opts.BurnRate
+
+	if burning == w.breached {
+		return SLOBreach{}, false
+	}
+	w.breached = burning
+	if !burning {
+		return SLOBreach{}, false
+	}
+
+	return SLOBreach{
+		Name:          name,
+		SLO:           slo,
+		BurnRate:      status.BurnRate,
+		ShortBurnRate: status.ShortBurnRate,
+	}, true
+}
+
+// status must be called with t.mu locked.
+func (t *SLOTracker) status(w *sloWindow, slo SLO, now int64) SLOStatus {
+	var total, bad, shortTotal, shortBad uint64
+	for _, b := range w.buckets {
+		age := now - b.index
+		if age < 0 || age >= sloBuckets {
+			continue
+		}
+
+		total += b.total
+		bad += b.bad
+		if age < sloShortBuckets {
+			shortTotal += b.total
+			shortBad += b.bad
+		}
+	}
+
+	status := SLOStatus{
+		Total:         total,
+		Bad:           bad,
+		Compliance:    1,
+		BurnRate:      burnRate(slo, total, bad),
+		ShortBurnRate: burnRate(slo, shortTotal, shortBad),
+		Breached:      w.breached,
+	}
+	if total > 0 {
+		status.Compliance = 1 - float64(bad)/float64(total)
+	}
+	return status
+}
+
+func (t *SLOTracker) index(now time.Time) int64 {
+	return now.UnixNano() / int64(t.bucket)
+}
+
+// burnRate returns how many times faster than allowed by
+// the SLO its error budget was spent by the transactions.
+func burnRate(slo SLO, total uint64, bad uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	budget := 1 - slo.Objective
+	if budget <= 0 {
+		// Any bad transaction breaches an objective of 100%:
+		if bad > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return float64(bad) / float64(total) / budget
+}
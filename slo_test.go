@@ -0,0 +1,147 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSLOTracker(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	fakeErr := errors.New("fake error")
+	succeed := func(tx *Tx) error { return nil }
+	fail := func(tx *Tx) error { return fakeErr }
+
+	newTracker := func(clock *fakeClock, breaches *[]SLOBreach) *SLOTracker {
+		return NewSLOTracker(SLOTrackerOptions{
+			SLOs: map[string]SLO{
+				"create-order": {Objective: 0.9, Latency: 100 * time.Millisecond},
+			},
+			Window:          time.Hour,
+			BurnRate:        2,
+			MinTransactions: 10,
+			OnBreach: func(ctx context.Context, breach SLOBreach) {
+				*breaches = append(*breaches, breach)
+			},
+			Clock: clock,
+		})
+	}
+
+	t.Run("should call the hook once when the error budget burns too fast", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		var breaches []SLOBreach
+		slos := newTracker(clock, &breaches)
+		opts := []Option{WithName("create-order"), slos.Option()}
+
+		for i := 0; i < 8; i++ {
+			_ = Run(ctx, db, succeed, opts...)
+		}
+		_ = Run(ctx, db, fail, opts...)
+		if len(breaches) != 0 {
+			t.Fatalf("expected no breach before MinTransactions, got: %+v", breaches)
+		}
+
+		for i := 0; i < 3; i++ {
+			err := Run(ctx, db, fail, opts...)
+			if !errors.Is(err, fakeErr) {
+				t.Fatalf("expected the error of the transaction, got: %v", err)
+			}
+		}
+		if len(breaches) != 1 {
+			t.Fatalf("expected 1 breach, got: %+v", breaches)
+		}
+		if breaches[0].Name != "create-order" || math.Abs(breaches[0].BurnRate-2) > 1e-9 {
+			t.Errorf("unexpected breach: %+v", breaches[0])
+		}
+
+		status, ok := slos.Status("create-order")
+		if !ok {
+			t.Fatalf("expected the name to be tracked")
+		}
+		if status.Total != 12 || status.Bad != 4 || !status.Breached {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("should count the slow transactions as bad", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		var breaches []SLOBreach
+		slos := newTracker(clock, &breaches)
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			clock.now = clock.now.Add(150 * time.Millisecond)
+			return nil
+		}, WithName("create-order"), slos.Option())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		_ = Run(ctx, db, succeed, WithName("create-order"), slos.Option())
+
+		status, _ := slos.Status("create-order")
+		if status.Total != 2 || status.Bad != 1 || status.Compliance != 0.5 {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	})
+
+	t.Run("should report a new breach after recovering", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		var breaches []SLOBreach
+		slos := newTracker(clock, &breaches)
+		opts := []Option{WithName("create-order"), slos.Option()}
+
+		for i := 0; i < 10; i++ {
+			_ = Run(ctx, db, fail, opts...)
+		}
+		if len(breaches) != 1 {
+			t.Fatalf("expected 1 breach, got: %d", len(breaches))
+		}
+
+		// The short window no longer holds the failures:
+		clock.now = clock.now.Add(10 * time.Minute)
+		_ = Run(ctx, db, succeed, opts...)
+
+		status, _ := slos.Status("create-order")
+		if status.Breached || status.ShortBurnRate != 0 {
+			t.Fatalf("expected the breach to be over, got: %+v", status)
+		}
+
+		_ = Run(ctx, db, fail, opts...)
+		if len(breaches) != 2 {
+			t.Errorf("expected a second breach, got: %d", len(breaches))
+		}
+
+		clock.now = clock.now.Add(time.Hour)
+		status, _ = slos.Status("create-order")
+		if status.Total != 0 || status.Compliance != 1 {
+			t.Errorf("expected the window to be empty, got: %+v", status)
+		}
+	})
+
+	t.Run("should ignore the commits skipped and the untracked names", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		var breaches []SLOBreach
+		slos := newTracker(clock, &breaches)
+
+		err := BeginFunc(ctx, db, func(tx *Tx) (bool, error) {
+			return false, nil
+		}, WithName("create-order"), slos.Option())
+		if err != nil {
+			t.Fatalf("BeginFunc failed: %v", err)
+		}
+		_ = Run(ctx, db, fail, WithName("other"), slos.Option())
+
+		status, _ := slos.Status("create-order")
+		if status.Total != 1 || status.Bad != 0 {
+			t.Errorf("unexpected status: %+v", status)
+		}
+		if _, ok := slos.Status("other"); ok {
+			t.Errorf("expected the name without an SLO not to be tracked")
+		}
+	})
+}
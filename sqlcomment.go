@@ -0,0 +1,108 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SQLCommenterOptions configures WithSQLCommenter.
+type SQLCommenterOptions struct {
+	// Tags returns extra key/value pairs for the comment of each statement,
+	// such as the traceparent of the span in ctx, which is what
+	// ktxotel.CommentTags returns. They take precedence over the tags
+	// added by ktx.
+	Tags func(ctx context.Context) map[string]string
+}
+
+// WithSQLCommenter appends a comment in the sqlcommenter format, e.g.
+// /*traceparent='00-...',transaction='create-order'*/, to every statement
+// executed inside the transaction, so the slow query logs and APM tools
+// on the database side can be correlated with the application:
+//
+//	err := ktx.Run(ctx, db, createOrder, ktx.WithName("create-order"),
+//		ktx.WithSQLCommenter(ktx.SQLCommenterOptions{Tags: ktxotel.CommentTags}),
+//	)
+//
+// The name of the transaction is added as the transaction tag and its ID,
+// if any, as transaction_id. Statements that already have a comment are
+// left untouched, as required by the format.
+//
+// Comments with values that change on each transaction, such as the
+// traceparent, make each statement unique, which defeats the caches of
+// prepared statements of some drivers and databases.
+func WithSQLCommenter(opts SQLCommenterOptions) Option {
+	return WithMiddleware(func(next DBRunner) DBRunner {
+		tx, _ := TxFromRunner(next)
+		return commentRunner{next: next, tx: tx, opts: opts}
+	})
+}
+
+type commentRunner struct {
+	next DBRunner
+	tx   *Tx
+	opts SQLCommenterOptions
+}
+
+func (r commentRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.next.ExecContext(ctx, r.comment(ctx, query), args...)
+}
+
+func (r commentRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.next.QueryContext(ctx, r.comment(ctx, query), args...)
+}
+
+func (r commentRunner) Unwrap() DBRunner {
+	return r.next
+}
+
+func (r commentRunner) comment(ctx context.Context, query string) string {
+	if strings.Contains(query, "/*") || strings.Contains(query, "--") {
+		return query
+	}
+
+	tags := map[string]string{}
+	if r.tx != nil {
+		if name := r.tx.Name(); name != "" {
+			tags["transaction"] = name
+		}
+		if id := r.tx.ID(); id != "" {
+			tags["transaction_id"] = id
+		}
+	}
+	if r.opts.Tags != nil {
+		for key, value := range r.opts.Tags(ctx) {
+			tags[key] = value
+		}
+	}
+
+	return appendComment(query, tags)
+}
+
+// appendComment serializes the tags as specified by sqlcommenter and
+// appends them to the query, before its trailing semicolon, if any.
+func appendComment(query string, tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if value == "" {
+			continue
+		}
+		pairs = append(pairs, escapeCommentTag(key)+"='"+escapeCommentTag(value)+"'")
+	}
+	if len(pairs) == 0 {
+		return query
+	}
+	sort.Strings(pairs)
+
+	trimmed := strings.TrimRight(query, " \t\r\n")
+	statement := strings.TrimSuffix(trimmed, ";")
+	return statement + " /*" + strings.Join(pairs, ",") + "*/" + trimmed[len(statement):]
+}
+
+// escapeCommentTag URL-encodes s, which also encodes its quotes
+// and slashes, so s can't end the value nor close the comment.
+func escapeCommentTag(s string) string {
+	return url.PathEscape(s)
+}
@@ -0,0 +1,119 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// queryRecorder is a runner that records the
+// statements received from the outer middlewares.
+type queryRecorder struct {
+	next    DBRunner
+	queries *[]string
+}
+
+func (r queryRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*r.queries = append(*r.queries, query)
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r queryRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*r.queries = append(*r.queries, query)
+	return r.next.QueryContext(ctx, query, args...)
+}
+
+func (r queryRecorder) Unwrap() DBRunner {
+	return r.next
+}
+
+func TestWithSQLCommenter(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	run := func(t *testing.T, fn func(tx *Tx) error, opts ...Option) []string {
+		t.Helper()
+
+		var queries []string
+		opts = append(opts, WithMiddleware(func(next DBRunner) DBRunner {
+			return queryRecorder{next: next, queries: &queries}
+		}))
+		err := Run(ctx, db, fn, opts...)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return queries
+	}
+
+	t.Run("should append the tags to the statements", func(t *testing.T) {
+		queries := run(t, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?);", "John", "john@example.com")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT name FROM users")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithName("create user"), WithSQLCommenter(SQLCommenterOptions{
+			Tags: func(ctx context.Context) map[string]string {
+				return map[string]string{
+					"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+					"route":       "/users/{id}",
+					"quote":       "it's",
+				}
+			},
+		}))
+
+		tags := "/*quote='it%27s',route='%2Fusers%2F%7Bid%7D'," +
+			"traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01',transaction='create%20user'*/"
+		expected := []string{
+			"INSERT INTO users (name, email) VALUES (?, ?) " + tags + ";",
+			"SELECT name FROM users " + tags,
+		}
+		if len(queries) != 2 || queries[0] != expected[0] || queries[1] != expected[1] {
+			t.Errorf("expected queries:\n%q\ngot:\n%q", expected, queries)
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should add the ID of the transaction", func(t *testing.T) {
+		var id string
+		queries := run(t, func(tx *Tx) error {
+			id = tx.ID()
+			_, err := tx.ExecContext(ctx, "DELETE FROM users")
+			return err
+		}, WithApplicationName("orders-api"), WithSQLCommenter(SQLCommenterOptions{}))
+
+		expected := "DELETE FROM users /*transaction_id='" + id + "'*/"
+		if len(queries) != 1 || queries[0] != expected {
+			t.Errorf("expected %q, got %q", expected, queries)
+		}
+	})
+
+	t.Run("should not change the statements with comments or without tags", func(t *testing.T) {
+		statements := []string{
+			"SELECT 1 /* existing */",
+			"SELECT 1 -- existing",
+			"SELECT 2",
+		}
+		queries := run(t, func(tx *Tx) error {
+			for _, query := range statements {
+				rows, err := tx.QueryContext(ctx, query)
+				if err != nil {
+					return err
+				}
+				_ = rows.Close()
+			}
+			return nil
+		}, WithSQLCommenter(SQLCommenterOptions{}))
+
+		if len(queries) != 3 || queries[0] != statements[0] || queries[1] != statements[1] || queries[2] != statements[2] {
+			t.Errorf("expected the statements untouched, got %q", queries)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TxStats summarizes the statements executed by a transaction.
+type TxStats struct {
+	// Statements counts the statements executed through the *Tx,
+	// including the ones that failed.
+	Statements int
+
+	// DBTime is the total time spent waiting for the database.
+	DBTime time.Duration
+
+	// SlowestStatement is the Fingerprint of the slowest
+	// statement and SlowestDuration is how long it took.
+	SlowestStatement string
+	SlowestDuration  time.Duration
+
+	// RowsAffected is the sum of the rows affected by the statements
+	// executed with ExecContext.
+	//
+	// Rows read by QueryContext are not counted since the *sql.Rows
+	// are consumed by the caller.
+	RowsAffected int64
+
+	// Execs counts the statements executed with ExecContext.
+	Execs int
+
+	// CommitSkipped is true when the transaction was started WithReadOnly
+	// and, since it executed no statements with ExecContext, it was rolled
+	// back instead of committed.
+	CommitSkipped bool
+}
+
+// Stats returns the statistics of the statements executed so far
+// through the *Tx, which is mostly useful on the OnCommit and
+// OnRollback hooks.
+func (tx *Tx) Stats() TxStats {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	stats := tx.stats
+	stats.SlowestStatement = Fingerprint(tx.slowestQuery)
+	return stats
+}
+
+func (tx *Tx) recordStatement(query string, took time.Duration, rowsAffected int64, exec bool) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.stats.Statements++
+	if exec {
+		tx.stats.Execs++
+	}
+	tx.stats.DBTime += took
+	tx.stats.RowsAffected += rowsAffected
+	if took > tx.stats.SlowestDuration || tx.stats.Statements == 1 {
+		tx.stats.SlowestDuration = took
+		// The fingerprint is computed lazily by Stats
+		// to keep it out of the path of each statement:
+		tx.slowestQuery = query
+	}
+}
+
+// recordCapturedExec records the rows affected by a statement executed
+// with ExecContext that WithChangeCapture turned into a query, which the
+// statsRunner has already counted as a statement but not as an exec.
+func (tx *Tx) recordCapturedExec(rowsAffected int64) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.stats.Execs++
+	tx.stats.RowsAffected += rowsAffected
+}
+
+// rowsAffected returns the rows affected so far
+// without the cost of building the whole TxStats.
+func (tx *Tx) rowsAffected() int64 {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.stats.RowsAffected
+}
+
+// statementCount returns the statements executed so far
+// without the cost of building the whole TxStats.
+func (tx *Tx) statementCount() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.stats.Statements
+}
+
+// statsRunner is the innermost runner of every managed transaction,
+// so the time measured doesn't include the time spent on middlewares.
+type statsRunner struct {
+	next DBRunner
+	tx   *Tx
+}
+
+func (r *statsRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := r.next.ExecContext(ctx, query, unwrapSensitive(args)...)
+	took := time.Since(start)
+
+	var rows int64
+	if err == nil {
+		rows, _ = result.RowsAffected()
+	}
+	r.tx.recordStatement(query, took, rows, true)
+
+	return result, err
+}
+
+func (r *statsRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := r.next.QueryContext(ctx, query, unwrapSensitive(args)...)
+	r.tx.recordStatement(query, time.Since(start), 0, false)
+
+	return rows, err
+}
+
+func (r *statsRunner) Unwrap() DBRunner {
+	return r.next
+}
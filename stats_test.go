@@ -0,0 +1,85 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTxStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	t.Run("should summarize the statements of the transaction", func(t *testing.T) {
+		var stats TxStats
+		err := Run(ctx, db, func(tx *Tx) error {
+			for _, email := range []string{"a@example.com", "b@example.com"} {
+				_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", email)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err := tx.ExecContext(ctx, "UPDATE users SET name = ? WHERE name = ?", "Jane", "John")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT id FROM users")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				stats = tx.Stats()
+			},
+		}))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if stats.Statements != 4 {
+			t.Errorf("expected 4 statements, got %d", stats.Statements)
+		}
+		if stats.RowsAffected < 4 {
+			t.Errorf("expected at least 4 rows affected, got %d", stats.RowsAffected)
+		}
+		if stats.DBTime <= 0 || stats.SlowestDuration <= 0 || stats.SlowestDuration > stats.DBTime {
+			t.Errorf("unexpected durations: %+v", stats)
+		}
+
+		validFingerprints := map[string]bool{
+			"insert into users (name, email) values (...)": true,
+			"update users set name = ? where name = ?":     true,
+			"select id from users":                         true,
+		}
+		if !validFingerprints[stats.SlowestStatement] {
+			t.Errorf("unexpected slowest statement: %q", stats.SlowestStatement)
+		}
+	})
+
+	t.Run("should be available on rollback", func(t *testing.T) {
+		var stats TxStats
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO missing_table VALUES (1)")
+			return errors.Join(err, errors.New("fake error"))
+		}, WithHooks(Hooks{
+			OnRollback: func(ctx context.Context, tx *Tx, err error) {
+				stats = tx.Stats()
+			},
+		}))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if stats.Statements != 1 || stats.RowsAffected != 0 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+		if stats.SlowestStatement != "insert into missing_table values (...)" {
+			t.Errorf("unexpected slowest statement: %q", stats.SlowestStatement)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrAssertionFailed is wrapped by the errors of the
+// assertions of Steps that are violated.
+var ErrAssertionFailed = errors.New("assertion failed")
+
+// TxStep is a named stage of a workflow executed by Steps.
+type TxStep struct {
+	name string
+	fn   func(ctx context.Context, tx *Tx) error
+
+	// checks run after fn with the rows it affected.
+	checks []func(rowsAffected int64) error
+}
+
+// Step creates a named stage for Steps.
+func Step(name string, fn func(tx *Tx) error) TxStep {
+	return TxStep{
+		name: name,
+		fn: func(ctx context.Context, tx *Tx) error {
+			return fn(tx)
+		},
+	}
+}
+
+// ExpectRowsAffected makes the step fail unless the statements it
+// executes with ExecContext affect exactly n rows in total, e.g. for
+// detecting that an UPDATE didn't find the row it was meant to change.
+func (s TxStep) ExpectRowsAffected(n int64) TxStep {
+	s.checks = append(s.checks[:len(s.checks):len(s.checks)], func(rowsAffected int64) error {
+		if rowsAffected != n {
+			return fmt.Errorf("%w: expected %d rows affected, got %d", ErrAssertionFailed, n, rowsAffected)
+		}
+		return nil
+	})
+	return s
+}
+
+// Invariant creates a step that runs a query returning a single boolean
+// and fails, rolling back the transaction, if it is not true:
+//
+//	ktx.Invariant("balance >= 0", "SELECT balance >= 0 FROM accounts WHERE id = $1", id)
+//
+// The description is used as the name of the step.
+func Invariant(description string, query string, args ...interface{}) TxStep {
+	return TxStep{name: description, fn: func(ctx context.Context, tx *Tx) error {
+		var ok bool
+		err := queryValue(ctx, tx, &ok, query, args...)
+		if err != nil {
+			return fmt.Errorf("error checking invariant: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: invariant violated", ErrAssertionFailed)
+		}
+		return nil
+	}}
+}
+
+// StepError is returned by Steps with the name of the step that failed.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step '%s' failed: %s", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Steps runs the steps in order inside a single transaction, stopping
+// at the first one that fails, whose error is wrapped in a *StepError
+// so it is clear which stage of the workflow failed:
+//
+//	err := ktx.Steps(ctx, db,
+//		ktx.Step("reserve-stock", reserveStock),
+//		ktx.Step("charge", charge),
+//	)
+//
+// Assertions such as ExpectRowsAffected and Invariant abort the workflow
+// with an error wrapping ErrAssertionFailed when they are violated.
+//
+// As with Run, if db is already a transaction the steps run inside of it,
+// so Options can be set by calling Steps from the callback of Run.
+func Steps(ctx context.Context, db DBRunner, steps ...TxStep) error {
+	return Run(ctx, db, func(tx *Tx) error {
+		for _, step := range steps {
+			before := tx.rowsAffected()
+			err := step.fn(ctx, tx)
+			for i := 0; err == nil && i < len(step.checks); i++ {
+				err = step.checks[i](tx.rowsAffected() - before)
+			}
+			if err != nil {
+				return &StepError{Step: step.name, Err: err}
+			}
+		}
+		return nil
+	})
+}
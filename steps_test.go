@@ -0,0 +1,145 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	ctx := context.Background()
+
+	insertUser := func(name string) func(tx *Tx) error {
+		return func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", name, name+"@example.com")
+			return err
+		}
+	}
+
+	t.Run("should run all steps in the same transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var executed []string
+		err := Steps(ctx, db,
+			Step("first", func(tx *Tx) error {
+				executed = append(executed, "first")
+				return insertUser("john")(tx)
+			}),
+			Step("second", func(tx *Tx) error {
+				executed = append(executed, "second")
+				return insertUser("jane")(tx)
+			}),
+		)
+		if err != nil {
+			t.Fatalf("Steps failed: %v", err)
+		}
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 2 || len(executed) != 2 {
+			t.Errorf("expected both steps to be committed, got %d users and steps %v", count, executed)
+		}
+	})
+
+	t.Run("should wrap the error with the name of the failing step", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		calledLast := false
+		err := Steps(ctx, db,
+			Step("reserve-stock", insertUser("john")),
+			Step("charge", func(tx *Tx) error { return fakeErr }),
+			Step("notify", func(tx *Tx) error {
+				calledLast = true
+				return nil
+			}),
+		)
+
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != "charge" {
+			t.Fatalf("expected a StepError for 'charge', got: %v", err)
+		}
+		if !errors.Is(err, fakeErr) {
+			t.Errorf("expected the error to wrap the cause, got: %v", err)
+		}
+		if calledLast {
+			t.Error("the steps after the failure should not run")
+		}
+
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+		if err != nil {
+			t.Fatalf("failed to count users: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected the transaction to be rolled back, got %d users", count)
+		}
+	})
+}
+
+func TestSteps_Assertions(t *testing.T) {
+	ctx := context.Background()
+
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Exec("INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	rename := func(email string) func(tx *Tx) error {
+		return func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE users SET name = name || '!' WHERE email = ?", email)
+			return err
+		}
+	}
+
+	t.Run("should pass when the assertions hold", func(t *testing.T) {
+		err := Steps(ctx, db,
+			Step("rename", rename("john@example.com")).ExpectRowsAffected(1),
+			Invariant("names end with !", "SELECT COUNT(*) = 0 FROM users WHERE name NOT LIKE '%!'"),
+		)
+		if err != nil {
+			t.Fatalf("Steps failed: %v", err)
+		}
+	})
+
+	t.Run("should fail when the rows affected are not the expected", func(t *testing.T) {
+		err := Steps(ctx, db,
+			Step("rename", rename("john@example.com")).ExpectRowsAffected(1),
+			Step("rename-missing", rename("missing@example.com")).ExpectRowsAffected(1),
+		)
+
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != "rename-missing" || !errors.Is(err, ErrAssertionFailed) {
+			t.Fatalf("expected an assertion error on 'rename-missing', got: %v", err)
+		}
+
+		var name string
+		err = db.QueryRow("SELECT name FROM users").Scan(&name)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if name != "John!" {
+			t.Errorf("expected the transaction to be rolled back, got name %s", name)
+		}
+	})
+
+	t.Run("should fail when an invariant is violated", func(t *testing.T) {
+		err := Steps(ctx, db,
+			Step("rename", rename("john@example.com")),
+			Invariant("single exclamation", "SELECT name NOT LIKE '%!!' FROM users"),
+		)
+
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != "single exclamation" || !errors.Is(err, ErrAssertionFailed) {
+			t.Fatalf("expected an assertion error on the invariant, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStatementTimeout is returned by the statements that exceed
+// the timeout configured with WithStatementTimeout.
+var ErrStatementTimeout = errors.New("statement timeout exceeded")
+
+// ErrCommitTimeout is returned when the commit of a transaction takes
+// longer than the timeout configured with WithCommitTimeout, in which
+// case it is not known whether the transaction was committed.
+var ErrCommitTimeout = errors.New("commit timeout exceeded")
+
+// ErrRollbackTimeout is wrapped by the error returned when the rollback
+// of a transaction takes longer than the timeout configured with
+// WithRollbackTimeout.
+var ErrRollbackTimeout = errors.New("rollback timeout exceeded")
+
+// WithCommitTimeout limits how long the commit of the transaction can take,
+// since database/sql has no way of canceling it and a commit can hang
+// forever on a network partition, failing with ErrCommitTimeout instead.
+//
+// The commit itself keeps running in the background until the driver
+// returns, so whether the transaction was committed is unknown and the
+// error also wraps ErrCommitAmbiguous.
+func WithCommitTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.commitTimeout = timeout
+	}
+}
+
+// WithRollbackTimeout limits how long the rollback of the transaction can
+// take, failing with ErrRollbackTimeout instead of hanging the caller.
+//
+// The rollback keeps running in the background until the driver returns.
+func WithRollbackTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.rollbackTimeout = timeout
+	}
+}
+
+// commit commits the transaction within the WithCommitTimeout.
+func (tx *Tx) commit(ctx context.Context) error {
+	if tx.canSkipCommit() {
+		return tx.skipCommit(ctx)
+	}
+
+	// Avoids allocating the method value on the path of every transaction:
+	if tx.cfg.commitTimeout <= 0 {
+		return tx.sqlTx.Commit()
+	}
+
+	err := callWithTimeout(ctx, tx.cfg.commitTimeout, ErrCommitTimeout, tx.sqlTx.Commit)
+	if errors.Is(err, ErrCommitTimeout) {
+		tx.abandoned = true
+	}
+	return err
+}
+
+// rollback rolls the transaction back within the WithRollbackTimeout.
+func (tx *Tx) rollback(ctx context.Context) error {
+	if tx.cfg.rollbackTimeout <= 0 {
+		return tx.sqlTx.Rollback()
+	}
+
+	err := callWithTimeout(ctx, tx.cfg.rollbackTimeout, ErrRollbackTimeout, tx.sqlTx.Rollback)
+	if errors.Is(err, ErrRollbackTimeout) {
+		tx.abandoned = true
+	}
+	return err
+}
+
+// callWithTimeout waits at most timeout for fn, on a context derived from
+// ctx that is not canceled with it, so the commit or rollback of a
+// transaction whose context was canceled still gets the whole timeout.
+func callWithTimeout(ctx context.Context, timeout time.Duration, errTimeout error, fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w: gave up after %s", errTimeout, timeout)
+	}
+}
+
+// WithStatementTimeout limits how long each statement executed through
+// the *Tx can take, independently of the deadline of the transaction,
+// so a single slow query can't silently consume its whole budget.
+//
+// The timeout is applied with a context derived from the one of the
+// statement, so a shorter deadline on that context still prevails.
+// For queries it also covers reading the returned rows, which are
+// closed by database/sql once it expires.
+//
+// Statements that time out fail with an error wrapping
+// both ErrStatementTimeout and context.DeadlineExceeded.
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.statementTimeout = timeout
+	}
+}
+
+// timeoutRunner is the runner that implements WithStatementTimeout.
+type timeoutRunner struct {
+	next    DBRunner
+	tx      *Tx
+	timeout time.Duration
+}
+
+func (r timeoutRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmtCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.next.ExecContext(stmtCtx, query, args...)
+	return result, r.wrapErr(ctx, stmtCtx, err)
+}
+
+func (r timeoutRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmtCtx, cancel := context.WithTimeout(ctx, r.timeout)
+
+	rows, err := r.next.QueryContext(stmtCtx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, r.wrapErr(ctx, stmtCtx, err)
+	}
+
+	// The context must outlive the call for the rows to be read, and
+	// *sql.Rows has no hook for its Close, so it is released when the
+	// transaction finishes or, if that comes first, when it expires:
+	stop := context.AfterFunc(r.tx.done(), cancel)
+	context.AfterFunc(stmtCtx, func() { stop() })
+
+	return rows, nil
+}
+
+// done returns the context that is canceled when the transaction
+// finishes, which is only created by the first query that needs it.
+func (tx *Tx) done() context.Context {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.doneCtx == nil {
+		tx.doneCtx, tx.cancelDone = context.WithCancel(context.Background())
+	}
+	return tx.doneCtx
+}
+
+// wrapErr marks the errors caused by the statement timeout,
+// as opposed to the deadline of the parent context.
+func (r timeoutRunner) wrapErr(ctx context.Context, stmtCtx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(stmtCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrStatementTimeout, r.timeout, err)
+}
+
+func (r timeoutRunner) Unwrap() DBRunner {
+	return r.next
+}
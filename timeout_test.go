@@ -0,0 +1,173 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// infiniteQuery is a query that only stops when interrupted.
+const infiniteQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c"
+
+func TestWithStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should interrupt slow statements", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, infiniteQuery)
+			return err
+		}, WithStatementTimeout(50*time.Millisecond))
+		if !errors.Is(err, ErrStatementTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected ErrStatementTimeout, got: %v", err)
+		}
+	})
+
+	t.Run("should keep the rows of queries readable", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var count int
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.QueryContext(ctx, "SELECT COUNT(*) FROM users")
+			if err != nil {
+				return err
+			}
+			defer func() { _ = rows.Close() }()
+
+			rows.Next()
+			return rows.Scan(&count)
+		}, WithStatementTimeout(time.Second))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("should release the contexts of the queries when the transaction finishes", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		var stmtCtx context.Context
+		tx := &Tx{}
+		runner := timeoutRunner{next: contextRecorder{next: db, ctx: &stmtCtx}, tx: tx, timeout: time.Minute}
+
+		rows, err := runner.QueryContext(ctx, "SELECT id FROM users")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = rows.Close()
+		if stmtCtx.Err() != nil {
+			t.Fatalf("expected the context of the query to be alive, got: %v", stmtCtx.Err())
+		}
+
+		tx.release()
+
+		// The contexts are canceled in the background:
+		select {
+		case <-stmtCtx.Done():
+		case <-time.After(time.Second):
+			t.Error("expected the context of the query to be canceled")
+		}
+	})
+
+	t.Run("should not blame the statement for the deadline of the transaction", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, infiniteQuery)
+			return err
+		}, WithStatementTimeout(time.Hour))
+		if err == nil || errors.Is(err, ErrStatementTimeout) {
+			t.Errorf("expected an error not caused by the statement timeout, got: %v", err)
+		}
+	})
+}
+
+func TestCommitAndRollbackTimeouts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should give up on commits that hang", func(t *testing.T) {
+		fake := &procedureConnector{hang: make(chan struct{})}
+		defer close(fake.hang)
+		db := sql.OpenDB(fake)
+
+		var ambiguous error
+		start := time.Now()
+		err := Run(ctx, db, func(tx *Tx) error {
+			return nil
+		}, WithCommitTimeout(20*time.Millisecond), WithHooks(Hooks{
+			OnCommitAmbiguous: func(ctx context.Context, tx *Tx, err error) {
+				ambiguous = err
+			},
+		}))
+		if !errors.Is(err, ErrCommitTimeout) {
+			t.Fatalf("expected ErrCommitTimeout, got: %v", err)
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Fatalf("expected the commit to be abandoned after the timeout, took %s", took)
+		}
+		if !errors.Is(ambiguous, ErrCommitTimeout) {
+			t.Errorf("expected the OnCommitAmbiguous hooks to receive ErrCommitTimeout, got: %v", ambiguous)
+		}
+	})
+
+	t.Run("should give up on rollbacks that hang", func(t *testing.T) {
+		fake := &procedureConnector{hang: make(chan struct{})}
+		defer close(fake.hang)
+		db := sql.OpenDB(fake)
+
+		errTest := errors.New("test error")
+		err := Run(ctx, db, func(tx *Tx) error {
+			return errTest
+		}, WithRollbackTimeout(20*time.Millisecond))
+		if !errors.Is(err, ErrRollbackTimeout) {
+			t.Fatalf("expected ErrRollbackTimeout, got: %v", err)
+		}
+	})
+
+	t.Run("should commit normally within the timeouts", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES ('John', 'john@example.com')")
+			return err
+		}, WithCommitTimeout(time.Second), WithRollbackTimeout(time.Second))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		assertUserCount(t, db, 1)
+	})
+}
+
+// contextRecorder is a runner that records the
+// context of the last query it receives.
+type contextRecorder struct {
+	next DBRunner
+	ctx  *context.Context
+}
+
+func (r contextRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.next.ExecContext(ctx, query, args...)
+}
+
+func (r contextRecorder) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*r.ctx = ctx
+	return r.next.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,142 @@
+package ktx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ChangeKind describes the kind of a change, either registered on a
+// UnitOfWork or captured with WithChangeCapture.
+type ChangeKind string
+
+// The kinds of changes.
+const (
+	ChangeInsert ChangeKind = "insert"
+	ChangeUpdate ChangeKind = "update"
+	ChangeDelete ChangeKind = "delete"
+)
+
+type change struct {
+	kind ChangeKind
+	fn   func(ctx context.Context, db DBRunner) error
+}
+
+// UnitOfWork collects the changes produced by the business logic
+// so they can be written together in a single transaction.
+//
+// Changes are registered as closures that receive the transaction
+// runner and are executed in the order they were registered when
+// Commit is called.
+//
+// It is safe for concurrent use.
+type UnitOfWork struct {
+	db   DBRunner
+	opts []Option
+
+	mu      sync.Mutex
+	changes []change
+
+	// discards counts the calls to Discard, so Commit doesn't
+	// restore the changes discarded while it was running:
+	discards int
+}
+
+// NewUnitOfWork creates a UnitOfWork that will commit its changes on db
+// using Run with the input Options, so hooks and any other Options
+// apply to the transaction started by Commit.
+func NewUnitOfWork(db DBRunner, opts ...Option) *UnitOfWork {
+	return &UnitOfWork{
+		db:   db,
+		opts: opts,
+	}
+}
+
+// RegisterInsert registers a closure that inserts an entity.
+func (u *UnitOfWork) RegisterInsert(fn func(ctx context.Context, db DBRunner) error) {
+	u.register(ChangeInsert, fn)
+}
+
+// RegisterUpdate registers a closure that updates an entity.
+func (u *UnitOfWork) RegisterUpdate(fn func(ctx context.Context, db DBRunner) error) {
+	u.register(ChangeUpdate, fn)
+}
+
+// RegisterDelete registers a closure that deletes an entity.
+func (u *UnitOfWork) RegisterDelete(fn func(ctx context.Context, db DBRunner) error) {
+	u.register(ChangeDelete, fn)
+}
+
+func (u *UnitOfWork) register(kind ChangeKind, fn func(ctx context.Context, db DBRunner) error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.changes = append(u.changes, change{
+		kind: kind,
+		fn:   fn,
+	})
+}
+
+// Pending returns how many changes of each kind are waiting to be committed.
+func (u *UnitOfWork) Pending() map[ChangeKind]int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	pending := map[ChangeKind]int{}
+	for _, c := range u.changes {
+		pending[c.kind]++
+	}
+	return pending
+}
+
+// Discard removes all the registered changes without executing them.
+func (u *UnitOfWork) Discard() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.changes = nil
+	u.discards++
+}
+
+// Commit executes all the registered changes inside a single transaction.
+//
+// If any of them fails the transaction is rolled back and the changes are
+// kept so Commit can be retried, otherwise the UnitOfWork is emptied.
+//
+// The changes are taken out of the UnitOfWork while they are executed,
+// so the changes registered meanwhile are left for the next Commit and
+// concurrent calls to Commit never execute the same changes.
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	u.mu.Lock()
+	changes := u.changes
+	discards := u.discards
+	u.changes = nil
+	u.mu.Unlock()
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	err := Run(ctx, u.db, func(tx *Tx) error {
+		for i, c := range changes {
+			err := c.fn(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("error executing change #%d (%s): %w", i+1, c.kind, err)
+			}
+		}
+		return nil
+	}, u.opts...)
+	if err != nil {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		// The failed changes go back before the ones registered
+		// while they were running, unless they were discarded:
+		if u.discards == discards {
+			u.changes = append(changes, u.changes...)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+
+	insertUser := func(name, email string) func(ctx context.Context, db DBRunner) error {
+		return func(ctx context.Context, db DBRunner) error {
+			_, err := db.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", name, email)
+			return err
+		}
+	}
+
+	t.Run("should commit all changes in order", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		committed := false
+		uow := NewUnitOfWork(db, WithHooks(Hooks{
+			OnCommit: func(ctx context.Context, tx *Tx) {
+				committed = true
+			},
+		}))
+
+		uow.RegisterInsert(insertUser("John", "john@example.com"))
+		uow.RegisterInsert(insertUser("Jane", "jane@example.com"))
+		uow.RegisterUpdate(func(ctx context.Context, db DBRunner) error {
+			_, err := db.ExecContext(ctx, "UPDATE users SET name = ? WHERE email = ?", "Johnny", "john@example.com")
+			return err
+		})
+		uow.RegisterDelete(func(ctx context.Context, db DBRunner) error {
+			_, err := db.ExecContext(ctx, "DELETE FROM users WHERE email = ?", "jane@example.com")
+			return err
+		})
+
+		pending := uow.Pending()
+		if pending[ChangeInsert] != 2 || pending[ChangeUpdate] != 1 || pending[ChangeDelete] != 1 {
+			t.Fatalf("unexpected pending changes: %v", pending)
+		}
+
+		if n := countDbUsers(t, db); n != 0 {
+			t.Fatalf("expected no changes before Commit, got %d users", n)
+		}
+
+		err := uow.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if !committed {
+			t.Fatal("expected the hooks to be called")
+		}
+
+		var name string
+		err = db.QueryRow("SELECT name FROM users").Scan(&name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := countDbUsers(t, db); n != 1 || name != "Johnny" {
+			t.Fatalf("unexpected final state: %d users, name: %s", n, name)
+		}
+
+		if len(uow.Pending()) != 0 {
+			t.Fatalf("expected no pending changes after commit, got: %v", uow.Pending())
+		}
+	})
+
+	t.Run("should rollback and keep the changes on error", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		testError := errors.New("test error")
+		uow := NewUnitOfWork(db)
+		uow.RegisterInsert(insertUser("John", "john@example.com"))
+		uow.RegisterUpdate(func(ctx context.Context, db DBRunner) error {
+			return testError
+		})
+
+		err := uow.Commit(ctx)
+		if !errors.Is(err, testError) {
+			t.Fatalf("expected test error, got: %v", err)
+		}
+
+		if n := countDbUsers(t, db); n != 0 {
+			t.Fatalf("expected 0 users (rollback should have occurred), got %d", n)
+		}
+		if uow.Pending()[ChangeInsert] != 1 {
+			t.Fatalf("expected changes to be kept, got: %v", uow.Pending())
+		}
+
+		uow.Discard()
+		if len(uow.Pending()) != 0 {
+			t.Fatalf("expected no pending changes after Discard, got: %v", uow.Pending())
+		}
+	})
+
+	t.Run("should keep the changes registered or discarded while committing", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		uow := NewUnitOfWork(db)
+		uow.RegisterInsert(func(ctx context.Context, db DBRunner) error {
+			// Simulates another goroutine using the UnitOfWork meanwhile:
+			uow.Discard()
+			uow.RegisterInsert(insertUser("Jane", "jane@example.com"))
+			uow.RegisterInsert(insertUser("Mary", "mary@example.com"))
+			return insertUser("John", "john@example.com")(ctx, db)
+		})
+
+		err := uow.Commit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := countDbUsers(t, db); n != 1 {
+			t.Fatalf("expected 1 user, got %d", n)
+		}
+		if uow.Pending()[ChangeInsert] != 2 {
+			t.Fatalf("expected the 2 new changes to be kept, got: %v", uow.Pending())
+		}
+
+		err = uow.Commit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := countDbUsers(t, db); n != 3 {
+			t.Fatalf("expected 3 users, got %d", n)
+		}
+	})
+
+	t.Run("should not restore the changes discarded while committing", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		testError := errors.New("test error")
+		uow := NewUnitOfWork(db)
+		uow.RegisterUpdate(func(ctx context.Context, db DBRunner) error {
+			uow.Discard()
+			uow.RegisterInsert(insertUser("Jane", "jane@example.com"))
+			return testError
+		})
+
+		err := uow.Commit(ctx)
+		if !errors.Is(err, testError) {
+			t.Fatalf("expected test error, got: %v", err)
+		}
+
+		pending := uow.Pending()
+		if len(pending) != 1 || pending[ChangeInsert] != 1 {
+			t.Fatalf("expected only the change registered after Discard, got: %v", pending)
+		}
+	})
+
+	t.Run("should not execute the same changes on concurrent commits", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		uow := NewUnitOfWork(db)
+
+		executions := 0
+		var nestedErr error
+		uow.RegisterInsert(func(ctx context.Context, db DBRunner) error {
+			executions++
+			if executions == 1 {
+				nestedErr = uow.Commit(ctx)
+			}
+			return insertUser("John", "john@example.com")(ctx, db)
+		})
+
+		err := uow.Commit(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if nestedErr != nil {
+			t.Fatalf("unexpected error on the concurrent commit: %v", nestedErr)
+		}
+		if executions != 1 {
+			t.Fatalf("expected the change to run once, got %d", executions)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UpsertOption configures the behavior of Upsert when
+// a row with the same keys already exists.
+type UpsertOption func(*upsertConfig)
+
+type upsertConfig struct {
+	ignore        bool
+	updateColumns []string
+}
+
+// OnConflictIgnore makes Upsert keep the existing row untouched.
+func OnConflictIgnore() UpsertOption {
+	return func(c *upsertConfig) {
+		c.ignore = true
+	}
+}
+
+// OnConflictUpdate makes Upsert only update the input columns
+// of the existing row, by default all the columns that are not
+// keys are updated.
+func OnConflictUpdate(columns ...string) UpsertOption {
+	return func(c *upsertConfig) {
+		c.updateColumns = columns
+	}
+}
+
+// Upsert inserts a row with the input values on table or, if a row with
+// the same values for the keys already exists, updates it instead.
+//
+// The keys must match a primary key or unique constraint of the table and
+// must also be present on values. The statement is generated for the dialect:
+// `ON CONFLICT ... DO UPDATE` on Postgres and SQLite, `MERGE` on SQL Server
+// and `ON DUPLICATE KEY UPDATE` on MySQL, where the keys are ignored since
+// the conflict is detected on any unique constraint.
+func Upsert(ctx context.Context, db Execer, dialect Dialect, table string, keys []string, values map[string]interface{}, opts ...UpsertOption) (sql.Result, error) {
+	query, args, err := buildUpsert(dialect, table, keys, values, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error upserting record on table '%s': %w", table, err)
+	}
+	return result, nil
+}
+
+func buildUpsert(dialect Dialect, table string, keys []string, values map[string]interface{}, opts []UpsertOption) (query string, args []interface{}, err error) {
+	var cfg upsertConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if len(keys) == 0 {
+		return "", nil, errors.New("at least one key column is required for upserting")
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range keys {
+		if _, found := values[key]; !found {
+			return "", nil, fmt.Errorf("the key column '%s' is missing from the upserted values", key)
+		}
+		isKey[key] = true
+	}
+
+	// Sorted so the generated statement is stable:
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var quotedColumns, placeholders []string
+	for _, column := range columns {
+		quotedColumns = append(quotedColumns, dialect.Quote(column))
+		placeholders = append(placeholders, dialect.Placeholder(len(args)))
+		args = append(args, values[column])
+	}
+
+	updateColumns := cfg.updateColumns
+	if updateColumns == nil {
+		for _, column := range columns {
+			if !isKey[column] {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+	}
+	for _, column := range updateColumns {
+		if _, found := values[column]; !found {
+			return "", nil, fmt.Errorf("the updated column '%s' is missing from the upserted values", column)
+		}
+	}
+	ignore := cfg.ignore || len(updateColumns) == 0
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		dialect.Quote(table),
+		strings.Join(quotedColumns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+
+	switch dialect.Name() {
+	case MySQL.Name():
+		var sets []string
+		if ignore {
+			// A no-op update, since INSERT IGNORE would also ignore other errors:
+			sets = append(sets, fmt.Sprintf("%s = %s", dialect.Quote(keys[0]), dialect.Quote(keys[0])))
+		} else {
+			for _, column := range updateColumns {
+				sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", dialect.Quote(column), dialect.Quote(column)))
+			}
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+
+	case Postgres.Name(), SQLite.Name():
+		quotedKeys := make([]string, len(keys))
+		for i, key := range keys {
+			quotedKeys[i] = dialect.Quote(key)
+		}
+		query += " ON CONFLICT (" + strings.Join(quotedKeys, ", ") + ")"
+
+		if ignore {
+			query += " DO NOTHING"
+			break
+		}
+
+		sets := make([]string, len(updateColumns))
+		for i, column := range updateColumns {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", dialect.Quote(column), dialect.Quote(column))
+		}
+		query += " DO UPDATE SET " + strings.Join(sets, ", ")
+
+	case SQLServer.Name():
+		// HOLDLOCK makes the MERGE safe against concurrent upserts of the same keys:
+		conditions := make([]string, len(keys))
+		for i, key := range keys {
+			conditions[i] = fmt.Sprintf("target.%s = source.%s", dialect.Quote(key), dialect.Quote(key))
+		}
+		sourceColumns := make([]string, len(quotedColumns))
+		for i, column := range quotedColumns {
+			sourceColumns[i] = "source." + column
+		}
+
+		query = fmt.Sprintf(
+			"MERGE INTO %s WITH (HOLDLOCK) AS target USING (VALUES (%s)) AS source (%s) ON %s",
+			dialect.Quote(table),
+			strings.Join(placeholders, ", "),
+			strings.Join(quotedColumns, ", "),
+			strings.Join(conditions, " AND "),
+		)
+		if !ignore {
+			sets := make([]string, len(updateColumns))
+			for i, column := range updateColumns {
+				sets[i] = fmt.Sprintf("target.%s = source.%s", dialect.Quote(column), dialect.Quote(column))
+			}
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+		}
+		query += fmt.Sprintf(
+			" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);",
+			strings.Join(quotedColumns, ", "),
+			strings.Join(sourceColumns, ", "),
+		)
+
+	default:
+		return "", nil, fmt.Errorf("upsert is not supported for dialect '%s'", dialect.Name())
+	}
+
+	return query, args, nil
+}
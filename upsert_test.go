@@ -0,0 +1,134 @@
+package ktx
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBuildUpsert(t *testing.T) {
+	values := map[string]interface{}{
+		"email": "john@example.com",
+		"name":  "John",
+		"age":   42,
+	}
+
+	tests := []struct {
+		desc     string
+		dialect  Dialect
+		opts     []UpsertOption
+		expected string
+	}{
+		{
+			desc:     "postgres updating all columns",
+			dialect:  Postgres,
+			expected: `INSERT INTO "users" ("age", "email", "name") VALUES ($1, $2, $3) ON CONFLICT ("email") DO UPDATE SET "age" = EXCLUDED."age", "name" = EXCLUDED."name"`,
+		},
+		{
+			desc:     "sqlite updating some columns",
+			dialect:  SQLite,
+			opts:     []UpsertOption{OnConflictUpdate("name")},
+			expected: `INSERT INTO "users" ("age", "email", "name") VALUES (?, ?, ?) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			desc:     "postgres ignoring conflicts",
+			dialect:  Postgres,
+			opts:     []UpsertOption{OnConflictIgnore()},
+			expected: `INSERT INTO "users" ("age", "email", "name") VALUES ($1, $2, $3) ON CONFLICT ("email") DO NOTHING`,
+		},
+		{
+			desc:     "mysql updating all columns",
+			dialect:  MySQL,
+			expected: "INSERT INTO `users` (`age`, `email`, `name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `age` = VALUES(`age`), `name` = VALUES(`name`)",
+		},
+		{
+			desc:     "mysql ignoring conflicts",
+			dialect:  MySQL,
+			opts:     []UpsertOption{OnConflictIgnore()},
+			expected: "INSERT INTO `users` (`age`, `email`, `name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `email` = `email`",
+		},
+		{
+			desc:     "sqlserver updating all columns",
+			dialect:  SQLServer,
+			expected: "MERGE INTO [users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3)) AS source ([age], [email], [name]) ON target.[email] = source.[email] WHEN MATCHED THEN UPDATE SET target.[age] = source.[age], target.[name] = source.[name] WHEN NOT MATCHED THEN INSERT ([age], [email], [name]) VALUES (source.[age], source.[email], source.[name]);",
+		},
+		{
+			desc:     "sqlserver ignoring conflicts",
+			dialect:  SQLServer,
+			opts:     []UpsertOption{OnConflictIgnore()},
+			expected: "MERGE INTO [users] WITH (HOLDLOCK) AS target USING (VALUES (@p1, @p2, @p3)) AS source ([age], [email], [name]) ON target.[email] = source.[email] WHEN NOT MATCHED THEN INSERT ([age], [email], [name]) VALUES (source.[age], source.[email], source.[name]);",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			query, args, err := buildUpsert(test.dialect, "users", []string{"email"}, values, test.opts)
+			if err != nil {
+				t.Fatalf("buildUpsert failed: %v", err)
+			}
+			if query != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, query)
+			}
+			if len(args) != 3 || args[0] != 42 || args[1] != "john@example.com" || args[2] != "John" {
+				t.Errorf("unexpected args: %v", args)
+			}
+		})
+	}
+
+	t.Run("should validate the keys and columns", func(t *testing.T) {
+		_, _, err := buildUpsert(Postgres, "users", nil, values, nil)
+		if err == nil {
+			t.Errorf("expected an error for missing keys")
+		}
+
+		_, _, err = buildUpsert(Postgres, "users", []string{"id"}, values, nil)
+		if err == nil {
+			t.Errorf("expected an error for a key missing from the values")
+		}
+
+		_, _, err = buildUpsert(Postgres, "users", []string{"email"}, values, []UpsertOption{OnConflictUpdate("missing")})
+		if err == nil {
+			t.Errorf("expected an error for an updated column missing from the values")
+		}
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+
+	readName := func(t *testing.T, email string) string {
+		var name string
+		err := db.QueryRow("SELECT name FROM users WHERE email = ?", email).Scan(&name)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		return name
+	}
+
+	err := Run(ctx, db, func(tx *Tx) error {
+		for _, name := range []string{"John", "Johnny"} {
+			_, err := Upsert(ctx, tx, SQLite, "users", []string{"email"}, map[string]interface{}{
+				"email": "upsert@example.com",
+				"name":  name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := Upsert(ctx, tx, SQLite, "users", []string{"email"}, map[string]interface{}{
+			"email": "upsert@example.com",
+			"name":  "ignored",
+		}, OnConflictIgnore())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if name := readName(t, "upsert@example.com"); name != "Johnny" {
+		t.Errorf("expected the name to be updated once, got %q", name)
+	}
+}
@@ -0,0 +1,34 @@
+package ktx
+
+import (
+	"context"
+)
+
+// TransactionValue works like Run but returns the value returned by the
+// callback, which saves declaring it outside of the closure:
+//
+//	total, err := ktx.TransactionValue(ctx, db, func(tx *ktx.Tx) (int, error) {
+//		return countOrders(ctx, tx)
+//	}, ktx.WithReadOnly())
+//
+// When the transaction fails the last value returned by the callback
+// is returned together with the error.
+//
+// The value can be cached for a short time with ResultCache.Option.
+func TransactionValue[T any](ctx context.Context, db DBRunner, fn func(tx *Tx) (T, error), opts ...Option) (value T, err error) {
+	// Transactions that are reused never read from the cache,
+	// since the cached values might not reflect their writes:
+	if _, ok := db.(TxBeginner); ok {
+		var cfg config
+		cfg.apply(opts)
+		if cfg.resultCache != nil {
+			return cachedValue(ctx, db, &cfg, fn, opts)
+		}
+	}
+
+	err = Run(ctx, db, func(tx *Tx) error {
+		value, err = fn(tx)
+		return err
+	}, opts...)
+	return value, err
+}
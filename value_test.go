@@ -0,0 +1,67 @@
+package ktx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTransactionValue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should commit and return the value of the callback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		id, err := TransactionValue(ctx, db, func(tx *Tx) (int64, error) {
+			result, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return 0, err
+			}
+			return result.LastInsertId()
+		})
+		if err != nil {
+			t.Fatalf("TransactionValue failed: %v", err)
+		}
+		if id != 1 {
+			t.Errorf("expected id 1, got: %d", id)
+		}
+		assertUserCount(t, db, 1)
+	})
+
+	t.Run("should roll back and return the error of the callback", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		fakeErr := errors.New("fake error")
+		_, err := TransactionValue(ctx, db, func(tx *Tx) (string, error) {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			if err != nil {
+				return "", err
+			}
+			return "", fakeErr
+		})
+		if !errors.Is(err, fakeErr) {
+			t.Fatalf("expected fake error, got: %v", err)
+		}
+		assertUserCount(t, db, 0)
+	})
+
+	t.Run("should reuse the transaction passed as db", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer func() { _ = db.Close() }()
+
+		err := Run(ctx, db, func(outer *Tx) error {
+			inner, err := TransactionValue(ctx, outer, func(tx *Tx) (*Tx, error) {
+				return tx, nil
+			})
+			if inner != outer {
+				t.Errorf("expected the outer transaction to be reused")
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	})
+}
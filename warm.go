@@ -0,0 +1,238 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// WarmPoolOptions configures a WarmPool.
+type WarmPoolOptions struct {
+	// Size is how many validated connections are kept
+	// checked out for the next begins, defaults to 2.
+	Size int
+
+	// ValidateInterval is how often the warm connections are pinged
+	// and replaced if broken, defaults to 30 seconds.
+	ValidateInterval time.Duration
+
+	// SlowBegin is how long opening and validating a connection must
+	// take for the begins served by it to count as slow begins saved,
+	// defaults to 10ms.
+	SlowBegin time.Duration
+}
+
+// WarmPoolStats are the counters of a WarmPool.
+type WarmPoolStats struct {
+	// WarmBegins counts the transactions started on a warm connection.
+	WarmBegins uint64
+	// ColdBegins counts the transactions started directly on the pool,
+	// because no warm connection was available.
+	ColdBegins uint64
+	// SlowBeginsSaved counts the warm begins whose connection took longer
+	// than SlowBegin to open and validate, i.e. the begins that would have
+	// been slow without the WarmPool.
+	SlowBeginsSaved uint64
+}
+
+// WarmPool is a TxBeginner that keeps a few validated connections checked
+// out of a *sql.DB and starts the transactions on them, so the latency of
+// BeginTx doesn't include growing the pool, e.g. dialing and the TLS
+// handshake, on paths that run after the pool shrank:
+//
+//	pool := ktx.NewWarmPool(db, ktx.WarmPoolOptions{Size: 4})
+//	defer pool.Close()
+//
+//	err := ktx.Run(ctx, pool, createOrder)
+//
+// Each connection used by a transaction goes back to the pool of db once the
+// transaction ends, and a new one is opened in the background to replace it.
+// When no warm connection is available the transaction starts directly on db.
+//
+// The warm connections count towards the limit set with db.SetMaxOpenConns,
+// which should be higher than Size. The statements executed outside of
+// transactions go directly to db.
+type WarmPool struct {
+	db   *sql.DB
+	opts WarmPoolOptions
+
+	conns  chan warmConn
+	refill chan struct{}
+	stop   context.CancelFunc
+	done   chan struct{}
+
+	closeErr error
+
+	warmBegins      atomic.Uint64
+	coldBegins      atomic.Uint64
+	slowBeginsSaved atomic.Uint64
+}
+
+type warmConn struct {
+	conn *sql.Conn
+	took time.Duration
+}
+
+// NewWarmPool creates a WarmPool and starts filling it in the background.
+func NewWarmPool(db *sql.DB, opts WarmPoolOptions) *WarmPool {
+	if opts.Size <= 0 {
+		opts.Size = 2
+	}
+	if opts.ValidateInterval <= 0 {
+		opts.ValidateInterval = 30 * time.Second
+	}
+	if opts.SlowBegin <= 0 {
+		opts.SlowBegin = 10 * time.Millisecond
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	p := &WarmPool{
+		db:     db,
+		opts:   opts,
+		conns:  make(chan warmConn, opts.Size),
+		refill: make(chan struct{}, 1),
+		stop:   stop,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+
+	return p
+}
+
+// Stats returns the counters of the pool.
+func (p *WarmPool) Stats() WarmPoolStats {
+	return WarmPoolStats{
+		WarmBegins:      p.warmBegins.Load(),
+		ColdBegins:      p.coldBegins.Load(),
+		SlowBeginsSaved: p.slowBeginsSaved.Load(),
+	}
+}
+
+// BeginTx starts the transaction on a warm connection, or directly
+// on the pool if none is available.
+func (p *WarmPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	var wc warmConn
+	select {
+	case wc = <-p.conns:
+	default:
+		p.coldBegins.Add(1)
+		return p.db.BeginTx(ctx, opts)
+	}
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+
+	sqlTx, err := wc.conn.BeginTx(ctx, opts)
+
+	// Close blocks until the transaction ends, then
+	// returns the connection to the pool of db:
+	go func() { _ = wc.conn.Close() }()
+
+	if err != nil {
+		p.coldBegins.Add(1)
+		return p.db.BeginTx(ctx, opts)
+	}
+
+	p.warmBegins.Add(1)
+	if wc.took >= p.opts.SlowBegin {
+		p.slowBeginsSaved.Add(1)
+	}
+	return sqlTx, nil
+}
+
+// ExecContext executes the statement directly on the pool.
+func (p *WarmPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes the query directly on the pool.
+func (p *WarmPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, args...)
+}
+
+// Close stops refilling the pool and returns the warm connections
+// to db, which is not closed. The transactions started after Close
+// start directly on db.
+func (p *WarmPool) Close() error {
+	p.stop()
+	<-p.done
+	return p.closeErr
+}
+
+func (p *WarmPool) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.opts.ValidateInterval)
+	defer ticker.Stop()
+
+	for {
+		p.fill(ctx)
+
+		select {
+		case <-ctx.Done():
+			p.drain()
+			return
+		case <-p.refill:
+		case <-ticker.C:
+			p.validate(ctx)
+		}
+	}
+}
+
+// fill opens connections until the pool is full, leaving the
+// failures to be retried on the next refill or validation.
+// Only run sends to p.conns so these sends never block.
+func (p *WarmPool) fill(ctx context.Context) {
+	for len(p.conns) < cap(p.conns) {
+		start := time.Now()
+		conn, err := p.db.Conn(ctx)
+		if err != nil {
+			return
+		}
+
+		err = conn.PingContext(ctx)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		p.conns <- warmConn{conn: conn, took: time.Since(start)}
+	}
+}
+
+// validate pings the warm connections, discarding the broken ones.
+func (p *WarmPool) validate(ctx context.Context) {
+	for i := len(p.conns); i > 0; i-- {
+		var wc warmConn
+		select {
+		case wc = <-p.conns:
+		default:
+			return
+		}
+
+		err := wc.conn.PingContext(ctx)
+		if err != nil {
+			_ = wc.conn.Close()
+			continue
+		}
+		p.conns <- wc
+	}
+}
+
+func (p *WarmPool) drain() {
+	for {
+		select {
+		case wc := <-p.conns:
+			err := wc.conn.Close()
+			if err != nil && !errors.Is(err, sql.ErrConnDone) {
+				p.closeErr = errors.Join(p.closeErr, err)
+			}
+		default:
+			return
+		}
+	}
+}
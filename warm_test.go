@@ -0,0 +1,130 @@
+package ktx
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWarmPool(t *testing.T) {
+	ctx := context.Background()
+
+	// Each connection to an in-memory SQLite database has its own
+	// database, so the tests use a file shared by all of them:
+	openDB := func(t *testing.T) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "warm.db")+"?_journal_mode=WAL&_busy_timeout=100")
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		_, err = db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, email TEXT UNIQUE NOT NULL)")
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		return db
+	}
+
+	waitFor := func(t *testing.T, what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("should start the transactions on the warm connections", func(t *testing.T) {
+		db := openDB(t)
+		defer func() { _ = db.Close() }()
+
+		pool := NewWarmPool(db, WarmPoolOptions{Size: 2, SlowBegin: time.Nanosecond})
+		defer func() { _ = pool.Close() }()
+
+		waitFor(t, "the pool to fill", func() bool { return len(pool.conns) == 2 })
+
+		err := Run(ctx, pool, func(tx *Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO users (name, email) VALUES (?, ?)", "John", "john@example.com")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		assertUserCount(t, db, 1)
+
+		stats := pool.Stats()
+		if stats.WarmBegins != 1 || stats.ColdBegins != 0 || stats.SlowBeginsSaved != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+
+		waitFor(t, "the pool to refill", func() bool { return len(pool.conns) == 2 })
+	})
+
+	t.Run("should not count the fast connections as slow begins saved", func(t *testing.T) {
+		db := openDB(t)
+		defer func() { _ = db.Close() }()
+
+		pool := NewWarmPool(db, WarmPoolOptions{Size: 1, SlowBegin: time.Hour})
+		defer func() { _ = pool.Close() }()
+
+		waitFor(t, "the pool to fill", func() bool { return len(pool.conns) == 1 })
+
+		err := Run(ctx, pool, func(tx *Tx) error { return nil })
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		stats := pool.Stats()
+		if stats.WarmBegins != 1 || stats.SlowBeginsSaved != 0 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("should start the transactions on db after closing", func(t *testing.T) {
+		db := openDB(t)
+		defer func() { _ = db.Close() }()
+
+		pool := NewWarmPool(db, WarmPoolOptions{Size: 2})
+		waitFor(t, "the pool to fill", func() bool { return len(pool.conns) == 2 })
+
+		err := pool.Close()
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if inUse := db.Stats().InUse; inUse != 0 {
+			t.Errorf("expected the warm connections to be returned, got %d in use", inUse)
+		}
+
+		err = Run(ctx, pool, func(tx *Tx) error { return nil })
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		stats := pool.Stats()
+		if stats.WarmBegins != 0 || stats.ColdBegins != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("should return the connections to db when the transactions end", func(t *testing.T) {
+		db := openDB(t)
+		defer func() { _ = db.Close() }()
+
+		pool := NewWarmPool(db, WarmPoolOptions{Size: 1})
+		waitFor(t, "the pool to fill", func() bool { return len(pool.conns) == 1 })
+
+		err := Run(ctx, pool, func(tx *Tx) error { return nil })
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		waitFor(t, "the pool to refill", func() bool { return len(pool.conns) == 1 })
+		err = pool.Close()
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		waitFor(t, "the connections to be returned", func() bool { return db.Stats().InUse == 0 })
+	})
+}